@@ -0,0 +1,40 @@
+package simba
+
+type AccessMask uint32
+
+const (
+	// MS-SMB2 2.2.13.1.1 File_Pipe_Printer_Access_Mask
+	FILE_READ_DATA         AccessMask = 0x00000001
+	FILE_WRITE_DATA        AccessMask = 0x00000002
+	FILE_APPEND_DATA       AccessMask = 0x00000004
+	FILE_READ_EA           AccessMask = 0x00000008
+	FILE_WRITE_EA          AccessMask = 0x00000010
+	FILE_EXECUTE           AccessMask = 0x00000020
+	FILE_DELETE_CHILD      AccessMask = 0x00000040
+	FILE_READ_ATTRIBUTES   AccessMask = 0x00000080
+	FILE_WRITE_ATTRIBUTES  AccessMask = 0x00000100
+	DELETE                 AccessMask = 0x00010000
+	READ_CONTROL           AccessMask = 0x00020000
+	WRITE_DAC              AccessMask = 0x00040000
+	WRITE_OWNER            AccessMask = 0x00080000
+	SYNCHRONIZE            AccessMask = 0x00100000
+	ACCESS_SYSTEM_SECURITY AccessMask = 0x01000000
+	MAXIMUM_ALLOWED        AccessMask = 0x02000000
+	GENERIC_ALL            AccessMask = 0x10000000
+	GENERIC_EXECUTE        AccessMask = 0x20000000
+	GENERIC_WRITE          AccessMask = 0x40000000
+	GENERIC_READ           AccessMask = 0x80000000
+)
+
+const (
+	// The generic rights mapped to the rights of files.
+	// MS-SMB2 3.3.5.9 Receiving an SMB2 CREATE Request
+	FILE_GENERIC_READ    = READ_CONTROL | SYNCHRONIZE | FILE_READ_DATA | FILE_READ_ATTRIBUTES | FILE_READ_EA
+	FILE_GENERIC_WRITE   = READ_CONTROL | SYNCHRONIZE | FILE_WRITE_DATA | FILE_WRITE_ATTRIBUTES | FILE_WRITE_EA | FILE_APPEND_DATA
+	FILE_GENERIC_EXECUTE = READ_CONTROL | SYNCHRONIZE | FILE_READ_ATTRIBUTES | FILE_EXECUTE
+	FILE_ALL_ACCESS      = DELETE | READ_CONTROL | WRITE_DAC | WRITE_OWNER | SYNCHRONIZE | 0x1ff
+
+	// validAccess are the rights an open may ask for.
+	validAccess = FILE_ALL_ACCESS | ACCESS_SYSTEM_SECURITY | MAXIMUM_ALLOWED |
+		GENERIC_ALL | GENERIC_EXECUTE | GENERIC_WRITE | GENERIC_READ
+)
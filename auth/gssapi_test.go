@@ -1,144 +1,215 @@
-package auth
-
-import (
-	encoding_asn1 "encoding/asn1"
-	"encoding/hex"
-	"testing"
-)
-
-func TestGSSAPIDecode(t *testing.T) {
-	cases := []struct {
-		name     string
-		input    []byte
-		expected InitPayload
-	}{
-		{
-			name: "test",
-			input: func() []byte {
-				r, _ := hex.DecodeString("604806062b0601050502a03e303ca00e300c060a2b06010401823702020aa22a04284e544c4d5353500001000000158288e2000000000000000000000000000000000a0000000000000f")
-				return r
-			}(),
-			expected: InitPayload{
-				OID: encoding_asn1.ObjectIdentifier([]int{1, 3, 6, 1, 5, 5, 2}),
-				Token: NegotiationToken{
-					NegTokenInit: NegTokenInitData{
-						MechTypes: MechTypeList{
-							MechType(encoding_asn1.ObjectIdentifier([]int{1, 3, 6, 1, 4, 1, 311, 2, 2, 10})),
-						},
-						ReqFlags: encoding_asn1.BitString{Bytes: []byte{}, BitLength: 0},
-						MechToken: []byte{
-							0x4e, 0x54, 0x4c, 0x4d, 0x53, 0x53, 0x50, 0x00, 0x01, 0x00, 0x00, 0x00, 0x15, 0x82, 0x88, 0xe2, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 0x0a, 00, 00, 00, 00, 00, 00, 0x0f,
-						},
-					},
-				},
-			},
-		},
-		{
-			name: "response from samba",
-			input: func() []byte {
-				r, _ := hex.DecodeString("604806062b0601050502a03e303ca00e300c060a2b06010401823702020aa32a3028a0261b246e6f745f646566696e65645f696e5f5246433431373840706c656173655f69676e6f7265")
-				return r
-			}(),
-			expected: InitPayload{
-				OID: encoding_asn1.ObjectIdentifier([]int{1, 3, 6, 1, 5, 5, 2}),
-				Token: NegotiationToken{
-					NegTokenInit: NegTokenInitData{
-						MechTypes: MechTypeList{
-							MechType(encoding_asn1.ObjectIdentifier([]int{1, 3, 6, 1, 4, 1, 311, 2, 2, 10})),
-						},
-						ReqFlags:  encoding_asn1.BitString{Bytes: []byte{}, BitLength: 0},
-						NegHints:  []byte("not_defined_in_RFC4178@please_ignore"),
-						MechToken: []byte{},
-					},
-				},
-			},
-		},
-	}
-
-	for _, c := range cases {
-		t.Run(c.name, func(t *testing.T) {
-			// var actual InitPayload
-			actual, err := NewInitPayload(c.input)
-			if err != nil {
-				t.Errorf("NewInitPayload() = %v, want %v", err, nil)
-				return
-			}
-			if actual.OID.String() != c.expected.OID.String() {
-				t.Errorf("NewInitPayload().OID = %v, want %v", actual.OID, c.expected.OID)
-			}
-
-			if actual.Token.NegTokenInit.ReqFlags.BitLength != c.expected.Token.NegTokenInit.ReqFlags.BitLength ||
-				hex.EncodeToString(actual.Token.NegTokenInit.ReqFlags.Bytes) != hex.EncodeToString(c.expected.Token.NegTokenInit.ReqFlags.Bytes) {
-				t.Errorf("NewInitPayload().Token.NegTokenInit.ReqFlags = %v, want %v", actual.Token.NegTokenInit.ReqFlags, c.expected.Token.NegTokenInit.ReqFlags)
-			}
-
-			if hex.EncodeToString(actual.Token.NegTokenInit.MechToken) != hex.EncodeToString(c.expected.Token.NegTokenInit.MechToken) {
-				t.Errorf("NewInitPayload().Token.NegTokenInit.MechToken = %v, want %v", actual.Token.NegTokenInit.MechToken, c.expected.Token.NegTokenInit.MechToken)
-			}
-
-			if len(actual.Token.NegTokenInit.MechTypes) != len(c.expected.Token.NegTokenInit.MechTypes) {
-				t.Errorf("NewInitPayload().Token.NegTokenInit.MechTypes = %v, want %v", actual.Token.NegTokenInit.MechTypes, c.expected.Token.NegTokenInit.MechTypes)
-			}
-
-			for i, mechType := range actual.Token.NegTokenInit.MechTypes {
-				if encoding_asn1.ObjectIdentifier(mechType).String() != encoding_asn1.ObjectIdentifier(c.expected.Token.NegTokenInit.MechTypes[i]).String() {
-					t.Errorf("NewInitPayload().Token.NegTokenInit.MechTypes[%v] = %v, want %v", i, mechType, c.expected.Token.NegTokenInit.MechTypes[i])
-				}
-			}
-
-			if hex.EncodeToString(actual.Token.NegTokenInit.MechListMIC) != hex.EncodeToString(c.expected.Token.NegTokenInit.MechListMIC) {
-				t.Errorf("NewInitPayload().Token.NegTokenInit.MechListMIC = %v, want %v", actual.Token.NegTokenInit.MechListMIC, c.expected.Token.NegTokenInit.MechListMIC)
-			}
-
-			if hex.EncodeToString(actual.Token.NegTokenInit.NegHints) != hex.EncodeToString(c.expected.Token.NegTokenInit.NegHints) {
-				t.Errorf("NewInitPayload().Token.NegTokenInit.NegHints = %v, want %v", actual.Token.NegTokenInit.NegHints, c.expected.Token.NegTokenInit.NegHints)
-			}
-
-			// TODO
-		})
-	}
-
-}
-
-func TestGSSAPIEncode(t *testing.T) {
-	cases := []struct {
-		name     string
-		input    InitPayload
-		expected []byte
-	}{
-		{
-			name: "basic",
-			input: InitPayload{
-				OID: encoding_asn1.ObjectIdentifier([]int{1, 3, 6, 1, 5, 5, 2}),
-				Token: NegotiationToken{
-					NegTokenInit: NegTokenInitData{
-						MechTypes: MechTypeList{
-							MechType(encoding_asn1.ObjectIdentifier([]int{1, 3, 6, 1, 4, 1, 311, 2, 2, 10})),
-						},
-						ReqFlags:  encoding_asn1.BitString{Bytes: []byte{}, BitLength: 0},
-						NegHints:  []byte("not_defined_in_RFC4178@please_ignore"),
-						MechToken: []byte{},
-					},
-				},
-			},
-			expected: func() []byte {
-				r, _ := hex.DecodeString("604806062b0601050502a03e303ca00e300c060a2b06010401823702020aa32a3028a0261b246e6f745f646566696e65645f696e5f5246433431373840706c656173655f69676e6f7265")
-				return r
-			}(),
-		},
-	}
-
-	for _, c := range cases {
-		t.Run(c.name, func(t *testing.T) {
-			actual, err := c.input.Bytes()
-			if err != nil {
-				t.Errorf("Encode() = %v, want %v", err, nil)
-				return
-			}
-			if hex.EncodeToString(actual) != hex.EncodeToString(c.expected) {
-				t.Errorf("Encode() = %0x, want %0x", actual, c.expected)
-			}
-		})
-	}
-
-}
+package auth
+
+import (
+	encoding_asn1 "encoding/asn1"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestGSSAPIDecode(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    []byte
+		expected InitPayload
+	}{
+		{
+			name: "test",
+			input: func() []byte {
+				r, _ := hex.DecodeString("604806062b0601050502a03e303ca00e300c060a2b06010401823702020aa22a04284e544c4d5353500001000000158288e2000000000000000000000000000000000a0000000000000f")
+				return r
+			}(),
+			expected: InitPayload{
+				OID: encoding_asn1.ObjectIdentifier([]int{1, 3, 6, 1, 5, 5, 2}),
+				Token: NegotiationToken{
+					NegTokenInit: NegTokenInitData{
+						MechTypes: MechTypeList{
+							MechType(encoding_asn1.ObjectIdentifier([]int{1, 3, 6, 1, 4, 1, 311, 2, 2, 10})),
+						},
+						ReqFlags: encoding_asn1.BitString{Bytes: []byte{}, BitLength: 0},
+						MechToken: []byte{
+							0x4e, 0x54, 0x4c, 0x4d, 0x53, 0x53, 0x50, 0x00, 0x01, 0x00, 0x00, 0x00, 0x15, 0x82, 0x88, 0xe2, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 00, 0x0a, 00, 00, 00, 00, 00, 00, 0x0f,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "response from samba",
+			input: func() []byte {
+				r, _ := hex.DecodeString("604806062b0601050502a03e303ca00e300c060a2b06010401823702020aa32a3028a0261b246e6f745f646566696e65645f696e5f5246433431373840706c656173655f69676e6f7265")
+				return r
+			}(),
+			expected: InitPayload{
+				OID: encoding_asn1.ObjectIdentifier([]int{1, 3, 6, 1, 5, 5, 2}),
+				Token: NegotiationToken{
+					NegTokenInit: NegTokenInitData{
+						MechTypes: MechTypeList{
+							MechType(encoding_asn1.ObjectIdentifier([]int{1, 3, 6, 1, 4, 1, 311, 2, 2, 10})),
+						},
+						ReqFlags:  encoding_asn1.BitString{Bytes: []byte{}, BitLength: 0},
+						NegHints:  []byte("not_defined_in_RFC4178@please_ignore"),
+						MechToken: []byte{},
+					},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// var actual InitPayload
+			actual, err := NewInitPayload(c.input)
+			if err != nil {
+				t.Errorf("NewInitPayload() = %v, want %v", err, nil)
+				return
+			}
+			if actual.OID.String() != c.expected.OID.String() {
+				t.Errorf("NewInitPayload().OID = %v, want %v", actual.OID, c.expected.OID)
+			}
+
+			if actual.Token.NegTokenInit.ReqFlags.BitLength != c.expected.Token.NegTokenInit.ReqFlags.BitLength ||
+				hex.EncodeToString(actual.Token.NegTokenInit.ReqFlags.Bytes) != hex.EncodeToString(c.expected.Token.NegTokenInit.ReqFlags.Bytes) {
+				t.Errorf("NewInitPayload().Token.NegTokenInit.ReqFlags = %v, want %v", actual.Token.NegTokenInit.ReqFlags, c.expected.Token.NegTokenInit.ReqFlags)
+			}
+
+			if hex.EncodeToString(actual.Token.NegTokenInit.MechToken) != hex.EncodeToString(c.expected.Token.NegTokenInit.MechToken) {
+				t.Errorf("NewInitPayload().Token.NegTokenInit.MechToken = %v, want %v", actual.Token.NegTokenInit.MechToken, c.expected.Token.NegTokenInit.MechToken)
+			}
+
+			if len(actual.Token.NegTokenInit.MechTypes) != len(c.expected.Token.NegTokenInit.MechTypes) {
+				t.Errorf("NewInitPayload().Token.NegTokenInit.MechTypes = %v, want %v", actual.Token.NegTokenInit.MechTypes, c.expected.Token.NegTokenInit.MechTypes)
+			}
+
+			for i, mechType := range actual.Token.NegTokenInit.MechTypes {
+				if encoding_asn1.ObjectIdentifier(mechType).String() != encoding_asn1.ObjectIdentifier(c.expected.Token.NegTokenInit.MechTypes[i]).String() {
+					t.Errorf("NewInitPayload().Token.NegTokenInit.MechTypes[%v] = %v, want %v", i, mechType, c.expected.Token.NegTokenInit.MechTypes[i])
+				}
+			}
+
+			if hex.EncodeToString(actual.Token.NegTokenInit.MechListMIC) != hex.EncodeToString(c.expected.Token.NegTokenInit.MechListMIC) {
+				t.Errorf("NewInitPayload().Token.NegTokenInit.MechListMIC = %v, want %v", actual.Token.NegTokenInit.MechListMIC, c.expected.Token.NegTokenInit.MechListMIC)
+			}
+
+			if hex.EncodeToString(actual.Token.NegTokenInit.NegHints) != hex.EncodeToString(c.expected.Token.NegTokenInit.NegHints) {
+				t.Errorf("NewInitPayload().Token.NegTokenInit.NegHints = %v, want %v", actual.Token.NegTokenInit.NegHints, c.expected.Token.NegTokenInit.NegHints)
+			}
+
+			// TODO
+		})
+	}
+
+}
+
+func TestGSSAPIEncode(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    InitPayload
+		expected []byte
+	}{
+		{
+			name: "basic",
+			input: InitPayload{
+				OID: encoding_asn1.ObjectIdentifier([]int{1, 3, 6, 1, 5, 5, 2}),
+				Token: NegotiationToken{
+					NegTokenInit: NegTokenInitData{
+						MechTypes: MechTypeList{
+							MechType(encoding_asn1.ObjectIdentifier([]int{1, 3, 6, 1, 4, 1, 311, 2, 2, 10})),
+						},
+						ReqFlags:  encoding_asn1.BitString{Bytes: []byte{}, BitLength: 0},
+						NegHints:  []byte("not_defined_in_RFC4178@please_ignore"),
+						MechToken: []byte{},
+					},
+				},
+			},
+			expected: func() []byte {
+				r, _ := hex.DecodeString("604806062b0601050502a03e303ca00e300c060a2b06010401823702020aa32a3028a0261b246e6f745f646566696e65645f696e5f5246433431373840706c656173655f69676e6f7265")
+				return r
+			}(),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := c.input.Bytes()
+			if err != nil {
+				t.Errorf("Encode() = %v, want %v", err, nil)
+				return
+			}
+			if hex.EncodeToString(actual) != hex.EncodeToString(c.expected) {
+				t.Errorf("Encode() = %0x, want %0x", actual, c.expected)
+			}
+		})
+	}
+
+}
+
+func TestMechTypeListBytes(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    MechTypeList
+		expected string
+	}{
+		{"ntlm", MechTypeList{NlmpMechType}, "300c060a2b06010401823702020a"},
+		{
+			"kerberos and ntlm",
+			MechTypeList{MechType{1, 2, 840, 48018, 1, 2, 2}, NlmpMechType},
+			"301706092a864882f712010202060a2b06010401823702020a",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := c.input.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(actual) != c.expected {
+				t.Errorf("Bytes() = %x, want %v", actual, c.expected)
+			}
+		})
+	}
+}
+
+func TestNewNegTokenInit2(t *testing.T) {
+	cases := []struct {
+		name      string
+		mechTypes MechTypeList
+		expected  string
+	}{
+		{
+			"ntlm",
+			MechTypeList{NlmpMechType},
+			// as sent by samba
+			"604806062b0601050502a03e303ca00e300c060a2b06010401823702020aa32a3028a0261b246e6f745f646566696e65645f696e5f5246433431373840706c656173655f69676e6f7265",
+		},
+		{
+			"kerberos and ntlm",
+			MechTypeList{MSKerberosMechType, KerberosMechType, NlmpMechType},
+			"605e06062b0601050502a0543052a024302206092a864882f71201020206092a864886f712010202060a2b06010401823702020a" +
+				"a32a3028a0261b246e6f745f646566696e65645f696e5f5246433431373840706c656173655f69676e6f7265",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := NewNegTokenInit2(c.mechTypes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := NewInitPayload(actual)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(parsed.Token.NegTokenInit.MechTypes, c.mechTypes) {
+				t.Errorf("MechTypes = %v, want %v", parsed.Token.NegTokenInit.MechTypes, c.mechTypes)
+			}
+			if string(parsed.Token.NegTokenInit.NegHints) != NegHintsIgnore {
+				t.Errorf("NegHints = %q, want %q", parsed.Token.NegTokenInit.NegHints, NegHintsIgnore)
+			}
+			if hex.EncodeToString(actual) != c.expected {
+				t.Errorf("NewNegTokenInit2() = %x, want %v", actual, c.expected)
+			}
+		})
+	}
+}
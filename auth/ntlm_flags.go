@@ -1,36 +1,36 @@
-package auth
-
-const (
-	NTLMSSP_NEGOTIATE_UNICODE uint32 = 1 << iota
-	NTLMSSP_NEGOTIATE_OEM
-	NTLMSSP_REQUEST_TARGET
-	r10
-	NTLMSSP_NEGOTIATE_SIGN
-	NTLMSSP_NEGOTIATE_SEAL
-	NTLMSSP_NEGOTIATE_DATAGRAM
-	NTLMSSP_NEGOTIATE_LM_KEY
-	r9
-	NTLMSSP_NEGOTIATE_NTLM
-	r8
-	NTLMSSP_NEGOTIATE_ANONYMOUS
-	NTLMSSP_NEGOTIATE_OEM_DOMAIN_SUPPLIED
-	NTLMSSP_NEGOTIATE_OEM_WORKSTATION_SUPPLIED
-	r7
-	NTLMSSP_NEGOTIATE_ALWAYS_SIGN
-	NTLMSSP_TARGET_TYPE_DOMAIN
-	NTLMSSP_TARGET_TYPE_SERVER
-	r6
-	NTLMSSP_NEGOTIATE_EXTENDED_SESSIONSECURITY
-	NTLMSSP_NEGOTIATE_IDENTIFY
-	r5
-	NTLMSSP_REQUEST_NON_NT_SESSION_KEY
-	NTLMSSP_NEGOTIATE_TARGET_INFO
-	r4
-	NTLMSSP_NEGOTIATE_VERSION
-	r3
-	r2
-	r1
-	NTLMSSP_NEGOTIATE_128
-	NTLMSSP_NEGOTIATE_KEY_EXCH
-	NTLMSSP_NEGOTIATE_56
-)
+package auth
+
+const (
+	NTLMSSP_NEGOTIATE_UNICODE uint32 = 1 << iota
+	NTLMSSP_NEGOTIATE_OEM
+	NTLMSSP_REQUEST_TARGET
+	r10
+	NTLMSSP_NEGOTIATE_SIGN
+	NTLMSSP_NEGOTIATE_SEAL
+	NTLMSSP_NEGOTIATE_DATAGRAM
+	NTLMSSP_NEGOTIATE_LM_KEY
+	r9
+	NTLMSSP_NEGOTIATE_NTLM
+	r8
+	NTLMSSP_NEGOTIATE_ANONYMOUS
+	NTLMSSP_NEGOTIATE_OEM_DOMAIN_SUPPLIED
+	NTLMSSP_NEGOTIATE_OEM_WORKSTATION_SUPPLIED
+	r7
+	NTLMSSP_NEGOTIATE_ALWAYS_SIGN
+	NTLMSSP_TARGET_TYPE_DOMAIN
+	NTLMSSP_TARGET_TYPE_SERVER
+	r6
+	NTLMSSP_NEGOTIATE_EXTENDED_SESSIONSECURITY
+	NTLMSSP_NEGOTIATE_IDENTIFY
+	r5
+	NTLMSSP_REQUEST_NON_NT_SESSION_KEY
+	NTLMSSP_NEGOTIATE_TARGET_INFO
+	r4
+	NTLMSSP_NEGOTIATE_VERSION
+	r3
+	r2
+	r1
+	NTLMSSP_NEGOTIATE_128
+	NTLMSSP_NEGOTIATE_KEY_EXCH
+	NTLMSSP_NEGOTIATE_56
+)
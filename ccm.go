@@ -0,0 +1,175 @@
+package simba
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+var errCCMOpen = errors.New("ccm: message authentication failed")
+
+// ccm implements AES-CCM as a cipher.AEAD, crypto/cipher only provides GCM.
+// SMB 3.x uses 11 bytes nonces and 16 bytes tags.
+// RFC 3610 Counter with CBC-MAC (CCM)
+type ccm struct {
+	b         cipher.Block
+	nonceSize int
+	tagSize   int
+}
+
+// newCCM returns CCM with the given nonce and tag sizes, nonceSize must be
+// within 7..13 and tagSize even within 4..16.
+func newCCM(b cipher.Block, nonceSize, tagSize int) (cipher.AEAD, error) {
+	if b.BlockSize() != 16 {
+		return nil, errors.New("ccm: block size must be 16 bytes")
+	}
+	if nonceSize < 7 || nonceSize > 13 {
+		return nil, errors.New("ccm: invalid nonce size")
+	}
+	if tagSize < 4 || tagSize > 16 || tagSize%2 != 0 {
+		return nil, errors.New("ccm: invalid tag size")
+	}
+	return &ccm{b: b, nonceSize: nonceSize, tagSize: tagSize}, nil
+}
+
+func (c *ccm) NonceSize() int { return c.nonceSize }
+
+func (c *ccm) Overhead() int { return c.tagSize }
+
+// maxLength is the largest message the L bytes length field can express.
+func (c *ccm) maxLength() uint64 {
+	l := 15 - c.nonceSize
+	if l >= 8 {
+		return 1<<64 - 1
+	}
+	return 1<<(8*uint(l)) - 1
+}
+
+// counter returns the counter block A_i.
+func (c *ccm) counter(nonce []byte, i uint64) []byte {
+	a := make([]byte, 16)
+	a[0] = byte(15 - c.nonceSize - 1)
+	copy(a[1:], nonce)
+	for j := 15; j > c.nonceSize; j-- {
+		a[j] = byte(i)
+		i >>= 8
+	}
+	return a
+}
+
+// mac returns the CBC-MAC T of plaintext and additionalData.
+func (c *ccm) mac(nonce, plaintext, additionalData []byte) []byte {
+	x := make([]byte, 16)
+	x[0] = byte(8*((c.tagSize-2)/2) + (15 - c.nonceSize - 1))
+	if len(additionalData) > 0 {
+		x[0] |= 0x40
+	}
+	copy(x[1:], nonce)
+	n := uint64(len(plaintext))
+	for j := 15; j > c.nonceSize; j-- {
+		x[j] = byte(n)
+		n >>= 8
+	}
+	c.b.Encrypt(x, x)
+
+	if len(additionalData) > 0 {
+		var a []byte
+		switch n := uint64(len(additionalData)); {
+		case n < 1<<16-1<<8:
+			a = []byte{byte(n >> 8), byte(n)}
+		case n < 1<<32:
+			a = []byte{0xff, 0xfe, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+		default:
+			a = []byte{0xff, 0xff, byte(n >> 56), byte(n >> 48), byte(n >> 40), byte(n >> 32),
+				byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+		}
+		c.cbc(x, append(a, additionalData...))
+	}
+	c.cbc(x, plaintext)
+	return x
+}
+
+// cbc continues the CBC-MAC x over msg zero padded to the block size.
+func (c *ccm) cbc(x, msg []byte) {
+	for len(msg) > 0 {
+		n := len(msg)
+		if n > 16 {
+			n = 16
+		}
+		xorBytes(x[:n], msg[:n])
+		c.b.Encrypt(x, x)
+		msg = msg[n:]
+	}
+}
+
+// ctr xors src with the key stream starting at counter block A_1.
+func (c *ccm) ctr(dst, src, nonce []byte) {
+	s := make([]byte, 16)
+	for i := uint64(1); len(src) > 0; i++ {
+		c.b.Encrypt(s, c.counter(nonce, i))
+		n := len(src)
+		if n > 16 {
+			n = 16
+		}
+		for j := 0; j < n; j++ {
+			dst[j] = src[j] ^ s[j]
+		}
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+func (c *ccm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != c.nonceSize {
+		panic("ccm: incorrect nonce length")
+	}
+	if uint64(len(plaintext)) > c.maxLength() {
+		panic("ccm: message too large")
+	}
+	t := c.mac(nonce, plaintext, additionalData)
+	s0 := make([]byte, 16)
+	c.b.Encrypt(s0, c.counter(nonce, 0))
+	xorBytes(t, s0)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+c.tagSize)
+	c.ctr(out, plaintext, nonce)
+	copy(out[len(plaintext):], t[:c.tagSize])
+	return ret
+}
+
+func (c *ccm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != c.nonceSize {
+		panic("ccm: incorrect nonce length")
+	}
+	if len(ciphertext) < c.tagSize || uint64(len(ciphertext)-c.tagSize) > c.maxLength() {
+		return nil, errCCMOpen
+	}
+	tag := ciphertext[len(ciphertext)-c.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-c.tagSize]
+
+	plaintext := make([]byte, len(ciphertext))
+	c.ctr(plaintext, ciphertext, nonce)
+	t := c.mac(nonce, plaintext, additionalData)
+	s0 := make([]byte, 16)
+	c.b.Encrypt(s0, c.counter(nonce, 0))
+	xorBytes(t, s0)
+	if subtle.ConstantTimeCompare(t[:c.tagSize], tag) != 1 {
+		return nil, errCCMOpen
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext))
+	copy(out, plaintext)
+	return ret, nil
+}
+
+// sliceForAppend extends in by n bytes, reusing its capacity if possible,
+// and returns the whole slice and the extension.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
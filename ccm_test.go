@@ -0,0 +1,75 @@
+package simba
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestAESCCM(t *testing.T) {
+	cases := []struct {
+		name      string
+		key       string
+		nonce     string
+		aad       string
+		plaintext string
+		tagSize   int
+		expected  string
+	}{
+		{
+			// NIST SP 800-38C C.1 Example 1
+			name:      "sp800-38c example 1",
+			key:       "404142434445464748494a4b4c4d4e4f",
+			nonce:     "10111213141516",
+			aad:       "0001020304050607",
+			plaintext: "20212223",
+			tagSize:   4,
+			expected:  "7162015b4dac255d",
+		},
+		{
+			// RFC 3610 8. Test Vectors, Packet Vector #1
+			name:      "rfc3610 packet vector 1",
+			key:       "c0c1c2c3c4c5c6c7c8c9cacbcccdcecf",
+			nonce:     "00000003020100a0a1a2a3a4a5",
+			aad:       "0001020304050607",
+			plaintext: "08090a0b0c0d0e0f101112131415161718191a1b1c1d1e",
+			tagSize:   8,
+			expected:  "588c979a61c663d2f066d0c2c0f989806d5f6b61dac38417e8d12cfdf926e0",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			key, _ := hex.DecodeString(c.key)
+			nonce, _ := hex.DecodeString(c.nonce)
+			aad, _ := hex.DecodeString(c.aad)
+			plaintext, _ := hex.DecodeString(c.plaintext)
+
+			b, err := aes.NewCipher(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			aead, err := newCCM(b, len(nonce), c.tagSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := aead.Seal(nil, nonce, plaintext, aad)
+			if hex.EncodeToString(actual) != c.expected {
+				t.Errorf("Seal() = %x, want %v", actual, c.expected)
+			}
+
+			opened, err := aead.Open(nil, nonce, actual, aad)
+			if err != nil {
+				t.Fatalf("Open() error: %v", err)
+			}
+			if hex.EncodeToString(opened) != c.plaintext {
+				t.Errorf("Open() = %x, want %v", opened, c.plaintext)
+			}
+
+			actual[0] ^= 0x01
+			if _, err := aead.Open(nil, nonce, actual, aad); err == nil {
+				t.Errorf("Open() of a modified ciphertext succeeded")
+			}
+		})
+	}
+}
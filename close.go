@@ -0,0 +1,156 @@
+package simba
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"time"
+)
+
+type CloseFlags uint16
+
+const (
+	// MS-SMB2 2.2.15 SMB2 CLOSE Request
+	SMB2_CLOSE_FLAG_POSTQUERY_ATTRIB CloseFlags = 0x0001
+)
+
+// MS-SMB2 2.2.15 SMB2 CLOSE Request
+type CloseRequest []byte
+
+func (p CloseRequest) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 24
+	if len(p) < 24 {
+		return true
+	}
+
+	return false
+}
+
+func (p CloseRequest) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p CloseRequest) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 24)
+}
+
+func (p CloseRequest) Flags() CloseFlags {
+	return CloseFlags(binary.LittleEndian.Uint16(p[2:4]))
+}
+
+func (p CloseRequest) SetFlags(v CloseFlags) {
+	binary.LittleEndian.PutUint16(p[2:4], uint16(v))
+}
+
+func (p CloseRequest) FileId() FileId {
+	return getFileId(p[8:24])
+}
+
+func (p CloseRequest) SetFileId(v FileId) {
+	putFileId(p[8:24], v)
+}
+
+// MS-SMB2 2.2.16 SMB2 CLOSE Response
+type CloseResponse []byte
+
+func (p CloseResponse) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 60
+	if len(p) < 60 {
+		return true
+	}
+
+	return false
+}
+
+func (p CloseResponse) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p CloseResponse) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 60)
+}
+
+func (p CloseResponse) Flags() CloseFlags {
+	return CloseFlags(binary.LittleEndian.Uint16(p[2:4]))
+}
+
+func (p CloseResponse) SetFlags(v CloseFlags) {
+	binary.LittleEndian.PutUint16(p[2:4], uint16(v))
+}
+
+func (p CloseResponse) CreationTime() time.Time {
+	return getFiletime(p[8:16])
+}
+
+func (p CloseResponse) SetCreationTime(v time.Time) {
+	putFiletime(p[8:16], v)
+}
+
+func (p CloseResponse) LastAccessTime() time.Time {
+	return getFiletime(p[16:24])
+}
+
+func (p CloseResponse) SetLastAccessTime(v time.Time) {
+	putFiletime(p[16:24], v)
+}
+
+func (p CloseResponse) LastWriteTime() time.Time {
+	return getFiletime(p[24:32])
+}
+
+func (p CloseResponse) SetLastWriteTime(v time.Time) {
+	putFiletime(p[24:32], v)
+}
+
+func (p CloseResponse) ChangeTime() time.Time {
+	return getFiletime(p[32:40])
+}
+
+func (p CloseResponse) SetChangeTime(v time.Time) {
+	putFiletime(p[32:40], v)
+}
+
+func (p CloseResponse) AllocationSize() int64 {
+	return int64(binary.LittleEndian.Uint64(p[40:48]))
+}
+
+func (p CloseResponse) SetAllocationSize(v int64) {
+	binary.LittleEndian.PutUint64(p[40:48], uint64(v))
+}
+
+func (p CloseResponse) EndOfFile() int64 {
+	return int64(binary.LittleEndian.Uint64(p[48:56]))
+}
+
+func (p CloseResponse) SetEndOfFile(v int64) {
+	binary.LittleEndian.PutUint64(p[48:56], uint64(v))
+}
+
+func (p CloseResponse) FileAttributes() FileAttributes {
+	return FileAttributes(binary.LittleEndian.Uint32(p[56:60]))
+}
+
+func (p CloseResponse) SetFileAttributes(v FileAttributes) {
+	binary.LittleEndian.PutUint32(p[56:60], uint32(v))
+}
+
+// NewCloseResponse returns a CLOSE response, with the attributes of the
+// file described by fi unless it is nil.
+func NewCloseResponse(fi fs.FileInfo) CloseResponse {
+	p := CloseResponse(make([]byte, 60))
+	p.SetStructureSize()
+	if fi == nil {
+		return p
+	}
+	p.SetFlags(SMB2_CLOSE_FLAG_POSTQUERY_ATTRIB)
+	creation, access, write, change := fileTimes(fi)
+	p.SetCreationTime(creation)
+	p.SetLastAccessTime(access)
+	p.SetLastWriteTime(write)
+	p.SetChangeTime(change)
+	p.SetAllocationSize(allocationSize(fi))
+	if !fi.IsDir() {
+		p.SetEndOfFile(fi.Size())
+	}
+	p.SetFileAttributes(fileAttributes(fi))
+	return p
+}
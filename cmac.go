@@ -0,0 +1,68 @@
+package simba
+
+import (
+	"crypto/cipher"
+)
+
+// aesCMAC computes the AES-CMAC of msg with the block cipher b.
+// RFC 4493 2.4 MAC Generation Algorithm
+func aesCMAC(b cipher.Block, msg []byte) []byte {
+	const bs = 16
+	k1, k2 := cmacSubkeys(b)
+
+	n := (len(msg) + bs - 1) / bs
+	complete := n > 0 && len(msg)%bs == 0
+	if n == 0 {
+		n = 1
+	}
+
+	last := make([]byte, bs)
+	if complete {
+		copy(last, msg[(n-1)*bs:])
+		xorBytes(last, k1)
+	} else {
+		rest := msg[(n-1)*bs:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		xorBytes(last, k2)
+	}
+
+	x := make([]byte, bs)
+	for i := 0; i < n-1; i++ {
+		xorBytes(x, msg[i*bs:(i+1)*bs])
+		b.Encrypt(x, x)
+	}
+	xorBytes(x, last)
+	b.Encrypt(x, x)
+	return x
+}
+
+// cmacSubkeys returns K1 and K2.
+// RFC 4493 2.3 Subkey Generation Algorithm
+func cmacSubkeys(b cipher.Block) ([]byte, []byte) {
+	l := make([]byte, 16)
+	b.Encrypt(l, l)
+	k1 := cmacShift(l)
+	k2 := cmacShift(k1)
+	return k1, k2
+}
+
+func cmacShift(in []byte) []byte {
+	const rb = 0x87
+	out := make([]byte, len(in))
+	var carry byte
+	for i := len(in) - 1; i >= 0; i-- {
+		out[i] = in[i]<<1 | carry
+		carry = in[i] >> 7
+	}
+	if in[0]&0x80 != 0 {
+		out[len(out)-1] ^= rb
+	}
+	return out
+}
+
+func xorBytes(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
@@ -0,0 +1,37 @@
+package simba
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestAESCMAC(t *testing.T) {
+	// RFC 4493 4. Test Vectors
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	msg, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+
+	cases := []struct {
+		name     string
+		length   int
+		expected string
+	}{
+		{"example 1", 0, "bb1d6929e95937287fa37d129b756746"},
+		{"example 2", 16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{"example 3", 40, "dfa66747de9ae63030ca32611497c827"},
+		{"example 4", 64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := aesCMAC(b, msg[:c.length])
+			if hex.EncodeToString(actual) != c.expected {
+				t.Errorf("aesCMAC() = %x, want %v", actual, c.expected)
+			}
+		})
+	}
+}
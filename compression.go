@@ -1,30 +1,90 @@
-package simba
-
-type CompressionAlgorithm uint16
-
-const (
-	// MS-SMB2 - v20211006 page 49/481
-	SMB2_COMPRESSION_CAPABILITIES_NONE       CompressionAlgorithm = 0x0000
-	SMB2_COMPRESSION_CAPABILITIES_LZNT1      CompressionAlgorithm = 0x0001
-	SMB2_COMPRESSION_CAPABILITIES_LZ77       CompressionAlgorithm = 0x0002
-	SMB2_COMPRESSION_CAPABILITIES_LZ77_HUFF  CompressionAlgorithm = 0x0003
-	SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1 CompressionAlgorithm = 0x0004
-)
-
-type CompressionCapability []byte
-
-func (c CompressionCapability) CompressionAlgorithmCount() uint16 {
-	return uint16(le.Uint16(c[0:2]))
-}
-
-func (c CompressionCapability) SetCompressionAlgorithmCount(v uint16) {
-	le.PutUint16(c[0:2], v)
-}
-
-func (c CompressionCapability) CompressionAlgorithms() []CompressionAlgorithm {
-	var res []CompressionAlgorithm
-	for i := 0; i < int(c.CompressionAlgorithmCount()); i++ {
-		res = append(res, CompressionAlgorithm(le.Uint16(c[4+i*2:6+i*2])))
-	}
-	return res
-}
+package simba
+
+type CompressionAlgorithm uint16
+
+const (
+	// MS-SMB2 - v20211006 page 49/481
+	SMB2_COMPRESSION_CAPABILITIES_NONE       CompressionAlgorithm = 0x0000
+	SMB2_COMPRESSION_CAPABILITIES_LZNT1      CompressionAlgorithm = 0x0001
+	SMB2_COMPRESSION_CAPABILITIES_LZ77       CompressionAlgorithm = 0x0002
+	SMB2_COMPRESSION_CAPABILITIES_LZ77_HUFF  CompressionAlgorithm = 0x0003
+	SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1 CompressionAlgorithm = 0x0004
+)
+
+func (a CompressionAlgorithm) String() string {
+	switch a {
+	case SMB2_COMPRESSION_CAPABILITIES_NONE:
+		return "NONE"
+	case SMB2_COMPRESSION_CAPABILITIES_LZNT1:
+		return "LZNT1"
+	case SMB2_COMPRESSION_CAPABILITIES_LZ77:
+		return "LZ77"
+	case SMB2_COMPRESSION_CAPABILITIES_LZ77_HUFF:
+		return "LZ77+Huffman"
+	case SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1:
+		return "Pattern_V1"
+	}
+	return "Unknown"
+}
+
+// SMB2_READFLAG_REQUEST_COMPRESSED asks for a compressed READ response.
+// MS-SMB2 2.2.19 SMB2 READ Request
+const SMB2_READFLAG_REQUEST_COMPRESSED uint8 = 0x02
+
+// SMB2_COMPRESSION_CAPABILITIES Flags
+const (
+	SMB2_COMPRESSION_CAPABILITIES_FLAG_NONE    uint32 = 0x00000000
+	SMB2_COMPRESSION_CAPABILITIES_FLAG_CHAINED uint32 = 0x00000001
+)
+
+// MS-SMB2 2.2.3.1.3 SMB2_COMPRESSION_CAPABILITIES
+type CompressionCapability []byte
+
+func (c CompressionCapability) CompressionAlgorithmCount() uint16 {
+	return uint16(le.Uint16(c[0:2]))
+}
+
+func (c CompressionCapability) SetCompressionAlgorithmCount(v uint16) {
+	le.PutUint16(c[0:2], v)
+}
+
+func (c CompressionCapability) Flags() uint32 {
+	return le.Uint32(c[4:8])
+}
+
+func (c CompressionCapability) SetFlags(v uint32) {
+	le.PutUint32(c[4:8], v)
+}
+
+func (c CompressionCapability) CompressionAlgorithms() []CompressionAlgorithm {
+	var res []CompressionAlgorithm
+	for i := 0; i < int(c.CompressionAlgorithmCount()) && 10+i*2 <= len(c); i++ {
+		res = append(res, CompressionAlgorithm(le.Uint16(c[8+i*2:10+i*2])))
+	}
+	return res
+}
+
+func (c CompressionCapability) SetCompressionAlgorithms(v []CompressionAlgorithm) {
+	c.SetCompressionAlgorithmCount(uint16(len(v)))
+	for i, a := range v {
+		le.PutUint16(c[8+i*2:10+i*2], uint16(a))
+	}
+}
+
+// NewCompressionCapability returns the SMB2_COMPRESSION_CAPABILITIES data
+// listing algorithms.
+func NewCompressionCapability(algorithms []CompressionAlgorithm, flags uint32) CompressionCapability {
+	c := CompressionCapability(make([]byte, 8+2*len(algorithms)))
+	c.SetFlags(flags)
+	c.SetCompressionAlgorithms(algorithms)
+	return c
+}
+
+func containsCompressionAlgorithm(list []CompressionAlgorithm, v CompressionAlgorithm) bool {
+	for _, a := range list {
+		if a == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,363 @@
+package simba
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/PichuChen/simba/xca"
+)
+
+// MS-SMB2 2.2.42 SMB2 COMPRESSION_TRANSFORM_HEADER
+// The unchained form is followed by Offset bytes of uncompressed data and
+// the compressed data. The chained form shares the first 8 bytes and is
+// followed by SMB2_COMPRESSION_CHAINED_PAYLOAD_HEADERs.
+type CompressionTransformHeader []byte
+
+const compressionTransformHeaderSize = 16
+
+// SMB2_COMPRESSION_TRANSFORM_HEADER Flags
+const (
+	SMB2_COMPRESSION_FLAG_NONE    uint16 = 0x0000
+	SMB2_COMPRESSION_FLAG_CHAINED uint16 = 0x0001
+)
+
+func (p CompressionTransformHeader) IsInvalid() bool {
+	if len(p) < compressionTransformHeaderSize {
+		return true
+	}
+	if p[0] != 0xfc || p[1] != 'S' || p[2] != 'M' || p[3] != 'B' {
+		return true
+	}
+	return false
+}
+
+func (p CompressionTransformHeader) ProtocolId() []byte {
+	return p[:4]
+}
+
+func (p CompressionTransformHeader) SetProtocolId() {
+	copy(p[:4], []byte{0xfc, 0x53, 0x4d, 0x42})
+}
+
+func (p CompressionTransformHeader) OriginalCompressedSegmentSize() uint32 {
+	return binary.LittleEndian.Uint32(p[4:8])
+}
+
+func (p CompressionTransformHeader) SetOriginalCompressedSegmentSize(v uint32) {
+	binary.LittleEndian.PutUint32(p[4:8], v)
+}
+
+func (p CompressionTransformHeader) CompressionAlgorithm() CompressionAlgorithm {
+	return CompressionAlgorithm(binary.LittleEndian.Uint16(p[8:10]))
+}
+
+func (p CompressionTransformHeader) SetCompressionAlgorithm(v CompressionAlgorithm) {
+	binary.LittleEndian.PutUint16(p[8:10], uint16(v))
+}
+
+func (p CompressionTransformHeader) Flags() uint16 {
+	return binary.LittleEndian.Uint16(p[10:12])
+}
+
+func (p CompressionTransformHeader) SetFlags(v uint16) {
+	binary.LittleEndian.PutUint16(p[10:12], v)
+}
+
+// Offset is only in the unchained form.
+func (p CompressionTransformHeader) Offset() uint32 {
+	return binary.LittleEndian.Uint32(p[12:16])
+}
+
+func (p CompressionTransformHeader) SetOffset(v uint32) {
+	binary.LittleEndian.PutUint32(p[12:16], v)
+}
+
+// MS-SMB2 2.2.42.2.1 SMB2_COMPRESSION_CHAINED_PAYLOAD_HEADER
+type CompressionPayloadHeader []byte
+
+const compressionPayloadHeaderSize = 8
+
+func (p CompressionPayloadHeader) CompressionAlgorithm() CompressionAlgorithm {
+	return CompressionAlgorithm(binary.LittleEndian.Uint16(p[0:2]))
+}
+
+func (p CompressionPayloadHeader) SetCompressionAlgorithm(v CompressionAlgorithm) {
+	binary.LittleEndian.PutUint16(p[0:2], uint16(v))
+}
+
+func (p CompressionPayloadHeader) Flags() uint16 {
+	return binary.LittleEndian.Uint16(p[2:4])
+}
+
+func (p CompressionPayloadHeader) SetFlags(v uint16) {
+	binary.LittleEndian.PutUint16(p[2:4], v)
+}
+
+// Length counts the payload after the header, OriginalPayloadSize included.
+func (p CompressionPayloadHeader) Length() uint32 {
+	return binary.LittleEndian.Uint32(p[4:8])
+}
+
+func (p CompressionPayloadHeader) SetLength(v uint32) {
+	binary.LittleEndian.PutUint32(p[4:8], v)
+}
+
+// OriginalPayloadSize is only present for the LZ algorithms.
+func (p CompressionPayloadHeader) OriginalPayloadSize() uint32 {
+	return binary.LittleEndian.Uint32(p[8:12])
+}
+
+func (p CompressionPayloadHeader) SetOriginalPayloadSize(v uint32) {
+	binary.LittleEndian.PutUint32(p[8:12], v)
+}
+
+// MS-SMB2 2.2.42.2.2 SMB2_COMPRESSION_PATTERN_PAYLOAD_V1
+type PatternV1Payload []byte
+
+const patternV1PayloadSize = 8
+
+func (p PatternV1Payload) Pattern() uint8 {
+	return p[0]
+}
+
+func (p PatternV1Payload) SetPattern(v uint8) {
+	p[0] = v
+}
+
+func (p PatternV1Payload) Repetitions() uint32 {
+	return binary.LittleEndian.Uint32(p[4:8])
+}
+
+func (p PatternV1Payload) SetRepetitions(v uint32) {
+	binary.LittleEndian.PutUint32(p[4:8], v)
+}
+
+func compressPayload(a CompressionAlgorithm, data []byte) []byte {
+	switch a {
+	case SMB2_COMPRESSION_CAPABILITIES_LZNT1:
+		return xca.CompressLZNT1(data)
+	case SMB2_COMPRESSION_CAPABILITIES_LZ77:
+		return xca.CompressLZ77(data)
+	case SMB2_COMPRESSION_CAPABILITIES_LZ77_HUFF:
+		return xca.CompressLZ77Huffman(data)
+	}
+	return nil
+}
+
+func decompressPayload(a CompressionAlgorithm, data []byte, size int) ([]byte, error) {
+	switch a {
+	case SMB2_COMPRESSION_CAPABILITIES_LZNT1:
+		return xca.DecompressLZNT1(data, size)
+	case SMB2_COMPRESSION_CAPABILITIES_LZ77:
+		return xca.DecompressLZ77(data, size)
+	case SMB2_COMPRESSION_CAPABILITIES_LZ77_HUFF:
+		return xca.DecompressLZ77Huffman(data, size)
+	}
+	return nil, fmt.Errorf("compression algorithm %v", a)
+}
+
+// patternMinRun is the shortest run of one byte sent as a Pattern_V1
+// payload in a chained message.
+const patternMinRun = 32
+
+// compressMessage returns msg compressed with the first algorithm of algs
+// that is not Pattern_V1, in the chained form if chained. Runs of one byte
+// at either end become Pattern_V1 payloads when algs has it. ok is false
+// when compression does not make msg smaller.
+// MS-SMB2 3.1.4.4 Compressing the Message
+func compressMessage(algs []CompressionAlgorithm, chained bool, msg []byte) (pkt []byte, ok bool) {
+	var alg CompressionAlgorithm
+	for _, a := range algs {
+		if a != SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1 {
+			alg = a
+			break
+		}
+	}
+
+	if !chained {
+		if alg == SMB2_COMPRESSION_CAPABILITIES_NONE {
+			return nil, false
+		}
+		compressed := compressPayload(alg, msg)
+		if compressionTransformHeaderSize+len(compressed) >= len(msg) {
+			return nil, false
+		}
+		hdr := CompressionTransformHeader(make([]byte, compressionTransformHeaderSize, compressionTransformHeaderSize+len(compressed)))
+		hdr.SetProtocolId()
+		hdr.SetOriginalCompressedSegmentSize(uint32(len(msg)))
+		hdr.SetCompressionAlgorithm(alg)
+		hdr.SetFlags(SMB2_COMPRESSION_FLAG_NONE)
+		hdr.SetOffset(0)
+		return append(hdr, compressed...), true
+	}
+
+	pattern := containsCompressionAlgorithm(algs, SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1)
+	var leading, trailing int
+	if pattern {
+		leading = runLength(msg, 0, 1)
+		if leading < patternMinRun {
+			leading = 0
+		}
+		if leading < len(msg) {
+			trailing = runLength(msg, len(msg)-1, -1)
+			if trailing < patternMinRun {
+				trailing = 0
+			}
+		}
+	}
+
+	pkt = make([]byte, 8, len(msg))
+	CompressionTransformHeader(pkt).SetProtocolId()
+	CompressionTransformHeader(pkt).SetOriginalCompressedSegmentSize(uint32(len(msg)))
+	if leading > 0 {
+		pkt = appendPatternPayload(pkt, msg[0], leading)
+	}
+	if middle := msg[leading : len(msg)-trailing]; len(middle) > 0 {
+		var compressed []byte
+		if alg != SMB2_COMPRESSION_CAPABILITIES_NONE {
+			compressed = compressPayload(alg, middle)
+		}
+		if compressed != nil && len(compressed)+4 < len(middle) {
+			pkt = appendPayloadHeader(pkt, alg, 4+len(compressed))
+			pkt = append(pkt, byte(len(middle)), byte(len(middle)>>8), byte(len(middle)>>16), byte(len(middle)>>24))
+			pkt = append(pkt, compressed...)
+		} else {
+			pkt = appendPayloadHeader(pkt, SMB2_COMPRESSION_CAPABILITIES_NONE, len(middle))
+			pkt = append(pkt, middle...)
+		}
+	}
+	if trailing > 0 {
+		pkt = appendPatternPayload(pkt, msg[len(msg)-1], trailing)
+	}
+	if len(pkt) >= len(msg) {
+		return nil, false
+	}
+	return pkt, true
+}
+
+// runLength returns how many bytes equal to msg[start] follow each other
+// from start in direction step.
+func runLength(msg []byte, start, step int) int {
+	n := 0
+	for i := start; i >= 0 && i < len(msg) && msg[i] == msg[start]; i += step {
+		n++
+	}
+	return n
+}
+
+func appendPayloadHeader(pkt []byte, a CompressionAlgorithm, length int) []byte {
+	hdr := CompressionPayloadHeader(make([]byte, compressionPayloadHeaderSize))
+	hdr.SetCompressionAlgorithm(a)
+	hdr.SetFlags(SMB2_COMPRESSION_FLAG_CHAINED)
+	hdr.SetLength(uint32(length))
+	return append(pkt, hdr...)
+}
+
+func appendPatternPayload(pkt []byte, pattern byte, repetitions int) []byte {
+	pkt = appendPayloadHeader(pkt, SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1, patternV1PayloadSize)
+	p := PatternV1Payload(make([]byte, patternV1PayloadSize))
+	p.SetPattern(pattern)
+	p.SetRepetitions(uint32(repetitions))
+	return append(pkt, p...)
+}
+
+// decompressMessage returns the message compressed in pkt, at most max
+// bytes, into a buffer from getFrameBuffer. Only the algorithms in algs
+// are accepted.
+// MS-SMB2 3.3.5.2.12 Decompressing the Message
+func decompressMessage(algs []CompressionAlgorithm, chained bool, pkt CompressionTransformHeader, max int) (PacketCodec, error) {
+	if pkt.IsInvalid() {
+		return nil, fmt.Errorf("invalid compression transform header")
+	}
+	size := int(pkt.OriginalCompressedSegmentSize())
+	accept := func(a CompressionAlgorithm) error {
+		if !containsCompressionAlgorithm(algs, a) {
+			return fmt.Errorf("compression algorithm %v was not negotiated", a)
+		}
+		return nil
+	}
+
+	if pkt.Flags() != SMB2_COMPRESSION_FLAG_CHAINED {
+		offset := int(pkt.Offset())
+		if err := accept(pkt.CompressionAlgorithm()); err != nil {
+			return nil, err
+		}
+		if offset > len(pkt)-compressionTransformHeaderSize || offset+size > max {
+			return nil, fmt.Errorf("compressed message of %d+%d bytes", offset, size)
+		}
+		data, err := decompressPayload(pkt.CompressionAlgorithm(), pkt[compressionTransformHeaderSize+offset:], size)
+		if err != nil {
+			return nil, err
+		}
+		out := getFrameBuffer(offset + size)
+		copy(out, pkt[compressionTransformHeaderSize:compressionTransformHeaderSize+offset])
+		copy(out[offset:], data)
+		return out, nil
+	}
+
+	if !chained {
+		return nil, fmt.Errorf("chained compression was not negotiated")
+	}
+	if size > max {
+		return nil, fmt.Errorf("compressed message of %d bytes", size)
+	}
+	out := getFrameBuffer(size)[:0]
+	for rest := []byte(pkt[8:]); len(rest) > 0; {
+		if len(rest) < compressionPayloadHeaderSize {
+			putFrameBuffer(out)
+			return nil, fmt.Errorf("truncated chained payload header")
+		}
+		hdr := CompressionPayloadHeader(rest)
+		length := int(hdr.Length())
+		if length > len(rest)-compressionPayloadHeaderSize {
+			putFrameBuffer(out)
+			return nil, fmt.Errorf("chained payload of %d bytes", length)
+		}
+		payload := rest[compressionPayloadHeaderSize : compressionPayloadHeaderSize+length]
+		rest = rest[compressionPayloadHeaderSize+length:]
+
+		var data []byte
+		var err error
+		switch a := hdr.CompressionAlgorithm(); a {
+		case SMB2_COMPRESSION_CAPABILITIES_NONE:
+			data = payload
+		case SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1:
+			if err = accept(a); err != nil {
+				break
+			}
+			if len(payload) < patternV1PayloadSize ||
+				int(PatternV1Payload(payload).Repetitions()) > size-len(out) {
+				err = fmt.Errorf("invalid pattern payload")
+				break
+			}
+			p := PatternV1Payload(payload)
+			for i := 0; i < int(p.Repetitions()); i++ {
+				out = append(out, p.Pattern())
+			}
+			continue
+		default:
+			if err = accept(a); err != nil {
+				break
+			}
+			if len(payload) < 4 || int(binary.LittleEndian.Uint32(payload)) > size-len(out) {
+				err = fmt.Errorf("invalid %v payload", a)
+				break
+			}
+			data, err = decompressPayload(a, payload[4:], int(binary.LittleEndian.Uint32(payload)))
+		}
+		if err != nil {
+			putFrameBuffer(out)
+			return nil, err
+		}
+		if len(data) > size-len(out) {
+			putFrameBuffer(out)
+			return nil, fmt.Errorf("chained payloads exceed %d bytes", size)
+		}
+		out = append(out, data...)
+	}
+	if len(out) != size {
+		putFrameBuffer(out)
+		return nil, fmt.Errorf("chained payloads of %d bytes, want %d", len(out), size)
+	}
+	return out, nil
+}
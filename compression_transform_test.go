@@ -0,0 +1,213 @@
+package simba
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCompressionTransformHeader(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    []byte
+		expected map[string]interface{}
+	}{
+		{
+			name: "unchained",
+			input: func() []byte {
+				r, _ := hex.DecodeString("fc534d42" + "00100000" + "0200" + "0000" + "40000000")
+				return r
+			}(),
+			expected: map[string]interface{}{
+				"OriginalCompressedSegmentSize": uint32(4096),
+				"CompressionAlgorithm":          SMB2_COMPRESSION_CAPABILITIES_LZ77,
+				"Flags":                         SMB2_COMPRESSION_FLAG_NONE,
+				"Offset":                        uint32(64),
+			},
+		},
+		{
+			name: "chained",
+			input: func() []byte {
+				r, _ := hex.DecodeString("fc534d42" + "00100000" + "0400" + "0100" + "08000000")
+				return r
+			}(),
+			expected: map[string]interface{}{
+				"OriginalCompressedSegmentSize": uint32(4096),
+				"CompressionAlgorithm":          SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1,
+				"Flags":                         SMB2_COMPRESSION_FLAG_CHAINED,
+				"Offset":                        uint32(8),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := CompressionTransformHeader(c.input)
+			if p.IsInvalid() {
+				t.Fatalf("IsInvalid() = true")
+			}
+			if v := p.OriginalCompressedSegmentSize(); v != c.expected["OriginalCompressedSegmentSize"] {
+				t.Errorf("OriginalCompressedSegmentSize() = %v, want %v", v, c.expected["OriginalCompressedSegmentSize"])
+			}
+			if v := p.CompressionAlgorithm(); v != c.expected["CompressionAlgorithm"] {
+				t.Errorf("CompressionAlgorithm() = %v, want %v", v, c.expected["CompressionAlgorithm"])
+			}
+			if v := p.Flags(); v != c.expected["Flags"] {
+				t.Errorf("Flags() = %v, want %v", v, c.expected["Flags"])
+			}
+			if v := p.Offset(); v != c.expected["Offset"] {
+				t.Errorf("Offset() = %v, want %v", v, c.expected["Offset"])
+			}
+		})
+	}
+}
+
+// compressibleMessage returns a READ response like message: an SMB2
+// header, text and a zero filled tail.
+func compressibleMessage() []byte {
+	msg, _ := hex.DecodeString(echoRequest)
+	msg = append(msg, bytes.Repeat([]byte("simba compression "), 500)...)
+	return append(msg, make([]byte, 3000)...)
+}
+
+func TestCompressMessage(t *testing.T) {
+	msg := compressibleMessage()
+	cases := []struct {
+		name       string
+		algs       []CompressionAlgorithm
+		chained    bool
+		algorithms []CompressionAlgorithm // of the chained payloads
+	}{
+		{name: "lznt1", algs: []CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_LZNT1}},
+		{name: "lz77", algs: []CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_LZ77}},
+		{name: "lz77+huffman", algs: []CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_LZ77_HUFF}},
+		{
+			name:       "chained",
+			algs:       []CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_LZ77, SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1},
+			chained:    true,
+			algorithms: []CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_LZ77, SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1},
+		},
+		{
+			name:       "chained pattern only",
+			algs:       []CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1},
+			chained:    true,
+			algorithms: []CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_NONE, SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pkt, ok := compressMessage(c.algs, c.chained, msg)
+			if !ok {
+				t.Fatalf("compressMessage() did not compress")
+			}
+			hdr := CompressionTransformHeader(pkt)
+			if c.chained != (hdr.Flags() == SMB2_COMPRESSION_FLAG_CHAINED) {
+				t.Errorf("Flags() = %v", hdr.Flags())
+			}
+			if c.chained {
+				var algorithms []CompressionAlgorithm
+				for rest := pkt[8:]; len(rest) > 0; {
+					p := CompressionPayloadHeader(rest)
+					algorithms = append(algorithms, p.CompressionAlgorithm())
+					rest = rest[compressionPayloadHeaderSize+int(p.Length()):]
+				}
+				if len(algorithms) != len(c.algorithms) {
+					t.Fatalf("payloads = %v, want %v", algorithms, c.algorithms)
+				}
+				for i := range algorithms {
+					if algorithms[i] != c.algorithms[i] {
+						t.Errorf("payloads = %v, want %v", algorithms, c.algorithms)
+					}
+				}
+			}
+
+			actual, err := decompressMessage(c.algs, c.chained, hdr, len(msg))
+			if err != nil {
+				t.Fatalf("decompressMessage() error = %v", err)
+			}
+			if !bytes.Equal(actual, msg) {
+				t.Errorf("decompressMessage() differs from the message")
+			}
+			putFrameBuffer(actual)
+
+			if _, err := decompressMessage(c.algs, c.chained, hdr, len(msg)-1); err == nil {
+				t.Errorf("decompressMessage() above the limit succeeded")
+			}
+			if _, err := decompressMessage(nil, c.chained, hdr, len(msg)); err == nil {
+				t.Errorf("decompressMessage() with an algorithm that was not negotiated succeeded")
+			}
+		})
+	}
+}
+
+func TestDecompressMessageOffset(t *testing.T) {
+	msg := compressibleMessage()
+	hdr := CompressionTransformHeader(make([]byte, compressionTransformHeaderSize))
+	hdr.SetProtocolId()
+	hdr.SetOriginalCompressedSegmentSize(uint32(len(msg) - 64))
+	hdr.SetCompressionAlgorithm(SMB2_COMPRESSION_CAPABILITIES_LZNT1)
+	hdr.SetOffset(64)
+	// the SMB2 header is left uncompressed
+	pkt := append(append(hdr, msg[:64]...), compressPayload(SMB2_COMPRESSION_CAPABILITIES_LZNT1, msg[64:])...)
+
+	actual, err := decompressMessage([]CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_LZNT1}, false, pkt, len(msg))
+	if err != nil {
+		t.Fatalf("decompressMessage() error = %v", err)
+	}
+	if !bytes.Equal(actual, msg) {
+		t.Errorf("decompressMessage() differs from the message")
+	}
+}
+
+func TestSelectCompression(t *testing.T) {
+	cases := []struct {
+		name     string
+		server   *Server
+		offer    CompressionCapability
+		expected []CompressionAlgorithm
+		chained  bool
+	}{
+		{
+			name:     "windows 11",
+			server:   &Server{},
+			offer:    NewCompressionCapability([]CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_LZ77, SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1}, SMB2_COMPRESSION_CAPABILITIES_FLAG_CHAINED),
+			expected: []CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_LZ77, SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1},
+			chained:  true,
+		},
+		{
+			name:     "pattern needs chaining",
+			server:   &Server{},
+			offer:    NewCompressionCapability([]CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1, SMB2_COMPRESSION_CAPABILITIES_LZNT1}, SMB2_COMPRESSION_CAPABILITIES_FLAG_NONE),
+			expected: []CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_LZNT1},
+		},
+		{
+			name:     "disabled",
+			server:   &Server{CompressionAlgorithms: []CompressionAlgorithm{}},
+			offer:    NewCompressionCapability([]CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_LZ77}, SMB2_COMPRESSION_CAPABILITIES_FLAG_CHAINED),
+			expected: []CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_NONE},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conn := &conn{server: c.server}
+			resp := conn.selectCompression(c.offer)
+			actual := resp.CompressionAlgorithms()
+			if len(actual) != len(c.expected) {
+				t.Fatalf("CompressionAlgorithms() = %v, want %v", actual, c.expected)
+			}
+			for i := range actual {
+				if actual[i] != c.expected[i] {
+					t.Errorf("CompressionAlgorithms() = %v, want %v", actual, c.expected)
+				}
+			}
+			if conn.compressionChained != c.chained {
+				t.Errorf("compressionChained = %v, want %v", conn.compressionChained, c.chained)
+			}
+			if (resp.Flags() == SMB2_COMPRESSION_CAPABILITIES_FLAG_CHAINED) != c.chained {
+				t.Errorf("Flags() = %v", resp.Flags())
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package simba
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"time"
+	"unicode/utf16"
+)
+
+type OplockLevel uint8
+
+const (
+	// MS-SMB2 2.2.13 SMB2 CREATE Request
+	SMB2_OPLOCK_LEVEL_NONE      OplockLevel = 0x00
+	SMB2_OPLOCK_LEVEL_II        OplockLevel = 0x01
+	SMB2_OPLOCK_LEVEL_EXCLUSIVE OplockLevel = 0x08
+	SMB2_OPLOCK_LEVEL_BATCH     OplockLevel = 0x09
+	SMB2_OPLOCK_LEVEL_LEASE     OplockLevel = 0xFF
+)
+
+type ImpersonationLevel uint32
+
+const (
+	// MS-SMB2 2.2.13 SMB2 CREATE Request
+	IMPERSONATION_ANONYMOUS      ImpersonationLevel = 0x00000000
+	IMPERSONATION_IDENTIFICATION ImpersonationLevel = 0x00000001
+	IMPERSONATION_IMPERSONATION  ImpersonationLevel = 0x00000002
+	IMPERSONATION_DELEGATE       ImpersonationLevel = 0x00000003
+)
+
+type ShareAccess uint32
+
+const (
+	// MS-SMB2 2.2.13 SMB2 CREATE Request
+	FILE_SHARE_READ   ShareAccess = 0x00000001
+	FILE_SHARE_WRITE  ShareAccess = 0x00000002
+	FILE_SHARE_DELETE ShareAccess = 0x00000004
+)
+
+type CreateDisposition uint32
+
+const (
+	// MS-SMB2 2.2.13 SMB2 CREATE Request
+	FILE_SUPERSEDE    CreateDisposition = 0x00000000
+	FILE_OPEN         CreateDisposition = 0x00000001
+	FILE_CREATE       CreateDisposition = 0x00000002
+	FILE_OPEN_IF      CreateDisposition = 0x00000003
+	FILE_OVERWRITE    CreateDisposition = 0x00000004
+	FILE_OVERWRITE_IF CreateDisposition = 0x00000005
+)
+
+type CreateOptions uint32
+
+const (
+	// MS-SMB2 2.2.13 SMB2 CREATE Request
+	FILE_DIRECTORY_FILE            CreateOptions = 0x00000001
+	FILE_WRITE_THROUGH             CreateOptions = 0x00000002
+	FILE_SEQUENTIAL_ONLY           CreateOptions = 0x00000004
+	FILE_NO_INTERMEDIATE_BUFFERING CreateOptions = 0x00000008
+	FILE_SYNCHRONOUS_IO_ALERT      CreateOptions = 0x00000010
+	FILE_SYNCHRONOUS_IO_NONALERT   CreateOptions = 0x00000020
+	FILE_NON_DIRECTORY_FILE        CreateOptions = 0x00000040
+	FILE_COMPLETE_IF_OPLOCKED      CreateOptions = 0x00000100
+	FILE_NO_EA_KNOWLEDGE           CreateOptions = 0x00000200
+	FILE_OPEN_REMOTE_INSTANCE      CreateOptions = 0x00000400
+	FILE_RANDOM_ACCESS             CreateOptions = 0x00000800
+	FILE_DELETE_ON_CLOSE           CreateOptions = 0x00001000
+	FILE_OPEN_BY_FILE_ID           CreateOptions = 0x00002000
+	FILE_OPEN_FOR_BACKUP_INTENT    CreateOptions = 0x00004000
+	FILE_NO_COMPRESSION            CreateOptions = 0x00008000
+	FILE_OPEN_REQUIRING_OPLOCK     CreateOptions = 0x00010000
+	FILE_DISALLOW_EXCLUSIVE        CreateOptions = 0x00020000
+	FILE_RESERVE_OPFILTER          CreateOptions = 0x00100000
+	FILE_OPEN_REPARSE_POINT        CreateOptions = 0x00200000
+	FILE_OPEN_NO_RECALL            CreateOptions = 0x00400000
+	FILE_OPEN_FOR_FREE_SPACE_QUERY CreateOptions = 0x00800000
+)
+
+type CreateAction uint32
+
+const (
+	// MS-SMB2 2.2.14 SMB2 CREATE Response
+	FILE_SUPERSEDED  CreateAction = 0x00000000
+	FILE_OPENED      CreateAction = 0x00000001
+	FILE_CREATED     CreateAction = 0x00000002
+	FILE_OVERWRITTEN CreateAction = 0x00000003
+)
+
+// FileId identifies an open.
+// MS-SMB2 2.2.14.1 SMB2_FILEID
+type FileId struct {
+	Persistent uint64
+	Volatile   uint64
+}
+
+func getFileId(b []byte) FileId {
+	return FileId{
+		Persistent: le.Uint64(b[0:8]),
+		Volatile:   le.Uint64(b[8:16]),
+	}
+}
+
+func putFileId(b []byte, v FileId) {
+	le.PutUint64(b[0:8], v.Persistent)
+	le.PutUint64(b[8:16], v.Volatile)
+}
+
+// MS-SMB2 2.2.13 SMB2 CREATE Request
+type CreateRequest []byte
+
+func (p CreateRequest) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 57
+	if len(p) < 56 {
+		return true
+	}
+
+	return false
+}
+
+func (p CreateRequest) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p CreateRequest) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 57)
+}
+
+func (p CreateRequest) SecurityFlags() uint8 {
+	return p[2]
+}
+
+func (p CreateRequest) RequestedOplockLevel() OplockLevel {
+	return OplockLevel(p[3])
+}
+
+func (p CreateRequest) SetRequestedOplockLevel(v OplockLevel) {
+	p[3] = byte(v)
+}
+
+func (p CreateRequest) ImpersonationLevel() ImpersonationLevel {
+	return ImpersonationLevel(binary.LittleEndian.Uint32(p[4:8]))
+}
+
+func (p CreateRequest) SetImpersonationLevel(v ImpersonationLevel) {
+	binary.LittleEndian.PutUint32(p[4:8], uint32(v))
+}
+
+func (p CreateRequest) DesiredAccess() AccessMask {
+	return AccessMask(binary.LittleEndian.Uint32(p[24:28]))
+}
+
+func (p CreateRequest) SetDesiredAccess(v AccessMask) {
+	binary.LittleEndian.PutUint32(p[24:28], uint32(v))
+}
+
+func (p CreateRequest) FileAttributes() FileAttributes {
+	return FileAttributes(binary.LittleEndian.Uint32(p[28:32]))
+}
+
+func (p CreateRequest) SetFileAttributes(v FileAttributes) {
+	binary.LittleEndian.PutUint32(p[28:32], uint32(v))
+}
+
+func (p CreateRequest) ShareAccess() ShareAccess {
+	return ShareAccess(binary.LittleEndian.Uint32(p[32:36]))
+}
+
+func (p CreateRequest) SetShareAccess(v ShareAccess) {
+	binary.LittleEndian.PutUint32(p[32:36], uint32(v))
+}
+
+func (p CreateRequest) CreateDisposition() CreateDisposition {
+	return CreateDisposition(binary.LittleEndian.Uint32(p[36:40]))
+}
+
+func (p CreateRequest) SetCreateDisposition(v CreateDisposition) {
+	binary.LittleEndian.PutUint32(p[36:40], uint32(v))
+}
+
+func (p CreateRequest) CreateOptions() CreateOptions {
+	return CreateOptions(binary.LittleEndian.Uint32(p[40:44]))
+}
+
+func (p CreateRequest) SetCreateOptions(v CreateOptions) {
+	binary.LittleEndian.PutUint32(p[40:44], uint32(v))
+}
+
+// NameOffset is from the beginning of the SMB2 header.
+func (p CreateRequest) NameOffset() uint16 {
+	return binary.LittleEndian.Uint16(p[44:46])
+}
+
+func (p CreateRequest) SetNameOffset(v uint16) {
+	binary.LittleEndian.PutUint16(p[44:46], v)
+}
+
+func (p CreateRequest) NameLength() uint16 {
+	return binary.LittleEndian.Uint16(p[46:48])
+}
+
+func (p CreateRequest) SetNameLength(v uint16) {
+	binary.LittleEndian.PutUint16(p[46:48], v)
+}
+
+func (p CreateRequest) CreateContextsOffset() uint32 {
+	return binary.LittleEndian.Uint32(p[48:52])
+}
+
+func (p CreateRequest) CreateContextsLength() uint32 {
+	return binary.LittleEndian.Uint32(p[52:56])
+}
+
+// Name returns the name of the file relative to the share, and false when
+// it lies outside of the request. An empty name is the root of the share.
+func (p CreateRequest) Name() (string, bool) {
+	if p.NameLength() == 0 {
+		return "", true
+	}
+	if p.NameOffset() < 64 || p.NameLength()%2 != 0 {
+		return "", false
+	}
+	offset := int(p.NameOffset()) - 64
+	length := int(p.NameLength())
+	if offset+length > len(p) {
+		return "", false
+	}
+	return decodeUTF16(p[offset : offset+length]), true
+}
+
+// SetName sets the name at NameOffset, the request must be large enough.
+func (p CreateRequest) SetName(v string) {
+	u := utf16.Encode([]rune(v))
+	offset := int(p.NameOffset()) - 64
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(p[offset+2*i:], c)
+	}
+	p.SetNameLength(uint16(2 * len(u)))
+}
+
+// MS-SMB2 2.2.14 SMB2 CREATE Response
+type CreateResponse []byte
+
+func (p CreateResponse) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 89
+	if len(p) < 88 {
+		return true
+	}
+
+	return false
+}
+
+func (p CreateResponse) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p CreateResponse) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 89)
+}
+
+func (p CreateResponse) OplockLevel() OplockLevel {
+	return OplockLevel(p[2])
+}
+
+func (p CreateResponse) SetOplockLevel(v OplockLevel) {
+	p[2] = byte(v)
+}
+
+func (p CreateResponse) CreateAction() CreateAction {
+	return CreateAction(binary.LittleEndian.Uint32(p[4:8]))
+}
+
+func (p CreateResponse) SetCreateAction(v CreateAction) {
+	binary.LittleEndian.PutUint32(p[4:8], uint32(v))
+}
+
+func (p CreateResponse) CreationTime() time.Time {
+	return getFiletime(p[8:16])
+}
+
+func (p CreateResponse) SetCreationTime(v time.Time) {
+	putFiletime(p[8:16], v)
+}
+
+func (p CreateResponse) LastAccessTime() time.Time {
+	return getFiletime(p[16:24])
+}
+
+func (p CreateResponse) SetLastAccessTime(v time.Time) {
+	putFiletime(p[16:24], v)
+}
+
+func (p CreateResponse) LastWriteTime() time.Time {
+	return getFiletime(p[24:32])
+}
+
+func (p CreateResponse) SetLastWriteTime(v time.Time) {
+	putFiletime(p[24:32], v)
+}
+
+func (p CreateResponse) ChangeTime() time.Time {
+	return getFiletime(p[32:40])
+}
+
+func (p CreateResponse) SetChangeTime(v time.Time) {
+	putFiletime(p[32:40], v)
+}
+
+func (p CreateResponse) AllocationSize() int64 {
+	return int64(binary.LittleEndian.Uint64(p[40:48]))
+}
+
+func (p CreateResponse) SetAllocationSize(v int64) {
+	binary.LittleEndian.PutUint64(p[40:48], uint64(v))
+}
+
+func (p CreateResponse) EndOfFile() int64 {
+	return int64(binary.LittleEndian.Uint64(p[48:56]))
+}
+
+func (p CreateResponse) SetEndOfFile(v int64) {
+	binary.LittleEndian.PutUint64(p[48:56], uint64(v))
+}
+
+func (p CreateResponse) FileAttributes() FileAttributes {
+	return FileAttributes(binary.LittleEndian.Uint32(p[56:60]))
+}
+
+func (p CreateResponse) SetFileAttributes(v FileAttributes) {
+	binary.LittleEndian.PutUint32(p[56:60], uint32(v))
+}
+
+func (p CreateResponse) FileId() FileId {
+	return getFileId(p[64:80])
+}
+
+func (p CreateResponse) SetFileId(v FileId) {
+	putFileId(p[64:80], v)
+}
+
+func (p CreateResponse) CreateContextsOffset() uint32 {
+	return binary.LittleEndian.Uint32(p[80:84])
+}
+
+func (p CreateResponse) CreateContextsLength() uint32 {
+	return binary.LittleEndian.Uint32(p[84:88])
+}
+
+// NewCreateResponse returns the CREATE response of an open of the file
+// described by fi, without create contexts.
+func NewCreateResponse(action CreateAction, fileId FileId, fi fs.FileInfo) CreateResponse {
+	p := CreateResponse(make([]byte, 88))
+	p.SetStructureSize()
+	p.SetOplockLevel(SMB2_OPLOCK_LEVEL_NONE)
+	p.SetCreateAction(action)
+	creation, access, write, change := fileTimes(fi)
+	p.SetCreationTime(creation)
+	p.SetLastAccessTime(access)
+	p.SetLastWriteTime(write)
+	p.SetChangeTime(change)
+	p.SetAllocationSize(allocationSize(fi))
+	if !fi.IsDir() {
+		p.SetEndOfFile(fi.Size())
+	}
+	p.SetFileAttributes(fileAttributes(fi))
+	p.SetFileId(fileId)
+	return p
+}
@@ -0,0 +1,450 @@
+package simba
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"testing"
+	"unicode/utf16"
+)
+
+// newCreateRequest returns a CREATE request of name on tree treeId of
+// session id.
+func newCreateRequest(id uint64, treeId uint32, messageId uint64, name string, access AccessMask, shareAccess ShareAccess, disposition CreateDisposition, options CreateOptions) []byte {
+	size := 56 + 2*len(utf16.Encode([]rune(name)))
+	if size == 56 {
+		size++
+	}
+	p := newRequest(SMB2_CREATE, messageId, id, treeId, 57, size)
+	msg := CreateRequest(p[64:])
+	msg.SetImpersonationLevel(IMPERSONATION_IMPERSONATION)
+	msg.SetDesiredAccess(access)
+	msg.SetShareAccess(shareAccess)
+	msg.SetCreateDisposition(disposition)
+	msg.SetCreateOptions(options)
+	msg.SetNameOffset(64 + 56)
+	msg.SetName(name)
+	return p
+}
+
+func TestCreateRequest(t *testing.T) {
+	p := newCreateRequest(1, 2, 3, `dir\a.txt`, GENERIC_READ, FILE_SHARE_READ, FILE_OPEN_IF, FILE_NON_DIRECTORY_FILE)
+	msg := CreateRequest(p[64:])
+	if msg.IsInvalid() || msg.StructureSize() != 57 {
+		t.Fatalf("StructureSize %d", msg.StructureSize())
+	}
+	if name, ok := msg.Name(); !ok || name != `dir\a.txt` {
+		t.Errorf("Name %q, %v", name, ok)
+	}
+	if msg.DesiredAccess() != GENERIC_READ || msg.ShareAccess() != FILE_SHARE_READ ||
+		msg.CreateDisposition() != FILE_OPEN_IF || msg.CreateOptions() != FILE_NON_DIRECTORY_FILE ||
+		msg.ImpersonationLevel() != IMPERSONATION_IMPERSONATION {
+		t.Errorf("request %x", []byte(msg))
+	}
+	msg.SetNameLength(200)
+	if _, ok := msg.Name(); ok {
+		t.Error("Name out of the request")
+	}
+}
+
+func TestVFSName(t *testing.T) {
+	cases := []struct {
+		name string
+		want string
+		err  bool
+	}{
+		{"", ".", false},
+		{"a.txt", "a.txt", false},
+		{`dir\sub\a.txt`, "dir/sub/a.txt", false},
+		{`dir\..\a.txt`, "", true},
+		{`dir\\a.txt`, "", true},
+		{`dir\`, "", true},
+		{"a/b", "", true},
+		{"a:stream", "", true},
+		{"a*", "", true},
+	}
+	for _, tc := range cases {
+		got, err := vfsName(tc.name)
+		if got != tc.want || (err != nil) != tc.err {
+			t.Errorf("vfsName(%q) = %q, %v", tc.name, got, err)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	m := NewMemFS()
+	if err := m.Mkdir("docs", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"docs/a.txt": "hello", "ro.txt": "ro"} {
+		f, err := m.Open(name, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteAt([]byte(data), 0)
+		f.Close()
+	}
+	if err := m.SetAttributes("ro.txt", FILE_ATTRIBUTE_READONLY); err != nil {
+		t.Fatal(err)
+	}
+
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	srv := &Server{}
+	data := &Share{Name: "data", FS: m}
+	archive := &Share{Name: "archive", FS: m, ReadOnly: true}
+	for _, sh := range []*Share{data, archive} {
+		if err := srv.AddShare(sh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := srv.newConn(sv)
+	c.dialect = SMB2_DIALECT_302
+	s, err := c.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.state = sessionValid
+	dataTree := s.newTreeConnect(data)
+	archiveTree := s.newTreeConnect(archive)
+	ipcTree := s.newTreeConnect(ipcShare)
+	go c.serve()
+
+	roundTrip := func(msg []byte) PacketCodec {
+		if err := writeFrame(cl, msg); err != nil {
+			t.Fatal(err)
+		}
+		r, err := readFrame(cl, directTCPMaxLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return PacketCodec(r)
+	}
+
+	const shareAll = FILE_SHARE_READ | FILE_SHARE_WRITE | FILE_SHARE_DELETE
+	cases := []struct {
+		name        string
+		tree        *treeConnect
+		path        string
+		access      AccessMask
+		shareAccess ShareAccess
+		disposition CreateDisposition
+		options     CreateOptions
+		status      uint32
+		action      CreateAction
+		attrs       FileAttributes
+		size        int64
+	}{
+		{"open", dataTree, `docs\a.txt`, GENERIC_READ, shareAll, FILE_OPEN, 0, STATUS_SUCCESS, FILE_OPENED, FILE_ATTRIBUTE_NORMAL, 5},
+		{"open root", dataTree, "", FILE_READ_ATTRIBUTES, shareAll, FILE_OPEN, FILE_DIRECTORY_FILE, STATUS_SUCCESS, FILE_OPENED, FILE_ATTRIBUTE_DIRECTORY, 0},
+		{"create", dataTree, "new.txt", GENERIC_WRITE, shareAll, FILE_CREATE, FILE_NON_DIRECTORY_FILE, STATUS_SUCCESS, FILE_CREATED, FILE_ATTRIBUTE_NORMAL, 0},
+		{"create existing", dataTree, "new.txt", GENERIC_WRITE, shareAll, FILE_CREATE, 0, STATUS_OBJECT_NAME_COLLISION, 0, 0, 0},
+		{"open missing", dataTree, "none.txt", GENERIC_READ, shareAll, FILE_OPEN, 0, STATUS_OBJECT_NAME_NOT_FOUND, 0, 0, 0},
+		{"open missing directory", dataTree, `none\a.txt`, GENERIC_READ, shareAll, FILE_OPEN, 0, STATUS_OBJECT_PATH_NOT_FOUND, 0, 0, 0},
+		{"open if missing", dataTree, `docs\b.txt`, GENERIC_READ | GENERIC_WRITE, shareAll, FILE_OPEN_IF, 0, STATUS_SUCCESS, FILE_CREATED, FILE_ATTRIBUTE_NORMAL, 0},
+		{"open if existing", dataTree, `docs\b.txt`, GENERIC_READ, shareAll, FILE_OPEN_IF, 0, STATUS_SUCCESS, FILE_OPENED, FILE_ATTRIBUTE_NORMAL, 0},
+		{"overwrite missing", dataTree, "c.txt", GENERIC_WRITE, shareAll, FILE_OVERWRITE, 0, STATUS_OBJECT_NAME_NOT_FOUND, 0, 0, 0},
+		{"overwrite if", dataTree, `docs\a.txt`, GENERIC_WRITE, shareAll, FILE_OVERWRITE_IF, 0, STATUS_SUCCESS, FILE_OVERWRITTEN, FILE_ATTRIBUTE_NORMAL, 0},
+		{"supersede", dataTree, "new.txt", GENERIC_WRITE, shareAll, FILE_SUPERSEDE, 0, STATUS_SUCCESS, FILE_SUPERSEDED, FILE_ATTRIBUTE_NORMAL, 0},
+		{"create directory", dataTree, `docs\sub`, FILE_READ_DATA, shareAll, FILE_CREATE, FILE_DIRECTORY_FILE, STATUS_SUCCESS, FILE_CREATED, FILE_ATTRIBUTE_DIRECTORY, 0},
+		{"directory of a file", dataTree, `docs\a.txt`, GENERIC_READ, shareAll, FILE_OPEN, FILE_DIRECTORY_FILE, STATUS_NOT_A_DIRECTORY, 0, 0, 0},
+		{"file of a directory", dataTree, "docs", GENERIC_READ, shareAll, FILE_OPEN, FILE_NON_DIRECTORY_FILE, STATUS_FILE_IS_A_DIRECTORY, 0, 0, 0},
+		{"overwrite directory", dataTree, "docs", GENERIC_WRITE, shareAll, FILE_OVERWRITE_IF, 0, STATUS_FILE_IS_A_DIRECTORY, 0, 0, 0},
+		{"directory and file", dataTree, "docs", GENERIC_READ, shareAll, FILE_OPEN, FILE_DIRECTORY_FILE | FILE_NON_DIRECTORY_FILE, STATUS_INVALID_PARAMETER, 0, 0, 0},
+		{"supersede directory", dataTree, "docs", GENERIC_READ, shareAll, FILE_SUPERSEDE, FILE_DIRECTORY_FILE, STATUS_INVALID_PARAMETER, 0, 0, 0},
+		{"leading backslash", dataTree, `\docs`, GENERIC_READ, shareAll, FILE_OPEN, 0, STATUS_INVALID_PARAMETER, 0, 0, 0},
+		{"invalid name", dataTree, "a:b", GENERIC_READ, shareAll, FILE_OPEN, 0, STATUS_OBJECT_NAME_INVALID, 0, 0, 0},
+		{"invalid disposition", dataTree, "new.txt", GENERIC_READ, shareAll, 6, 0, STATUS_INVALID_PARAMETER, 0, 0, 0},
+		{"invalid access", dataTree, "new.txt", 0x00000200, shareAll, FILE_OPEN, 0, STATUS_ACCESS_DENIED, 0, 0, 0},
+		{"by file id", dataTree, "new.txt", GENERIC_READ, shareAll, FILE_OPEN, FILE_OPEN_BY_FILE_ID, STATUS_NOT_SUPPORTED, 0, 0, 0},
+		{"delete on close without delete", dataTree, "new.txt", GENERIC_WRITE, shareAll, FILE_OPEN, FILE_DELETE_ON_CLOSE, STATUS_ACCESS_DENIED, 0, 0, 0},
+		{"write read-only file", dataTree, "ro.txt", GENERIC_WRITE, shareAll, FILE_OPEN, 0, STATUS_ACCESS_DENIED, 0, 0, 0},
+		{"read read-only file", dataTree, "ro.txt", GENERIC_READ, shareAll, FILE_OPEN, 0, STATUS_SUCCESS, FILE_OPENED, FILE_ATTRIBUTE_READONLY, 2},
+		{"exclusive", dataTree, "lock.txt", GENERIC_WRITE, 0, FILE_CREATE, 0, STATUS_SUCCESS, FILE_CREATED, FILE_ATTRIBUTE_NORMAL, 0},
+		{"sharing violation", dataTree, "lock.txt", GENERIC_READ, shareAll, FILE_OPEN, 0, STATUS_SHARING_VIOLATION, 0, 0, 0},
+		{"name of another case", dataTree, "LOCK.txt", GENERIC_WRITE, 0, FILE_CREATE, 0, STATUS_SUCCESS, FILE_CREATED, FILE_ATTRIBUTE_NORMAL, 0},
+		{"attributes of exclusive", dataTree, "lock.txt", FILE_READ_ATTRIBUTES, shareAll, FILE_OPEN, 0, STATUS_SUCCESS, FILE_OPENED, FILE_ATTRIBUTE_NORMAL, 0},
+		{"shared read", dataTree, "ro.txt", GENERIC_READ, FILE_SHARE_READ, FILE_OPEN, 0, STATUS_SUCCESS, FILE_OPENED, FILE_ATTRIBUTE_READONLY, 2},
+		{"write of shared read", dataTree, "new.txt", GENERIC_WRITE, FILE_SHARE_READ, FILE_OPEN, 0, STATUS_SHARING_VIOLATION, 0, 0, 0},
+		{"read-only share write", archiveTree, `docs\a.txt`, GENERIC_WRITE, shareAll, FILE_OPEN, 0, STATUS_ACCESS_DENIED, 0, 0, 0},
+		{"read-only share maximum", archiveTree, `docs\a.txt`, MAXIMUM_ALLOWED, shareAll, FILE_OPEN, 0, STATUS_SUCCESS, FILE_OPENED, FILE_ATTRIBUTE_NORMAL, 0},
+		{"read-only share create", archiveTree, "d.txt", GENERIC_READ, shareAll, FILE_CREATE, 0, STATUS_ACCESS_DENIED, 0, 0, 0},
+		{"read-only share overwrite", archiveTree, `docs\a.txt`, GENERIC_READ, shareAll, FILE_OVERWRITE, 0, STATUS_ACCESS_DENIED, 0, 0, 0},
+		{"pipe", ipcTree, "srvsvc", GENERIC_READ, shareAll, FILE_OPEN, 0, STATUS_OBJECT_NAME_NOT_FOUND, 0, 0, 0},
+	}
+	fileIds := map[FileId]bool{}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := roundTrip(newCreateRequest(s.sessionId, tc.tree.treeId, uint64(i+1), tc.path,
+				tc.access, tc.shareAccess, tc.disposition, tc.options))
+			if r.Status() != tc.status {
+				t.Fatalf("status 0x%08x, want 0x%08x", r.Status(), tc.status)
+			}
+			if tc.status != STATUS_SUCCESS {
+				return
+			}
+			resp := CreateResponse(r[64:])
+			if resp.IsInvalid() || resp.StructureSize() != 89 {
+				t.Fatalf("StructureSize %d", resp.StructureSize())
+			}
+			if resp.CreateAction() != tc.action || resp.FileAttributes() != tc.attrs || resp.EndOfFile() != tc.size {
+				t.Errorf("action %d, attributes 0x%x, size %d, want %d, 0x%x, %d",
+					resp.CreateAction(), resp.FileAttributes(), resp.EndOfFile(), tc.action, tc.attrs, tc.size)
+			}
+			if resp.LastWriteTime().IsZero() || resp.OplockLevel() != SMB2_OPLOCK_LEVEL_NONE {
+				t.Errorf("LastWriteTime %v, oplock %d", resp.LastWriteTime(), resp.OplockLevel())
+			}
+			if fileIds[resp.FileId()] || s.opens[resp.FileId()] == nil {
+				t.Errorf("FileId %v", resp.FileId())
+			}
+			fileIds[resp.FileId()] = true
+		})
+	}
+
+	// files created with attributes, and deleted on close
+	hidden := newCreateRequest(s.sessionId, dataTree.treeId, 100, "tmp.txt", DELETE|GENERIC_WRITE, shareAll, FILE_CREATE, FILE_DELETE_ON_CLOSE)
+	CreateRequest(hidden[64:]).SetFileAttributes(FILE_ATTRIBUTE_HIDDEN)
+	r := roundTrip(hidden)
+	if r.Status() != STATUS_SUCCESS || CreateResponse(r[64:]).FileAttributes() != FILE_ATTRIBUTE_HIDDEN {
+		t.Fatalf("create hidden: status 0x%08x, attributes 0x%x", r.Status(), CreateResponse(r[64:]).FileAttributes())
+	}
+	if _, err := m.Stat("tmp.txt"); err != nil {
+		t.Fatal(err)
+	}
+	n := len(s.opens)
+	r = roundTrip(newRequest(SMB2_TREE_DISCONNECT, 101, s.sessionId, archiveTree.treeId, 4, 4))
+	if r.Status() != STATUS_SUCCESS || len(s.opens) != n-1 {
+		t.Errorf("opens of a disconnected tree: %d of %d left", len(s.opens), n)
+	}
+	r = roundTrip(newRequest(SMB2_TREE_DISCONNECT, 102, s.sessionId, dataTree.treeId, 4, 4))
+	if r.Status() != STATUS_SUCCESS || len(s.opens) != 0 {
+		t.Errorf("%d opens left", len(s.opens))
+	}
+	if _, err := m.Stat("tmp.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("file not deleted on close: %v", err)
+	}
+	if len(data.files) != 0 || len(archive.files) != 0 {
+		t.Errorf("sharing tables of %d and %d files", len(data.files), len(archive.files))
+	}
+}
+
+// failingFS fails the opens of directories, and the Stat of opened files.
+type failingFS struct{ VFS }
+
+func (f failingFS) Open(name string, flag int, perm fs.FileMode) (File, error) {
+	file, err := f.VFS.Open(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := file.Stat(); err == nil && fi.IsDir() {
+		file.Close()
+		return nil, fs.ErrPermission
+	}
+	return failingFile{file}, nil
+}
+
+type failingFile struct{ File }
+
+func (failingFile) Stat() (fs.FileInfo, error) {
+	return nil, errors.New("stat failed")
+}
+
+func TestCreateFailure(t *testing.T) {
+	m := NewMemFS()
+	f, err := m.Open("a.txt", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	srv := &Server{}
+	data := &Share{Name: "data", FS: failingFS{m}}
+	if err := srv.AddShare(data); err != nil {
+		t.Fatal(err)
+	}
+	c := srv.newConn(sv)
+	c.dialect = SMB2_DIALECT_302
+	s, err := c.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.state = sessionValid
+	tree := s.newTreeConnect(data)
+	go c.serve()
+
+	const shareAll = FILE_SHARE_READ | FILE_SHARE_WRITE | FILE_SHARE_DELETE
+	cases := []struct {
+		name    string
+		options CreateOptions
+		exists  bool
+	}{
+		{"new.txt", FILE_NON_DIRECTORY_FILE, false},
+		{"dir", FILE_DIRECTORY_FILE, false},
+		{"a.txt", 0, true},
+	}
+	for i, tc := range cases {
+		if err := writeFrame(cl, newCreateRequest(s.sessionId, tree.treeId, uint64(i+1), tc.name, GENERIC_READ, shareAll, FILE_OPEN_IF, tc.options)); err != nil {
+			t.Fatal(err)
+		}
+		r, err := readFrame(cl, directTCPMaxLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if PacketCodec(r).Status() == STATUS_SUCCESS {
+			t.Errorf("%s: open succeeded", tc.name)
+		}
+		if _, err := m.Stat(tc.name); (err == nil) != tc.exists {
+			t.Errorf("%s: exists %v, want %v", tc.name, err == nil, tc.exists)
+		}
+	}
+	if len(s.opens) != 0 || len(data.files) != 0 {
+		t.Errorf("%d opens, sharing table of %d files", len(s.opens), len(data.files))
+	}
+}
+
+func TestClose(t *testing.T) {
+	m := NewMemFS()
+	f, err := m.Open("a.txt", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte("hello"), 0)
+	f.Close()
+
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	srv := &Server{}
+	data := &Share{Name: "data", FS: m}
+	if err := srv.AddShare(data); err != nil {
+		t.Fatal(err)
+	}
+	c := srv.newConn(sv)
+	c.dialect = SMB2_DIALECT_302
+	s, err := c.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.state = sessionValid
+	tree := s.newTreeConnect(data)
+	other := s.newTreeConnect(data)
+	go c.serve()
+
+	messageId := uint64(0)
+	roundTrip := func(msg []byte) PacketCodec {
+		if err := writeFrame(cl, msg); err != nil {
+			t.Fatal(err)
+		}
+		r, err := readFrame(cl, directTCPMaxLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return PacketCodec(r)
+	}
+	create := func(name string, access AccessMask, shareAccess ShareAccess, disposition CreateDisposition, options CreateOptions) (FileId, uint32) {
+		messageId++
+		r := roundTrip(newCreateRequest(s.sessionId, tree.treeId, messageId, name, access, shareAccess, disposition, options))
+		if r.Status() != STATUS_SUCCESS {
+			return FileId{}, r.Status()
+		}
+		return CreateResponse(r[64:]).FileId(), r.Status()
+	}
+	closeFile := func(treeId uint32, id FileId, flags CloseFlags) PacketCodec {
+		messageId++
+		req := newRequest(SMB2_CLOSE, messageId, s.sessionId, treeId, 24, 24)
+		CloseRequest(req[64:]).SetFlags(flags)
+		CloseRequest(req[64:]).SetFileId(id)
+		return roundTrip(req)
+	}
+
+	const shareAll = FILE_SHARE_READ | FILE_SHARE_WRITE | FILE_SHARE_DELETE
+
+	// the sharing violation of an exclusive open ends with its close
+	id, status := create("a.txt", GENERIC_READ, 0, FILE_OPEN, 0)
+	if status != STATUS_SUCCESS {
+		t.Fatalf("open: status 0x%08x", status)
+	}
+	if _, status := create("a.txt", GENERIC_READ, shareAll, FILE_OPEN, 0); status != STATUS_SHARING_VIOLATION {
+		t.Errorf("open of exclusive: status 0x%08x", status)
+	}
+	if r := closeFile(other.treeId, id, 0); r.Status() != STATUS_FILE_CLOSED {
+		t.Errorf("close on another tree: status 0x%08x", r.Status())
+	}
+	r := closeFile(tree.treeId, id, SMB2_CLOSE_FLAG_POSTQUERY_ATTRIB)
+	if r.Status() != STATUS_SUCCESS {
+		t.Fatalf("close: status 0x%08x", r.Status())
+	}
+	resp := CloseResponse(r[64:])
+	if resp.IsInvalid() || resp.StructureSize() != 60 || resp.Flags() != SMB2_CLOSE_FLAG_POSTQUERY_ATTRIB {
+		t.Fatalf("StructureSize %d, flags %d", resp.StructureSize(), resp.Flags())
+	}
+	if resp.EndOfFile() != 5 || resp.FileAttributes() != FILE_ATTRIBUTE_NORMAL || resp.LastWriteTime().IsZero() {
+		t.Errorf("size %d, attributes 0x%x, LastWriteTime %v", resp.EndOfFile(), resp.FileAttributes(), resp.LastWriteTime())
+	}
+	if r := closeFile(tree.treeId, id, 0); r.Status() != STATUS_FILE_CLOSED {
+		t.Errorf("second close: status 0x%08x", r.Status())
+	}
+	id, status = create("a.txt", GENERIC_READ, shareAll, FILE_OPEN, 0)
+	if status != STATUS_SUCCESS {
+		t.Fatalf("open after close: status 0x%08x", status)
+	}
+	r = closeFile(tree.treeId, id, 0)
+	if r.Status() != STATUS_SUCCESS || CloseResponse(r[64:]).Flags() != 0 || CloseResponse(r[64:]).EndOfFile() != 0 {
+		t.Errorf("close without attributes: status 0x%08x, flags %d", r.Status(), CloseResponse(r[64:]).Flags())
+	}
+
+	// a file deleted on close is pending delete until its last open is closed
+	id, status = create("a.txt", DELETE, shareAll, FILE_OPEN, FILE_DELETE_ON_CLOSE)
+	if status != STATUS_SUCCESS {
+		t.Fatalf("open delete on close: status 0x%08x", status)
+	}
+	keep, status := create("a.txt", GENERIC_READ, shareAll, FILE_OPEN, 0)
+	if status != STATUS_SUCCESS {
+		t.Fatalf("second open: status 0x%08x", status)
+	}
+	if r := closeFile(tree.treeId, id, 0); r.Status() != STATUS_SUCCESS {
+		t.Fatalf("close: status 0x%08x", r.Status())
+	}
+	if _, status := create("a.txt", GENERIC_READ, shareAll, FILE_OPEN, 0); status != STATUS_DELETE_PENDING {
+		t.Errorf("open of deleted: status 0x%08x", status)
+	}
+	if r := closeFile(tree.treeId, keep, 0); r.Status() != STATUS_SUCCESS {
+		t.Fatalf("close: status 0x%08x", r.Status())
+	}
+	if _, err := m.Stat("a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("file not deleted on close: %v", err)
+	}
+	if _, status := create("a.txt", GENERIC_WRITE, shareAll, FILE_CREATE, 0); status != STATUS_SUCCESS {
+		t.Errorf("create after delete: status 0x%08x", status)
+	}
+	if len(s.opens) != 1 || len(data.files) != 1 {
+		t.Errorf("%d opens, sharing table of %d files", len(s.opens), len(data.files))
+	}
+}
+
+func TestSharingViolation(t *testing.T) {
+	cases := []struct {
+		granted     AccessMask
+		shared      ShareAccess
+		access      AccessMask
+		shareAccess ShareAccess
+		violation   bool
+	}{
+		{FILE_READ_DATA, FILE_SHARE_READ, FILE_READ_DATA, FILE_SHARE_READ, false},
+		{FILE_READ_DATA, FILE_SHARE_READ, FILE_WRITE_DATA, FILE_SHARE_READ, true},
+		{FILE_WRITE_DATA, FILE_SHARE_READ | FILE_SHARE_WRITE, FILE_READ_DATA, FILE_SHARE_READ, true},
+		{FILE_WRITE_DATA, FILE_SHARE_READ | FILE_SHARE_WRITE, FILE_READ_DATA, FILE_SHARE_WRITE, false},
+		{DELETE, FILE_SHARE_READ, FILE_READ_DATA, FILE_SHARE_READ, true},
+		{FILE_READ_DATA, FILE_SHARE_DELETE, DELETE, FILE_SHARE_READ, false},
+		{FILE_READ_ATTRIBUTES, 0, FILE_WRITE_DATA, 0, false},
+		{FILE_WRITE_DATA, 0, FILE_READ_ATTRIBUTES | SYNCHRONIZE, 0, false},
+	}
+	for _, tc := range cases {
+		f := &sharedFile{opens: []*open{{grantedAccess: tc.granted, shareAccess: tc.shared}}}
+		if got := f.sharingViolation(tc.access, tc.shareAccess); got != tc.violation {
+			t.Errorf("open 0x%x/%d then 0x%x/%d: violation %v", tc.granted, tc.shared, tc.access, tc.shareAccess, got)
+		}
+	}
+}
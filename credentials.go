@@ -0,0 +1,224 @@
+package simba
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/PichuChen/simba/ntlmssp"
+)
+
+// ErrAccountNotFound is returned by a CredentialStore that has no account
+// of the user.
+var ErrAccountNotFound = errors.New("credentials: account not found")
+
+// guestUserName is the user of guest sessions, anonymousSID and guestsSID
+// the well known SIDs of null sessions and of the guests.
+// MS-DTYP 2.4.2.4 Well-Known SID Structures
+const (
+	guestUserName = "Guest"
+	anonymousSID  = "S-1-5-7"
+	guestsSID     = "S-1-5-32-546"
+)
+
+type AccountFlags uint32
+
+const (
+	// ACCOUNT_DISABLED and ACCOUNT_LOCKED refuse the logon even with the
+	// right password.
+	ACCOUNT_DISABLED AccountFlags = 1 << iota
+	ACCOUNT_LOCKED
+	// ACCOUNT_PASSWORD_NOT_REQUIRED accounts log on with an empty password.
+	ACCOUNT_PASSWORD_NOT_REQUIRED
+)
+
+// Account is a user allowed to log on.
+type Account struct {
+	UserName string
+
+	// DomainName restricts the account to clients logging on to that
+	// domain, empty means any domain.
+	DomainName string
+
+	// NTHash is the MD4 of the UTF-16LE password, see ntlmssp.NTHash.
+	NTHash []byte
+
+	Flags AccountFlags
+
+	// SID and GroupSIDs identify the user and the groups it belongs to,
+	// in the S-1-5-21-... string form.
+	SID       string
+	GroupSIDs []string
+}
+
+// CredentialStore looks up the accounts of users logging on with NTLM.
+// Implementations must be safe for concurrent use.
+type CredentialStore interface {
+	// LookupAccount returns the account of user in domain, the domain
+	// the client sent which may be empty. It returns ErrAccountNotFound
+	// when there is none.
+	LookupAccount(user, domain string) (*Account, error)
+}
+
+// MemoryCredentials is a CredentialStore of fixed accounts, keyed by
+// accountKey. User and domain names are not case sensitive. It must not be
+// modified once the server uses it.
+type MemoryCredentials map[string]*Account
+
+// accountKey returns the key of the account of user in domain, an empty
+// domain being any domain: the upper cased "DOMAIN\user", or user alone.
+func accountKey(user, domain string) string {
+	if domain == "" {
+		return strings.ToUpper(user)
+	}
+	return strings.ToUpper(domain + `\` + user)
+}
+
+// NewMemoryCredentials returns the accounts of users by password.
+func NewMemoryCredentials(passwords map[string]string) MemoryCredentials {
+	m := MemoryCredentials{}
+	for user, password := range passwords {
+		m.Add(&Account{UserName: user, NTHash: ntlmssp.NTHash(password)})
+	}
+	return m
+}
+
+// Add adds the account a, replacing the account of the same user and
+// domain.
+func (m MemoryCredentials) Add(a *Account) {
+	m[accountKey(a.UserName, a.DomainName)] = a
+}
+
+// LookupAccount returns the account of user in domain, or else the
+// account of user in any domain.
+func (m MemoryCredentials) LookupAccount(user, domain string) (*Account, error) {
+	if domain != "" {
+		if a := m[accountKey(user, domain)]; a != nil {
+			return a, nil
+		}
+	}
+	if a := m[accountKey(user, "")]; a != nil {
+		return a, nil
+	}
+	return nil, ErrAccountNotFound
+}
+
+// ParseNTHashFile reads accounts from an htpasswd like file: one
+// "user:hash" line per account, where user may be prefixed with
+// "DOMAIN\" and hash is the NT hash in hex. Empty lines and lines
+// starting with # are ignored.
+func ParseNTHashFile(r io.Reader) (MemoryCredentials, error) {
+	m := MemoryCredentials{}
+	err := scanLines(r, func(line string) error {
+		fields := strings.Split(line, ":")
+		if len(fields) != 2 {
+			return fmt.Errorf("want user:hash")
+		}
+		hash, err := parseNTHash(fields[1])
+		if err != nil {
+			return err
+		}
+		a := &Account{UserName: fields[0], NTHash: hash}
+		if i := strings.IndexByte(a.UserName, '\\'); i >= 0 {
+			a.DomainName, a.UserName = a.UserName[:i], a.UserName[i+1:]
+		}
+		m.Add(a)
+		return nil
+	})
+	return m, err
+}
+
+// ParseSmbpasswd reads the accounts of a Samba smbpasswd file, whose
+// lines are
+//
+//	user:uid:LM hash:NT hash:[account flags]:LCT-last change time:
+//
+// Accounts without an NT hash can not log on, except the ones flagged N
+// with NO PASSWORD in place of the hash.
+func ParseSmbpasswd(r io.Reader) (MemoryCredentials, error) {
+	m := MemoryCredentials{}
+	err := scanLines(r, func(line string) error {
+		fields := strings.Split(line, ":")
+		if len(fields) < 5 {
+			return fmt.Errorf("want user:uid:lmhash:nthash:[flags]")
+		}
+		a := &Account{UserName: fields[0]}
+		flags := strings.Trim(fields[4], "[]")
+		if strings.ContainsRune(flags, 'D') {
+			a.Flags |= ACCOUNT_DISABLED
+		}
+		if strings.ContainsRune(flags, 'L') {
+			a.Flags |= ACCOUNT_LOCKED
+		}
+		if strings.ContainsRune(flags, 'N') {
+			a.Flags |= ACCOUNT_PASSWORD_NOT_REQUIRED
+		}
+
+		switch nt := fields[3]; {
+		case strings.HasPrefix(nt, "NO PASSWORD"):
+			if a.Flags&ACCOUNT_PASSWORD_NOT_REQUIRED != 0 {
+				a.NTHash = ntlmssp.NTHash("")
+			}
+		case strings.Trim(nt, "X") == "":
+			// no NT hash
+		default:
+			hash, err := parseNTHash(nt)
+			if err != nil {
+				return err
+			}
+			a.NTHash = hash
+		}
+		m.Add(a)
+		return nil
+	})
+	return m, err
+}
+
+// LoadNTHashFile reads the accounts of the file at path, see
+// ParseNTHashFile.
+func LoadNTHashFile(path string) (MemoryCredentials, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseNTHashFile(f)
+}
+
+// LoadSmbpasswd reads the accounts of the smbpasswd file at path, see
+// ParseSmbpasswd.
+func LoadSmbpasswd(path string) (MemoryCredentials, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSmbpasswd(f)
+}
+
+// scanLines calls fn with every line of r that is not empty or a comment.
+func scanLines(r io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return fmt.Errorf("credentials: line %d: %w", n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func parseNTHash(s string) ([]byte, error) {
+	hash, err := hex.DecodeString(s)
+	if err != nil || len(hash) != 16 {
+		return nil, fmt.Errorf("invalid NT hash %q", s)
+	}
+	return hash, nil
+}
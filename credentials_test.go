@@ -0,0 +1,136 @@
+package simba
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// memoryCredentials returns a MemoryCredentials of accounts.
+func memoryCredentials(accounts ...*Account) MemoryCredentials {
+	m := MemoryCredentials{}
+	for _, a := range accounts {
+		m.Add(a)
+	}
+	return m
+}
+
+func TestMemoryCredentials(t *testing.T) {
+	m := memoryCredentials(
+		&Account{UserName: "Alice", NTHash: make([]byte, 16)},
+		&Account{UserName: "Bob", DomainName: "CORP", NTHash: make([]byte, 16)},
+		&Account{UserName: "Bob", NTHash: make([]byte, 16), Flags: ACCOUNT_DISABLED},
+	)
+	if len(m) != 3 || m[`CORP\BOB`] == nil || m["BOB"] == nil {
+		t.Fatalf("keys of %v", m)
+	}
+	cases := []struct {
+		user   string
+		domain string
+		found  bool
+	}{
+		{"alice", "", true},
+		{"ALICE", "ANY", true},
+		{"bob", "corp", true},
+		{"bob", "", true},
+		{"carol", "", false},
+	}
+	for _, c := range cases {
+		a, err := m.LookupAccount(c.user, c.domain)
+		if c.found && (err != nil || !strings.EqualFold(a.UserName, c.user)) {
+			t.Errorf("LookupAccount(%q, %q) = %v, %v", c.user, c.domain, a, err)
+		}
+		if !c.found && !errors.Is(err, ErrAccountNotFound) {
+			t.Errorf("LookupAccount(%q, %q) error = %v, want ErrAccountNotFound", c.user, c.domain, err)
+		}
+	}
+
+	// the account of the domain is preferred to the one of any domain
+	if a, _ := m.LookupAccount("BOB", "corp"); a == nil || a.DomainName != "CORP" {
+		t.Errorf("LookupAccount() = %v, want the account of CORP", a)
+	}
+	if a, _ := m.LookupAccount("bob", "OTHER"); a == nil || a.Flags != ACCOUNT_DISABLED {
+		t.Errorf("LookupAccount() = %v, want the account of any domain", a)
+	}
+
+	passwords := NewMemoryCredentials(map[string]string{"User": "Password"})
+	a, err := passwords.LookupAccount("user", "Domain")
+	if err != nil || hex.EncodeToString(a.NTHash) != "a4f49c406510bdcab6824ee7c30fd852" {
+		t.Errorf("LookupAccount() = %v, %v", a, err)
+	}
+}
+
+func TestParseNTHashFile(t *testing.T) {
+	input := "" +
+		"# user:nthash\n" +
+		"User:a4f49c406510bdcab6824ee7c30fd852\n" +
+		"\n" +
+		"CORP\\Bob:31D6CFE0D16AE931B73C59D7E0C089C0\n"
+	m, err := ParseNTHashFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 {
+		t.Fatalf("%d accounts, want 2", len(m))
+	}
+	expected := map[string]map[string]interface{}{
+		"USER":     {"UserName": "User", "DomainName": "", "NTHash": "a4f49c406510bdcab6824ee7c30fd852"},
+		`CORP\BOB`: {"UserName": "Bob", "DomainName": "CORP", "NTHash": "31d6cfe0d16ae931b73c59d7e0c089c0"},
+	}
+	for key, e := range expected {
+		a := m[key]
+		if a == nil || a.UserName != e["UserName"] || a.DomainName != e["DomainName"] || hex.EncodeToString(a.NTHash) != e["NTHash"] {
+			t.Errorf("account %s = %+v, want %v", key, a, e)
+		}
+	}
+
+	for name, input := range map[string]string{
+		"no hash":    "User\n",
+		"short hash": "User:a4f49c40\n",
+		"not hex":    "User:zzf49c406510bdcab6824ee7c30fd852\n",
+	} {
+		if _, err := ParseNTHashFile(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: ParseNTHashFile() succeeded", name)
+		}
+	}
+}
+
+func TestParseSmbpasswd(t *testing.T) {
+	input := "" +
+		"user:1000:XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX:A4F49C406510BDCAB6824EE7C30FD852:[U          ]:LCT-63A1B2C3:\n" +
+		"gone:1001:XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX:A4F49C406510BDCAB6824EE7C30FD852:[DU         ]:LCT-63A1B2C3:\n" +
+		"open:1002:NO PASSWORDXXXXXXXXXXXXXXXXXXXXX:NO PASSWORDXXXXXXXXXXXXXXXXXXXXX:[NU         ]:LCT-63A1B2C3:\n" +
+		"nohash:1003:XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX:XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX:[U          ]:LCT-63A1B2C3:\n" +
+		"locked:1004:XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX:A4F49C406510BDCAB6824EE7C30FD852:[LU         ]:LCT-63A1B2C3:\n"
+	m, err := ParseSmbpasswd(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		user   string
+		flags  AccountFlags
+		ntHash string
+	}{
+		{"user", 0, "a4f49c406510bdcab6824ee7c30fd852"},
+		{"gone", ACCOUNT_DISABLED, "a4f49c406510bdcab6824ee7c30fd852"},
+		{"open", ACCOUNT_PASSWORD_NOT_REQUIRED, "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"nohash", 0, ""},
+		{"locked", ACCOUNT_LOCKED, "a4f49c406510bdcab6824ee7c30fd852"},
+	}
+	for _, c := range cases {
+		a, err := m.LookupAccount(c.user, "")
+		if err != nil {
+			t.Errorf("LookupAccount(%q) error: %v", c.user, err)
+			continue
+		}
+		if a.Flags != c.flags || hex.EncodeToString(a.NTHash) != c.ntHash {
+			t.Errorf("%s: flags 0x%x, NT hash %x, want 0x%x, %v", c.user, a.Flags, a.NTHash, c.flags, c.ntHash)
+		}
+	}
+
+	if _, err := ParseSmbpasswd(strings.NewReader("user:1000:XXXX\n")); err == nil {
+		t.Errorf("ParseSmbpasswd() accepted a truncated line")
+	}
+}
@@ -0,0 +1,55 @@
+package simba
+
+import "testing"
+
+func TestSelectDialect(t *testing.T) {
+	cases := []struct {
+		name     string
+		server   []Dialect
+		client   []Dialect
+		expected Dialect
+		ok       bool
+	}{
+		{
+			name:     "default picks highest",
+			client:   []Dialect{SMB2_DIALECT_311, SMB2_DIALECT_302, SMB2_DIALECT_30, SMB2_DIALECT_21, SMB2_DIALECT_202},
+			expected: SMB2_DIALECT_311,
+			ok:       true,
+		},
+		{
+			name:     "client without 3.1.1",
+			client:   []Dialect{SMB2_DIALECT_202, SMB2_DIALECT_21, SMB2_DIALECT_30},
+			expected: SMB2_DIALECT_30,
+			ok:       true,
+		},
+		{
+			name:     "server restricted",
+			server:   []Dialect{SMB2_DIALECT_21, SMB2_DIALECT_202},
+			client:   []Dialect{SMB2_DIALECT_311, SMB2_DIALECT_302, SMB2_DIALECT_21},
+			expected: SMB2_DIALECT_21,
+			ok:       true,
+		},
+		{
+			name:   "no common dialect",
+			server: []Dialect{SMB2_DIALECT_311},
+			client: []Dialect{SMB2_DIALECT_202, SMB2_DIALECT_21},
+		},
+		{
+			name:   "unknown dialects are ignored",
+			client: []Dialect{0x0222, SMB2_DIALECT_2xx},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv := &Server{Dialects: c.server}
+			actual, ok := srv.selectDialect(c.client)
+			if ok != c.ok {
+				t.Fatalf("selectDialect() ok = %v, want %v", ok, c.ok)
+			}
+			if actual != c.expected {
+				t.Errorf("selectDialect() = %v, want %v", actual, c.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package simba
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"sync"
+)
+
+// MS-SMB2 2.1 Transport
+// Over Direct TCP every SMB2 message is prefixed with a 4-byte header: one
+// zero byte followed by the 24-bit big-endian length of the message.
+const (
+	directTCPHeaderSize = 4
+	directTCPMaxLength  = 0x00FFFFFF
+
+	// frameOverhead is the room kept above the negotiated sizes for the
+	// SMB2 header, the fixed part of the request and any transform header.
+	frameOverhead = 64 * 1024
+
+	// negotiateFrameSize bounds the frames accepted before the dialect and
+	// MaxTransactSize have been negotiated.
+	negotiateFrameSize = 64 * 1024
+)
+
+var (
+	errFrameEmpty    = errors.New("direct tcp: zero-length frame")
+	errFrameTooLarge = errors.New("direct tcp: frame too large")
+)
+
+// Frame buffers are pooled in power-of-two size classes, from 4 KiB up to
+// the largest length the 24-bit header can express.
+const (
+	minFrameBufferShift = 12
+	maxFrameBufferShift = 24
+)
+
+var frameBufferPools [maxFrameBufferShift - minFrameBufferShift + 1]sync.Pool
+
+func frameBufferClass(n int) int {
+	if n <= 1<<minFrameBufferShift {
+		return 0
+	}
+	return bits.Len(uint(n-1)) - minFrameBufferShift
+}
+
+// getFrameBuffer returns a buffer of length n, reusing a pooled one when
+// possible. The buffer must be released with putFrameBuffer.
+func getFrameBuffer(n int) []byte {
+	class := frameBufferClass(n)
+	if b, ok := frameBufferPools[class].Get().(*[]byte); ok {
+		return (*b)[:n]
+	}
+	return make([]byte, n, 1<<(class+minFrameBufferShift))
+}
+
+func putFrameBuffer(b []byte) {
+	c := cap(b)
+	if c < 1<<minFrameBufferShift || c&(c-1) != 0 {
+		// not allocated by getFrameBuffer
+		return
+	}
+	class := frameBufferClass(c)
+	if class >= len(frameBufferPools) {
+		return
+	}
+	b = b[:0]
+	frameBufferPools[class].Put(&b)
+}
+
+// readFrame reads exactly one Direct TCP frame from r and returns its
+// payload. Frames that are empty or longer than max are rejected without
+// reading the payload, since the stream can not be trusted afterwards.
+func readFrame(r io.Reader, max int) ([]byte, error) {
+	var hdr [directTCPHeaderSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	if hdr[0] != 0 {
+		return nil, fmt.Errorf("direct tcp: invalid header 0x%02x", hdr[0])
+	}
+
+	n := int(hdr[1])<<16 | int(hdr[2])<<8 | int(hdr[3])
+	if n == 0 {
+		return nil, errFrameEmpty
+	}
+	if n > max {
+		return nil, fmt.Errorf("%w: %d > %d", errFrameTooLarge, n, max)
+	}
+
+	buf := getFrameBuffer(n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		putFrameBuffer(buf)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// writeFrame writes msg to w prefixed with its Direct TCP header.
+func writeFrame(w io.Writer, msg []byte) error {
+	if len(msg) > directTCPMaxLength {
+		return fmt.Errorf("%w: %d", errFrameTooLarge, len(msg))
+	}
+	pkt := make([]byte, directTCPHeaderSize+len(msg))
+	pkt[1] = byte(len(msg) >> 16)
+	pkt[2] = byte(len(msg) >> 8)
+	pkt[3] = byte(len(msg))
+	copy(pkt[directTCPHeaderSize:], msg)
+	_, err := w.Write(pkt)
+	return err
+}
@@ -0,0 +1,99 @@
+package simba
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadFrame(t *testing.T) {
+	large := bytes.Repeat([]byte{0xfe}, 70000)
+	cases := []struct {
+		name     string
+		input    []byte
+		max      int
+		expected []byte
+		err      error
+	}{
+		{
+			name:     "small",
+			input:    append([]byte{0x00, 0x00, 0x00, 0x04}, 0xfe, 'S', 'M', 'B'),
+			max:      negotiateFrameSize,
+			expected: []byte{0xfe, 'S', 'M', 'B'},
+		},
+		{
+			name:     "larger than 1KiB",
+			input:    append([]byte{0x00, 0x01, 0x11, 0x70}, large...),
+			max:      negotiateFrameSize * 2,
+			expected: large,
+		},
+		{
+			name:  "zero length",
+			input: []byte{0x00, 0x00, 0x00, 0x00},
+			max:   negotiateFrameSize,
+			err:   errFrameEmpty,
+		},
+		{
+			name:  "too large",
+			input: append([]byte{0x00, 0x01, 0x11, 0x70}, large...),
+			max:   negotiateFrameSize,
+			err:   errFrameTooLarge,
+		},
+		{
+			name:  "truncated",
+			input: []byte{0x00, 0x00, 0x00, 0x10, 0xfe, 'S', 'M', 'B'},
+			max:   negotiateFrameSize,
+			err:   io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// read one byte at a time to exercise split TCP segments
+			actual, err := readFrame(iotest.OneByteReader(bytes.NewReader(c.input)), c.max)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Errorf("readFrame() error = %v, want %v", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readFrame() error = %v", err)
+			}
+			if !bytes.Equal(actual, c.expected) {
+				t.Errorf("readFrame() = %d bytes, want %d bytes", len(actual), len(c.expected))
+			}
+			putFrameBuffer(actual)
+		})
+	}
+}
+
+func TestReadFrameSequence(t *testing.T) {
+	var stream bytes.Buffer
+	msgs := [][]byte{
+		bytes.Repeat([]byte{0x01}, 10),
+		bytes.Repeat([]byte{0x02}, 5000),
+		bytes.Repeat([]byte{0x03}, 3),
+	}
+	for _, m := range msgs {
+		if err := writeFrame(&stream, m); err != nil {
+			t.Fatalf("writeFrame() error = %v", err)
+		}
+	}
+
+	for i, m := range msgs {
+		actual, err := readFrame(&stream, negotiateFrameSize)
+		if err != nil {
+			t.Fatalf("readFrame() #%d error = %v", i, err)
+		}
+		if !bytes.Equal(actual, m) {
+			t.Errorf("readFrame() #%d = %x, want %x", i, actual, m)
+		}
+		putFrameBuffer(actual)
+	}
+	if _, err := readFrame(&stream, negotiateFrameSize); err != io.EOF {
+		t.Errorf("readFrame() at end error = %v, want %v", err, io.EOF)
+	}
+}
@@ -0,0 +1,343 @@
+package simba
+
+import (
+	"fmt"
+	"log"
+)
+
+// commandHandler serves request p of session s on tree connect tc, s and tc
+// are nil unless the command requires them.
+type commandHandler func(c *conn, p PacketCodec, s *session, tc *treeConnect) error
+
+// command describes how a request of an SMB2 command is verified before it
+// is dispatched to its handler.
+type command struct {
+	handler commandHandler
+
+	// structureSizes are the StructureSize values a request may have.
+	// MS-SMB2 2.2 Message Syntax
+	structureSizes []uint16
+
+	// needsSession and needsTree are set for commands that act on an
+	// established session, or on a tree connected on it.
+	needsSession bool
+	needsTree    bool
+
+	// fileIdOffset is the offset of the FileId in the request of commands
+	// acting on an open, zero for the others.
+	fileIdOffset int
+}
+
+// commands are the SMB2 commands by Command.
+// MS-SMB2 2.2.1 SMB2 Packet Header
+var commands = [...]command{
+	SMB2_NEGOTIATE:       {serveNegotiate, []uint16{36}, false, false, 0},
+	SMB2_SESSION_SETUP:   {serveSessionSetup, []uint16{25}, false, false, 0},
+	SMB2_LOGOFF:          {serveLogoff, []uint16{4}, true, false, 0},
+	SMB2_TREE_CONNECT:    {serveTreeConnect, []uint16{9}, true, false, 0},
+	SMB2_TREE_DISCONNECT: {serveTreeDisconnect, []uint16{4}, true, true, 0},
+	SMB2_CREATE:          {serveCreate, []uint16{57}, true, true, 0},
+	SMB2_CLOSE:           {serveClose, []uint16{24}, true, true, 8},
+	SMB2_FLUSH:           {serveNotSupported, []uint16{24}, true, true, 8},
+	SMB2_READ:            {serveNotSupported, []uint16{49}, true, true, 16},
+	SMB2_WRITE:           {serveNotSupported, []uint16{49}, true, true, 16},
+	SMB2_LOCK:            {serveNotSupported, []uint16{48}, true, true, 8},
+	SMB2_IOCTL:           {serveNotSupported, []uint16{57}, true, true, 8},
+	SMB2_CANCEL:          {serveCancel, []uint16{4}, false, false, 0},
+	SMB2_ECHO:            {serveEcho, []uint16{4}, false, false, 0},
+	SMB2_QUERY_DIRECTORY: {serveNotSupported, []uint16{33}, true, true, 8},
+	SMB2_CHANGE_NOTIFY:   {serveNotSupported, []uint16{32}, true, true, 8},
+	SMB2_QUERY_INFO:      {serveNotSupported, []uint16{41}, true, true, 24},
+	SMB2_SET_INFO:        {serveNotSupported, []uint16{33}, true, true, 16},
+	// an oplock break acknowledgment, or a lease break acknowledgment
+	// which has no FileId
+	SMB2_OPLOCK_BREAK: {serveNotSupported, []uint16{24, 36}, true, false, 0},
+}
+
+// compound is the state of a compounded request being served.
+// MS-SMB2 3.3.5.2.7 Handling Compounded Requests
+type compound struct {
+	// responses are sent chained once the last request is served.
+	responses []pendingResponse
+
+	// sessionId, treeId and fileId are the ones of the previous request,
+	// inherited by a related request. status is the one of the CREATE the
+	// related requests act on.
+	sessionId uint64
+	treeId    uint32
+	fileId    FileId
+	status    uint32
+}
+
+// pendingResponse is a response to a request of a compound, with how it
+// is to be signed or encrypted.
+type pendingResponse struct {
+	s       *session
+	req     PacketCodec
+	pkt     []byte
+	sign    bool
+	encrypt bool
+}
+
+// add queues response r, it is the previous operation of the next request.
+func (cp *compound) add(r pendingResponse) {
+	cp.responses = append(cp.responses, r)
+	resp := PacketCodec(r.pkt)
+	cp.sessionId = resp.SessionId()
+	cp.treeId = resp.TreeId()
+	switch {
+	case resp.Command() == SMB2_CREATE:
+		cp.status = resp.Status()
+		if resp.Status() == STATUS_SUCCESS {
+			cp.fileId = CreateResponse(resp[64:]).FileId()
+		}
+	case r.req.Flags()&SMB2_FLAGS_RELATED_OPERATIONS == 0:
+		cp.status = STATUS_SUCCESS
+	}
+}
+
+// related reports whether p is a related request of a compound.
+func (c *conn) related(p PacketCodec) bool {
+	return c.compound != nil && p.Flags()&SMB2_FLAGS_RELATED_OPERATIONS != 0
+}
+
+// serveMessage serves the requests of SMB2 message r, a compound of them
+// when NextCommand is set. An error ends the connection.
+// MS-SMB2 3.3.5.2.7 Handling Compounded Requests
+func (c *conn) serveMessage(r PacketCodec) error {
+	if r.NextCommand() == 0 {
+		return c.serveRequest(r, r.SessionId(), r.TreeId())
+	}
+
+	c.compound = &compound{}
+	defer func() { c.compound = nil }()
+	for off := 0; off < len(r); {
+		p := r[off:]
+		if off > 0 && (p.IsInvalid() || p.ProtocolId()[0] != 0xfe) {
+			log.Printf("serveMessage: invalid request at %d of a compound", off)
+			break
+		}
+		next := int(p.NextCommand())
+		if next != 0 {
+			// each request is 8 byte aligned and followed by another
+			if next%8 != 0 || next < 64 || next+64 > len(p) {
+				log.Printf("serveMessage: invalid NextCommand %d", next)
+				if err := c.writeErrorResponse(p[:64], STATUS_INVALID_PARAMETER); err != nil {
+					return err
+				}
+				break
+			}
+			p = p[:next]
+		}
+
+		// MS-SMB2 3.3.5.2.7.2 Handling Compounded Related Requests
+		sessionId, treeId := p.SessionId(), p.TreeId()
+		if p.Flags()&SMB2_FLAGS_RELATED_OPERATIONS != 0 {
+			if off == 0 {
+				// there is no previous request to inherit from
+				if err := c.writeErrorResponse(p, STATUS_INVALID_PARAMETER); err != nil {
+					return err
+				}
+				break
+			}
+			if sessionId == 1<<64-1 {
+				sessionId = c.compound.sessionId
+			}
+			if treeId == 1<<32-1 {
+				treeId = c.compound.treeId
+			}
+		}
+		if err := c.serveRequest(p, sessionId, treeId); err != nil {
+			return err
+		}
+		if next == 0 {
+			break
+		}
+		off += next
+	}
+	return c.sendCompound()
+}
+
+// serveRequest checks that request p of session sessionId on tree treeId
+// is encrypted, or signed, as they require and dispatches it. The ids
+// differ from the ones of p when it inherits them in a compound.
+func (c *conn) serveRequest(p PacketCodec, sessionId uint64, treeId uint32) error {
+	var err error
+	if !c.encrypted {
+		if err = c.checkEncryption(p, sessionId, treeId); err == nil {
+			err = c.checkSignature(p, sessionId)
+		}
+	}
+	// the signature covers the ids as they were sent
+	p.SetSessionId(sessionId)
+	p.SetTreeId(treeId)
+	if err != nil {
+		log.Printf("serveRequest: %v", err)
+		return c.writeErrorResponse(p, STATUS_ACCESS_DENIED)
+	}
+	if c.related(p) && c.compound.status>>30 == 3 {
+		// the CREATE failed, so do the requests on the open it did not
+		// make; other failures leave the open to the following ones, as
+		// Windows does, so that a CLOSE still closes it
+		return c.writeErrorResponse(p, c.compound.status)
+	}
+	return c.dispatch(p)
+}
+
+// sendCompound sends the responses to a compound chained in one message,
+// each one 8 byte aligned and signed on its own. The message is encrypted
+// if one of them is.
+// MS-SMB2 3.3.4.1.3 Sending Compounded Responses
+func (c *conn) sendCompound() error {
+	responses := c.compound.responses
+	if len(responses) == 0 {
+		return nil
+	}
+	var encryptSession *session
+	for _, r := range responses {
+		if r.encrypt {
+			encryptSession = r.s
+			break
+		}
+	}
+
+	msg := []byte{}
+	for i, r := range responses {
+		resp := PacketCodec(r.pkt)
+		if i > 0 && r.req.Flags()&SMB2_FLAGS_RELATED_OPERATIONS != 0 {
+			resp.SetFlags(resp.Flags() | SMB2_FLAGS_RELATED_OPERATIONS)
+		}
+		if i < len(responses)-1 {
+			resp = append(resp, make([]byte, (8-len(resp)%8)%8)...)
+			resp.SetNextCommand(uint32(len(resp)))
+		}
+		if encryptSession == nil && r.sign {
+			if err := signMessage(c.signingAlgorithm(), r.s.keys.SigningKey, resp); err != nil {
+				return err
+			}
+		}
+		msg = append(msg, resp...)
+	}
+	return c.writeResponse(encryptSession, responses[0].req, msg, encryptSession != nil)
+}
+
+// dispatch verifies request p and routes it to the handler of its command.
+// Malformed requests, and requests of a session or a tree that does not
+// exist, are failed with an ERROR response. An error ends the connection.
+// MS-SMB2 3.3.5.2 Receiving Any Message
+func (c *conn) dispatch(p PacketCodec) error {
+	if c.dialect == 0 || c.dialect == SMB2_DIALECT_2xx {
+		// the client must negotiate a dialect first
+		if p.Command() != SMB2_NEGOTIATE {
+			return fmt.Errorf("%v before negotiate", p.Command())
+		}
+	}
+	if int(p.Command()) >= len(commands) {
+		log.Printf("dispatch: unknown command %d", p.Command())
+		return c.writeErrorResponse(p, STATUS_INVALID_PARAMETER)
+	}
+	cmd := commands[p.Command()]
+	if !cmd.validStructureSize(p) {
+		log.Printf("dispatch: invalid %v request", p.Command())
+		return c.writeErrorResponse(p, STATUS_INVALID_PARAMETER)
+	}
+	if cmd.fileIdOffset != 0 && c.compound != nil {
+		c.compound.inheritFileId(p, cmd.fileIdOffset, c.related(p))
+	}
+
+	var s *session
+	var tc *treeConnect
+	if cmd.needsSession {
+		var status uint32
+		// MS-SMB2 3.3.5.2.9 Verifying the Session
+		if s, status = c.verifySession(p); status != STATUS_SUCCESS {
+			return c.writeErrorResponse(p, status)
+		}
+	}
+	if cmd.needsTree {
+		// MS-SMB2 3.3.5.2.11 Verifying the Tree Connect
+		var ok bool
+		if tc, ok = s.treeConnects[p.TreeId()]; !ok {
+			return c.writeErrorResponse(p, STATUS_NETWORK_NAME_DELETED)
+		}
+	}
+	return cmd.handler(c, p, s, tc)
+}
+
+// inheritFileId sets the FileId at offset off of the body of request p to
+// the one of the previous request when p is related and its FileId is all
+// ones, the FileId becomes the one of the next request.
+// MS-SMB2 3.3.5.2.7.2 Handling Compounded Related Requests
+func (cp *compound) inheritFileId(p PacketCodec, off int, related bool) {
+	b := p[64+off : 64+off+16]
+	if related && getFileId(b) == (FileId{1<<64 - 1, 1<<64 - 1}) {
+		putFileId(b, cp.fileId)
+	}
+	cp.fileId = getFileId(b)
+}
+
+// validStructureSize reports whether the StructureSize of request p is one
+// of the command and its fixed part is complete. An odd StructureSize
+// counts the first byte of the variable part, which may be empty.
+func (cmd command) validStructureSize(p PacketCodec) bool {
+	if len(p) < 64+2 {
+		return false
+	}
+	size := le.Uint16(p[64:66])
+	for _, s := range cmd.structureSizes {
+		if size == s {
+			return len(p)-64 >= int(s&^1)
+		}
+	}
+	return false
+}
+
+func serveNegotiate(c *conn, p PacketCodec, s *session, tc *treeConnect) error {
+	return c.handleNegotiate(p, NegotiateRequest(p[64:]))
+}
+
+func serveSessionSetup(c *conn, p PacketCodec, s *session, tc *treeConnect) error {
+	return c.handleSessionSetup(p, SessionSetupRequest(p[64:]))
+}
+
+func serveLogoff(c *conn, p PacketCodec, s *session, tc *treeConnect) error {
+	return c.handleLogoff(p, s)
+}
+
+func serveTreeConnect(c *conn, p PacketCodec, s *session, tc *treeConnect) error {
+	return c.handleTreeConnect(p, s, TreeConnectRequest(p[64:]))
+}
+
+func serveTreeDisconnect(c *conn, p PacketCodec, s *session, tc *treeConnect) error {
+	return c.handleTreeDisconnect(p, s, tc)
+}
+
+func serveCreate(c *conn, p PacketCodec, s *session, tc *treeConnect) error {
+	return c.handleCreate(p, s, tc, CreateRequest(p[64:]))
+}
+
+func serveClose(c *conn, p PacketCodec, s *session, tc *treeConnect) error {
+	return c.handleClose(p, s, tc, CloseRequest(p[64:]))
+}
+
+// serveNotSupported fails requests of the commands the server does not
+// implement.
+func serveNotSupported(c *conn, p PacketCodec, s *session, tc *treeConnect) error {
+	return c.writeErrorResponse(p, STATUS_NOT_SUPPORTED)
+}
+
+// serveCancel ignores CANCEL requests: no request is processed
+// asynchronously, so there is none to cancel. CANCEL has no response.
+// MS-SMB2 3.3.5.16 Receiving an SMB2 CANCEL Request
+func serveCancel(c *conn, p PacketCodec, s *session, tc *treeConnect) error {
+	return nil
+}
+
+// serveEcho answers an ECHO request.
+// MS-SMB2 3.3.5.17 Receiving an SMB2 ECHO Request
+func serveEcho(c *conn, p PacketCodec, s *session, tc *treeConnect) error {
+	pkt := []byte{}
+	pkt = append(pkt, newResponseHeader(p, STATUS_SUCCESS)...)
+	pkt = append(pkt, NewEchoResponse()...)
+	return c.sendResponse(c.sessions[p.SessionId()], p, pkt)
+}
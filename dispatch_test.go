@@ -0,0 +1,287 @@
+package simba
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+// newRequest returns a request of command on session id and tree treeId,
+// with a body of size bytes starting with structureSize.
+func newRequest(command Command, messageId, id uint64, treeId uint32, structureSize uint16, size int) []byte {
+	hdr := PacketCodec(make([]byte, 64))
+	hdr.SetProtocolId()
+	hdr.SetStructureSize()
+	hdr.SetCommand(command)
+	hdr.SetMessageId(messageId)
+	hdr.SetSessionId(id)
+	hdr.SetTreeId(treeId)
+	body := make([]byte, size)
+	le.PutUint16(body, structureSize)
+	return append(hdr, body...)
+}
+
+func TestDispatch(t *testing.T) {
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	srv := &Server{}
+	c := srv.newConn(sv)
+	c.dialect = SMB2_DIALECT_302
+	s, err := c.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.state = sessionValid
+	s.treeConnects[1] = &treeConnect{treeId: 1}
+	go c.serve()
+
+	roundTrip := func(msg []byte) PacketCodec {
+		if err := writeFrame(cl, msg); err != nil {
+			t.Fatal(err)
+		}
+		r, err := readFrame(cl, directTCPMaxLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return PacketCodec(r)
+	}
+
+	cases := []struct {
+		name    string
+		request []byte
+		status  uint32
+	}{
+		{"echo", newRequest(SMB2_ECHO, 1, 0, 0, 4, 4), STATUS_SUCCESS},
+		{"echo structure size", newRequest(SMB2_ECHO, 2, 0, 0, 5, 4), STATUS_INVALID_PARAMETER},
+		{"unknown command", newRequest(Command(0x13), 3, s.sessionId, 0, 4, 4), STATUS_INVALID_PARAMETER},
+		{"truncated read", newRequest(SMB2_READ, 4, s.sessionId, 1, 49, 10), STATUS_INVALID_PARAMETER},
+		{"no body", newRequest(SMB2_FLUSH, 5, s.sessionId, 1, 24, 24)[:64], STATUS_INVALID_PARAMETER},
+		{"unknown session", newRequest(SMB2_TREE_CONNECT, 6, s.sessionId+1, 0, 9, 8), STATUS_USER_SESSION_DELETED},
+		{"unknown tree", newRequest(SMB2_CREATE, 7, s.sessionId, 2, 57, 56), STATUS_NETWORK_NAME_DELETED},
+		{"not supported", newRequest(SMB2_FLUSH, 8, s.sessionId, 1, 24, 24), STATUS_NOT_SUPPORTED},
+		{"lease break acknowledgment", newRequest(SMB2_OPLOCK_BREAK, 9, s.sessionId, 0, 36, 36), STATUS_NOT_SUPPORTED},
+		{"malformed spnego", newSessionSetupRequest(0, 12, []byte{0x60, 0x01, 0x00}), STATUS_INVALID_PARAMETER},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := PacketCodec(tc.request)
+			r := roundTrip(tc.request)
+			if r.Status() != tc.status {
+				t.Errorf("status 0x%08x, want 0x%08x", r.Status(), tc.status)
+			}
+			if r.Command() != p.Command() || r.MessageId() != p.MessageId() {
+				t.Errorf("response to %v %d, want %v %d", r.Command(), r.MessageId(), p.Command(), p.MessageId())
+			}
+			if tc.status != STATUS_SUCCESS && ErrorResponse(r[64:]).StructureSize() != 9 {
+				t.Errorf("ERROR response StructureSize %d", ErrorResponse(r[64:]).StructureSize())
+			}
+		})
+	}
+
+	// CANCEL has no response, the next one answers the ECHO
+	if err := writeFrame(cl, newRequest(SMB2_CANCEL, 10, 0, 0, 4, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if r := roundTrip(newRequest(SMB2_ECHO, 11, 0, 0, 4, 4)); r.Command() != SMB2_ECHO || r.MessageId() != 11 {
+		t.Errorf("response to %v %d after CANCEL", r.Command(), r.MessageId())
+	}
+}
+
+func TestDispatchBeforeNegotiate(t *testing.T) {
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	c := (&Server{}).newConn(sv)
+	go c.serve()
+
+	if err := writeFrame(cl, newRequest(SMB2_ECHO, 0, 0, 0, 4, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readFrame(cl, directTCPMaxLength); err == nil {
+		t.Errorf("ECHO before NEGOTIATE was answered, want the connection closed")
+	}
+}
+
+// newCompound chains requests in one message, signed with key unless it is
+// nil.
+func newCompound(key []byte, requests ...[]byte) []byte {
+	msg := []byte{}
+	for i, req := range requests {
+		p := PacketCodec(append([]byte{}, req...))
+		if i < len(requests)-1 {
+			p = append(p, make([]byte, (8-len(p)%8)%8)...)
+			p.SetNextCommand(uint32(len(p)))
+		}
+		if key != nil {
+			signMessage(SMB2_SIGNING_ALGORITHM_AES_CMAC, key, p)
+		}
+		msg = append(msg, p...)
+	}
+	return msg
+}
+
+// related marks request p as related to the previous one of a compound,
+// inheriting its ids.
+func related(p []byte) []byte {
+	r := PacketCodec(p)
+	r.SetFlags(r.Flags() | SMB2_FLAGS_RELATED_OPERATIONS)
+	r.SetSessionId(1<<64 - 1)
+	r.SetTreeId(1<<32 - 1)
+	return r
+}
+
+func TestDispatchCompound(t *testing.T) {
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	srv := &Server{}
+	sh := &Share{Name: "data", FS: NewMemFS()}
+	if err := srv.AddShare(sh); err != nil {
+		t.Fatal(err)
+	}
+	c := srv.newConn(sv)
+	c.dialect = SMB2_DIALECT_302
+	s, err := c.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 16)
+	s.state = sessionValid
+	s.signingRequired = true
+	s.keys.SigningKey = key
+	tree := s.newTreeConnect(sh)
+	go c.serve()
+
+	allOnes := FileId{1<<64 - 1, 1<<64 - 1}
+	queryInfo := newRequest(SMB2_QUERY_INFO, 3, s.sessionId, tree.treeId, 41, 41)
+	putFileId(queryInfo[64+24:], allOnes)
+	closeFile := newRequest(SMB2_CLOSE, 4, s.sessionId, tree.treeId, 24, 24)
+	putFileId(closeFile[64+8:], allOnes)
+	badNext := PacketCodec(newRequest(SMB2_ECHO, 1, 0, 0, 4, 4))
+	badNext.SetNextCommand(68)
+
+	cases := []struct {
+		name     string
+		request  []byte
+		statuses []uint32
+		related  bool
+		opens    int
+	}{
+		{
+			"unrelated",
+			newCompound(key,
+				newRequest(SMB2_ECHO, 1, 0, 0, 4, 4),
+				newCreateRequest(s.sessionId, tree.treeId, 2, "a.txt", GENERIC_READ, FILE_SHARE_READ, FILE_OPEN_IF, 0)),
+			[]uint32{STATUS_SUCCESS, STATUS_SUCCESS},
+			false,
+			1,
+		},
+		{
+			// as sent by Windows after TREE_CONNECT
+			"create query info close",
+			newCompound(key,
+				newCreateRequest(s.sessionId, tree.treeId, 2, "", FILE_READ_ATTRIBUTES, FILE_SHARE_READ, FILE_OPEN, 0),
+				related(queryInfo), related(closeFile)),
+			[]uint32{STATUS_SUCCESS, STATUS_NOT_SUPPORTED, STATUS_SUCCESS},
+			true,
+			1,
+		},
+		{
+			"failed create",
+			newCompound(key,
+				newCreateRequest(s.sessionId, tree.treeId, 2, "none", GENERIC_READ, FILE_SHARE_READ, FILE_OPEN, 0),
+				related(queryInfo), related(closeFile)),
+			[]uint32{STATUS_OBJECT_NAME_NOT_FOUND, STATUS_OBJECT_NAME_NOT_FOUND, STATUS_OBJECT_NAME_NOT_FOUND},
+			true,
+			1,
+		},
+		{
+			"related first",
+			newCompound(nil, related(newRequest(SMB2_ECHO, 1, 0, 0, 4, 4)), newRequest(SMB2_ECHO, 2, 0, 0, 4, 4)),
+			[]uint32{STATUS_INVALID_PARAMETER},
+			false,
+			1,
+		},
+		{
+			"unaligned next command",
+			append(badNext, newRequest(SMB2_ECHO, 2, 0, 0, 4, 4)...),
+			[]uint32{STATUS_INVALID_PARAMETER},
+			false,
+			1,
+		},
+		{
+			"tree disconnect",
+			newCompound(key,
+				newCreateRequest(s.sessionId, tree.treeId, 2, "a.txt", GENERIC_READ, FILE_SHARE_READ, FILE_OPEN, 0),
+				related(newRequest(SMB2_TREE_DISCONNECT, 3, s.sessionId, tree.treeId, 4, 4))),
+			[]uint32{STATUS_SUCCESS, STATUS_SUCCESS},
+			true,
+			0,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := writeFrame(cl, tc.request); err != nil {
+				t.Fatal(err)
+			}
+			r, err := readFrame(cl, directTCPMaxLength)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var statuses []uint32
+			for off := 0; ; {
+				resp := PacketCodec(r[off:])
+				next := int(resp.NextCommand())
+				if next != 0 {
+					if next%8 != 0 || next > len(resp) {
+						t.Fatalf("NextCommand %d of %d bytes", next, len(resp))
+					}
+					resp = resp[:next]
+				}
+				statuses = append(statuses, resp.Status())
+				if resp.SessionId() == s.sessionId {
+					if !verifySignature(SMB2_SIGNING_ALGORITHM_AES_CMAC, key, resp) {
+						t.Errorf("response %d is not signed", len(statuses))
+					}
+					if resp.TreeId() != tree.treeId {
+						t.Errorf("response %d on tree 0x%x", len(statuses), resp.TreeId())
+					}
+				}
+				if related := resp.Flags()&SMB2_FLAGS_RELATED_OPERATIONS != 0; related != (tc.related && off > 0) {
+					t.Errorf("response %d related %v", len(statuses), related)
+				}
+				if next == 0 {
+					break
+				}
+				off += next
+			}
+			if fmt.Sprintf("%x", statuses) != fmt.Sprintf("%x", tc.statuses) {
+				t.Errorf("statuses %x, want %x", statuses, tc.statuses)
+			}
+			if len(s.opens) != tc.opens {
+				t.Errorf("%d opens, want %d", len(s.opens), tc.opens)
+			}
+		})
+	}
+	if len(s.treeConnects) != 0 {
+		t.Errorf("tree still connected")
+	}
+}
+
+func TestCompoundInheritFileId(t *testing.T) {
+	id := FileId{1, 2}
+	cp := &compound{fileId: id}
+	p := PacketCodec(newRequest(SMB2_CLOSE, 1, 1, 1, 24, 24))
+	putFileId(p[64+8:], FileId{1<<64 - 1, 1<<64 - 1})
+	cp.inheritFileId(p, 8, true)
+	if getFileId(p[64+8:]) != id {
+		t.Errorf("FileId %v, want %v", getFileId(p[64+8:]), id)
+	}
+
+	// the FileId of an unrelated request is kept and passed on
+	other := FileId{3, 4}
+	putFileId(p[64+8:], other)
+	cp.inheritFileId(p, 8, false)
+	if getFileId(p[64+8:]) != other || cp.fileId != other {
+		t.Errorf("FileId %v, %v, want %v", getFileId(p[64+8:]), cp.fileId, other)
+	}
+}
@@ -0,0 +1,52 @@
+package simba
+
+import (
+	"encoding/binary"
+)
+
+// MS-SMB2 2.2.28 SMB2 ECHO Request
+type EchoRequest []byte
+
+func (p EchoRequest) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 4
+	if len(p) < 4 {
+		return true
+	}
+
+	return false
+}
+
+func (p EchoRequest) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p EchoRequest) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 4)
+}
+
+// MS-SMB2 2.2.29 SMB2 ECHO Response
+type EchoResponse []byte
+
+func (p EchoResponse) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 4
+	if len(p) < 4 {
+		return true
+	}
+
+	return false
+}
+
+func (p EchoResponse) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p EchoResponse) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 4)
+}
+
+// NewEchoResponse returns an ECHO response.
+func NewEchoResponse() EchoResponse {
+	r := EchoResponse(make([]byte, 4))
+	r.SetStructureSize()
+	return r
+}
@@ -1,43 +1,135 @@
-package simba
-
-type Cipher uint16
-
-const (
-	// MS-SMB2 - v20211006 page 48/481
-	SMB2_ENCRYPTION_AES128_CCM Cipher = 0x0001
-	SMB2_ENCRYPTION_AES128_GCM Cipher = 0x0002
-	SMB2_ENCRYPTION_AES256_CCM Cipher = 0x0003
-	SMB2_ENCRYPTION_AES256_GCM Cipher = 0x0004
-)
-
-func (c Cipher) String() string {
-	switch c {
-	case SMB2_ENCRYPTION_AES128_CCM:
-		return "SMB2_ENCRYPTION_AES128_CCM"
-	case SMB2_ENCRYPTION_AES128_GCM:
-		return "SMB2_ENCRYPTION_AES128_GCM"
-	case SMB2_ENCRYPTION_AES256_CCM:
-		return "SMB2_ENCRYPTION_AES256_CCM"
-	case SMB2_ENCRYPTION_AES256_GCM:
-		return "SMB2_ENCRYPTION_AES256_GCM"
-	}
-	return "Unknown"
-}
-
-type EncryptionCapability []byte
-
-func (e EncryptionCapability) CipherCount() uint16 {
-	return uint16(le.Uint16(e[0:2]))
-}
-
-func (e EncryptionCapability) SetCipherCount(c uint16) {
-	le.PutUint16(e[0:2], c)
-}
-
-func (e EncryptionCapability) Ciphers() []Cipher {
-	var res []Cipher
-	for i := 0; i < int(e.CipherCount()); i++ {
-		res = append(res, Cipher(le.Uint16(e[4+i*2:6+i*2])))
-	}
-	return res
-}
+package simba
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+)
+
+type Cipher uint16
+
+const (
+	// MS-SMB2 - v20211006 page 48/481
+	SMB2_ENCRYPTION_AES128_CCM Cipher = 0x0001
+	SMB2_ENCRYPTION_AES128_GCM Cipher = 0x0002
+	SMB2_ENCRYPTION_AES256_CCM Cipher = 0x0003
+	SMB2_ENCRYPTION_AES256_GCM Cipher = 0x0004
+)
+
+func (c Cipher) String() string {
+	switch c {
+	case SMB2_ENCRYPTION_AES128_CCM:
+		return "SMB2_ENCRYPTION_AES128_CCM"
+	case SMB2_ENCRYPTION_AES128_GCM:
+		return "SMB2_ENCRYPTION_AES128_GCM"
+	case SMB2_ENCRYPTION_AES256_CCM:
+		return "SMB2_ENCRYPTION_AES256_CCM"
+	case SMB2_ENCRYPTION_AES256_GCM:
+		return "SMB2_ENCRYPTION_AES256_GCM"
+	}
+	return "Unknown"
+}
+
+type EncryptionCapability []byte
+
+func (e EncryptionCapability) CipherCount() uint16 {
+	return uint16(le.Uint16(e[0:2]))
+}
+
+func (e EncryptionCapability) SetCipherCount(c uint16) {
+	le.PutUint16(e[0:2], c)
+}
+
+func (e EncryptionCapability) Ciphers() []Cipher {
+	var res []Cipher
+	for i := 0; i < int(e.CipherCount()) && 4+i*2 <= len(e); i++ {
+		res = append(res, Cipher(le.Uint16(e[2+i*2:4+i*2])))
+	}
+	return res
+}
+
+func (e EncryptionCapability) SetCiphers(v []Cipher) {
+	e.SetCipherCount(uint16(len(v)))
+	for i, c := range v {
+		le.PutUint16(e[2+i*2:4+i*2], uint16(c))
+	}
+}
+
+// NewEncryptionCapability returns the SMB2_ENCRYPTION_CAPABILITIES data
+// listing ciphers.
+// MS-SMB2 2.2.3.1.2 SMB2_ENCRYPTION_CAPABILITIES
+func NewEncryptionCapability(ciphers []Cipher) EncryptionCapability {
+	e := EncryptionCapability(make([]byte, 2+2*len(ciphers)))
+	e.SetCiphers(ciphers)
+	return e
+}
+
+// newAEAD returns the authenticated cipher c keyed with key. SMB uses 11
+// bytes nonces with CCM, 12 bytes with GCM, and 16 bytes tags.
+func newAEAD(c Cipher, key []byte) (cipher.AEAD, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	switch c {
+	case SMB2_ENCRYPTION_AES128_CCM, SMB2_ENCRYPTION_AES256_CCM:
+		return newCCM(b, 11, 16)
+	case SMB2_ENCRYPTION_AES128_GCM, SMB2_ENCRYPTION_AES256_GCM:
+		return cipher.NewGCM(b)
+	}
+	return nil, fmt.Errorf("unknown cipher %v", c)
+}
+
+// encryptMessage returns msg encrypted with key and wrapped in a
+// TRANSFORM_HEADER of session sessionId. nonce must never be used twice
+// with key.
+// MS-SMB2 3.1.4.3 Encrypting the Message
+func encryptMessage(c Cipher, key, nonce []byte, sessionId uint64, msg []byte) ([]byte, error) {
+	aead, err := newAEAD(c, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%v nonce of %d bytes", c, len(nonce))
+	}
+
+	hdr := TransformHeader(make([]byte, transformHeaderSize, transformHeaderSize+len(msg)+aead.Overhead()))
+	hdr.SetProtocolId()
+	hdr.SetNonce(nonce)
+	hdr.SetOriginalMessageSize(uint32(len(msg)))
+	hdr.SetFlags(SMB2_TRANSFORM_FLAG_ENCRYPTED)
+	hdr.SetSessionId(sessionId)
+
+	sealed := aead.Seal(hdr, nonce, msg, hdr.AssociatedData())
+	// the tag is carried in the Signature field, not after the message
+	tag := sealed[len(sealed)-aead.Overhead():]
+	pkt := TransformHeader(sealed[:len(sealed)-aead.Overhead()])
+	pkt.SetSignature(tag)
+	return pkt, nil
+}
+
+// decryptMessage returns the message wrapped in the TRANSFORM_HEADER pkt,
+// decrypted with key into dst.
+// MS-SMB2 3.3.5.2.1.1 Decrypting the Message
+func decryptMessage(c Cipher, key []byte, pkt TransformHeader, dst []byte) ([]byte, error) {
+	if pkt.IsInvalid() {
+		return nil, fmt.Errorf("invalid transform header")
+	}
+	if pkt.Flags() != SMB2_TRANSFORM_FLAG_ENCRYPTED {
+		return nil, fmt.Errorf("transform header flags 0x%04x", pkt.Flags())
+	}
+	// a compressed message may be shorter than an SMB2 header
+	if int(pkt.OriginalMessageSize()) != len(pkt.Message()) || len(pkt.Message()) == 0 {
+		return nil, fmt.Errorf("transform header message size %d, got %d bytes",
+			pkt.OriginalMessageSize(), len(pkt.Message()))
+	}
+
+	aead, err := newAEAD(c, key)
+	if err != nil {
+		return nil, err
+	}
+	sealed := make([]byte, 0, len(pkt.Message())+aead.Overhead())
+	sealed = append(sealed, pkt.Message()...)
+	sealed = append(sealed, pkt.Signature()...)
+	return aead.Open(dst[:0], pkt.Nonce()[:aead.NonceSize()], sealed, pkt.AssociatedData())
+}
@@ -0,0 +1,214 @@
+package simba
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"testing"
+)
+
+// echoRequest is an ECHO request of session 0x1122334455667788.
+const echoRequest = "fe534d4240000000000000000d00000000000000000000000500000000000000000000000000000088776655443322110000000000000000000000000000000004000000"
+
+func TestEncryptMessage(t *testing.T) {
+	cases := []struct {
+		cipher    Cipher
+		keyLength int
+		nonceSize int
+	}{
+		{SMB2_ENCRYPTION_AES128_CCM, 16, 11},
+		{SMB2_ENCRYPTION_AES128_GCM, 16, 12},
+		{SMB2_ENCRYPTION_AES256_CCM, 32, 11},
+		{SMB2_ENCRYPTION_AES256_GCM, 32, 12},
+	}
+
+	msg, _ := hex.DecodeString(echoRequest)
+	for _, c := range cases {
+		t.Run(c.cipher.String(), func(t *testing.T) {
+			key := bytes.Repeat([]byte{0x42}, c.keyLength)
+			nonce := bytes.Repeat([]byte{0x01}, c.nonceSize)
+
+			pkt, err := encryptMessage(c.cipher, key, nonce, 0x1122334455667788, msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hdr := TransformHeader(pkt)
+			if hdr.IsInvalid() || len(pkt) != transformHeaderSize+len(msg) {
+				t.Fatalf("invalid transform header of %d bytes", len(pkt))
+			}
+			if hdr.OriginalMessageSize() != uint32(len(msg)) || hdr.SessionId() != 0x1122334455667788 ||
+				hdr.Flags() != SMB2_TRANSFORM_FLAG_ENCRYPTED {
+				t.Errorf("unexpected transform header %x", pkt[:transformHeaderSize])
+			}
+			if bytes.Equal(hdr.Message(), msg) {
+				t.Errorf("message is not encrypted")
+			}
+
+			plain, err := decryptMessage(c.cipher, key, hdr, make([]byte, len(msg)))
+			if err != nil {
+				t.Fatalf("decryptMessage() error: %v", err)
+			}
+			if !bytes.Equal(plain, msg) {
+				t.Errorf("decryptMessage() = %x, want %x", plain, msg)
+			}
+
+			// the session id is authenticated
+			hdr.SetSessionId(0x1122334455667789)
+			if _, err := decryptMessage(c.cipher, key, hdr, nil); err == nil {
+				t.Errorf("decryptMessage() of a modified header succeeded")
+			}
+			hdr.SetSessionId(0x1122334455667788)
+			hdr.Message()[0] ^= 0x01
+			if _, err := decryptMessage(c.cipher, key, hdr, nil); err == nil {
+				t.Errorf("decryptMessage() of a modified message succeeded")
+			}
+		})
+	}
+}
+
+func TestSessionNextNonce(t *testing.T) {
+	s := &session{}
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		nonce, err := s.nextNonce(11)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(nonce) != 11 {
+			t.Fatalf("nonce of %d bytes", len(nonce))
+		}
+		if seen[string(nonce)] {
+			t.Fatalf("nonce %x repeated", nonce)
+		}
+		seen[string(nonce)] = true
+	}
+
+	s.nonce = 1<<64 - 2
+	if _, err := s.nextNonce(12); err != nil {
+		t.Fatalf("last nonce: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.nextNonce(12); err != errNonceExhausted {
+			t.Errorf("nextNonce() after the last nonce: %v", err)
+		}
+	}
+}
+
+func TestConnEncryption(t *testing.T) {
+	key := bytes.Repeat([]byte{0x24}, 16)
+	s := &session{
+		sessionId:   0x1122334455667788,
+		encryptData: true,
+		// the client encrypts with the key the server decrypts with
+		keys: SessionKeys{SigningKey: key, EncryptionKey: key, DecryptionKey: key},
+	}
+	c := &conn{
+		dialect:  SMB2_DIALECT_311,
+		cipherId: SMB2_ENCRYPTION_AES128_GCM,
+		sessions: map[uint64]*session{s.sessionId: s},
+	}
+
+	msg, _ := hex.DecodeString(echoRequest)
+	if err := c.checkEncryption(msg, s.sessionId, 0); err == nil {
+		t.Errorf("checkEncryption() accepted an unencrypted ECHO")
+	}
+	setup := PacketCodec(append([]byte{}, msg...))
+	setup.SetCommand(SMB2_SESSION_SETUP)
+	if err := c.checkEncryption(setup, s.sessionId, 0); err != nil {
+		t.Errorf("checkEncryption() rejected SESSION_SETUP: %v", err)
+	}
+	if c.shouldEncrypt(s, setup) {
+		t.Errorf("SESSION_SETUP response encrypted before the client has the keys")
+	}
+
+	first, err := c.encrypt(s, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.encrypt(s, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(TransformHeader(first).Nonce(), TransformHeader(second).Nonce()) {
+		t.Errorf("nonce repeated")
+	}
+
+	plain, err := c.decrypt(TransformHeader(first))
+	if err != nil {
+		t.Fatalf("decrypt() error: %v", err)
+	}
+	if !bytes.Equal(plain, msg) {
+		t.Errorf("decrypt() = %x, want %x", []byte(plain), msg)
+	}
+	putFrameBuffer(plain)
+
+	unknown := TransformHeader(append([]byte{}, first...))
+	unknown.SetSessionId(1)
+	if _, err := c.decrypt(unknown); err == nil {
+		t.Errorf("decrypt() of an unknown session succeeded")
+	}
+
+	// a message of another session wrapped with the keys of s
+	other := PacketCodec(append([]byte{}, msg...))
+	other.SetSessionId(1)
+	wrapped, err := c.encrypt(s, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.decrypt(TransformHeader(wrapped)); err == nil {
+		t.Errorf("decrypt() accepted a message of another session")
+	}
+}
+
+func TestServeEncryptedCompressed(t *testing.T) {
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	c := (&Server{}).newConn(sv)
+	c.dialect = SMB2_DIALECT_311
+	c.cipherId = SMB2_ENCRYPTION_AES128_GCM
+	c.compressionIds = []CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_LZ77}
+	s, err := c.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := bytes.Repeat([]byte{0x24}, 16)
+	s.state = sessionValid
+	s.keys = SessionKeys{SigningKey: key, EncryptionKey: key, DecryptionKey: key}
+	go c.serve()
+
+	// an ECHO padded so that it compresses
+	echo := PacketCodec(newRequest(SMB2_ECHO, 1, s.sessionId, 0, 4, 4096))
+	send := func(msg []byte) {
+		compressed, ok := compressMessage(c.compressionIds, false, msg)
+		if !ok {
+			t.Fatal("ECHO not compressed")
+		}
+		pkt, err := encryptMessage(c.cipherId, key, make([]byte, 12), s.sessionId, compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeFrame(cl, pkt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(echo)
+	r, err := readFrame(cl, directTCPMaxLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := decryptMessage(c.cipherId, key, TransformHeader(r), make([]byte, len(r)))
+	if err != nil {
+		t.Fatalf("response not encrypted: %v", err)
+	}
+	if resp := PacketCodec(plain); resp.Command() != SMB2_ECHO || resp.Status() != STATUS_SUCCESS {
+		t.Errorf("response %v 0x%08x", resp.Command(), resp.Status())
+	}
+
+	// the compressed message of another session drops the connection
+	echo.SetSessionId(s.sessionId + 1)
+	send(echo)
+	if _, err := readFrame(cl, directTCPMaxLength); err == nil {
+		t.Errorf("message of another session answered")
+	}
+}
@@ -1,6 +1,51 @@
-package simba
-
-const (
-	STATUS_MORE_PROCESSING_REQUIRED uint32 = 0xC0000016
-	STATUS_LOGON_FAILURE            uint32 = 0xC000006D
-)
+package simba
+
+import "fmt"
+
+const (
+	// MS-ERREF - v20230920 2.3.1 NTSTATUS Values
+	STATUS_SUCCESS                  uint32 = 0x00000000
+	STATUS_UNSUCCESSFUL             uint32 = 0xC0000001
+	STATUS_INVALID_PARAMETER        uint32 = 0xC000000D
+	STATUS_ACCESS_DENIED            uint32 = 0xC0000022
+	STATUS_MORE_PROCESSING_REQUIRED uint32 = 0xC0000016
+	STATUS_OBJECT_NAME_INVALID      uint32 = 0xC0000033
+	STATUS_OBJECT_NAME_NOT_FOUND    uint32 = 0xC0000034
+	STATUS_OBJECT_NAME_COLLISION    uint32 = 0xC0000035
+	STATUS_OBJECT_PATH_NOT_FOUND    uint32 = 0xC000003A
+	STATUS_SHARING_VIOLATION        uint32 = 0xC0000043
+	STATUS_DELETE_PENDING           uint32 = 0xC0000056
+	STATUS_LOGON_FAILURE            uint32 = 0xC000006D
+	STATUS_ACCOUNT_DISABLED         uint32 = 0xC0000072
+	STATUS_DISK_FULL                uint32 = 0xC000007F
+	STATUS_MEDIA_WRITE_PROTECTED    uint32 = 0xC00000A2
+	STATUS_BAD_IMPERSONATION_LEVEL  uint32 = 0xC00000A5
+	STATUS_FILE_IS_A_DIRECTORY      uint32 = 0xC00000BA
+	STATUS_NOT_SUPPORTED            uint32 = 0xC00000BB
+	STATUS_NETWORK_NAME_DELETED     uint32 = 0xC00000C9
+	STATUS_BAD_NETWORK_NAME         uint32 = 0xC00000CC
+	STATUS_DIRECTORY_NOT_EMPTY      uint32 = 0xC0000101
+	STATUS_NOT_A_DIRECTORY          uint32 = 0xC0000103
+	STATUS_CANNOT_DELETE            uint32 = 0xC0000121
+	STATUS_FILE_CLOSED              uint32 = 0xC0000128
+	STATUS_USER_SESSION_DELETED     uint32 = 0xC0000203
+	STATUS_ACCOUNT_LOCKED_OUT       uint32 = 0xC0000234
+	STATUS_NETWORK_SESSION_EXPIRED  uint32 = 0xC000035C
+
+	STATUS_SMB_NO_PREAUTH_INTEGRITY_HASH_OVERLAP uint32 = 0xC05D0000
+)
+
+// statusError is an error that is answered to the client with its NTSTATUS.
+type statusError uint32
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("ntstatus 0x%08x", uint32(e))
+}
+
+// errorStatus returns the NTSTATUS to answer err with.
+func errorStatus(err error) uint32 {
+	if e, ok := err.(statusError); ok {
+		return uint32(e)
+	}
+	return STATUS_INVALID_PARAMETER
+}
@@ -0,0 +1,64 @@
+package simba
+
+import (
+	"encoding/binary"
+)
+
+// MS-SMB2 2.2.2 SMB2 ERROR Response
+type ErrorResponse []byte
+
+func (p ErrorResponse) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 9
+	if len(p) < 8 {
+		return true
+	}
+
+	return false
+}
+
+func (p ErrorResponse) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p ErrorResponse) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 9)
+}
+
+// SMB 3.1.1 only, otherwise MUST be 0
+func (p ErrorResponse) ErrorContextCount() uint8 {
+	return p[2]
+}
+
+func (p ErrorResponse) SetErrorContextCount(v uint8) {
+	p[2] = v
+}
+
+func (p ErrorResponse) ByteCount() uint32 {
+	return binary.LittleEndian.Uint32(p[4:8])
+}
+
+func (p ErrorResponse) SetByteCount(v uint32) {
+	binary.LittleEndian.PutUint32(p[4:8], v)
+}
+
+func (p ErrorResponse) ErrorData() []byte {
+	count := p.ByteCount()
+	if uint32(len(p)-8) < count {
+		return nil
+	}
+	return p[8 : 8+count]
+}
+
+func (p ErrorResponse) SetErrorData(v []byte) {
+	p.SetByteCount(uint32(len(v)))
+	copy(p[8:], v)
+}
+
+// NewErrorResponse returns an ERROR response without error data. The
+// response carries a single zero byte since ByteCount of zero still requires
+// one byte of ErrorData on the wire.
+func NewErrorResponse() ErrorResponse {
+	r := ErrorResponse(make([]byte, 9))
+	r.SetStructureSize()
+	return r
+}
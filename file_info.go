@@ -0,0 +1,252 @@
+package simba
+
+import (
+	"io/fs"
+	"time"
+)
+
+// allocationUnit is the cluster size AllocationSize is rounded up to.
+const allocationUnit = 4096
+
+// getFiletime decodes a FILETIME, the count of 100 nanoseconds since
+// January 1, 1601 UTC. Zero is no time.
+// MS-DTYP 2.3.3 FILETIME
+func getFiletime(b []byte) time.Time {
+	dateTime := le.Uint64(b)
+	if dateTime == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(dateTime-116444736000000000)*100)
+}
+
+func putFiletime(b []byte, v time.Time) {
+	if v.IsZero() {
+		le.PutUint64(b, 0)
+		return
+	}
+	le.PutUint64(b, uint64(v.UnixNano()/100+116444736000000000))
+}
+
+// allocationSize returns the space allocated to the file described by fi.
+func allocationSize(fi fs.FileInfo) int64 {
+	if fi.IsDir() {
+		return 0
+	}
+	return (fi.Size() + allocationUnit - 1) / allocationUnit * allocationUnit
+}
+
+// fileTimes returns the creation, last access, last write and change time
+// of the file described by fi. fs.FileInfo only has the modification time,
+// it stands for all of them.
+func fileTimes(fi fs.FileInfo) (creation, access, write, change time.Time) {
+	t := fi.ModTime()
+	return t, t, t, t
+}
+
+// MS-FSCC 2.4.7 FileBasicInformation
+type FileBasicInformation []byte
+
+// NewFileBasicInformation returns the FileBasicInformation of the file
+// described by fi.
+func NewFileBasicInformation(fi fs.FileInfo) FileBasicInformation {
+	p := FileBasicInformation(make([]byte, 40))
+	creation, access, write, change := fileTimes(fi)
+	p.SetCreationTime(creation)
+	p.SetLastAccessTime(access)
+	p.SetLastWriteTime(write)
+	p.SetChangeTime(change)
+	p.SetFileAttributes(fileAttributes(fi))
+	return p
+}
+
+func (p FileBasicInformation) IsInvalid() bool {
+	// MS-FSCC, 40 bytes with the 4 bytes Reserved
+	return len(p) < 40
+}
+
+func (p FileBasicInformation) CreationTime() time.Time {
+	return getFiletime(p[0:8])
+}
+
+func (p FileBasicInformation) SetCreationTime(v time.Time) {
+	putFiletime(p[0:8], v)
+}
+
+func (p FileBasicInformation) LastAccessTime() time.Time {
+	return getFiletime(p[8:16])
+}
+
+func (p FileBasicInformation) SetLastAccessTime(v time.Time) {
+	putFiletime(p[8:16], v)
+}
+
+func (p FileBasicInformation) LastWriteTime() time.Time {
+	return getFiletime(p[16:24])
+}
+
+func (p FileBasicInformation) SetLastWriteTime(v time.Time) {
+	putFiletime(p[16:24], v)
+}
+
+func (p FileBasicInformation) ChangeTime() time.Time {
+	return getFiletime(p[24:32])
+}
+
+func (p FileBasicInformation) SetChangeTime(v time.Time) {
+	putFiletime(p[24:32], v)
+}
+
+func (p FileBasicInformation) FileAttributes() FileAttributes {
+	return FileAttributes(le.Uint32(p[32:36]))
+}
+
+func (p FileBasicInformation) SetFileAttributes(v FileAttributes) {
+	le.PutUint32(p[32:36], uint32(v))
+}
+
+// MS-FSCC 2.4.41 FileStandardInformation
+type FileStandardInformation []byte
+
+// NewFileStandardInformation returns the FileStandardInformation of the
+// file described by fi.
+func NewFileStandardInformation(fi fs.FileInfo) FileStandardInformation {
+	p := FileStandardInformation(make([]byte, 24))
+	p.SetAllocationSize(allocationSize(fi))
+	if !fi.IsDir() {
+		p.SetEndOfFile(fi.Size())
+	}
+	p.SetNumberOfLinks(1)
+	p.SetDirectory(fi.IsDir())
+	return p
+}
+
+func (p FileStandardInformation) IsInvalid() bool {
+	return len(p) < 22
+}
+
+func (p FileStandardInformation) AllocationSize() int64 {
+	return int64(le.Uint64(p[0:8]))
+}
+
+func (p FileStandardInformation) SetAllocationSize(v int64) {
+	le.PutUint64(p[0:8], uint64(v))
+}
+
+func (p FileStandardInformation) EndOfFile() int64 {
+	return int64(le.Uint64(p[8:16]))
+}
+
+func (p FileStandardInformation) SetEndOfFile(v int64) {
+	le.PutUint64(p[8:16], uint64(v))
+}
+
+func (p FileStandardInformation) NumberOfLinks() uint32 {
+	return le.Uint32(p[16:20])
+}
+
+func (p FileStandardInformation) SetNumberOfLinks(v uint32) {
+	le.PutUint32(p[16:20], v)
+}
+
+func (p FileStandardInformation) DeletePending() bool {
+	return p[20] != 0
+}
+
+func (p FileStandardInformation) SetDeletePending(v bool) {
+	p[20] = boolByte(v)
+}
+
+func (p FileStandardInformation) Directory() bool {
+	return p[21] != 0
+}
+
+func (p FileStandardInformation) SetDirectory(v bool) {
+	p[21] = boolByte(v)
+}
+
+// MS-FSCC 2.4.29 FileNetworkOpenInformation
+type FileNetworkOpenInformation []byte
+
+// NewFileNetworkOpenInformation returns the FileNetworkOpenInformation of
+// the file described by fi.
+func NewFileNetworkOpenInformation(fi fs.FileInfo) FileNetworkOpenInformation {
+	p := FileNetworkOpenInformation(make([]byte, 56))
+	creation, access, write, change := fileTimes(fi)
+	p.SetCreationTime(creation)
+	p.SetLastAccessTime(access)
+	p.SetLastWriteTime(write)
+	p.SetChangeTime(change)
+	p.SetAllocationSize(allocationSize(fi))
+	if !fi.IsDir() {
+		p.SetEndOfFile(fi.Size())
+	}
+	p.SetFileAttributes(fileAttributes(fi))
+	return p
+}
+
+func (p FileNetworkOpenInformation) IsInvalid() bool {
+	return len(p) < 52
+}
+
+func (p FileNetworkOpenInformation) CreationTime() time.Time {
+	return getFiletime(p[0:8])
+}
+
+func (p FileNetworkOpenInformation) SetCreationTime(v time.Time) {
+	putFiletime(p[0:8], v)
+}
+
+func (p FileNetworkOpenInformation) LastAccessTime() time.Time {
+	return getFiletime(p[8:16])
+}
+
+func (p FileNetworkOpenInformation) SetLastAccessTime(v time.Time) {
+	putFiletime(p[8:16], v)
+}
+
+func (p FileNetworkOpenInformation) LastWriteTime() time.Time {
+	return getFiletime(p[16:24])
+}
+
+func (p FileNetworkOpenInformation) SetLastWriteTime(v time.Time) {
+	putFiletime(p[16:24], v)
+}
+
+func (p FileNetworkOpenInformation) ChangeTime() time.Time {
+	return getFiletime(p[24:32])
+}
+
+func (p FileNetworkOpenInformation) SetChangeTime(v time.Time) {
+	putFiletime(p[24:32], v)
+}
+
+func (p FileNetworkOpenInformation) AllocationSize() int64 {
+	return int64(le.Uint64(p[32:40]))
+}
+
+func (p FileNetworkOpenInformation) SetAllocationSize(v int64) {
+	le.PutUint64(p[32:40], uint64(v))
+}
+
+func (p FileNetworkOpenInformation) EndOfFile() int64 {
+	return int64(le.Uint64(p[40:48]))
+}
+
+func (p FileNetworkOpenInformation) SetEndOfFile(v int64) {
+	le.PutUint64(p[40:48], uint64(v))
+}
+
+func (p FileNetworkOpenInformation) FileAttributes() FileAttributes {
+	return FileAttributes(le.Uint32(p[48:52]))
+}
+
+func (p FileNetworkOpenInformation) SetFileAttributes(v FileAttributes) {
+	le.PutUint32(p[48:52], uint32(v))
+}
+
+func boolByte(v bool) byte {
+	if v {
+		return 1
+	}
+	return 0
+}
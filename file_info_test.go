@@ -0,0 +1,106 @@
+package simba
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFiletime(t *testing.T) {
+	b := make([]byte, 8)
+	putFiletime(b, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC))
+	if le.Uint64(b) != 116444736000000000 {
+		t.Errorf("FILETIME of the epoch %d", le.Uint64(b))
+	}
+	v := time.Date(2024, 2, 29, 12, 30, 15, 123456700, time.UTC)
+	putFiletime(b, v)
+	if got := getFiletime(b); !got.Equal(v) {
+		t.Errorf("getFiletime %v, want %v", got, v)
+	}
+	putFiletime(b, time.Time{})
+	if le.Uint64(b) != 0 || !getFiletime(b).IsZero() {
+		t.Errorf("zero time %d", le.Uint64(b))
+	}
+}
+
+func TestFileInformation(t *testing.T) {
+	modTime := time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)
+	mapFS := fstest.MapFS{
+		"file.bin": {Data: make([]byte, 5000), Mode: 0644, ModTime: modTime},
+		"ro.txt":   {Data: []byte("ro"), Mode: 0444, ModTime: modTime},
+		"dir":      {Mode: fs.ModeDir | 0755, ModTime: modTime},
+	}
+	cases := []struct {
+		name       string
+		attrs      FileAttributes
+		allocation int64
+		size       int64
+		directory  bool
+	}{
+		{"file.bin", FILE_ATTRIBUTE_NORMAL, 8192, 5000, false},
+		{"ro.txt", FILE_ATTRIBUTE_READONLY, 4096, 2, false},
+		{"dir", FILE_ATTRIBUTE_DIRECTORY, 0, 0, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fi, err := fs.Stat(mapFS, tc.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			basic := NewFileBasicInformation(fi)
+			if len(basic) != 40 || basic.IsInvalid() {
+				t.Fatalf("FileBasicInformation of %d bytes", len(basic))
+			}
+			if !basic[:36].IsInvalid() {
+				t.Errorf("FileBasicInformation without Reserved accepted")
+			}
+			for _, v := range []time.Time{basic.CreationTime(), basic.LastAccessTime(), basic.LastWriteTime(), basic.ChangeTime()} {
+				if !v.Equal(modTime) {
+					t.Errorf("FileBasicInformation time %v", v)
+				}
+			}
+			if basic.FileAttributes() != tc.attrs {
+				t.Errorf("FileBasicInformation attributes 0x%x, want 0x%x", basic.FileAttributes(), tc.attrs)
+			}
+
+			standard := NewFileStandardInformation(fi)
+			if len(standard) != 24 || standard.IsInvalid() {
+				t.Fatalf("FileStandardInformation of %d bytes", len(standard))
+			}
+			if standard.AllocationSize() != tc.allocation || standard.EndOfFile() != tc.size ||
+				standard.NumberOfLinks() != 1 || standard.DeletePending() || standard.Directory() != tc.directory {
+				t.Errorf("FileStandardInformation %x", []byte(standard))
+			}
+
+			open := NewFileNetworkOpenInformation(fi)
+			if len(open) != 56 || open.IsInvalid() {
+				t.Fatalf("FileNetworkOpenInformation of %d bytes", len(open))
+			}
+			if !open.CreationTime().Equal(modTime) || !open.LastWriteTime().Equal(modTime) ||
+				open.AllocationSize() != tc.allocation || open.EndOfFile() != tc.size || open.FileAttributes() != tc.attrs {
+				t.Errorf("FileNetworkOpenInformation %x", []byte(open))
+			}
+		})
+	}
+
+	// the attributes kept by a MemFS
+	m := NewMemFS()
+	f, err := m.Open("hidden", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := m.SetAttributes("hidden", FILE_ATTRIBUTE_HIDDEN|FILE_ATTRIBUTE_ARCHIVE); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := m.Stat("hidden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := NewFileBasicInformation(fi).FileAttributes(); got != FILE_ATTRIBUTE_HIDDEN|FILE_ATTRIBUTE_ARCHIVE {
+		t.Errorf("MemFS attributes 0x%x", got)
+	}
+}
@@ -1,21 +1,21 @@
-package simba
-
-type FLAGS uint32
-
-const (
-	// MS-SMB2 - v20211006 page 33/481
-	SMB2_FLAGS_SERVER_TO_REDIR    FLAGS = 0x00000001
-	SMB2_FLAGS_ASYNC_COMMAND      FLAGS = 0x00000002
-	SMB2_FLAGS_RELATED_OPERATIONS FLAGS = 0x00000004
-	SMB2_FLAGS_SIGNED             FLAGS = 0x00000008
-	SMB2_FLAGS_PRIORITY_MASK      FLAGS = 0x00000070
-	SMB2_FLAGS_DFS_OPERATIONS     FLAGS = 0x10000000
-	SMB2_FLAGS_REPLAY_OPERATION   FLAGS = 0x20000000
-)
-
-type SessionFlags uint8
-
-const (
-	// MS-SMB2 - v20211006 page 56/481
-	SMB2_SESSION_FLAG_BINDING SessionFlags = 0x01
-)
+package simba
+
+type FLAGS uint32
+
+const (
+	// MS-SMB2 - v20211006 page 33/481
+	SMB2_FLAGS_SERVER_TO_REDIR    FLAGS = 0x00000001
+	SMB2_FLAGS_ASYNC_COMMAND      FLAGS = 0x00000002
+	SMB2_FLAGS_RELATED_OPERATIONS FLAGS = 0x00000004
+	SMB2_FLAGS_SIGNED             FLAGS = 0x00000008
+	SMB2_FLAGS_PRIORITY_MASK      FLAGS = 0x00000070
+	SMB2_FLAGS_DFS_OPERATIONS     FLAGS = 0x10000000
+	SMB2_FLAGS_REPLAY_OPERATION   FLAGS = 0x20000000
+)
+
+type SessionFlags uint8
+
+const (
+	// MS-SMB2 - v20211006 page 56/481
+	SMB2_SESSION_FLAG_BINDING SessionFlags = 0x01
+)
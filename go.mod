@@ -7,4 +7,4 @@ require (
 	golang.org/x/sys v0.3.0
 )
 
-require github.com/Azure/go-ntlmssp v0.0.0-20221128193559-754e69321358 // indirect
+require github.com/Azure/go-ntlmssp v0.0.0-20221128193559-754e69321358
@@ -1,21 +1,72 @@
-package simba
-
-import (
-	"crypto/hmac"
-	"crypto/sha256"
-)
-
-// r = 32, L = 128 if CipherID is 128CCM or 12GCM, L = 256 if CipherID is 256CCM or 256GCM
-// MS-SMB2 3.1.4.2, PRF is HMAC-SHA256, KDF algorithm is Counter Mode
-func smb3kdf(sessionKey []byte, label, context string) []byte {
-	h := hmac.New(sha256.New, sessionKey)
-
-	h.Write([]byte{0x00, 0x00, 0x00, 0x01}) // i = 1, r = 32
-	h.Write([]byte(label))
-	h.Write([]byte{0x00})
-	h.Write([]byte(context))
-	h.Write([]byte{0x00, 0x00, 0x00, 0x80}) // L = 128, r = 32
-
-	return h.Sum(nil)[:16]
-
-}
+package simba
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// r = 32, L = 128 if CipherID is 128CCM or 12GCM, L = 256 if CipherID is 256CCM or 256GCM
+// MS-SMB2 3.1.4.2, PRF is HMAC-SHA256, KDF algorithm is Counter Mode
+// L is given in bits and is at most 256, a single PRF iteration.
+func smb3kdf(sessionKey []byte, label, context string, l int) []byte {
+	h := hmac.New(sha256.New, sessionKey)
+
+	h.Write([]byte{0x00, 0x00, 0x00, 0x01}) // i = 1, r = 32
+	h.Write([]byte(label))
+	h.Write([]byte{0x00})
+	h.Write([]byte(context))
+	h.Write([]byte{byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l)}) // L, r = 32
+
+	return h.Sum(nil)[:l/8]
+
+}
+
+// SessionKeys are the keys of an SMB2 session, named from the server side:
+// EncryptionKey protects server to client messages and DecryptionKey the
+// client to server ones.
+// MS-SMB2 3.3.1.8 Per Session
+type SessionKeys struct {
+	SigningKey     []byte
+	EncryptionKey  []byte
+	DecryptionKey  []byte
+	ApplicationKey []byte
+}
+
+// DeriveSessionKeys derives the keys of a session from the session key
+// returned by authentication. preauthIntegrityHashValue is the context of
+// SMB 3.1.1 and ignored for the other dialects. SMB 2.x has no encryption
+// and uses the session key itself for signing.
+// MS-SMB2 3.3.5.5.3 Handling GSS-API Authentication
+func DeriveSessionKeys(dialect Dialect, cipher Cipher, fullSessionKey []byte, preauthIntegrityHashValue []byte) SessionKeys {
+	// Session.SessionKey is the first 16 bytes of the key, zero padded
+	sessionKey := make([]byte, 16)
+	copy(sessionKey, fullSessionKey)
+
+	switch dialect {
+	case SMB2_DIALECT_202, SMB2_DIALECT_21:
+		return SessionKeys{
+			SigningKey:     sessionKey,
+			ApplicationKey: sessionKey,
+		}
+	case SMB2_DIALECT_30, SMB2_DIALECT_302:
+		return SessionKeys{
+			SigningKey:     smb3kdf(sessionKey, "SMB2AESCMAC\x00", "SmbSign\x00", 128),
+			EncryptionKey:  smb3kdf(sessionKey, "SMB2AESCCM\x00", "ServerOut\x00", 128),
+			DecryptionKey:  smb3kdf(sessionKey, "SMB2AESCCM\x00", "ServerIn \x00", 128),
+			ApplicationKey: smb3kdf(sessionKey, "SMB2APP\x00", "SmbRpc\x00", 128),
+		}
+	}
+
+	context := string(preauthIntegrityHashValue)
+	cipherKey, l := sessionKey, 128
+	if cipher == SMB2_ENCRYPTION_AES256_CCM || cipher == SMB2_ENCRYPTION_AES256_GCM {
+		// Session.FullSessionKey is used for 256 bits ciphers
+		cipherKey, l = fullSessionKey, 256
+	}
+	return SessionKeys{
+		SigningKey:     smb3kdf(sessionKey, "SMBSigningKey\x00", context, 128),
+		EncryptionKey:  smb3kdf(cipherKey, "SMBS2CCipherKey\x00", context, l),
+		DecryptionKey:  smb3kdf(cipherKey, "SMBC2SCipherKey\x00", context, l),
+		ApplicationKey: smb3kdf(sessionKey, "SMBAppKey\x00", context, 128),
+	}
+}
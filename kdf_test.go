@@ -1,80 +1,183 @@
-package simba
-
-import (
-	"bytes"
-	"encoding/hex"
-	"testing"
-)
-
-func TestKDF(t *testing.T) {
-	cases := []struct {
-		name     string
-		input    []byte
-		labal    string
-		context  string
-		expected []byte
-	}{
-		{
-			name: "case 1",
-			input: func() []byte {
-				r, _ := hex.DecodeString("7CD451825D0450D235424E44BA6E78CC")
-				return r
-			}(),
-			labal:   "SMB2AESCMAC\x00",
-			context: "SmbSign\x00",
-			expected: func() []byte {
-				r, _ := hex.DecodeString("0B7E9C5CAC36C0F6EA9AB275298CEDCE")
-				return r
-			}(),
-		},
-		{
-			name: "case 2",
-			input: func() []byte {
-				r, _ := hex.DecodeString("7CD451825D0450D235424E44BA6E78CC")
-				return r
-			}(),
-			labal:   "SMB2APP\x00",
-			context: "SmbRpc\x00",
-			expected: func() []byte {
-				r, _ := hex.DecodeString("BB23A4575AA26C721AF525AF15A87B4F")
-				return r
-			}(),
-		},
-		{
-			name: "case 3",
-			input: func() []byte {
-				r, _ := hex.DecodeString("7CD451825D0450D235424E44BA6E78CC")
-				return r
-			}(),
-			labal:   "SMB2AESCCM\x00",
-			context: "ServerIn \x00",
-			expected: func() []byte {
-				r, _ := hex.DecodeString("FAD27796665B313EBB578F388632B4F7")
-				return r
-			}(),
-		},
-		{
-			name: "case 4",
-			input: func() []byte {
-				r, _ := hex.DecodeString("7CD451825D0450D235424E44BA6E78CC")
-				return r
-			}(),
-			labal:   "SMB2AESCCM\x00",
-			context: "ServerOut\x00",
-			expected: func() []byte {
-				r, _ := hex.DecodeString("B0F0427F7CEB416D1D9DCC0CD4F99447")
-				return r
-			}(),
-		},
-	}
-
-	for _, c := range cases {
-		t.Run(c.name, func(t *testing.T) {
-			actual := smb3kdf(c.input, c.labal, c.context)
-			if !bytes.Equal(actual, c.expected) {
-				t.Errorf("expected %0x, actual %0x", c.expected, actual)
-			}
-		})
-	}
-
-}
+package simba
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestKDF(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    []byte
+		labal    string
+		context  string
+		l        int
+		expected []byte
+	}{
+		{
+			name: "case 1",
+			input: func() []byte {
+				r, _ := hex.DecodeString("7CD451825D0450D235424E44BA6E78CC")
+				return r
+			}(),
+			labal:   "SMB2AESCMAC\x00",
+			context: "SmbSign\x00",
+			l:       128,
+			expected: func() []byte {
+				r, _ := hex.DecodeString("0B7E9C5CAC36C0F6EA9AB275298CEDCE")
+				return r
+			}(),
+		},
+		{
+			name: "case 2",
+			input: func() []byte {
+				r, _ := hex.DecodeString("7CD451825D0450D235424E44BA6E78CC")
+				return r
+			}(),
+			labal:   "SMB2APP\x00",
+			context: "SmbRpc\x00",
+			l:       128,
+			expected: func() []byte {
+				r, _ := hex.DecodeString("BB23A4575AA26C721AF525AF15A87B4F")
+				return r
+			}(),
+		},
+		{
+			name: "case 3",
+			input: func() []byte {
+				r, _ := hex.DecodeString("7CD451825D0450D235424E44BA6E78CC")
+				return r
+			}(),
+			labal:   "SMB2AESCCM\x00",
+			context: "ServerIn \x00",
+			l:       128,
+			expected: func() []byte {
+				r, _ := hex.DecodeString("FAD27796665B313EBB578F388632B4F7")
+				return r
+			}(),
+		},
+		{
+			name: "case 4",
+			input: func() []byte {
+				r, _ := hex.DecodeString("7CD451825D0450D235424E44BA6E78CC")
+				return r
+			}(),
+			labal:   "SMB2AESCCM\x00",
+			context: "ServerOut\x00",
+			l:       128,
+			expected: func() []byte {
+				r, _ := hex.DecodeString("B0F0427F7CEB416D1D9DCC0CD4F99447")
+				return r
+			}(),
+		},
+		{
+			name: "case 5, L = 256",
+			input: func() []byte {
+				r, _ := hex.DecodeString("7CD451825D0450D235424E44BA6E78CC")
+				return r
+			}(),
+			labal:   "SMB2AESCCM\x00",
+			context: "ServerIn \x00",
+			l:       256,
+			expected: func() []byte {
+				r, _ := hex.DecodeString("6A9ADC1FB00C4E4ADCC51FB2220632CB4E78BAD3ACF5A322E1514A0D0BC0B6A7")
+				return r
+			}(),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := smb3kdf(c.input, c.labal, c.context, c.l)
+			if !bytes.Equal(actual, c.expected) {
+				t.Errorf("expected %0x, actual %0x", c.expected, actual)
+			}
+		})
+	}
+
+}
+
+func TestDeriveSessionKeys(t *testing.T) {
+	mustDecode := func(s string) []byte {
+		r, err := hex.DecodeString(s)
+		if err != nil {
+			panic(err)
+		}
+		return r
+	}
+	sessionKey := mustDecode("270E1BA896585EEB7AF3472D3B4C75A700112233445566778899AABBCCDDEEFF")
+	preauthIntegrityHashValue := mustDecode("0DD13628CC3ED218EF9DF9772D436D0887AB9814BFAE63A80AA845F36909DB7928622DDDAD522D9751640A459762C5A9D6BB084CBB3CE6BDADEF5D5BCE3C6C01")
+
+	cases := []struct {
+		name     string
+		dialect  Dialect
+		cipher   Cipher
+		key      []byte
+		expected SessionKeys
+	}{
+		{
+			name:    "2.1",
+			dialect: SMB2_DIALECT_21,
+			key:     mustDecode("7CD451825D0450D235424E44BA6E78CC"),
+			expected: SessionKeys{
+				SigningKey:     mustDecode("7CD451825D0450D235424E44BA6E78CC"),
+				ApplicationKey: mustDecode("7CD451825D0450D235424E44BA6E78CC"),
+			},
+		},
+		{
+			name:    "3.0",
+			dialect: SMB2_DIALECT_30,
+			cipher:  SMB2_ENCRYPTION_AES128_CCM,
+			key:     mustDecode("7CD451825D0450D235424E44BA6E78CC"),
+			expected: SessionKeys{
+				SigningKey:     mustDecode("0B7E9C5CAC36C0F6EA9AB275298CEDCE"),
+				EncryptionKey:  mustDecode("B0F0427F7CEB416D1D9DCC0CD4F99447"),
+				DecryptionKey:  mustDecode("FAD27796665B313EBB578F388632B4F7"),
+				ApplicationKey: mustDecode("BB23A4575AA26C721AF525AF15A87B4F"),
+			},
+		},
+		{
+			name:    "3.1.1 AES-128-GCM",
+			dialect: SMB2_DIALECT_311,
+			cipher:  SMB2_ENCRYPTION_AES128_GCM,
+			key:     sessionKey,
+			expected: SessionKeys{
+				SigningKey:     mustDecode("73FE7A9A77BEF0BDE49C650D8CCB5F76"),
+				EncryptionKey:  mustDecode("E2AF0DCEFAC68DA71A0DFBD0D1350D74"),
+				DecryptionKey:  mustDecode("629BCBC54422A0F572B97F45989B6073"),
+				ApplicationKey: mustDecode("6D7AD7954E9EC61E907B4D473DC178FF"),
+			},
+		},
+		{
+			name:    "3.1.1 AES-256-GCM",
+			dialect: SMB2_DIALECT_311,
+			cipher:  SMB2_ENCRYPTION_AES256_GCM,
+			key:     sessionKey,
+			expected: SessionKeys{
+				SigningKey:     mustDecode("73FE7A9A77BEF0BDE49C650D8CCB5F76"),
+				EncryptionKey:  mustDecode("91AD560E05812329CF160CBB9DAF512C3A9E8B7BB356EAD4FB9DDB96C7BAE57C"),
+				DecryptionKey:  mustDecode("BBBBF56E9E99F163484A5F5F9AE28D7BFB3F1EBE5076CC408D1C9CD2DCCF0C96"),
+				ApplicationKey: mustDecode("6D7AD7954E9EC61E907B4D473DC178FF"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := DeriveSessionKeys(c.dialect, c.cipher, c.key, preauthIntegrityHashValue)
+			if !bytes.Equal(actual.SigningKey, c.expected.SigningKey) {
+				t.Errorf("SigningKey = %0x, want %0x", actual.SigningKey, c.expected.SigningKey)
+			}
+			if !bytes.Equal(actual.EncryptionKey, c.expected.EncryptionKey) {
+				t.Errorf("EncryptionKey = %0x, want %0x", actual.EncryptionKey, c.expected.EncryptionKey)
+			}
+			if !bytes.Equal(actual.DecryptionKey, c.expected.DecryptionKey) {
+				t.Errorf("DecryptionKey = %0x, want %0x", actual.DecryptionKey, c.expected.DecryptionKey)
+			}
+			if !bytes.Equal(actual.ApplicationKey, c.expected.ApplicationKey) {
+				t.Errorf("ApplicationKey = %0x, want %0x", actual.ApplicationKey, c.expected.ApplicationKey)
+			}
+		})
+	}
+}
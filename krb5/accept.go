@@ -0,0 +1,214 @@
+package krb5
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultMaxClockSkew is the tolerated difference between the clocks of
+// the client and the server.
+// RFC 4120 1.6 Environmental Assumptions
+const DefaultMaxClockSkew = 5 * time.Minute
+
+const (
+	// Flags of the GSS-API checksum of the authenticator
+	// RFC 4121 4.1.1.1 Checksum Flags Field
+	GSS_C_DELEG_FLAG    uint32 = 1
+	GSS_C_MUTUAL_FLAG   uint32 = 2
+	GSS_C_REPLAY_FLAG   uint32 = 4
+	GSS_C_SEQUENCE_FLAG uint32 = 8
+	GSS_C_CONF_FLAG     uint32 = 16
+	GSS_C_INTEG_FLAG    uint32 = 32
+)
+
+var (
+	ErrClockSkew         = errors.New("krb5: clock skew too great")
+	ErrTicketExpired     = errors.New("krb5: ticket expired")
+	ErrTicketNotYetValid = errors.New("krb5: ticket not yet valid")
+	ErrReplay            = errors.New("krb5: request is a replay")
+)
+
+// Context is the security context of a client whose AP-REQ was accepted.
+type Context struct {
+	Client PrincipalName
+	Realm  string
+
+	// SessionKey is the subkey of the authenticator, or the session key
+	// of the ticket when the client sent none.
+	SessionKey EncryptionKey
+
+	AuthTime time.Time
+	EndTime  time.Time
+
+	// LogonInfo comes from the PAC of the ticket, nil without one.
+	LogonInfo *LogonInfo
+
+	// Response is the AP-REP token answering a client that asked for
+	// mutual authentication, nil otherwise.
+	Response []byte
+}
+
+// Accept verifies the GSS-API AP-REQ token of a client with the keys of
+// kt: the ticket must decrypt with the key of the service it names and be
+// valid now, the authenticator must be of the client of the ticket and
+// recent. replay remembers the authenticators accepted, it may be nil.
+// RFC 4120 3.2.3 Receipt of KRB_AP_REQ Message
+func Accept(kt *Keytab, replay *ReplayCache, token []byte) (*Context, error) {
+	tokID, msg, err := UnwrapToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if tokID != TOK_ID_AP_REQ {
+		return nil, fmt.Errorf("krb5: token 0x%04x is not an AP-REQ", tokID)
+	}
+	req, err := NewAPReq(msg)
+	if err != nil {
+		return nil, err
+	}
+	if req.APOptions.At(AP_OPTIONS_USE_SESSION_KEY) != 0 {
+		return nil, fmt.Errorf("krb5: user to user authentication is not supported")
+	}
+
+	ticket := req.Ticket
+	key, err := kt.Key(ticket.SName, ticket.Realm, ticket.EncPart.EType, uint32(ticket.EncPart.KVNO))
+	if err != nil {
+		return nil, err
+	}
+	b, err := key.Decrypt(KeyUsageTicket, ticket.EncPart.Cipher)
+	if err != nil {
+		return nil, fmt.Errorf("ticket for %v@%s: %w", ticket.SName, ticket.Realm, err)
+	}
+	part, err := NewEncTicketPart(b)
+	if err != nil {
+		return nil, err
+	}
+	b, err = part.Key.Decrypt(KeyUsageAPReqAuthenticator, req.Authenticator.Cipher)
+	if err != nil {
+		return nil, fmt.Errorf("authenticator of %v@%s: %w", part.CName, part.CRealm, err)
+	}
+	a, err := NewAuthenticator(b)
+	if err != nil {
+		return nil, err
+	}
+	if a.CRealm != part.CRealm || !a.CName.equal(part.CName) {
+		return nil, fmt.Errorf("krb5: authenticator of %v@%s for a ticket of %v@%s", a.CName, a.CRealm, part.CName, part.CRealm)
+	}
+
+	now := time.Now()
+	if d := now.Sub(a.CTime); d > DefaultMaxClockSkew || d < -DefaultMaxClockSkew {
+		return nil, ErrClockSkew
+	}
+	start := part.StartTime
+	if start.IsZero() {
+		start = part.AuthTime
+	}
+	if start.Sub(now) > DefaultMaxClockSkew || part.Flags.At(TICKET_FLAGS_INVALID) != 0 {
+		return nil, ErrTicketNotYetValid
+	}
+	if now.Sub(part.EndTime) > DefaultMaxClockSkew {
+		return nil, ErrTicketExpired
+	}
+	if replay != nil && !replay.add(a, now) {
+		return nil, ErrReplay
+	}
+
+	ctx := &Context{
+		Client:     part.CName,
+		Realm:      part.CRealm,
+		SessionKey: part.Key,
+		AuthTime:   part.AuthTime,
+		EndTime:    part.EndTime,
+	}
+	if a.Subkey.KeyType != 0 {
+		ctx.SessionKey = a.Subkey
+	}
+	if pac := FindPAC(part.AuthorizationData); pac != nil {
+		if ctx.LogonInfo, err = pac.LogonInfo(); err != nil {
+			return nil, err
+		}
+	}
+	if req.APOptions.At(AP_OPTIONS_MUTUAL_REQUIRED) != 0 || gssFlags(a)&GSS_C_MUTUAL_FLAG != 0 {
+		if ctx.Response, err = newAPRepToken(part.Key, a); err != nil {
+			return nil, err
+		}
+	}
+	return ctx, nil
+}
+
+// gssFlags returns the flags of the GSS-API checksum of the authenticator.
+// RFC 4121 4.1.1 Authenticator Checksum
+func gssFlags(a *Authenticator) uint32 {
+	if a.Cksum.CksumType != cksumTypeGSSAPI || len(a.Cksum.Checksum) < 24 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(a.Cksum.Checksum[20:24])
+}
+
+// newAPRepToken returns the AP-REP token answering the authenticator,
+// encrypted with the session key of the ticket. It carries the initial
+// sequence number of the server.
+// RFC 4120 3.2.4 Generation of a KRB_AP_REP Message
+func newAPRepToken(key EncryptionKey, a *Authenticator) ([]byte, error) {
+	seq := make([]byte, 4)
+	if _, err := rand.Read(seq); err != nil {
+		return nil, err
+	}
+	part := &EncAPRepPart{
+		CTime:     a.CTime,
+		Cusec:     a.Cusec,
+		SeqNumber: int64(binary.BigEndian.Uint32(seq) & 0x3fffffff),
+	}
+	b, err := part.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := key.Encrypt(KeyUsageAPRepEncPart, b)
+	if err != nil {
+		return nil, err
+	}
+	rep := &APRep{EncPart: EncryptedData{EType: key.KeyType, Cipher: cipher}}
+	if b, err = rep.Bytes(); err != nil {
+		return nil, err
+	}
+	return WrapToken(TOK_ID_AP_REP, b)
+}
+
+// ReplayCache remembers the authenticators accepted within the clock
+// skew, so that a captured AP-REQ can not be accepted twice. The zero
+// value is ready to use and it is safe for concurrent use.
+// RFC 4120 3.2.3 Receipt of KRB_AP_REQ Message
+type ReplayCache struct {
+	mu   sync.Mutex
+	seen map[replayKey]time.Time
+}
+
+type replayKey struct {
+	client string
+	ctime  int64
+	cusec  int
+}
+
+// add records the authenticator a at now, it returns false when it was
+// already seen.
+func (c *ReplayCache) add(a *Authenticator, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen == nil {
+		c.seen = make(map[replayKey]time.Time)
+	}
+	for k, expires := range c.seen {
+		if now.After(expires) {
+			delete(c.seen, k)
+		}
+	}
+	k := replayKey{client: a.CName.String() + "@" + a.CRealm, ctime: a.CTime.Unix(), cusec: a.Cusec}
+	if _, ok := c.seen[k]; ok {
+		return false
+	}
+	c.seen[k] = a.CTime.Add(DefaultMaxClockSkew)
+	return true
+}
@@ -0,0 +1,268 @@
+package krb5
+
+import (
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testService = PrincipalName{NameType: KRB_NT_SRV_INST, NameString: []string{"cifs", "server.example.com"}}
+
+// testKDC issues tickets for the services of its keytab, standing in for
+// the KDC of the realm.
+type testKDC struct {
+	realm  string
+	keytab *Keytab
+}
+
+func newTestKDC(t *testing.T) *testKDC {
+	kdc := &testKDC{realm: "EXAMPLE.COM", keytab: &Keytab{}}
+	for _, etype := range []int32{ETYPE_AES256_CTS_HMAC_SHA1_96, ETYPE_RC4_HMAC} {
+		key, err := StringToKey(etype, "service password", "EXAMPLE.COMcifsserver.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		kdc.keytab.Entries = append(kdc.keytab.Entries, KeytabEntry{Principal: testService, Realm: kdc.realm, KVNO: 2, Key: key})
+	}
+	return kdc
+}
+
+// ticket returns a ticket of client for the service, encrypted with the
+// service key of etype, and its session key.
+func (kdc *testKDC) ticket(t *testing.T, client string, etype int32, pac PAC, lifetime time.Duration) (*Ticket, EncryptionKey) {
+	serviceKey, err := kdc.keytab.Key(testService, kdc.realm, etype, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionKey := EncryptionKey{KeyType: etype, KeyValue: make([]byte, keySize(etype))}
+	for i := range sessionKey.KeyValue {
+		sessionKey.KeyValue[i] = byte(i)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	part := &EncTicketPart{
+		Flags:    asn1.BitString{Bytes: []byte{0x40, 0x81, 0x00, 0x00}, BitLength: 32},
+		Key:      sessionKey,
+		CRealm:   kdc.realm,
+		CName:    PrincipalName{NameType: KRB_NT_PRINCIPAL, NameString: []string{client}},
+		AuthTime: now,
+		EndTime:  now.Add(lifetime),
+	}
+	if pac != nil {
+		inner, err := asn1.Marshal(AuthorizationData{{ADType: AD_WIN2K_PAC, ADData: pac}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.AuthorizationData = AuthorizationData{{ADType: AD_IF_RELEVANT, ADData: inner}}
+	}
+	b, err := part.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := serviceKey.Encrypt(KeyUsageTicket, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Ticket{
+		TktVNO:  pvno,
+		Realm:   kdc.realm,
+		SName:   testService,
+		EncPart: EncryptedData{EType: etype, KVNO: 2, Cipher: cipher},
+	}, sessionKey
+}
+
+// apReqToken returns the AP-REQ token of client presenting the ticket.
+func apReqToken(t *testing.T, ticket *Ticket, sessionKey EncryptionKey, a *Authenticator, mutual bool) []byte {
+	b, err := a.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := sessionKey.Encrypt(KeyUsageAPReqAuthenticator, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &APReq{
+		APOptions:     asn1.BitString{Bytes: []byte{0, 0, 0, 0}, BitLength: 32},
+		Ticket:        *ticket,
+		Authenticator: EncryptedData{EType: sessionKey.KeyType, Cipher: cipher},
+	}
+	if mutual {
+		req.APOptions.Bytes[0] |= 0x80 >> AP_OPTIONS_MUTUAL_REQUIRED
+	}
+	if b, err = req.Bytes(); err != nil {
+		t.Fatal(err)
+	}
+	token, err := WrapToken(TOK_ID_AP_REQ, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func newTestAuthenticator(client string, ctime time.Time, subkey EncryptionKey, gssFlags uint32) *Authenticator {
+	cksum := make([]byte, 24)
+	binary.LittleEndian.PutUint32(cksum[0:4], 16)
+	binary.LittleEndian.PutUint32(cksum[20:24], gssFlags)
+	return &Authenticator{
+		AuthenticatorVNO: pvno,
+		CRealm:           "EXAMPLE.COM",
+		CName:            PrincipalName{NameType: KRB_NT_PRINCIPAL, NameString: []string{client}},
+		Cksum:            Checksum{CksumType: cksumTypeGSSAPI, Checksum: cksum},
+		Cusec:            1234,
+		CTime:            ctime.UTC().Truncate(time.Second),
+		Subkey:           subkey,
+		SeqNumber:        42,
+	}
+}
+
+func TestAccept(t *testing.T) {
+	kdc := newTestKDC(t)
+	subkey := EncryptionKey{KeyType: ETYPE_AES256_CTS_HMAC_SHA1_96, KeyValue: make([]byte, 32)}
+	for i := range subkey.KeyValue {
+		subkey.KeyValue[i] = 0xa0 + byte(i)
+	}
+
+	t.Run("aes with pac", func(t *testing.T) {
+		ticket, sessionKey := kdc.ticket(t, "alice", ETYPE_AES256_CTS_HMAC_SHA1_96, newTestPAC(testLogonInfo), 10*time.Hour)
+		a := newTestAuthenticator("alice", time.Now(), subkey, GSS_C_MUTUAL_FLAG|GSS_C_INTEG_FLAG)
+		ctx, err := Accept(kdc.keytab, &ReplayCache{}, apReqToken(t, ticket, sessionKey, a, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ctx.Client.String() != "alice" || ctx.Realm != "EXAMPLE.COM" {
+			t.Errorf("client = %v@%v, want alice@EXAMPLE.COM", ctx.Client, ctx.Realm)
+		}
+		if !reflect.DeepEqual(ctx.SessionKey, subkey) {
+			t.Errorf("SessionKey = %x, want the subkey %x", ctx.SessionKey.KeyValue, subkey.KeyValue)
+		}
+		if !reflect.DeepEqual(ctx.LogonInfo, testLogonInfo) {
+			t.Errorf("LogonInfo = %+v, want %+v", ctx.LogonInfo, testLogonInfo)
+		}
+		if ctx.EndTime.Sub(ctx.AuthTime) != 10*time.Hour {
+			t.Errorf("ticket lifetime = %v, want 10h", ctx.EndTime.Sub(ctx.AuthTime))
+		}
+
+		// the client checks the AP-REP with the session key of the ticket
+		tokID, b, err := UnwrapToken(ctx.Response)
+		if err != nil || tokID != TOK_ID_AP_REP {
+			t.Fatalf("UnwrapToken() = 0x%04x, %v", tokID, err)
+		}
+		rep, err := NewAPRep(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err = sessionKey.Decrypt(KeyUsageAPRepEncPart, rep.EncPart.Cipher)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part, err := NewEncAPRepPart(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !part.CTime.Equal(a.CTime) || part.Cusec != a.Cusec {
+			t.Errorf("EncAPRepPart = %v.%v, want %v.%v", part.CTime, part.Cusec, a.CTime, a.Cusec)
+		}
+	})
+
+	t.Run("rc4 without subkey", func(t *testing.T) {
+		ticket, sessionKey := kdc.ticket(t, "bob", ETYPE_RC4_HMAC, nil, time.Hour)
+		a := newTestAuthenticator("bob", time.Now(), EncryptionKey{}, 0)
+		ctx, err := Accept(kdc.keytab, nil, apReqToken(t, ticket, sessionKey, a, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ctx.SessionKey, sessionKey) {
+			t.Errorf("SessionKey = %x, want the ticket key %x", ctx.SessionKey.KeyValue, sessionKey.KeyValue)
+		}
+		if ctx.LogonInfo != nil || ctx.Response != nil {
+			t.Errorf("LogonInfo = %v, Response = %x, want none", ctx.LogonInfo, ctx.Response)
+		}
+	})
+
+	t.Run("mutual ap options", func(t *testing.T) {
+		ticket, sessionKey := kdc.ticket(t, "bob", ETYPE_AES256_CTS_HMAC_SHA1_96, nil, time.Hour)
+		a := newTestAuthenticator("bob", time.Now(), EncryptionKey{}, 0)
+		ctx, err := Accept(kdc.keytab, nil, apReqToken(t, ticket, sessionKey, a, true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ctx.Response == nil {
+			t.Errorf("Response = nil, want an AP-REP")
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		ticket, sessionKey := kdc.ticket(t, "alice", ETYPE_AES256_CTS_HMAC_SHA1_96, nil, time.Hour)
+		token := apReqToken(t, ticket, sessionKey, newTestAuthenticator("alice", time.Now(), subkey, 0), false)
+		replay := &ReplayCache{}
+		if _, err := Accept(kdc.keytab, replay, token); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Accept(kdc.keytab, replay, token); !errors.Is(err, ErrReplay) {
+			t.Errorf("Accept() of a replay = %v, want ErrReplay", err)
+		}
+	})
+
+	errorCases := []struct {
+		name     string
+		token    func(t *testing.T) []byte
+		expected error
+	}{
+		{
+			"expired",
+			func(t *testing.T) []byte {
+				ticket, sessionKey := kdc.ticket(t, "alice", ETYPE_AES256_CTS_HMAC_SHA1_96, nil, -time.Hour)
+				return apReqToken(t, ticket, sessionKey, newTestAuthenticator("alice", time.Now(), subkey, 0), false)
+			},
+			ErrTicketExpired,
+		},
+		{
+			"clock skew",
+			func(t *testing.T) []byte {
+				ticket, sessionKey := kdc.ticket(t, "alice", ETYPE_AES256_CTS_HMAC_SHA1_96, nil, time.Hour)
+				return apReqToken(t, ticket, sessionKey, newTestAuthenticator("alice", time.Now().Add(-10*time.Minute), subkey, 0), false)
+			},
+			ErrClockSkew,
+		},
+		{
+			"unknown kvno",
+			func(t *testing.T) []byte {
+				ticket, sessionKey := kdc.ticket(t, "alice", ETYPE_AES256_CTS_HMAC_SHA1_96, nil, time.Hour)
+				ticket.EncPart.KVNO = 3
+				return apReqToken(t, ticket, sessionKey, newTestAuthenticator("alice", time.Now(), subkey, 0), false)
+			},
+			ErrNoKey,
+		},
+		{
+			"tampered ticket",
+			func(t *testing.T) []byte {
+				ticket, sessionKey := kdc.ticket(t, "alice", ETYPE_RC4_HMAC, nil, time.Hour)
+				ticket.EncPart.Cipher[20] ^= 0x01
+				return apReqToken(t, ticket, sessionKey, newTestAuthenticator("alice", time.Now(), subkey, 0), false)
+			},
+			ErrIntegrity,
+		},
+		{
+			"authenticator of another client",
+			func(t *testing.T) []byte {
+				ticket, sessionKey := kdc.ticket(t, "alice", ETYPE_AES256_CTS_HMAC_SHA1_96, nil, time.Hour)
+				return apReqToken(t, ticket, sessionKey, newTestAuthenticator("mallory", time.Now(), subkey, 0), false)
+			},
+			nil,
+		},
+	}
+	for _, c := range errorCases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Accept(kdc.keytab, nil, c.token(t))
+			if err == nil {
+				t.Fatalf("Accept() succeeded")
+			}
+			if c.expected != nil && !errors.Is(err, c.expected) {
+				t.Errorf("Accept() = %v, want %v", err, c.expected)
+			}
+		})
+	}
+}
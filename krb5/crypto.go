@@ -0,0 +1,379 @@
+package krb5
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/PichuChen/simba/ntlmssp"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// Encryption types
+	// RFC 3962 7. Assigned Numbers, RFC 4757 7.1
+	ETYPE_AES128_CTS_HMAC_SHA1_96 int32 = 17
+	ETYPE_AES256_CTS_HMAC_SHA1_96 int32 = 18
+	ETYPE_RC4_HMAC                int32 = 23
+)
+
+const (
+	// Key usage numbers
+	// RFC 4120 7.5.1
+	KeyUsageTicket             uint32 = 2
+	KeyUsageAPReqAuthenticator uint32 = 11
+	KeyUsageAPRepEncPart       uint32 = 12
+)
+
+// aesIterations is the default PBKDF2 iteration count of the AES string to
+// key function.
+// RFC 3962 4. Key Generation from Pass Phrases or Random Data
+const aesIterations = 4096
+
+// hmacSize is the size of the truncated HMAC-SHA1 of AES ciphertexts.
+const hmacSize = 12
+
+// ErrIntegrity is returned when a ciphertext fails its integrity check,
+// most often because it was encrypted with another key.
+var ErrIntegrity = errors.New("krb5: integrity check failed")
+
+// EncryptionKey is a key of an encryption type.
+// RFC 4120 5.2.9 Cryptosystem-Related Types
+type EncryptionKey struct {
+	KeyType  int32  `asn1:"explicit,tag:0"`
+	KeyValue []byte `asn1:"explicit,tag:1"`
+}
+
+// keySize returns the size of the keys of etype, zero if it is not
+// supported.
+func keySize(etype int32) int {
+	switch etype {
+	case ETYPE_AES128_CTS_HMAC_SHA1_96, ETYPE_RC4_HMAC:
+		return 16
+	case ETYPE_AES256_CTS_HMAC_SHA1_96:
+		return 32
+	}
+	return 0
+}
+
+func (k EncryptionKey) check() error {
+	size := keySize(k.KeyType)
+	if size == 0 {
+		return fmt.Errorf("krb5: unsupported encryption type %d", k.KeyType)
+	}
+	if len(k.KeyValue) != size {
+		return fmt.Errorf("krb5: key of encryption type %d is %d bytes", k.KeyType, len(k.KeyValue))
+	}
+	return nil
+}
+
+// Encrypt returns the ciphertext of plaintext for the key usage, with a
+// random confounder.
+func (k EncryptionKey) Encrypt(usage uint32, plaintext []byte) ([]byte, error) {
+	if err := k.check(); err != nil {
+		return nil, err
+	}
+	if k.KeyType == ETYPE_RC4_HMAC {
+		return rc4HMACEncrypt(k.KeyValue, usage, plaintext)
+	}
+	return aesEncrypt(k.KeyValue, usage, plaintext)
+}
+
+// Decrypt returns the plaintext of ciphertext for the key usage.
+func (k EncryptionKey) Decrypt(usage uint32, ciphertext []byte) ([]byte, error) {
+	if err := k.check(); err != nil {
+		return nil, err
+	}
+	if k.KeyType == ETYPE_RC4_HMAC {
+		return rc4HMACDecrypt(k.KeyValue, usage, ciphertext)
+	}
+	return aesDecrypt(k.KeyValue, usage, ciphertext)
+}
+
+// StringToKey returns the key of etype derived from password. salt is the
+// realm followed by the components of the principal name unless the KDC
+// says otherwise, RC4-HMAC keys are the NT hash and ignore it.
+func StringToKey(etype int32, password, salt string) (EncryptionKey, error) {
+	switch etype {
+	case ETYPE_AES128_CTS_HMAC_SHA1_96, ETYPE_AES256_CTS_HMAC_SHA1_96:
+		key, err := aesStringToKey(password, salt, aesIterations, keySize(etype))
+		return EncryptionKey{KeyType: etype, KeyValue: key}, err
+	case ETYPE_RC4_HMAC:
+		return EncryptionKey{KeyType: etype, KeyValue: ntlmssp.NTHash(password)}, nil
+	}
+	return EncryptionKey{}, fmt.Errorf("krb5: unsupported encryption type %d", etype)
+}
+
+// RFC 3962 4. Key Generation from Pass Phrases or Random Data
+func aesStringToKey(password, salt string, iterations, size int) ([]byte, error) {
+	tkey := pbkdf2.Key([]byte(password), []byte(salt), iterations, size, sha1.New)
+	return dk(tkey, []byte("kerberos"))
+}
+
+// aesEncrypt encrypts the confounder and plaintext with AES in CTS mode and
+// appends the truncated HMAC-SHA1 of them.
+// RFC 3961 5.3 Cryptosystem Profile Based on Simplified Profile
+func aesEncrypt(key []byte, usage uint32, plaintext []byte) ([]byte, error) {
+	ke, ki, err := aesUsageKeys(key, usage)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, aes.BlockSize+len(plaintext))
+	if _, err := rand.Read(data[:aes.BlockSize]); err != nil {
+		return nil, err
+	}
+	copy(data[aes.BlockSize:], plaintext)
+
+	ciphertext, err := ctsEncrypt(ke, data)
+	if err != nil {
+		return nil, err
+	}
+	return append(ciphertext, hmacSHA1(ki, data)[:hmacSize]...), nil
+}
+
+func aesDecrypt(key []byte, usage uint32, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < aes.BlockSize+hmacSize {
+		return nil, fmt.Errorf("krb5: ciphertext of %d bytes is too short", len(ciphertext))
+	}
+	ke, ki, err := aesUsageKeys(key, usage)
+	if err != nil {
+		return nil, err
+	}
+	mac := ciphertext[len(ciphertext)-hmacSize:]
+	data, err := ctsDecrypt(ke, ciphertext[:len(ciphertext)-hmacSize])
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, hmacSHA1(ki, data)[:hmacSize]) {
+		return nil, ErrIntegrity
+	}
+	return data[aes.BlockSize:], nil
+}
+
+// aesUsageKeys returns the encryption and integrity keys of a key usage.
+// RFC 3961 5.3 Cryptosystem Profile Based on Simplified Profile
+func aesUsageKeys(key []byte, usage uint32) (ke, ki []byte, err error) {
+	constant := make([]byte, 5)
+	binary.BigEndian.PutUint32(constant, usage)
+	constant[4] = 0xAA
+	if ke, err = dk(key, constant); err != nil {
+		return nil, nil, err
+	}
+	constant[4] = 0x55
+	if ki, err = dk(key, constant); err != nil {
+		return nil, nil, err
+	}
+	return ke, ki, nil
+}
+
+// dk derives a key from the base key and a constant, random-to-key being
+// the identity for AES.
+// RFC 3961 5.1 A Key Derivation Function
+func dk(key, constant []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	k := nfold(constant, aes.BlockSize)
+	out := make([]byte, 0, len(key)+aes.BlockSize)
+	for len(out) < len(key) {
+		block.Encrypt(k, k)
+		out = append(out, k...)
+	}
+	return out[:len(key)], nil
+}
+
+// nfold stretches or shrinks in to n bytes: copies of in, each rotated 13
+// bits right of the previous one, are added with one's complement
+// addition.
+// RFC 3961 5.1 A Key Derivation Function
+func nfold(in []byte, n int) []byte {
+	l := lcm(len(in), n)
+	buf := make([]byte, 0, l)
+	for i := 0; len(buf) < l; i++ {
+		buf = append(buf, rotateRight(in, 13*i)...)
+	}
+
+	out := make([]byte, n)
+	for i := 0; i < l; i += n {
+		carry := 0
+		for j := n - 1; j >= 0; j-- {
+			s := int(out[j]) + int(buf[i+j]) + carry
+			out[j], carry = byte(s), s>>8
+		}
+		// end around carry
+		for carry != 0 {
+			for j := n - 1; j >= 0 && carry != 0; j-- {
+				s := int(out[j]) + carry
+				out[j], carry = byte(s), s>>8
+			}
+		}
+	}
+	return out
+}
+
+func rotateRight(b []byte, bits int) []byte {
+	size := len(b) * 8
+	bits %= size
+	out := make([]byte, len(b))
+	for i := 0; i < size; i++ {
+		j := (i - bits + size) % size
+		if b[j/8]&(0x80>>(j%8)) != 0 {
+			out[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return out
+}
+
+func lcm(a, b int) int {
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
+
+// ctsEncrypt encrypts plaintext of at least one block with AES in CBC mode
+// with ciphertext stealing, the last two blocks swapped, and a zero IV.
+// RFC 3962 5. Ciphertext Stealing
+func ctsEncrypt(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	n := len(plaintext)
+	if n < aes.BlockSize {
+		return nil, fmt.Errorf("krb5: plaintext of %d bytes is shorter than a block", n)
+	}
+	if n == aes.BlockSize {
+		out := make([]byte, aes.BlockSize)
+		block.Encrypt(out, plaintext)
+		return out, nil
+	}
+
+	padded := make([]byte, (n+aes.BlockSize-1)/aes.BlockSize*aes.BlockSize)
+	copy(padded, plaintext)
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(padded, padded)
+
+	m := len(padded)
+	out := make([]byte, 0, n)
+	out = append(out, padded[:m-2*aes.BlockSize]...)
+	out = append(out, padded[m-aes.BlockSize:]...)
+	out = append(out, padded[m-2*aes.BlockSize:n-aes.BlockSize]...)
+	return out, nil
+}
+
+func ctsDecrypt(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	n := len(ciphertext)
+	if n < aes.BlockSize {
+		return nil, fmt.Errorf("krb5: ciphertext of %d bytes is shorter than a block", n)
+	}
+	if n == aes.BlockSize {
+		out := make([]byte, aes.BlockSize)
+		block.Decrypt(out, ciphertext)
+		return out, nil
+	}
+
+	// the ciphertext ends with the last CBC block followed by the stolen
+	// head of the one before it
+	r := n % aes.BlockSize
+	if r == 0 {
+		r = aes.BlockSize
+	}
+	head := ciphertext[:n-aes.BlockSize-r]
+	last, stolen := ciphertext[n-aes.BlockSize-r:n-r], ciphertext[n-r:]
+
+	d := make([]byte, aes.BlockSize)
+	block.Decrypt(d, last)
+	lastPlain := make([]byte, r)
+	for i := range lastPlain {
+		lastPlain[i] = d[i] ^ stolen[i]
+	}
+	prev := make([]byte, aes.BlockSize)
+	copy(prev, stolen)
+	copy(prev[r:], d[r:])
+
+	iv := make([]byte, aes.BlockSize)
+	if len(head) > 0 {
+		copy(iv, head[len(head)-aes.BlockSize:])
+	}
+	prevPlain := make([]byte, aes.BlockSize)
+	block.Decrypt(prevPlain, prev)
+	for i := range prevPlain {
+		prevPlain[i] ^= iv[i]
+	}
+
+	out := make([]byte, len(head), n)
+	if len(head) > 0 {
+		cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, head)
+	}
+	out = append(out, prevPlain...)
+	return append(out, lastPlain...), nil
+}
+
+// rc4HMACEncrypt encrypts a confounder and plaintext with RC4 keyed by the
+// HMAC-MD5 checksum of them, which precedes the ciphertext.
+// RFC 4757 5. Encryption Types
+func rc4HMACEncrypt(key []byte, usage uint32, plaintext []byte) ([]byte, error) {
+	k1 := rc4HMACUsageKey(key, usage)
+	data := make([]byte, 8+len(plaintext))
+	if _, err := rand.Read(data[:8]); err != nil {
+		return nil, err
+	}
+	copy(data[8:], plaintext)
+
+	checksum := hmacMD5(k1, data)
+	c, err := rc4.NewCipher(hmacMD5(k1, checksum))
+	if err != nil {
+		return nil, err
+	}
+	c.XORKeyStream(data, data)
+	return append(checksum, data...), nil
+}
+
+func rc4HMACDecrypt(key []byte, usage uint32, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < md5.Size+8 {
+		return nil, fmt.Errorf("krb5: ciphertext of %d bytes is too short", len(ciphertext))
+	}
+	k1 := rc4HMACUsageKey(key, usage)
+	checksum := ciphertext[:md5.Size]
+	c, err := rc4.NewCipher(hmacMD5(k1, checksum))
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, len(ciphertext)-md5.Size)
+	c.XORKeyStream(data, ciphertext[md5.Size:])
+	if !hmac.Equal(checksum, hmacMD5(k1, data)) {
+		return nil, ErrIntegrity
+	}
+	return data[8:], nil
+}
+
+func rc4HMACUsageKey(key []byte, usage uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, usage)
+	return hmacMD5(key, b)
+}
+
+func hmacSHA1(key, data []byte) []byte {
+	h := hmac.New(sha1.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hmacMD5(key, data []byte) []byte {
+	h := hmac.New(md5.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,130 @@
+package krb5
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestNfold(t *testing.T) {
+	// RFC 3961 A.1 n-fold
+	cases := []struct {
+		input    string
+		n        int
+		expected string
+	}{
+		{"012345", 8, "be072631276b1955"},
+		{"password", 7, "78a07b6caf85fa"},
+		{"Rough Consensus, and Running Code", 8, "bb6ed30870b7f0e0"},
+		{"password", 21, "59e4a8ca7c0385c3c37b3f6d2000247cb6e6bd5b3e"},
+		{"MASSACHVSETTS INSTITVTE OF TECHNOLOGY", 24, "db3b0d8f0b061e603282b308a50841229ad798fab9540c1b"},
+		{"kerberos", 8, "6b65726265726f73"},
+		{"kerberos", 16, "6b65726265726f737b9b5b2b93132b93"},
+	}
+
+	for _, c := range cases {
+		actual := nfold([]byte(c.input), c.n)
+		if hex.EncodeToString(actual) != c.expected {
+			t.Errorf("nfold(%q, %d) = %x, want %v", c.input, c.n, actual, c.expected)
+		}
+	}
+}
+
+func TestAESStringToKey(t *testing.T) {
+	// RFC 3962 Appendix B. Sample Test Vectors
+	cases := []struct {
+		iterations int
+		size       int
+		expected   string
+	}{
+		{1, 16, "42263c6e89f4fc28b8df68ee09799f15"},
+		{1, 32, "fe697b52bc0d3ce14432ba036a92e65bbb52280990a2fa27883998d72af30161"},
+		{2, 16, "c651bf29e2300ac27fa469d693bdda13"},
+		{2, 32, "a2e16d16b36069c135d5e9d2e25f896102685618b95914b467c67622225824ff"},
+		{1200, 16, "4c01cd46d632d01e6dbe230a01ed642a"},
+		{1200, 32, "55a6ac740ad17b4846941051e1e8b0a7548d93b0ab30a8bc3ff16280382b8c2a"},
+	}
+
+	for _, c := range cases {
+		actual, err := aesStringToKey("password", "ATHENA.MIT.EDUraeburn", c.iterations, c.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(actual) != c.expected {
+			t.Errorf("aesStringToKey(%d, %d) = %x, want %v", c.iterations, c.size, actual, c.expected)
+		}
+	}
+}
+
+func TestCTS(t *testing.T) {
+	// RFC 3962 Appendix B, AES 128-bit key "chicken teriyaki" and a zero IV
+	key := []byte("chicken teriyaki")
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"4920776f756c64206c696b652074686520", "c6353568f2bf8cb4d8a580362da7ff7f97"},
+		{
+			"4920776f756c64206c696b65207468652047656e6572616c20476175277320",
+			"fc00783e0efdb2c1d445d4c8eff7ed2297687268d6ecccc0c07b25e25ecfe5",
+		},
+		{
+			"4920776f756c64206c696b65207468652047656e6572616c2047617527732043",
+			"39312523a78662d5be7fcbcc98ebf5a897687268d6ecccc0c07b25e25ecfe584",
+		},
+		{
+			"4920776f756c64206c696b65207468652047656e6572616c20476175277320436869636b656e2c20706c656173652c",
+			"97687268d6ecccc0c07b25e25ecfe584b3fffd940c16a18c1b5549d2f838029e39312523a78662d5be7fcbcc98ebf5",
+		},
+	}
+
+	for _, c := range cases {
+		input, _ := hex.DecodeString(c.input)
+		actual, err := ctsEncrypt(key, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(actual) != c.expected {
+			t.Errorf("ctsEncrypt(%v) = %x, want %v", c.input, actual, c.expected)
+		}
+		plaintext, err := ctsDecrypt(key, actual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plaintext, input) {
+			t.Errorf("ctsDecrypt(%x) = %x, want %v", actual, plaintext, c.input)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, etype := range []int32{ETYPE_AES128_CTS_HMAC_SHA1_96, ETYPE_AES256_CTS_HMAC_SHA1_96, ETYPE_RC4_HMAC} {
+		key, err := StringToKey(etype, "Password", "EXAMPLE.COMcifsserver.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, size := range []int{0, 1, 16, 33} {
+			plaintext := bytes.Repeat([]byte{0x5a}, size)
+			ciphertext, err := key.Encrypt(KeyUsageTicket, plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := key.Decrypt(KeyUsageTicket, ciphertext)
+			if err != nil {
+				t.Errorf("etype %d, %d bytes: Decrypt() = %v", etype, size, err)
+				continue
+			}
+			if !bytes.Equal(actual, plaintext) {
+				t.Errorf("etype %d, %d bytes: Decrypt() = %x, want %x", etype, size, actual, plaintext)
+			}
+			if _, err := key.Decrypt(KeyUsageAPReqAuthenticator, ciphertext); !errors.Is(err, ErrIntegrity) {
+				t.Errorf("etype %d, %d bytes: Decrypt() with another usage = %v, want ErrIntegrity", etype, size, err)
+			}
+		}
+	}
+
+	if _, err := (EncryptionKey{KeyType: 1, KeyValue: make([]byte, 8)}).Encrypt(KeyUsageTicket, nil); err == nil {
+		t.Errorf("Encrypt() with DES succeeded")
+	}
+}
@@ -0,0 +1,203 @@
+package krb5
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// keytabVersion is the version of the MIT keytab format, the older 0x0501
+// with host byte order is not supported.
+const keytabVersion = 0x0502
+
+// ErrNoKey is returned when the keytab has no key to decrypt a ticket.
+var ErrNoKey = errors.New("krb5: no key in keytab")
+
+// KeytabEntry is a key of a service principal.
+type KeytabEntry struct {
+	Principal PrincipalName
+	Realm     string
+	Timestamp time.Time
+	KVNO      uint32
+	Key       EncryptionKey
+}
+
+// Keytab holds the keys of the services of the server, as exported by
+// ktutil or ktpass.
+type Keytab struct {
+	Entries []KeytabEntry
+}
+
+// LoadKeytab reads the keytab file at path.
+func LoadKeytab(path string) (*Keytab, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeytab(b)
+}
+
+// ParseKeytab parses a keytab of the MIT format:
+//
+//	keytab {
+//	    uint16_t file_format_version;    /* 0x0502 */
+//	    keytab_entry entries[*];
+//	};
+//
+//	keytab_entry {
+//	    int32_t size;
+//	    uint16_t num_components;
+//	    counted_octet_string realm;
+//	    counted_octet_string components[num_components];
+//	    uint32_t name_type;
+//	    uint32_t timestamp;
+//	    uint8_t vno8;
+//	    keyblock key;
+//	    uint32_t vno; /* optional */
+//	};
+//
+// All integers are big endian, entries of negative size are holes left by
+// removed keys.
+func ParseKeytab(b []byte) (*Keytab, error) {
+	if len(b) < 2 || binary.BigEndian.Uint16(b) != keytabVersion {
+		return nil, fmt.Errorf("krb5: not a keytab of version 0x%04x", keytabVersion)
+	}
+	kt := &Keytab{}
+	for b = b[2:]; len(b) > 0; {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("krb5: truncated keytab")
+		}
+		size := int32(binary.BigEndian.Uint32(b))
+		b = b[4:]
+		n := int(size)
+		if size < 0 {
+			n = -n
+		}
+		if n > len(b) {
+			return nil, fmt.Errorf("krb5: truncated keytab entry")
+		}
+		if size > 0 {
+			e, err := parseKeytabEntry(b[:n])
+			if err != nil {
+				return nil, err
+			}
+			kt.Entries = append(kt.Entries, e)
+		}
+		b = b[n:]
+	}
+	return kt, nil
+}
+
+func parseKeytabEntry(b keytabReader) (e KeytabEntry, err error) {
+	components, ok := b.uint16()
+	if !ok {
+		return e, errTruncatedEntry
+	}
+	if e.Realm, ok = b.string(); !ok {
+		return e, errTruncatedEntry
+	}
+	for i := 0; i < int(components); i++ {
+		s, ok := b.string()
+		if !ok {
+			return e, errTruncatedEntry
+		}
+		e.Principal.NameString = append(e.Principal.NameString, s)
+	}
+	nameType, ok := b.uint32()
+	if !ok {
+		return e, errTruncatedEntry
+	}
+	e.Principal.NameType = int32(nameType)
+	timestamp, ok := b.uint32()
+	if !ok {
+		return e, errTruncatedEntry
+	}
+	e.Timestamp = time.Unix(int64(timestamp), 0)
+	vno8, ok := b.uint8()
+	if !ok {
+		return e, errTruncatedEntry
+	}
+	e.KVNO = uint32(vno8)
+	keyType, ok := b.uint16()
+	if !ok {
+		return e, errTruncatedEntry
+	}
+	e.Key.KeyType = int32(keyType)
+	key, ok := b.string()
+	if !ok {
+		return e, errTruncatedEntry
+	}
+	e.Key.KeyValue = []byte(key)
+	// the 32 bits vno replaces vno8 when present
+	if vno, ok := b.uint32(); ok && vno != 0 {
+		e.KVNO = vno
+	}
+	return e, nil
+}
+
+var errTruncatedEntry = errors.New("krb5: truncated keytab entry")
+
+type keytabReader []byte
+
+func (r *keytabReader) uint8() (uint8, bool) {
+	if len(*r) < 1 {
+		return 0, false
+	}
+	v := (*r)[0]
+	*r = (*r)[1:]
+	return v, true
+}
+
+func (r *keytabReader) uint16() (uint16, bool) {
+	if len(*r) < 2 {
+		return 0, false
+	}
+	v := binary.BigEndian.Uint16(*r)
+	*r = (*r)[2:]
+	return v, true
+}
+
+func (r *keytabReader) uint32() (uint32, bool) {
+	if len(*r) < 4 {
+		return 0, false
+	}
+	v := binary.BigEndian.Uint32(*r)
+	*r = (*r)[4:]
+	return v, true
+}
+
+// string reads a counted_octet_string.
+func (r *keytabReader) string() (string, bool) {
+	l, ok := r.uint16()
+	if !ok || int(l) > len(*r) {
+		return "", false
+	}
+	v := string((*r)[:l])
+	*r = (*r)[l:]
+	return v, true
+}
+
+// Key returns the key of the service principal in realm for etype, of
+// version kvno or the latest one when kvno is zero. Names are compared
+// without case as Windows does.
+func (kt *Keytab) Key(principal PrincipalName, realm string, etype int32, kvno uint32) (EncryptionKey, error) {
+	var found *KeytabEntry
+	for i, e := range kt.Entries {
+		if e.Key.KeyType != etype || !strings.EqualFold(e.Realm, realm) || !e.Principal.equalFold(principal) {
+			continue
+		}
+		if kvno != 0 && e.KVNO != kvno {
+			continue
+		}
+		if found == nil || e.KVNO > found.KVNO {
+			found = &kt.Entries[i]
+		}
+	}
+	if found == nil {
+		return EncryptionKey{}, fmt.Errorf("%w: %v@%s kvno %d etype %d", ErrNoKey, principal, realm, kvno, etype)
+	}
+	return found.Key, nil
+}
@@ -0,0 +1,117 @@
+package krb5
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// keytabEntry returns the bytes of a keytab entry, with the 32 bits vno
+// when vno32 is not zero.
+func keytabEntry(realm string, components []string, kvno uint8, vno32 uint32, key EncryptionKey) []byte {
+	var b []byte
+	putString := func(s string) {
+		b = appendUint16(b, uint16(len(s)))
+		b = append(b, s...)
+	}
+	b = appendUint16(b, uint16(len(components)))
+	putString(realm)
+	for _, c := range components {
+		putString(c)
+	}
+	b = appendUint32(b, KRB_NT_SRV_HST)
+	b = appendUint32(b, 1700000000)
+	b = append(b, kvno)
+	b = appendUint16(b, uint16(key.KeyType))
+	putString(string(key.KeyValue))
+	if vno32 != 0 {
+		b = appendUint32(b, vno32)
+	}
+	return append(appendUint32(nil, uint32(len(b))), b...)
+}
+
+func TestParseKeytab(t *testing.T) {
+	key := func(etype int32, c byte) EncryptionKey {
+		k := make([]byte, keySize(etype))
+		for i := range k {
+			k[i] = c
+		}
+		return EncryptionKey{KeyType: etype, KeyValue: k}
+	}
+	service := []string{"cifs", "server.example.com"}
+
+	b := []byte{0x05, 0x02}
+	b = append(b, keytabEntry("EXAMPLE.COM", service, 2, 0, key(ETYPE_AES256_CTS_HMAC_SHA1_96, 0x02))...)
+	// a hole left by a removed entry
+	b = append(b, 0xff, 0xff, 0xff, 0xfc, 0, 0, 0, 0)
+	b = append(b, keytabEntry("EXAMPLE.COM", service, 3, 300, key(ETYPE_AES256_CTS_HMAC_SHA1_96, 0x03))...)
+	b = append(b, keytabEntry("EXAMPLE.COM", service, 3, 0, key(ETYPE_RC4_HMAC, 0x23))...)
+
+	kt, err := ParseKeytab(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kt.Entries) != 3 {
+		t.Fatalf("%d entries, want 3", len(kt.Entries))
+	}
+	e := kt.Entries[0]
+	if e.Realm != "EXAMPLE.COM" || e.Principal.String() != "cifs/server.example.com" || e.Principal.NameType != KRB_NT_SRV_HST ||
+		e.KVNO != 2 || e.Timestamp.Unix() != 1700000000 {
+		t.Errorf("Entries[0] = %+v", e)
+	}
+	if kt.Entries[1].KVNO != 300 {
+		t.Errorf("Entries[1].KVNO = %v, want 300", kt.Entries[1].KVNO)
+	}
+
+	cases := []struct {
+		name      string
+		principal []string
+		realm     string
+		etype     int32
+		kvno      uint32
+		expected  string
+	}{
+		{"latest", service, "EXAMPLE.COM", ETYPE_AES256_CTS_HMAC_SHA1_96, 0, hex.EncodeToString(key(ETYPE_AES256_CTS_HMAC_SHA1_96, 0x03).KeyValue)},
+		{"kvno", service, "EXAMPLE.COM", ETYPE_AES256_CTS_HMAC_SHA1_96, 2, hex.EncodeToString(key(ETYPE_AES256_CTS_HMAC_SHA1_96, 0x02).KeyValue)},
+		{"case", []string{"CIFS", "Server.Example.COM"}, "example.com", ETYPE_RC4_HMAC, 3, hex.EncodeToString(key(ETYPE_RC4_HMAC, 0x23).KeyValue)},
+		{"unknown kvno", service, "EXAMPLE.COM", ETYPE_AES256_CTS_HMAC_SHA1_96, 4, ""},
+		{"unknown etype", service, "EXAMPLE.COM", ETYPE_AES128_CTS_HMAC_SHA1_96, 0, ""},
+		{"unknown realm", service, "OTHER.COM", ETYPE_AES256_CTS_HMAC_SHA1_96, 0, ""},
+		{"unknown service", []string{"host", "server.example.com"}, "EXAMPLE.COM", ETYPE_AES256_CTS_HMAC_SHA1_96, 0, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := kt.Key(PrincipalName{NameType: KRB_NT_SRV_INST, NameString: c.principal}, c.realm, c.etype, c.kvno)
+			if c.expected == "" {
+				if !errors.Is(err, ErrNoKey) {
+					t.Errorf("Key() error = %v, want ErrNoKey", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(actual.KeyValue) != c.expected {
+				t.Errorf("Key() = %x, want %v", actual.KeyValue, c.expected)
+			}
+		})
+	}
+
+	for name, b := range map[string][]byte{
+		"version":   {0x05, 0x01},
+		"truncated": b[:len(b)-1],
+		"entry":     {0x05, 0x02, 0, 0, 0, 2, 0, 1},
+	} {
+		if _, err := ParseKeytab(b); err == nil {
+			t.Errorf("%s: ParseKeytab() succeeded", name)
+		}
+	}
+}
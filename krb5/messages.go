@@ -0,0 +1,366 @@
+package krb5
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+const (
+	pvno = 5
+
+	// Message types
+	// RFC 4120 5.10 Application Tag Numbers
+	KRB_AP_REQ = 14
+	KRB_AP_REP = 15
+)
+
+// Application tags of the messages and encrypted parts.
+// RFC 4120 5.10 Application Tag Numbers
+const (
+	tagTicket        = 1
+	tagAuthenticator = 2
+	tagEncTicketPart = 3
+	tagAPReq         = 14
+	tagAPRep         = 15
+	tagEncAPRepPart  = 27
+)
+
+const (
+	// Name types
+	// RFC 4120 6.2 Principal Names
+	KRB_NT_PRINCIPAL = 1
+	KRB_NT_SRV_INST  = 2
+	KRB_NT_SRV_HST   = 3
+)
+
+const (
+	// APOptions bits
+	// RFC 4120 5.5.1 KRB_AP_REQ Definition
+	AP_OPTIONS_USE_SESSION_KEY = 1
+	AP_OPTIONS_MUTUAL_REQUIRED = 2
+)
+
+const (
+	// TicketFlags bits
+	// RFC 4120 5.3 Tickets
+	TICKET_FLAGS_INVALID = 7
+)
+
+const (
+	// Authorization data types
+	// RFC 4120 7.5.4, MS-PAC 2.3
+	AD_IF_RELEVANT  = 1
+	AD_WIN2K_PAC    = 128
+	cksumTypeGSSAPI = 0x8003
+)
+
+// PrincipalName is a name of a client or service, the components of a
+// service name are the service and the host.
+// RFC 4120 5.2.2 Realm and PrincipalName
+type PrincipalName struct {
+	NameType   int32    `asn1:"explicit,tag:0"`
+	NameString []string `asn1:"explicit,tag:1"`
+}
+
+// String returns the components of the name joined by slashes.
+func (n PrincipalName) String() string {
+	return strings.Join(n.NameString, "/")
+}
+
+func (n PrincipalName) equal(other PrincipalName) bool {
+	if len(n.NameString) != len(other.NameString) {
+		return false
+	}
+	for i := range n.NameString {
+		if n.NameString[i] != other.NameString[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (n PrincipalName) equalFold(other PrincipalName) bool {
+	if len(n.NameString) != len(other.NameString) {
+		return false
+	}
+	for i := range n.NameString {
+		if !strings.EqualFold(n.NameString[i], other.NameString[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// EncryptedData is a ciphertext with the encryption type and version of
+// its key.
+// RFC 4120 5.2.9 Cryptosystem-Related Types
+type EncryptedData struct {
+	EType  int32  `asn1:"explicit,tag:0"`
+	KVNO   int    `asn1:"optional,explicit,tag:1"`
+	Cipher []byte `asn1:"explicit,tag:2"`
+}
+
+// Checksum
+// RFC 4120 5.2.9 Cryptosystem-Related Types
+type Checksum struct {
+	CksumType int32  `asn1:"explicit,tag:0"`
+	Checksum  []byte `asn1:"explicit,tag:1"`
+}
+
+// AuthorizationDataEntry
+// RFC 4120 5.2.6 AuthorizationData
+type AuthorizationDataEntry struct {
+	ADType int32  `asn1:"explicit,tag:0"`
+	ADData []byte `asn1:"explicit,tag:1"`
+}
+
+type AuthorizationData []AuthorizationDataEntry
+
+// HostAddress
+// RFC 4120 5.2.5 HostAddress and HostAddresses
+type HostAddress struct {
+	AddrType int32  `asn1:"explicit,tag:0"`
+	Address  []byte `asn1:"explicit,tag:1"`
+}
+
+// TransitedEncoding
+// RFC 4120 5.3 Tickets
+type TransitedEncoding struct {
+	TrType   int32  `asn1:"explicit,tag:0"`
+	Contents []byte `asn1:"explicit,tag:1"`
+}
+
+// Ticket
+// RFC 4120 5.3 Tickets
+type Ticket struct {
+	TktVNO  int           `asn1:"explicit,tag:0"`
+	Realm   string        `asn1:"explicit,tag:1"`
+	SName   PrincipalName `asn1:"explicit,tag:2"`
+	EncPart EncryptedData `asn1:"explicit,tag:3"`
+}
+
+// EncTicketPart is the part of a ticket encrypted with the key of the
+// service.
+// RFC 4120 5.3 Tickets
+type EncTicketPart struct {
+	Flags             asn1.BitString    `asn1:"explicit,tag:0"`
+	Key               EncryptionKey     `asn1:"explicit,tag:1"`
+	CRealm            string            `asn1:"explicit,tag:2"`
+	CName             PrincipalName     `asn1:"explicit,tag:3"`
+	Transited         TransitedEncoding `asn1:"explicit,tag:4"`
+	AuthTime          time.Time         `asn1:"generalized,explicit,tag:5"`
+	StartTime         time.Time         `asn1:"generalized,optional,explicit,tag:6"`
+	EndTime           time.Time         `asn1:"generalized,explicit,tag:7"`
+	RenewTill         time.Time         `asn1:"generalized,optional,explicit,tag:8"`
+	CAddr             []HostAddress     `asn1:"optional,explicit,tag:9"`
+	AuthorizationData AuthorizationData `asn1:"optional,explicit,tag:10"`
+}
+
+// Authenticator proves that the client knows the session key of the
+// ticket.
+// RFC 4120 5.5.1 KRB_AP_REQ Definition
+type Authenticator struct {
+	AuthenticatorVNO  int               `asn1:"explicit,tag:0"`
+	CRealm            string            `asn1:"explicit,tag:1"`
+	CName             PrincipalName     `asn1:"explicit,tag:2"`
+	Cksum             Checksum          `asn1:"optional,explicit,tag:3"`
+	Cusec             int               `asn1:"explicit,tag:4"`
+	CTime             time.Time         `asn1:"generalized,explicit,tag:5"`
+	Subkey            EncryptionKey     `asn1:"optional,explicit,tag:6"`
+	SeqNumber         int64             `asn1:"optional,explicit,tag:7"`
+	AuthorizationData AuthorizationData `asn1:"optional,explicit,tag:8"`
+}
+
+// APReq
+// RFC 4120 5.5.1 KRB_AP_REQ Definition
+type APReq struct {
+	APOptions     asn1.BitString
+	Ticket        Ticket
+	Authenticator EncryptedData
+}
+
+// apReq is the encoding of APReq, the ticket keeps its application tag
+// within the context tag.
+type apReq struct {
+	PVNO          int            `asn1:"explicit,tag:0"`
+	MsgType       int            `asn1:"explicit,tag:1"`
+	APOptions     asn1.BitString `asn1:"explicit,tag:2"`
+	Ticket        asn1.RawValue
+	Authenticator EncryptedData `asn1:"explicit,tag:4"`
+}
+
+// APRep
+// RFC 4120 5.5.2 KRB_AP_REP Definition
+type APRep struct {
+	EncPart EncryptedData
+}
+
+type apRep struct {
+	PVNO    int           `asn1:"explicit,tag:0"`
+	MsgType int           `asn1:"explicit,tag:1"`
+	EncPart EncryptedData `asn1:"explicit,tag:2"`
+}
+
+// EncAPRepPart
+// RFC 4120 5.5.2 KRB_AP_REP Definition
+type EncAPRepPart struct {
+	CTime     time.Time     `asn1:"generalized,explicit,tag:0"`
+	Cusec     int           `asn1:"explicit,tag:1"`
+	Subkey    EncryptionKey `asn1:"optional,explicit,tag:2"`
+	SeqNumber int64         `asn1:"optional,explicit,tag:3"`
+}
+
+// unmarshalApplication parses b as the DER encoding of v within the
+// application tag.
+func unmarshalApplication(b []byte, tag int, v interface{}) error {
+	rest, err := asn1.UnmarshalWithParams(b, v, fmt.Sprintf("application,explicit,tag:%d", tag))
+	if err != nil {
+		return fmt.Errorf("krb5: %T: %v", v, err)
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("krb5: %T: trailing data", v)
+	}
+	return nil
+}
+
+func marshalApplication(v interface{}, tag int) ([]byte, error) {
+	return asn1.MarshalWithParams(v, fmt.Sprintf("application,explicit,tag:%d", tag))
+}
+
+func NewTicket(b []byte) (*Ticket, error) {
+	t := &Ticket{}
+	return t, unmarshalApplication(b, tagTicket, t)
+}
+
+func (t *Ticket) Bytes() ([]byte, error) {
+	return marshalApplication(*t, tagTicket)
+}
+
+func NewEncTicketPart(b []byte) (*EncTicketPart, error) {
+	p := &EncTicketPart{}
+	return p, unmarshalApplication(b, tagEncTicketPart, p)
+}
+
+func (p *EncTicketPart) Bytes() ([]byte, error) {
+	return marshalApplication(*p, tagEncTicketPart)
+}
+
+func NewAuthenticator(b []byte) (*Authenticator, error) {
+	a := &Authenticator{}
+	return a, unmarshalApplication(b, tagAuthenticator, a)
+}
+
+func (a *Authenticator) Bytes() ([]byte, error) {
+	return marshalApplication(*a, tagAuthenticator)
+}
+
+func NewAPReq(b []byte) (*APReq, error) {
+	var r apReq
+	if err := unmarshalApplication(b, tagAPReq, &r); err != nil {
+		return nil, err
+	}
+	if r.PVNO != pvno || r.MsgType != KRB_AP_REQ {
+		return nil, fmt.Errorf("krb5: not an AP-REQ of version %d", pvno)
+	}
+	if r.Ticket.Class != asn1.ClassContextSpecific || r.Ticket.Tag != 3 {
+		return nil, fmt.Errorf("krb5: AP-REQ without ticket")
+	}
+	ticket, err := NewTicket(r.Ticket.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &APReq{APOptions: r.APOptions, Ticket: *ticket, Authenticator: r.Authenticator}, nil
+}
+
+func (r *APReq) Bytes() ([]byte, error) {
+	ticket, err := r.Ticket.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return marshalApplication(apReq{
+		PVNO:          pvno,
+		MsgType:       KRB_AP_REQ,
+		APOptions:     r.APOptions,
+		Ticket:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 3, IsCompound: true, Bytes: ticket},
+		Authenticator: r.Authenticator,
+	}, tagAPReq)
+}
+
+func NewAPRep(b []byte) (*APRep, error) {
+	var r apRep
+	if err := unmarshalApplication(b, tagAPRep, &r); err != nil {
+		return nil, err
+	}
+	if r.PVNO != pvno || r.MsgType != KRB_AP_REP {
+		return nil, fmt.Errorf("krb5: not an AP-REP of version %d", pvno)
+	}
+	return &APRep{EncPart: r.EncPart}, nil
+}
+
+func (r *APRep) Bytes() ([]byte, error) {
+	return marshalApplication(apRep{PVNO: pvno, MsgType: KRB_AP_REP, EncPart: r.EncPart}, tagAPRep)
+}
+
+func NewEncAPRepPart(b []byte) (*EncAPRepPart, error) {
+	p := &EncAPRepPart{}
+	return p, unmarshalApplication(b, tagEncAPRepPart, p)
+}
+
+func (p *EncAPRepPart) Bytes() ([]byte, error) {
+	return marshalApplication(*p, tagEncAPRepPart)
+}
+
+// GSS-API token identifiers of the Kerberos mechanism.
+// RFC 4121 4.1 Context Establishment Tokens
+const (
+	TOK_ID_AP_REQ    uint16 = 0x0100
+	TOK_ID_AP_REP    uint16 = 0x0200
+	TOK_ID_KRB_ERROR uint16 = 0x0300
+)
+
+// MechOID is the OID of the Kerberos GSS-API mechanism, MSMechOID the one
+// Windows clients offer in SPNEGO for the same mechanism.
+var (
+	MechOID   = asn1.ObjectIdentifier{1, 2, 840, 113554, 1, 2, 2}
+	MSMechOID = asn1.ObjectIdentifier{1, 2, 840, 48018, 1, 2, 2}
+)
+
+// UnwrapToken returns the identifier and the Kerberos message of a
+// context establishment token.
+// RFC 2743 3.1 Mechanism-Independent Token Format
+func UnwrapToken(b []byte) (uint16, []byte, error) {
+	input := cryptobyte.String(b)
+	var inner cryptobyte.String
+	var oid asn1.ObjectIdentifier
+	if !input.ReadASN1(&inner, cryptobyte_asn1.Tag(0).Constructed()|0x40 /* application */) || !input.Empty() {
+		return 0, nil, fmt.Errorf("krb5: not a GSS-API token")
+	}
+	if !inner.ReadASN1ObjectIdentifier(&oid) {
+		return 0, nil, fmt.Errorf("krb5: GSS-API token without mechanism")
+	}
+	if !oid.Equal(MechOID) && !oid.Equal(MSMechOID) {
+		return 0, nil, fmt.Errorf("krb5: GSS-API token of mechanism %v", oid)
+	}
+	var tokID uint16
+	if !inner.ReadUint16(&tokID) {
+		return 0, nil, fmt.Errorf("krb5: GSS-API token without TOK_ID")
+	}
+	return tokID, inner, nil
+}
+
+// WrapToken returns the context establishment token of a Kerberos
+// message.
+func WrapToken(tokID uint16, msg []byte) ([]byte, error) {
+	var builder cryptobyte.Builder
+	builder.AddASN1(cryptobyte_asn1.Tag(0).Constructed()|0x40 /* application */, func(builder *cryptobyte.Builder) {
+		builder.AddASN1ObjectIdentifier(MechOID)
+		builder.AddUint16(tokID)
+		builder.AddBytes(msg)
+	})
+	return builder.Bytes()
+}
@@ -0,0 +1,336 @@
+package krb5
+
+import (
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// PAC buffer types
+	// MS-PAC 2.4 PAC_INFO_BUFFER
+	PAC_LOGON_INFO       uint32 = 0x00000001
+	PAC_SERVER_CHECKSUM  uint32 = 0x00000006
+	PAC_PRIVSVR_CHECKSUM uint32 = 0x00000007
+	PAC_CLIENT_INFO      uint32 = 0x0000000A
+	PAC_UPN_DNS_INFO     uint32 = 0x0000000C
+)
+
+const (
+	pacTypeSize       = 8
+	pacInfoBufferSize = 16
+
+	// ndrHeaderSize is the size of the common and private headers of
+	// NDR type serialization version 1.
+	// MS-RPCE 2.2.6 Type Serialization Version 1
+	ndrHeaderSize = 16
+)
+
+var errInvalidPAC = errors.New("krb5: invalid PAC")
+
+// PAC is the Privilege Attribute Certificate a KDC puts in the tickets of
+// Windows domain users.
+// MS-PAC 2.3 PACTYPE
+type PAC []byte
+
+// FindPAC returns the PAC in the authorization data of a ticket, nil when
+// there is none. It lies within AD-IF-RELEVANT.
+// MS-PAC 2.3 PACTYPE
+func FindPAC(ad AuthorizationData) PAC {
+	for _, e := range ad {
+		if e.ADType != AD_IF_RELEVANT {
+			continue
+		}
+		var inner AuthorizationData
+		if _, err := asn1.Unmarshal(e.ADData, &inner); err != nil {
+			continue
+		}
+		for _, e := range inner {
+			if e.ADType == AD_WIN2K_PAC {
+				return PAC(e.ADData)
+			}
+		}
+	}
+	return nil
+}
+
+// Buffer returns the first buffer of type ulType.
+func (p PAC) Buffer(ulType uint32) ([]byte, bool) {
+	if len(p) < pacTypeSize {
+		return nil, false
+	}
+	count := binary.LittleEndian.Uint32(p[0:4])
+	if uint64(count) > uint64(len(p)-pacTypeSize)/pacInfoBufferSize {
+		return nil, false
+	}
+	for i := 0; i < int(count); i++ {
+		info := p[pacTypeSize+i*pacInfoBufferSize:]
+		if binary.LittleEndian.Uint32(info[0:4]) != ulType {
+			continue
+		}
+		size := uint64(binary.LittleEndian.Uint32(info[4:8]))
+		offset := binary.LittleEndian.Uint64(info[8:16])
+		if offset > uint64(len(p)) || size > uint64(len(p))-offset {
+			return nil, false
+		}
+		return p[offset : offset+size], true
+	}
+	return nil, false
+}
+
+// LogonInfo is the KERB_VALIDATION_INFO of a PAC, the user and the groups
+// it belongs to as the domain controller sees them.
+// MS-PAC 2.5 KERB_VALIDATION_INFO
+type LogonInfo struct {
+	EffectiveName      string
+	FullName           string
+	LogonServer        string
+	LogonDomainName    string
+	UserAccountControl uint32
+
+	// LogonDomainId is the SID of the domain, UserId and GroupIds the
+	// relative ids of the user and its groups within it.
+	LogonDomainId  string
+	UserId         uint32
+	PrimaryGroupId uint32
+	GroupIds       []uint32
+
+	// ExtraSids are groups of other domains.
+	ExtraSids []string
+
+	// ResourceGroupIds are the relative ids of domain local groups of
+	// ResourceGroupDomainSid.
+	ResourceGroupDomainSid string
+	ResourceGroupIds       []uint32
+}
+
+// UserSID returns the SID of the user.
+func (l *LogonInfo) UserSID() string {
+	return l.LogonDomainId + "-" + strconv.FormatUint(uint64(l.UserId), 10)
+}
+
+// GroupSIDs returns the SIDs of every group of the user.
+func (l *LogonInfo) GroupSIDs() []string {
+	var sids []string
+	for _, rid := range l.GroupIds {
+		sids = append(sids, l.LogonDomainId+"-"+strconv.FormatUint(uint64(rid), 10))
+	}
+	sids = append(sids, l.ExtraSids...)
+	for _, rid := range l.ResourceGroupIds {
+		sids = append(sids, l.ResourceGroupDomainSid+"-"+strconv.FormatUint(uint64(rid), 10))
+	}
+	return sids
+}
+
+// LogonInfo decodes the PAC_LOGON_INFO buffer, a KERB_VALIDATION_INFO
+// serialized with NDR type serialization version 1.
+// MS-PAC 2.5 KERB_VALIDATION_INFO
+func (p PAC) LogonInfo() (*LogonInfo, error) {
+	b, ok := p.Buffer(PAC_LOGON_INFO)
+	if !ok {
+		return nil, fmt.Errorf("%w: no PAC_LOGON_INFO", errInvalidPAC)
+	}
+	// MS-RPCE 2.2.6.1 Common Type Header, 2.2.6.2 Private Header
+	if len(b) < ndrHeaderSize || b[0] != 1 || b[1] != 0x10 {
+		return nil, fmt.Errorf("%w: PAC_LOGON_INFO is not little endian NDR", errInvalidPAC)
+	}
+	r := &ndrReader{b: b, off: ndrHeaderSize}
+	if r.uint32() == 0 {
+		return nil, fmt.Errorf("%w: null KERB_VALIDATION_INFO", errInvalidPAC)
+	}
+
+	l := &LogonInfo{}
+	r.skip(6 * 8) // LogonTime to PasswordMustChange
+	effectiveName := r.unicodeString()
+	fullName := r.unicodeString()
+	var unused [4]uint32 // LogonScript to HomeDirectoryDrive
+	for i := range unused {
+		unused[i] = r.unicodeString()
+	}
+	r.uint16() // LogonCount
+	r.uint16() // BadPasswordCount
+	l.UserId = r.uint32()
+	l.PrimaryGroupId = r.uint32()
+	r.uint32() // GroupCount
+	groupIds := r.uint32()
+	r.uint32() // UserFlags
+	r.skip(16) // UserSessionKey
+	logonServer := r.unicodeString()
+	logonDomainName := r.unicodeString()
+	logonDomainId := r.uint32()
+	r.skip(8) // Reserved1
+	l.UserAccountControl = r.uint32()
+	r.uint32() // SubAuthStatus
+	r.skip(16) // LastSuccessfulILogon, LastFailedILogon
+	r.uint32() // FailedILogonCount
+	r.uint32() // Reserved3
+	r.uint32() // SidCount
+	extraSids := r.uint32()
+	resourceGroupDomainSid := r.uint32()
+	r.uint32() // ResourceGroupCount
+	resourceGroupIds := r.uint32()
+
+	// the referents of the pointers follow in the order of the pointers
+	l.EffectiveName = r.deferredString(effectiveName)
+	l.FullName = r.deferredString(fullName)
+	for _, ptr := range unused {
+		r.deferredString(ptr)
+	}
+	if groupIds != 0 {
+		l.GroupIds = r.groupMemberships()
+	}
+	l.LogonServer = r.deferredString(logonServer)
+	l.LogonDomainName = r.deferredString(logonDomainName)
+	if logonDomainId != 0 {
+		l.LogonDomainId = r.sid()
+	}
+	if extraSids != 0 {
+		n := r.count(8)
+		pointers := make([]uint32, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			pointers = append(pointers, r.uint32())
+			r.uint32() // Attributes
+		}
+		for _, ptr := range pointers {
+			if ptr != 0 {
+				l.ExtraSids = append(l.ExtraSids, r.sid())
+			}
+		}
+	}
+	if resourceGroupDomainSid != 0 {
+		l.ResourceGroupDomainSid = r.sid()
+	}
+	if resourceGroupIds != 0 {
+		l.ResourceGroupIds = r.groupMemberships()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return l, nil
+}
+
+// ndrReader reads little endian NDR, primitives aligned to their size.
+// MS-RPCE 2.2.5 Serialization Format
+type ndrReader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (r *ndrReader) read(n, align int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	r.off = (r.off + align - 1) &^ (align - 1)
+	if n < 0 || r.off+n > len(r.b) {
+		r.err = fmt.Errorf("%w: truncated PAC_LOGON_INFO", errInvalidPAC)
+		return nil
+	}
+	v := r.b[r.off : r.off+n]
+	r.off += n
+	return v
+}
+
+func (r *ndrReader) skip(n int) {
+	r.read(n, 4)
+}
+
+func (r *ndrReader) uint8() uint8 {
+	if v := r.read(1, 1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (r *ndrReader) uint16() uint16 {
+	if v := r.read(2, 2); v != nil {
+		return binary.LittleEndian.Uint16(v)
+	}
+	return 0
+}
+
+func (r *ndrReader) uint32() uint32 {
+	if v := r.read(4, 4); v != nil {
+		return binary.LittleEndian.Uint32(v)
+	}
+	return 0
+}
+
+// count reads the element count of an array of elements of size bytes,
+// which must fit in what is left to read.
+func (r *ndrReader) count(size int) int {
+	n := r.uint32()
+	if r.err == nil && uint64(n)*uint64(size) > uint64(len(r.b)-r.off) {
+		r.err = fmt.Errorf("%w: truncated PAC_LOGON_INFO", errInvalidPAC)
+		return 0
+	}
+	return int(n)
+}
+
+// unicodeString reads the header of an RPC_UNICODE_STRING and returns the
+// pointer to its buffer, which is deferred.
+// MS-DTYP 2.3.10 RPC_UNICODE_STRING
+func (r *ndrReader) unicodeString() uint32 {
+	r.uint16() // Length
+	r.uint16() // MaximumLength
+	return r.uint32()
+}
+
+// deferredString reads the conformant varying array of the buffer of an
+// RPC_UNICODE_STRING.
+func (r *ndrReader) deferredString(ptr uint32) string {
+	if ptr == 0 {
+		return ""
+	}
+	r.uint32() // MaximumCount
+	r.uint32() // Offset
+	count := r.count(2)
+	b := r.read(count*2, 2)
+	if b == nil {
+		return ""
+	}
+	u := make([]uint16, count)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// groupMemberships reads a conformant array of GROUP_MEMBERSHIP and
+// returns the relative ids.
+// MS-PAC 2.2.2 GROUP_MEMBERSHIP
+func (r *ndrReader) groupMemberships() []uint32 {
+	n := r.count(8)
+	var rids []uint32
+	for i := 0; i < n && r.err == nil; i++ {
+		rids = append(rids, r.uint32())
+		r.uint32() // Attributes
+	}
+	return rids
+}
+
+// sid reads an RPC_SID and returns its string form.
+// MS-DTYP 2.4.2.3 RPC_SID
+func (r *ndrReader) sid() string {
+	r.uint32() // MaximumCount
+	revision := r.uint8()
+	count := r.uint8()
+	authority := r.read(6, 1)
+	if authority == nil {
+		return ""
+	}
+	var a uint64
+	for _, c := range authority {
+		a = a<<8 | uint64(c)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "S-%d-%d", revision, a)
+	for i := 0; i < int(count); i++ {
+		fmt.Fprintf(&sb, "-%d", r.uint32())
+	}
+	return sb.String()
+}
@@ -0,0 +1,263 @@
+package krb5
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// ndrWriter writes little endian NDR, as a domain controller serializes
+// the KERB_VALIDATION_INFO of a PAC.
+type ndrWriter struct {
+	b   []byte
+	ref uint32
+}
+
+func (w *ndrWriter) align(n int) {
+	for len(w.b)%n != 0 {
+		w.b = append(w.b, 0)
+	}
+}
+
+func (w *ndrWriter) uint16(v uint16) {
+	w.align(2)
+	w.b = append(w.b, byte(v), byte(v>>8))
+}
+
+func (w *ndrWriter) uint32(v uint32) {
+	w.align(4)
+	w.b = append(w.b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// pointer writes a unique pointer, null unless present.
+func (w *ndrWriter) pointer(present bool) {
+	if !present {
+		w.uint32(0)
+		return
+	}
+	w.ref += 4
+	w.uint32(0x00020000 + w.ref)
+}
+
+func (w *ndrWriter) unicodeString(s string) {
+	l := uint16(2 * len(utf16.Encode([]rune(s))))
+	w.uint16(l)
+	w.uint16(l)
+	w.pointer(true)
+}
+
+func (w *ndrWriter) deferredString(s string) {
+	u := utf16.Encode([]rune(s))
+	w.uint32(uint32(len(u)))
+	w.uint32(0)
+	w.uint32(uint32(len(u)))
+	for _, c := range u {
+		w.uint16(c)
+	}
+}
+
+func (w *ndrWriter) groupMemberships(rids []uint32) {
+	w.uint32(uint32(len(rids)))
+	for _, rid := range rids {
+		w.uint32(rid)
+		w.uint32(7) // SE_GROUP_MANDATORY | SE_GROUP_ENABLED_BY_DEFAULT | SE_GROUP_ENABLED
+	}
+}
+
+func (w *ndrWriter) sid(s string) {
+	parts := strings.Split(s, "-")
+	revision, _ := strconv.Atoi(parts[1])
+	authority, _ := strconv.ParseUint(parts[2], 10, 48)
+	subs := parts[3:]
+	w.uint32(uint32(len(subs)))
+	w.b = append(w.b, byte(revision), byte(len(subs)))
+	w.b = append(w.b, byte(authority>>40), byte(authority>>32), byte(authority>>24), byte(authority>>16), byte(authority>>8), byte(authority))
+	for _, sub := range subs {
+		v, _ := strconv.ParseUint(sub, 10, 32)
+		w.uint32(uint32(v))
+	}
+}
+
+// newTestPAC returns a PAC of a single PAC_LOGON_INFO buffer holding l.
+func newTestPAC(l *LogonInfo) PAC {
+	w := &ndrWriter{}
+	w.b = append(w.b, 1, 0x10, 8, 0, 0xcc, 0xcc, 0xcc, 0xcc) // common header
+	w.uint32(0)                                              // ObjectBufferLength
+	w.uint32(0)                                              // Filler
+	w.pointer(true)
+
+	w.b = append(w.b, make([]byte, 6*8)...) // LogonTime to PasswordMustChange
+	w.unicodeString(l.EffectiveName)
+	w.unicodeString(l.FullName)
+	for i := 0; i < 4; i++ {
+		w.unicodeString("")
+	}
+	w.uint16(12) // LogonCount
+	w.uint16(0)  // BadPasswordCount
+	w.uint32(l.UserId)
+	w.uint32(l.PrimaryGroupId)
+	w.uint32(uint32(len(l.GroupIds)))
+	w.pointer(len(l.GroupIds) > 0)
+	w.uint32(0x20)                         // UserFlags
+	w.b = append(w.b, make([]byte, 16)...) // UserSessionKey
+	w.unicodeString(l.LogonServer)
+	w.unicodeString(l.LogonDomainName)
+	w.pointer(true)
+	w.b = append(w.b, make([]byte, 8)...) // Reserved1
+	w.uint32(l.UserAccountControl)
+	w.uint32(0)                            // SubAuthStatus
+	w.b = append(w.b, make([]byte, 16)...) // LastSuccessfulILogon, LastFailedILogon
+	w.uint32(0)                            // FailedILogonCount
+	w.uint32(0)                            // Reserved3
+	w.uint32(uint32(len(l.ExtraSids)))
+	w.pointer(len(l.ExtraSids) > 0)
+	w.pointer(l.ResourceGroupDomainSid != "")
+	w.uint32(uint32(len(l.ResourceGroupIds)))
+	w.pointer(len(l.ResourceGroupIds) > 0)
+
+	w.deferredString(l.EffectiveName)
+	w.deferredString(l.FullName)
+	for i := 0; i < 4; i++ {
+		w.deferredString("")
+	}
+	if len(l.GroupIds) > 0 {
+		w.groupMemberships(l.GroupIds)
+	}
+	w.deferredString(l.LogonServer)
+	w.deferredString(l.LogonDomainName)
+	w.sid(l.LogonDomainId)
+	if len(l.ExtraSids) > 0 {
+		w.uint32(uint32(len(l.ExtraSids)))
+		for range l.ExtraSids {
+			w.pointer(true)
+			w.uint32(7)
+		}
+		for _, s := range l.ExtraSids {
+			w.sid(s)
+		}
+	}
+	if l.ResourceGroupDomainSid != "" {
+		w.sid(l.ResourceGroupDomainSid)
+	}
+	if len(l.ResourceGroupIds) > 0 {
+		w.groupMemberships(l.ResourceGroupIds)
+	}
+	w.align(8)
+	binary.LittleEndian.PutUint32(w.b[8:12], uint32(len(w.b)-16))
+
+	p := make([]byte, 24, 24+len(w.b))
+	binary.LittleEndian.PutUint32(p[0:4], 1) // cBuffers
+	binary.LittleEndian.PutUint32(p[8:12], PAC_LOGON_INFO)
+	binary.LittleEndian.PutUint32(p[12:16], uint32(len(w.b)))
+	binary.LittleEndian.PutUint64(p[16:24], 24)
+	return append(p, w.b...)
+}
+
+// testLogonInfo is the logon info of a user of a domain with a trust.
+var testLogonInfo = &LogonInfo{
+	EffectiveName:          "alice",
+	FullName:               "Alice Liddell",
+	LogonServer:            "DC1",
+	LogonDomainName:        "EXAMPLE",
+	UserAccountControl:     0x10,
+	LogonDomainId:          "S-1-5-21-3623811015-3361044348-30300820",
+	UserId:                 1104,
+	PrimaryGroupId:         513,
+	GroupIds:               []uint32{513, 1105},
+	ExtraSids:              []string{"S-1-18-1", "S-1-5-21-1004336348-1177238915-682003330-1111"},
+	ResourceGroupDomainSid: "S-1-5-21-2127521184-1604012920-1887927527",
+	ResourceGroupIds:       []uint32{1201},
+}
+
+func TestPACLogonInfo(t *testing.T) {
+	pac := newTestPAC(testLogonInfo)
+	actual, err := pac.LogonInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, testLogonInfo) {
+		t.Errorf("LogonInfo() = %+v, want %+v", actual, testLogonInfo)
+	}
+	if actual.UserSID() != "S-1-5-21-3623811015-3361044348-30300820-1104" {
+		t.Errorf("UserSID() = %v", actual.UserSID())
+	}
+	expected := []string{
+		"S-1-5-21-3623811015-3361044348-30300820-513",
+		"S-1-5-21-3623811015-3361044348-30300820-1105",
+		"S-1-18-1",
+		"S-1-5-21-1004336348-1177238915-682003330-1111",
+		"S-1-5-21-2127521184-1604012920-1887927527-1201",
+	}
+	if !reflect.DeepEqual(actual.GroupSIDs(), expected) {
+		t.Errorf("GroupSIDs() = %v, want %v", actual.GroupSIDs(), expected)
+	}
+
+	for i := len(pac) - 8; i > 24; i -= 7 {
+		truncated := append(PAC{}, pac[:i]...)
+		binary.LittleEndian.PutUint32(truncated[12:16], uint32(i-24))
+		if _, err := truncated.LogonInfo(); err == nil {
+			t.Errorf("LogonInfo() of %d bytes succeeded", i)
+			break
+		}
+	}
+	if _, err := (PAC{}).LogonInfo(); err == nil {
+		t.Errorf("LogonInfo() of an empty PAC succeeded")
+	}
+}
+
+func TestPACLogonInfoCounts(t *testing.T) {
+	le32 := func(v ...uint32) []byte {
+		b := make([]byte, 4*len(v))
+		for i, x := range v {
+			binary.LittleEndian.PutUint32(b[4*i:], x)
+		}
+		return b
+	}
+	pac := newTestPAC(testLogonInfo)
+	// the conformant counts of the arrays, found by their first elements
+	cases := []struct {
+		name  string
+		array []byte
+	}{
+		{"GroupIds", le32(2, 513)},
+		{"ExtraSids", le32(2, 0x0002003c, 7)},
+		{"ResourceGroupIds", le32(1, 1201)},
+	}
+	for _, tc := range cases {
+		off := bytes.Index(pac, tc.array)
+		if off < 0 {
+			t.Fatalf("%s: array not found", tc.name)
+		}
+		for _, n := range []uint32{1 << 20, 1<<32 - 1} {
+			bad := append(PAC{}, pac...)
+			binary.LittleEndian.PutUint32(bad[off:], n)
+			if _, err := bad.LogonInfo(); !errors.Is(err, errInvalidPAC) {
+				t.Errorf("%s of %d elements: %v", tc.name, n, err)
+			}
+		}
+	}
+}
+
+func TestFindPAC(t *testing.T) {
+	pac := newTestPAC(testLogonInfo)
+	inner, err := asn1.Marshal(AuthorizationData{{ADType: AD_WIN2K_PAC, ADData: pac}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ad := AuthorizationData{
+		{ADType: 141, ADData: []byte{0x30, 0x00}},
+		{ADType: AD_IF_RELEVANT, ADData: inner},
+	}
+	if actual := FindPAC(ad); !reflect.DeepEqual(actual, pac) {
+		t.Errorf("FindPAC() = %x, want %x", actual, pac)
+	}
+	if actual := FindPAC(ad[:1]); actual != nil {
+		t.Errorf("FindPAC() = %x, want nil", actual)
+	}
+}
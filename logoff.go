@@ -0,0 +1,52 @@
+package simba
+
+import (
+	"encoding/binary"
+)
+
+// MS-SMB2 2.2.7 SMB2 LOGOFF Request
+type LogoffRequest []byte
+
+func (p LogoffRequest) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 4
+	if len(p) < 4 {
+		return true
+	}
+
+	return false
+}
+
+func (p LogoffRequest) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p LogoffRequest) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 4)
+}
+
+// MS-SMB2 2.2.8 SMB2 LOGOFF Response
+type LogoffResponse []byte
+
+func (p LogoffResponse) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 4
+	if len(p) < 4 {
+		return true
+	}
+
+	return false
+}
+
+func (p LogoffResponse) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p LogoffResponse) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 4)
+}
+
+// NewLogoffResponse returns a LOGOFF response.
+func NewLogoffResponse() LogoffResponse {
+	r := LogoffResponse(make([]byte, 4))
+	r.SetStructureSize()
+	return r
+}
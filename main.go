@@ -1,23 +1,220 @@
-package simba
-
-import (
-	"encoding/binary"
-	"net"
-)
-
-type Server struct {
-	Addr string
-}
-
-var (
-	le = binary.LittleEndian
-)
-
-func (srv *Server) ListenAndServe(port string) error {
-	// addr := srv.Addr
-	ln, err := net.Listen("tcp", port)
-	if err != nil {
-		return err
-	}
-	return srv.Serve(ln)
-}
+package simba
+
+import (
+	"encoding/binary"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PichuChen/simba/auth"
+	"github.com/PichuChen/simba/krb5"
+	"github.com/PichuChen/simba/ntlmssp"
+)
+
+type Server struct {
+	Addr string
+
+	// Dialects restricts the SMB2 dialects offered to clients, nil means
+	// every dialect in supportedDialects.
+	Dialects []Dialect
+
+	// Ciphers, SigningAlgorithms and CompressionAlgorithms are the server
+	// preferences for SMB 3.1.1 negotiate contexts, most preferred first.
+	// nil means the defaults.
+	Ciphers               []Cipher
+	SigningAlgorithms     []SingingAlgorithm
+	CompressionAlgorithms []CompressionAlgorithm
+
+	// CompressResponses compresses every large response on connections
+	// that negotiated compression, not only the READ responses the client
+	// asked to be compressed.
+	CompressResponses bool
+
+	// RequireSigning makes the server require signed messages on every
+	// authenticated session.
+	RequireSigning bool
+
+	// EncryptData makes the server encrypt every session, clients that can
+	// not encrypt are refused at SESSION_SETUP.
+	EncryptData bool
+
+	// MaxTransactSize, MaxReadSize and MaxWriteSize are announced in the
+	// NEGOTIATE response, zero means defaultMaxTransactSize.
+	MaxTransactSize uint32
+	MaxReadSize     uint32
+	MaxWriteSize    uint32
+
+	// ComputerName and DomainName are the NetBIOS names of the server in
+	// NTLM CHALLENGE messages, DNSComputerName and DNSDomainName their DNS
+	// counterparts. Empty names default to the host name and WORKGROUP.
+	ComputerName    string
+	DomainName      string
+	DNSComputerName string
+	DNSDomainName   string
+
+	// Credentials holds the accounts allowed to log on, nil means none.
+	Credentials CredentialStore
+
+	// DisableNTLM turns NTLM authentication off, clients must then log on
+	// with Kerberos.
+	DisableNTLM bool
+
+	// AllowAnonymous lets clients log on with NTLM without a user name
+	// nor password, as null sessions.
+	AllowAnonymous bool
+
+	// MapToGuest logs users without an account on as guests, the "bad
+	// user" mapping of Samba; a wrong password still fails. Anonymous and
+	// guest sessions have no session key so they are neither signed nor
+	// encrypted, and only reach shares that allow guests.
+	MapToGuest bool
+
+	// NTLMSessionLifetime is how long NTLM sessions last before clients
+	// must re-authenticate, zero means forever. Kerberos sessions last as
+	// long as the ticket.
+	NTLMSessionLifetime time.Duration
+
+	// Keytab holds the keys of the service principals of the server, such
+	// as cifs/host.example.com, for clients logging on with Kerberos. nil
+	// disables Kerberos.
+	Keytab *krb5.Keytab
+
+	// replayCache remembers the Kerberos authenticators accepted.
+	replayCache krb5.ReplayCache
+
+	// sessionTable holds the sessions of every connection.
+	sessionTable sessionTable
+
+	// shares holds the shares added with AddShare.
+	shares shareTable
+}
+
+var (
+	le = binary.LittleEndian
+)
+
+const defaultMaxTransactSize = 8388608 // 8MB
+
+func (srv *Server) ListenAndServe(port string) error {
+	// addr := srv.Addr
+	ln, err := net.Listen("tcp", port)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(ln)
+}
+
+// targetNames returns the names of the server in NTLM CHALLENGE messages.
+func (srv *Server) targetNames() ntlmssp.TargetNames {
+	hostname, _ := os.Hostname()
+	names := ntlmssp.TargetNames{
+		NetBIOSComputerName: srv.ComputerName,
+		NetBIOSDomainName:   srv.DomainName,
+		DNSComputerName:     srv.DNSComputerName,
+		DNSDomainName:       srv.DNSDomainName,
+	}
+	if names.NetBIOSComputerName == "" {
+		// NetBIOS names are at most 15 characters
+		name := strings.ToUpper(strings.SplitN(hostname, ".", 2)[0])
+		if len(name) > 15 {
+			name = name[:15]
+		}
+		names.NetBIOSComputerName = name
+	}
+	if names.NetBIOSDomainName == "" {
+		names.NetBIOSDomainName = "WORKGROUP"
+	}
+	if names.DNSComputerName == "" {
+		names.DNSComputerName = strings.ToLower(hostname)
+	}
+	return names
+}
+
+// mechTypes returns the SPNEGO mechanisms enabled on the server, most
+// preferred first: Kerberos when it has a keytab, then NTLM.
+func (srv *Server) mechTypes() auth.MechTypeList {
+	var mechTypes auth.MechTypeList
+	if srv.Keytab != nil {
+		mechTypes = append(mechTypes, auth.MSKerberosMechType, auth.KerberosMechType)
+	}
+	if !srv.DisableNTLM {
+		mechTypes = append(mechTypes, auth.NlmpMechType)
+	}
+	return mechTypes
+}
+
+// negTokenInit returns the security buffer of the NEGOTIATE response, the
+// NegTokenInit2 offering the enabled mechanisms. It is empty when none is
+// enabled, leaving the choice to the client.
+// MS-SMB2 3.3.5.4 Receiving an SMB2 NEGOTIATE Request
+func (srv *Server) negTokenInit() []byte {
+	mechTypes := srv.mechTypes()
+	if len(mechTypes) == 0 {
+		return nil
+	}
+	token, err := auth.NewNegTokenInit2(mechTypes)
+	if err != nil {
+		log.Printf("negTokenInit: %v", err)
+		return nil
+	}
+	return token
+}
+
+// ntlmExpirationTime returns the expiration time of a session NTLM
+// authenticates now, zero when it does not expire.
+func (srv *Server) ntlmExpirationTime() time.Time {
+	if srv.NTLMSessionLifetime == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(srv.NTLMSessionLifetime)
+}
+
+func (srv *Server) maxTransactSize() uint32 {
+	if srv.MaxTransactSize == 0 {
+		return defaultMaxTransactSize
+	}
+	return srv.MaxTransactSize
+}
+
+func (srv *Server) maxReadSize() uint32 {
+	if srv.MaxReadSize == 0 {
+		return defaultMaxTransactSize
+	}
+	return srv.MaxReadSize
+}
+
+func (srv *Server) maxWriteSize() uint32 {
+	if srv.MaxWriteSize == 0 {
+		return defaultMaxTransactSize
+	}
+	return srv.MaxWriteSize
+}
+
+func (srv *Server) dialects() []Dialect {
+	if srv.Dialects == nil {
+		return supportedDialects
+	}
+	return srv.Dialects
+}
+
+// selectDialect returns the highest dialect both offered by the client and
+// enabled on the server.
+// MS-SMB2 3.3.5.4 Receiving an SMB2 NEGOTIATE Request
+func (srv *Server) selectDialect(clientDialects []Dialect) (Dialect, bool) {
+	var selected Dialect
+	found := false
+	for _, d := range srv.dialects() {
+		if !d.isSupported() {
+			continue
+		}
+		for _, cd := range clientDialects {
+			if d == cd && (!found || d > selected) {
+				selected = d
+				found = true
+			}
+		}
+	}
+	return selected, found
+}
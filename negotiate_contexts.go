@@ -0,0 +1,257 @@
+package simba
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"unicode/utf16"
+)
+
+// preauthSaltSize is the length of the salt sent in the server's
+// SMB2_PREAUTH_INTEGRITY_CAPABILITIES, as Windows does.
+const preauthSaltSize = 32
+
+// defaultCiphers, defaultSigningAlgorithms and defaultCompressionAlgorithms
+// are the server preferences used when the matching Server fields are nil.
+var (
+	defaultCiphers = []Cipher{
+		SMB2_ENCRYPTION_AES128_GCM,
+		SMB2_ENCRYPTION_AES128_CCM,
+		SMB2_ENCRYPTION_AES256_GCM,
+		SMB2_ENCRYPTION_AES256_CCM,
+	}
+	defaultSigningAlgorithms = []SingingAlgorithm{
+		SMB2_SIGNING_ALGORITHM_AES_GMAC,
+		SMB2_SIGNING_ALGORITHM_AES_CMAC,
+		SMB2_SIGNING_ALGORITHM_HMAC_SHA256,
+	}
+	defaultCompressionAlgorithms = []CompressionAlgorithm{
+		SMB2_COMPRESSION_CAPABILITIES_LZ77,
+		SMB2_COMPRESSION_CAPABILITIES_LZ77_HUFF,
+		SMB2_COMPRESSION_CAPABILITIES_LZNT1,
+		SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1,
+	}
+)
+
+func (srv *Server) ciphers() []Cipher {
+	if srv.Ciphers == nil {
+		return defaultCiphers
+	}
+	return srv.Ciphers
+}
+
+func (srv *Server) compressionAlgorithms() []CompressionAlgorithm {
+	if srv.CompressionAlgorithms == nil {
+		return defaultCompressionAlgorithms
+	}
+	return srv.CompressionAlgorithms
+}
+
+func (srv *Server) signingAlgorithms() []SingingAlgorithm {
+	if srv.SigningAlgorithms == nil {
+		return defaultSigningAlgorithms
+	}
+	return srv.SigningAlgorithms
+}
+
+// negotiateContextOffer holds the negotiate contexts sent by a 3.1.1
+// client, nil for the ones it did not send.
+type negotiateContextOffer struct {
+	preauth     PreauthIntegrityCapability
+	encryption  EncryptionCapability
+	compression CompressionCapability
+	signing     SigningCapability
+	transport   TransportCapability
+	rdma        RDMATransformCapability
+	netname     string
+}
+
+// parseNegotiateContexts decodes the contexts of a 3.1.1 NEGOTIATE request.
+// The returned error is a statusError to answer the request with.
+// MS-SMB2 3.3.5.4 Receiving an SMB2 NEGOTIATE Request
+func parseNegotiateContexts(list []NegotiateContext) (*negotiateContextOffer, error) {
+	offer := &negotiateContextOffer{}
+	seen := map[ContextType]bool{}
+	for _, c := range list {
+		t := c.ContextType()
+		data := c.Data()
+		if seen[t] && t != SMB2_NETNAME_NEGOTIATE_CONTEXT_ID {
+			log.Printf("parseNegotiateContexts: duplicate %v", t)
+			return nil, statusError(STATUS_INVALID_PARAMETER)
+		}
+		seen[t] = true
+
+		switch t {
+		case SMB2_PREAUTH_INTEGRITY_CAPABILITIES:
+			p := PreauthIntegrityCapability(data)
+			if len(p) < 4 || p.HashAlgorithmCount() == 0 ||
+				len(p) < 4+2*int(p.HashAlgorithmCount())+int(p.SaltLength()) {
+				return nil, statusError(STATUS_INVALID_PARAMETER)
+			}
+			offer.preauth = p
+		case SMB2_ENCRYPTION_CAPABILITIES:
+			e := EncryptionCapability(data)
+			if len(e) < 2 || e.CipherCount() == 0 || len(e) < 2+2*int(e.CipherCount()) {
+				return nil, statusError(STATUS_INVALID_PARAMETER)
+			}
+			offer.encryption = e
+		case SMB2_COMPRESSION_CAPABILITIES:
+			cc := CompressionCapability(data)
+			if len(cc) < 8 || cc.CompressionAlgorithmCount() == 0 ||
+				len(cc) < 8+2*int(cc.CompressionAlgorithmCount()) {
+				return nil, statusError(STATUS_INVALID_PARAMETER)
+			}
+			offer.compression = cc
+		case SMB2_SIGNING_CAPABILITIES:
+			sc := SigningCapability(data)
+			if len(sc) < 2 || sc.SigningAlgorithmCount() == 0 || len(sc) < 2+2*int(sc.SigningAlgorithmCount()) {
+				return nil, statusError(STATUS_INVALID_PARAMETER)
+			}
+			offer.signing = sc
+		case SMB2_TRANSPORT_CAPABILITIES:
+			if len(data) < 4 {
+				return nil, statusError(STATUS_INVALID_PARAMETER)
+			}
+			offer.transport = TransportCapability(data)
+		case SMB2_RDMA_TRANSFORM_CAPABILITIES:
+			r := RDMATransformCapability(data)
+			if len(r) < 8 || r.TransformCount() == 0 || len(r) < 8+2*int(r.TransformCount()) {
+				return nil, statusError(STATUS_INVALID_PARAMETER)
+			}
+			offer.rdma = r
+		case SMB2_NETNAME_NEGOTIATE_CONTEXT_ID:
+			offer.netname = decodeUTF16(data)
+		default:
+			// MS-SMB2 3.3.5.4, unknown contexts MUST be ignored
+			log.Printf("parseNegotiateContexts: ignore context type 0x%04x", uint16(t))
+		}
+	}
+
+	if offer.preauth == nil {
+		return nil, statusError(STATUS_INVALID_PARAMETER)
+	}
+	return offer, nil
+}
+
+// selectNegotiateContexts picks the algorithms of the connection from the
+// client offer and the server preferences, and returns the contexts of the
+// NEGOTIATE response.
+func (c *conn) selectNegotiateContexts(offer *negotiateContextOffer) ([]NegotiateContext, error) {
+	// MS-SMB2 3.3.5.4, AES-CMAC unless the client sends a signing context
+	c.signingAlgorithmId = SMB2_SIGNING_ALGORITHM_AES_CMAC
+	c.preauthIntegrityHashId = 0
+	for _, a := range offer.preauth.HashAlgorithms() {
+		if a == SMB2_PREAUTH_INTEGRITY_SHA512 {
+			c.preauthIntegrityHashId = a
+			break
+		}
+	}
+	if c.preauthIntegrityHashId == 0 {
+		return nil, statusError(STATUS_SMB_NO_PREAUTH_INTEGRITY_HASH_OVERLAP)
+	}
+
+	salt := make([]byte, preauthSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("preauth salt: %w", err)
+	}
+	contexts := []NegotiateContext{
+		NewNegotiateContext(SMB2_PREAUTH_INTEGRITY_CAPABILITIES,
+			NewPreauthIntegrityCapability([]HashAlgorithm{c.preauthIntegrityHashId}, salt)),
+	}
+
+	if offer.encryption != nil {
+		// a zero CipherId tells the client there is no common cipher
+		c.cipherId = 0
+		for _, s := range c.server.ciphers() {
+			if containsCipher(offer.encryption.Ciphers(), s) {
+				c.cipherId = s
+				break
+			}
+		}
+		contexts = append(contexts, NewNegotiateContext(SMB2_ENCRYPTION_CAPABILITIES,
+			NewEncryptionCapability([]Cipher{c.cipherId})))
+	}
+
+	if offer.signing != nil {
+		// keep AES-CMAC when there is no common algorithm
+		for _, s := range c.server.signingAlgorithms() {
+			if containsSigningAlgorithm(offer.signing.SigningAlgorithms(), s) {
+				c.signingAlgorithmId = s
+				break
+			}
+		}
+		contexts = append(contexts, NewNegotiateContext(SMB2_SIGNING_CAPABILITIES,
+			NewSigningCapability([]SingingAlgorithm{c.signingAlgorithmId})))
+	}
+
+	if offer.compression != nil {
+		contexts = append(contexts, NewNegotiateContext(SMB2_COMPRESSION_CAPABILITIES,
+			c.selectCompression(offer.compression)))
+	}
+
+	// Transport level security and RDMA transforms are only relevant over
+	// QUIC and SMB Direct, neither of which is served, so those contexts are
+	// not answered.
+	if offer.netname != "" {
+		log.Printf("selectNegotiateContexts: client connects to %q", offer.netname)
+	}
+
+	return contexts, nil
+}
+
+// selectCompression sets the compression algorithms of the connection to
+// the ones both sides support, in the server order, and returns the
+// SMB2_COMPRESSION_CAPABILITIES of the response. Pattern_V1 is only used
+// in chained compressed messages.
+// MS-SMB2 3.3.5.4 Receiving an SMB2 NEGOTIATE Request
+func (c *conn) selectCompression(offer CompressionCapability) CompressionCapability {
+	c.compressionChained = offer.Flags()&SMB2_COMPRESSION_CAPABILITIES_FLAG_CHAINED != 0
+	c.compressionIds = nil
+	for _, a := range c.server.compressionAlgorithms() {
+		if a == SMB2_COMPRESSION_CAPABILITIES_NONE || !containsCompressionAlgorithm(offer.CompressionAlgorithms(), a) {
+			continue
+		}
+		if a == SMB2_COMPRESSION_CAPABILITIES_PATTERN_V1 && !c.compressionChained {
+			continue
+		}
+		c.compressionIds = append(c.compressionIds, a)
+	}
+
+	if len(c.compressionIds) == 0 {
+		c.compressionChained = false
+		return NewCompressionCapability([]CompressionAlgorithm{SMB2_COMPRESSION_CAPABILITIES_NONE},
+			SMB2_COMPRESSION_CAPABILITIES_FLAG_NONE)
+	}
+	flags := SMB2_COMPRESSION_CAPABILITIES_FLAG_NONE
+	if c.compressionChained {
+		flags = SMB2_COMPRESSION_CAPABILITIES_FLAG_CHAINED
+	}
+	return NewCompressionCapability(c.compressionIds, flags)
+}
+
+func containsCipher(list []Cipher, v Cipher) bool {
+	for _, c := range list {
+		if c == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSigningAlgorithm(list []SingingAlgorithm, v SingingAlgorithm) bool {
+	for _, a := range list {
+		if a == v {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeUTF16 decodes a little-endian UTF-16 string without terminator.
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = le.Uint16(b[i*2 : i*2+2])
+	}
+	return string(utf16.Decode(u))
+}
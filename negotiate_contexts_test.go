@@ -0,0 +1,135 @@
+package simba
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestNegotiateContexts(t *testing.T) {
+	// windows 11 negotiate request, offering SHA-512, AES-128-GCM/CCM
+	input, _ := hex.DecodeString("2400050001000000440000002c51da83fcb210b928e7cfd82ab2e9a870000000020000001103020300031002020200000100260000000000010020000100a7c3f2609f1852aa4b6ec3f093ff21ede8587383e88e5c633848e007066ff41e00000200060000000000020002000100")
+
+	cases := []struct {
+		name     string
+		server   *Server
+		expected map[string]interface{}
+	}{
+		{
+			name:   "default preferences",
+			server: &Server{},
+			expected: map[string]interface{}{
+				"Cipher":   SMB2_ENCRYPTION_AES128_GCM,
+				"Contexts": []ContextType{SMB2_PREAUTH_INTEGRITY_CAPABILITIES, SMB2_ENCRYPTION_CAPABILITIES},
+			},
+		},
+		{
+			name:   "prefer CCM",
+			server: &Server{Ciphers: []Cipher{SMB2_ENCRYPTION_AES256_GCM, SMB2_ENCRYPTION_AES128_CCM}},
+			expected: map[string]interface{}{
+				"Cipher":   SMB2_ENCRYPTION_AES128_CCM,
+				"Contexts": []ContextType{SMB2_PREAUTH_INTEGRITY_CAPABILITIES, SMB2_ENCRYPTION_CAPABILITIES},
+			},
+		},
+		{
+			name:   "no common cipher",
+			server: &Server{Ciphers: []Cipher{SMB2_ENCRYPTION_AES256_GCM}},
+			expected: map[string]interface{}{
+				"Cipher":   Cipher(0),
+				"Contexts": []ContextType{SMB2_PREAUTH_INTEGRITY_CAPABILITIES, SMB2_ENCRYPTION_CAPABILITIES},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			offer, err := parseNegotiateContexts(NegotiateRequest(input).NegotiateContextList())
+			if err != nil {
+				t.Fatalf("parseNegotiateContexts() error = %v", err)
+			}
+			conn := &conn{server: c.server}
+			contexts, err := conn.selectNegotiateContexts(offer)
+			if err != nil {
+				t.Fatalf("selectNegotiateContexts() error = %v", err)
+			}
+			if conn.preauthIntegrityHashId != SMB2_PREAUTH_INTEGRITY_SHA512 {
+				t.Errorf("preauthIntegrityHashId = %v, want %v", conn.preauthIntegrityHashId, SMB2_PREAUTH_INTEGRITY_SHA512)
+			}
+			if conn.cipherId != c.expected["Cipher"].(Cipher) {
+				t.Errorf("cipherId = %v, want %v", conn.cipherId, c.expected["Cipher"])
+			}
+
+			// serialize the contexts in a response after a 74 bytes security buffer
+			offset := align8(64 + 64 + 74)
+			r := NegotiateResponse(make([]byte, offset-64+negotiateContextsLength(contexts)))
+			r.SetNegotiateContextOffset(uint32(offset))
+			r.SetNegotiateContextCount(uint16(len(contexts)))
+			r.SetNegotiateContexts(contexts)
+
+			actual := r.NegotiateContexts()
+			expected := c.expected["Contexts"].([]ContextType)
+			if len(actual) != len(expected) {
+				t.Fatalf("NegotiateContexts() = %v, want %v", actual, expected)
+			}
+			for i, v := range actual {
+				if v.ContextType() != expected[i] {
+					t.Errorf("NegotiateContexts()[%d] = %v, want %v", i, v.ContextType(), expected[i])
+				}
+			}
+			preauth := PreauthIntegrityCapability(actual[0].Data())
+			if len(preauth.Salt()) != preauthSaltSize {
+				t.Errorf("Salt() = %x, want %d bytes", preauth.Salt(), preauthSaltSize)
+			}
+			ciphers := EncryptionCapability(actual[1].Data()).Ciphers()
+			if len(ciphers) != 1 || ciphers[0] != c.expected["Cipher"].(Cipher) {
+				t.Errorf("Ciphers() = %v, want %v", ciphers, c.expected["Cipher"])
+			}
+		})
+	}
+}
+
+func TestParseNegotiateContextsInvalid(t *testing.T) {
+	preauth := NewNegotiateContext(SMB2_PREAUTH_INTEGRITY_CAPABILITIES,
+		NewPreauthIntegrityCapability([]HashAlgorithm{SMB2_PREAUTH_INTEGRITY_SHA512}, make([]byte, 32)))
+	encryption := NewNegotiateContext(SMB2_ENCRYPTION_CAPABILITIES, NewEncryptionCapability(nil))
+
+	cases := []struct {
+		name     string
+		input    []NegotiateContext
+		expected uint32
+	}{
+		{
+			name:     "missing preauth",
+			input:    []NegotiateContext{NewNegotiateContext(SMB2_ENCRYPTION_CAPABILITIES, NewEncryptionCapability([]Cipher{SMB2_ENCRYPTION_AES128_GCM}))},
+			expected: STATUS_INVALID_PARAMETER,
+		},
+		{
+			name:     "duplicate preauth",
+			input:    []NegotiateContext{preauth, preauth},
+			expected: STATUS_INVALID_PARAMETER,
+		},
+		{
+			name:     "empty cipher list",
+			input:    []NegotiateContext{preauth, encryption},
+			expected: STATUS_INVALID_PARAMETER,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := parseNegotiateContexts(c.input)
+			if errorStatus(err) != c.expected {
+				t.Errorf("parseNegotiateContexts() error = %v, want 0x%08x", err, c.expected)
+			}
+		})
+	}
+
+	// unknown hash algorithm
+	offer, err := parseNegotiateContexts([]NegotiateContext{NewNegotiateContext(SMB2_PREAUTH_INTEGRITY_CAPABILITIES,
+		NewPreauthIntegrityCapability([]HashAlgorithm{0x0002}, nil))})
+	if err != nil {
+		t.Fatalf("parseNegotiateContexts() error = %v", err)
+	}
+	if _, err := (&conn{server: &Server{}}).selectNegotiateContexts(offer); errorStatus(err) != STATUS_SMB_NO_PREAUTH_INTEGRITY_HASH_OVERLAP {
+		t.Errorf("selectNegotiateContexts() error = %v, want 0x%08x", err, STATUS_SMB_NO_PREAUTH_INTEGRITY_HASH_OVERLAP)
+	}
+}
@@ -1,3 +1,3 @@
-package simba
-
-type NetnameNegotitateContentID string
+package simba
+
+type NetnameNegotitateContentID string
@@ -0,0 +1,207 @@
+package ntlmssp
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+// MS-NLMP 2.2.1.3 AUTHENTICATE_MESSAGE
+type AuthenticateMessage []byte
+
+// authenticateMessageSize is the size of an AUTHENTICATE message up to its
+// payload when Version and MIC are present.
+const authenticateMessageSize = 88
+
+func (p AuthenticateMessage) IsInvalid() bool {
+	// Signature, MessageType, the six fields of the payload and
+	// NegotiateFlags
+	if len(p) < 64 {
+		return true
+	}
+
+	if string(p[0:8]) != "NTLMSSP\x00" {
+		return true
+	}
+	// Check MessageType == 3
+	if binary.LittleEndian.Uint32(p[8:12]) != 3 {
+		return true
+	}
+
+	return false
+}
+
+// field returns the payload described by the Len, MaxLen and BufferOffset
+// fields at offset, nil when it is empty or out of bounds.
+func (p AuthenticateMessage) field(offset int) []byte {
+	if len(p) < offset+8 {
+		return nil
+	}
+	l := uint32(binary.LittleEndian.Uint16(p[offset : offset+2]))
+	bufferOffset := binary.LittleEndian.Uint32(p[offset+4 : offset+8])
+	if l == 0 || uint64(bufferOffset)+uint64(l) > uint64(len(p)) {
+		return nil
+	}
+	return p[bufferOffset : bufferOffset+l]
+}
+
+// string decodes the string field at offset, UTF-16LE when
+// NTLMSSP_NEGOTIATE_UNICODE was negotiated.
+func (p AuthenticateMessage) string(offset int) string {
+	b := p.field(offset)
+	if p.NegotiateFlags()&NTLMSSP_NEGOTIATE_UNICODE == 0 {
+		return string(b)
+	}
+	return decodeUTF16(b)
+}
+
+// payloadOffset returns the offset of the first field of the payload, the
+// fixed part of the message ends there.
+func (p AuthenticateMessage) payloadOffset() int {
+	offset := len(p)
+	for i := 12; i < 60; i += 8 {
+		if binary.LittleEndian.Uint16(p[i:i+2]) == 0 {
+			continue
+		}
+		if o := int(binary.LittleEndian.Uint32(p[i+4 : i+8])); o < offset {
+			offset = o
+		}
+	}
+	return offset
+}
+
+func (p AuthenticateMessage) LmChallengeResponse() []byte {
+	return p.field(12)
+}
+
+func (p AuthenticateMessage) NtChallengeResponse() []byte {
+	return p.field(20)
+}
+
+func (p AuthenticateMessage) DomainName() string {
+	return p.string(28)
+}
+
+func (p AuthenticateMessage) UserName() string {
+	return p.string(36)
+}
+
+func (p AuthenticateMessage) Workstation() string {
+	return p.string(44)
+}
+
+func (p AuthenticateMessage) EncryptedRandomSessionKey() []byte {
+	return p.field(52)
+}
+
+// TargetInfo returns the copy of the target info the client returned in
+// its NTLMv2 response, nil for other responses.
+// MS-NLMP 2.2.2.7 NTLM v2: NTLMv2_CLIENT_CHALLENGE
+func (p AuthenticateMessage) TargetInfo() TargetInfo {
+	resp := p.NtChallengeResponse()
+	if len(resp) < 16+ntlmv2ClientChallengeSize {
+		return nil
+	}
+	return TargetInfo(resp[16+ntlmv2ClientChallengeSize:])
+}
+
+// IsAnonymous reports whether the client logs on anonymously: no user
+// name and no NT response, with an empty or single zero byte LM response.
+// MS-NLMP 3.2.5.1.2 Server Receives an AUTHENTICATE_MESSAGE from the Client
+func (p AuthenticateMessage) IsAnonymous() bool {
+	lm := p.LmChallengeResponse()
+	return p.UserName() == "" && len(p.NtChallengeResponse()) == 0 &&
+		(len(lm) == 0 || len(lm) == 1 && lm[0] == 0)
+}
+
+func (p AuthenticateMessage) NegotiateFlags() NegotiateFlags {
+	return NegotiateFlags(binary.LittleEndian.Uint32(p[60:64]))
+}
+
+// Version returns the version of the client, nil unless
+// NTLMSSP_NEGOTIATE_VERSION was negotiated.
+func (p AuthenticateMessage) Version() Version {
+	if p.NegotiateFlags()&NTLMSSP_NEGOTIATE_VERSION == 0 || p.payloadOffset() < 72 {
+		return nil
+	}
+	return Version(p[64:72])
+}
+
+// MIC returns the message integrity code of the client, nil when the
+// payload starts before the MIC field. A zero MIC may still be present,
+// MsvAvFlags in the client target info tells whether it was computed.
+func (p AuthenticateMessage) MIC() []byte {
+	if p.payloadOffset() < authenticateMessageSize {
+		return nil
+	}
+	return p[72:88]
+}
+
+func (p AuthenticateMessage) SetMIC(v []byte) {
+	if p.payloadOffset() < authenticateMessageSize {
+		return
+	}
+	copy(p[72:88], v)
+}
+
+// AuthenticateMessageFields are the contents of an AUTHENTICATE message.
+type AuthenticateMessageFields struct {
+	NegotiateFlags            NegotiateFlags
+	LmChallengeResponse       []byte
+	NtChallengeResponse       []byte
+	DomainName                string
+	UserName                  string
+	Workstation               string
+	EncryptedRandomSessionKey []byte
+	Version                   Version
+}
+
+// NewAuthenticateMessage returns the AUTHENTICATE message of f, with room
+// for a MIC set afterwards with SetMIC. The strings are UTF-16LE when
+// NTLMSSP_NEGOTIATE_UNICODE is set.
+func NewAuthenticateMessage(f AuthenticateMessageFields) AuthenticateMessage {
+	encode := func(s string) []byte {
+		if f.NegotiateFlags&NTLMSSP_NEGOTIATE_UNICODE != 0 {
+			return encodeUTF16(s)
+		}
+		return []byte(s)
+	}
+	fields := [][]byte{
+		f.LmChallengeResponse,
+		f.NtChallengeResponse,
+		encode(f.DomainName),
+		encode(f.UserName),
+		encode(f.Workstation),
+		f.EncryptedRandomSessionKey,
+	}
+
+	p := AuthenticateMessage(make([]byte, authenticateMessageSize))
+	copy(p[0:8], "NTLMSSP\x00")
+	binary.LittleEndian.PutUint32(p[8:12], 3)
+	for i, v := range fields {
+		offset := 12 + 8*i
+		binary.LittleEndian.PutUint16(p[offset:offset+2], uint16(len(v)))
+		binary.LittleEndian.PutUint16(p[offset+2:offset+4], uint16(len(v)))
+		binary.LittleEndian.PutUint32(p[offset+4:offset+8], uint32(len(p)))
+		p = append(p, v...)
+	}
+	binary.LittleEndian.PutUint32(p[60:64], uint32(f.NegotiateFlags))
+	copy(p[64:72], f.Version)
+	return p
+}
+
+func encodeUTF16(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u))
+	for i, v := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], v)
+	}
+	return b
+}
+
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
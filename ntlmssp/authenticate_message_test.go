@@ -0,0 +1,161 @@
+package ntlmssp
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestAuthenticateMessage(t *testing.T) {
+	version := Version(MustDecodeHex("0a0063450000000f"))
+	cases := []struct {
+		name     string
+		fields   AuthenticateMessageFields
+		expected map[string]interface{}
+	}{
+		{
+			"unicode",
+			AuthenticateMessageFields{
+				NegotiateFlags:            NTLMSSP_NEGOTIATE_UNICODE | NTLMSSP_NEGOTIATE_KEY_EXCH | NTLMSSP_NEGOTIATE_VERSION,
+				LmChallengeResponse:       make([]byte, 24),
+				NtChallengeResponse:       ntlmv2Response,
+				DomainName:                "Domain",
+				UserName:                  "Usér",
+				Workstation:               "COMPUTER",
+				EncryptedRandomSessionKey: MustDecodeHex("c5dad2544fc9799094ce1ce90bc9d03e"),
+				Version:                   version,
+			},
+			map[string]interface{}{
+				"DomainName":  "Domain",
+				"UserName":    "Usér",
+				"Workstation": "COMPUTER",
+				"Version":     []byte(version),
+				"Length":      88 + 24 + len(ntlmv2Response) + 12 + 8 + 16 + 16,
+			},
+		},
+		{
+			"oem",
+			AuthenticateMessageFields{
+				NegotiateFlags:      NTLMSSP_NEGOTIATE_OEM,
+				NtChallengeResponse: ntlmv2Response,
+				DomainName:          "DOMAIN",
+				UserName:            "USER",
+			},
+			map[string]interface{}{
+				"DomainName":  "DOMAIN",
+				"UserName":    "USER",
+				"Workstation": "",
+				"Version":     []byte(nil),
+				"Length":      88 + len(ntlmv2Response) + 6 + 4,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := NewAuthenticateMessage(c.fields)
+			if p.IsInvalid() {
+				t.Fatalf("IsInvalid() = true")
+			}
+			if len(p) != c.expected["Length"] {
+				t.Errorf("len = %d, want %v", len(p), c.expected["Length"])
+			}
+			if p.NegotiateFlags() != c.fields.NegotiateFlags {
+				t.Errorf("NegotiateFlags() = 0x%08x", uint32(p.NegotiateFlags()))
+			}
+			if !compareBytes(p.LmChallengeResponse(), c.fields.LmChallengeResponse) {
+				t.Errorf("LmChallengeResponse() = %x", p.LmChallengeResponse())
+			}
+			if !compareBytes(p.NtChallengeResponse(), c.fields.NtChallengeResponse) {
+				t.Errorf("NtChallengeResponse() = %x", p.NtChallengeResponse())
+			}
+			if !compareBytes(p.EncryptedRandomSessionKey(), c.fields.EncryptedRandomSessionKey) {
+				t.Errorf("EncryptedRandomSessionKey() = %x", p.EncryptedRandomSessionKey())
+			}
+			for name, actual := range map[string]string{
+				"DomainName":  p.DomainName(),
+				"UserName":    p.UserName(),
+				"Workstation": p.Workstation(),
+			} {
+				if actual != c.expected[name] {
+					t.Errorf("%s() = %q, want %q", name, actual, c.expected[name])
+				}
+			}
+			if v := []byte(p.Version()); !compareBytes(v, c.expected["Version"].([]byte)) {
+				t.Errorf("Version() = %x, want %x", v, c.expected["Version"])
+			}
+
+			if targetInfo := p.TargetInfo(); targetInfo.NbComputerName() != "Server" {
+				t.Errorf("TargetInfo() = %x", []byte(targetInfo))
+			}
+
+			mic := MustDecodeHex("00112233445566778899aabbccddeeff")
+			p.SetMIC(mic)
+			if !compareBytes(p.MIC(), mic) {
+				t.Errorf("MIC() = %x, want %x", p.MIC(), mic)
+			}
+		})
+	}
+}
+
+func TestAuthenticateMessageBounds(t *testing.T) {
+	p := NewAuthenticateMessage(AuthenticateMessageFields{
+		NegotiateFlags:      NTLMSSP_NEGOTIATE_UNICODE,
+		NtChallengeResponse: ntlmv2Response,
+		UserName:            "User",
+	})
+
+	// the payload starts right after NegotiateFlags: no Version nor MIC
+	short := append(AuthenticateMessage{}, p[:64]...)
+	short = append(short, p[88:]...)
+	for i := 12; i < 60; i += 8 {
+		offset := binary.LittleEndian.Uint32(short[i+4 : i+8])
+		binary.LittleEndian.PutUint32(short[i+4:i+8], offset-(88-64))
+	}
+	if short.MIC() != nil || short.Version() != nil {
+		t.Errorf("MIC() = %x, Version() = %x, want none", short.MIC(), []byte(short.Version()))
+	}
+	if short.UserName() != "User" {
+		t.Errorf("UserName() = %q", short.UserName())
+	}
+
+	// fields past the end of a truncated message are empty
+	truncated := p[:len(p)-2]
+	if truncated.UserName() != "" {
+		t.Errorf("UserName() of a truncated message = %q", truncated.UserName())
+	}
+	if !compareBytes(truncated.NtChallengeResponse(), ntlmv2Response) {
+		t.Errorf("NtChallengeResponse() = %x", truncated.NtChallengeResponse())
+	}
+
+	for name, b := range map[string][]byte{
+		"short":     p[:63],
+		"signature": append([]byte("NTLMSSQ\x00"), p[8:]...),
+		"type":      append(append([]byte{}, p[:8]...), append([]byte{2, 0, 0, 0}, p[12:]...)...),
+	} {
+		if !AuthenticateMessage(b).IsInvalid() {
+			t.Errorf("%s: IsInvalid() = false", name)
+		}
+	}
+}
+
+func TestAuthenticateMessageIsAnonymous(t *testing.T) {
+	cases := []struct {
+		name     string
+		fields   AuthenticateMessageFields
+		expected bool
+	}{
+		{"empty", AuthenticateMessageFields{}, true},
+		{"zero lm response", AuthenticateMessageFields{LmChallengeResponse: []byte{0}}, true},
+		{"lm response", AuthenticateMessageFields{LmChallengeResponse: []byte{1}}, false},
+		{"nt response", AuthenticateMessageFields{NtChallengeResponse: ntlmv2Response}, false},
+		{"user", AuthenticateMessageFields{UserName: "User"}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.fields.NegotiateFlags |= NTLMSSP_NEGOTIATE_UNICODE
+			if actual := NewAuthenticateMessage(c.fields).IsAnonymous(); actual != c.expected {
+				t.Errorf("IsAnonymous() = %v, want %v", actual, c.expected)
+			}
+		})
+	}
+}
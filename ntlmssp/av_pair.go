@@ -0,0 +1,227 @@
+package ntlmssp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+type AvId uint16
+
+const (
+	// MS-NLMP 2.2.2.1 AV_PAIR
+	MsvAvEOL             AvId = 0x0000
+	MsvAvNbComputerName  AvId = 0x0001
+	MsvAvNbDomainName    AvId = 0x0002
+	MsvAvDnsComputerName AvId = 0x0003
+	MsvAvDnsDomainName   AvId = 0x0004
+	MsvAvDnsTreeName     AvId = 0x0005
+	MsvAvFlags           AvId = 0x0006
+	MsvAvTimestamp       AvId = 0x0007
+	MsvAvSingleHost      AvId = 0x0008
+	MsvAvTargetName      AvId = 0x0009
+	MsvAvChannelBindings AvId = 0x000A
+)
+
+func (id AvId) String() string {
+	switch id {
+	case MsvAvEOL:
+		return "MsvAvEOL"
+	case MsvAvNbComputerName:
+		return "MsvAvNbComputerName"
+	case MsvAvNbDomainName:
+		return "MsvAvNbDomainName"
+	case MsvAvDnsComputerName:
+		return "MsvAvDnsComputerName"
+	case MsvAvDnsDomainName:
+		return "MsvAvDnsDomainName"
+	case MsvAvDnsTreeName:
+		return "MsvAvDnsTreeName"
+	case MsvAvFlags:
+		return "MsvAvFlags"
+	case MsvAvTimestamp:
+		return "MsvAvTimestamp"
+	case MsvAvSingleHost:
+		return "MsvAvSingleHost"
+	case MsvAvTargetName:
+		return "MsvAvTargetName"
+	case MsvAvChannelBindings:
+		return "MsvAvChannelBindings"
+	}
+	return fmt.Sprintf("AvId(0x%04x)", uint16(id))
+}
+
+const (
+	// Value of MsvAvFlags
+	MSV_AV_FLAG_AUTHENTICATION_CONSTRAINED uint32 = 0x00000001
+	MSV_AV_FLAG_MIC_PRESENT                uint32 = 0x00000002
+	MSV_AV_FLAG_UNTRUSTED_SPN              uint32 = 0x00000004
+)
+
+// TargetInfo is a list of AV_PAIR terminated by MsvAvEOL, the TargetInfo of
+// a CHALLENGE message or the copy a client returns in its NTLMv2 response.
+// The setters return a new list, p is left unchanged.
+type TargetInfo []byte
+
+// next returns the first AV pair of p and the pairs after it, ok is false
+// when the pair does not fit in p.
+func (p TargetInfo) next() (id AvId, value []byte, rest TargetInfo, ok bool) {
+	if len(p) < 4 {
+		return 0, nil, nil, false
+	}
+	id = AvId(binary.LittleEndian.Uint16(p[0:2]))
+	l := int(binary.LittleEndian.Uint16(p[2:4]))
+	if 4+l > len(p) {
+		return 0, nil, nil, false
+	}
+	return id, p[4 : 4+l], p[4+l:], true
+}
+
+// IsInvalid reports whether a pair overruns the list or MsvAvEOL is
+// missing.
+func (p TargetInfo) IsInvalid() bool {
+	for {
+		id, _, rest, ok := p.next()
+		if !ok {
+			return true
+		}
+		if id == MsvAvEOL {
+			return false
+		}
+		p = rest
+	}
+}
+
+// Ids returns the AvId of every pair before MsvAvEOL.
+func (p TargetInfo) Ids() []AvId {
+	var res []AvId
+	for {
+		id, _, rest, ok := p.next()
+		if !ok || id == MsvAvEOL {
+			return res
+		}
+		res = append(res, id)
+		p = rest
+	}
+}
+
+// Value returns the value of the pair id.
+func (p TargetInfo) Value(id AvId) ([]byte, bool) {
+	for {
+		pairId, value, rest, ok := p.next()
+		if !ok || pairId == MsvAvEOL {
+			return nil, false
+		}
+		if pairId == id {
+			return value, true
+		}
+		p = rest
+	}
+}
+
+// stringValue returns the UTF-16LE string value of the pair id.
+func (p TargetInfo) stringValue(id AvId) string {
+	v, _ := p.Value(id)
+	return decodeUTF16(v)
+}
+
+func (p TargetInfo) NbComputerName() string {
+	return p.stringValue(MsvAvNbComputerName)
+}
+
+func (p TargetInfo) NbDomainName() string {
+	return p.stringValue(MsvAvNbDomainName)
+}
+
+func (p TargetInfo) DnsComputerName() string {
+	return p.stringValue(MsvAvDnsComputerName)
+}
+
+func (p TargetInfo) DnsDomainName() string {
+	return p.stringValue(MsvAvDnsDomainName)
+}
+
+func (p TargetInfo) DnsTreeName() string {
+	return p.stringValue(MsvAvDnsTreeName)
+}
+
+// TargetName returns the SPN of the server the client authenticates to.
+func (p TargetInfo) TargetName() string {
+	return p.stringValue(MsvAvTargetName)
+}
+
+func (p TargetInfo) Flags() uint32 {
+	v, ok := p.Value(MsvAvFlags)
+	if !ok || len(v) != 4 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(v)
+}
+
+// Timestamp returns the FILETIME of MsvAvTimestamp, ok is false when it is
+// absent.
+func (p TargetInfo) Timestamp() (uint64, bool) {
+	v, ok := p.Value(MsvAvTimestamp)
+	if !ok || len(v) != 8 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(v), true
+}
+
+// SingleHost returns the Single_Host_Data structure of the client.
+// MS-NLMP 2.2.2.2 Single_Host_Data
+func (p TargetInfo) SingleHost() []byte {
+	v, _ := p.Value(MsvAvSingleHost)
+	return v
+}
+
+// ChannelBindings returns the MD5 hash of the gss_channel_bindings_struct
+// of the client, all zero when it has none.
+func (p TargetInfo) ChannelBindings() []byte {
+	v, _ := p.Value(MsvAvChannelBindings)
+	return v
+}
+
+// Set returns p with the pair id set to v, in place of the pair when
+// present and last otherwise.
+func (p TargetInfo) Set(id AvId, v []byte) TargetInfo {
+	pair := make([]byte, 4, 4+len(v))
+	binary.LittleEndian.PutUint16(pair[0:2], uint16(id))
+	binary.LittleEndian.PutUint16(pair[2:4], uint16(len(v)))
+	pair = append(pair, v...)
+
+	res := make(TargetInfo, 0, len(p)+len(pair)+4)
+	found := false
+	for {
+		pairId, _, rest, ok := p.next()
+		if !ok || pairId == MsvAvEOL {
+			break
+		}
+		if pairId != id {
+			res = append(res, p[:len(p)-len(rest)]...)
+		} else if !found {
+			res = append(res, pair...)
+			found = true
+		}
+		p = rest
+	}
+	if !found {
+		res = append(res, pair...)
+	}
+	return append(res, 0, 0, 0, 0) // MsvAvEOL
+}
+
+func (p TargetInfo) SetString(id AvId, v string) TargetInfo {
+	return p.Set(id, encodeUTF16(v))
+}
+
+func (p TargetInfo) SetFlags(v uint32) TargetInfo {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return p.Set(MsvAvFlags, b)
+}
+
+func (p TargetInfo) SetTimestamp(v uint64) TargetInfo {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, v)
+	return p.Set(MsvAvTimestamp, b)
+}
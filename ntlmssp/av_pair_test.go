@@ -0,0 +1,125 @@
+package ntlmssp
+
+import (
+	"testing"
+)
+
+// MS-NLMP 4.2.4.1.3 AV pairs of the CHALLENGE message
+var avPairsTargetInfo = MustDecodeHex("" +
+	"02000c0044006f006d00610069006e00" + // MsvAvNbDomainName
+	"01000c00530065007200760065007200" + // MsvAvNbComputerName
+	"00000000") // MsvAvEOL
+
+func TestTargetInfo(t *testing.T) {
+	channelBindings := MustDecodeHex("65867ab3e8b2d7d3e31fec4b0f85d6d5")
+	cases := []struct {
+		name     string
+		input    []byte
+		expected map[string]interface{}
+	}{
+		{
+			"challenge",
+			avPairsTargetInfo,
+			map[string]interface{}{
+				"Ids":            []AvId{MsvAvNbDomainName, MsvAvNbComputerName},
+				"NbDomainName":   "Domain",
+				"NbComputerName": "Server",
+				"Flags":          uint32(0),
+			},
+		},
+		{
+			"authenticate",
+			MustDecodeHex("" +
+				"02000c0044006f006d00610069006e00" + // MsvAvNbDomainName
+				"01000c00530065007200760065007200" + // MsvAvNbComputerName
+				"0700080000a0e1c8f1f2d801" + // MsvAvTimestamp
+				"0600040002000000" + // MsvAvFlags
+				"0a001000" + "65867ab3e8b2d7d3e31fec4b0f85d6d5" + // MsvAvChannelBindings
+				"0900160063006900660073002f00530065007200760065007200" + // MsvAvTargetName
+				"0000000000000000"), // MsvAvEOL and padding
+			map[string]interface{}{
+				"Ids":             []AvId{MsvAvNbDomainName, MsvAvNbComputerName, MsvAvTimestamp, MsvAvFlags, MsvAvChannelBindings, MsvAvTargetName},
+				"NbDomainName":    "Domain",
+				"NbComputerName":  "Server",
+				"Flags":           MSV_AV_FLAG_MIC_PRESENT,
+				"Timestamp":       uint64(0x01d8f2f1c8e1a000),
+				"ChannelBindings": channelBindings,
+				"TargetName":      "cifs/Server",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := TargetInfo(c.input)
+			if p.IsInvalid() {
+				t.Fatalf("IsInvalid() = true")
+			}
+			ids := p.Ids()
+			expectedIds := c.expected["Ids"].([]AvId)
+			if len(ids) != len(expectedIds) {
+				t.Fatalf("Ids() = %v, want %v", ids, expectedIds)
+			}
+			for i := range ids {
+				if ids[i] != expectedIds[i] {
+					t.Errorf("Ids() = %v, want %v", ids, expectedIds)
+				}
+			}
+			if p.NbDomainName() != c.expected["NbDomainName"] {
+				t.Errorf("NbDomainName() = %q", p.NbDomainName())
+			}
+			if p.NbComputerName() != c.expected["NbComputerName"] {
+				t.Errorf("NbComputerName() = %q", p.NbComputerName())
+			}
+			if p.Flags() != c.expected["Flags"] {
+				t.Errorf("Flags() = 0x%x", p.Flags())
+			}
+			ts, ok := p.Timestamp()
+			if expected, present := c.expected["Timestamp"]; ok != present || (ok && ts != expected) {
+				t.Errorf("Timestamp() = 0x%x, %v", ts, ok)
+			}
+			if expected, ok := c.expected["ChannelBindings"]; ok && !compareBytes(p.ChannelBindings(), expected.([]byte)) {
+				t.Errorf("ChannelBindings() = %x", p.ChannelBindings())
+			}
+			if expected, ok := c.expected["TargetName"]; ok && p.TargetName() != expected {
+				t.Errorf("TargetName() = %q", p.TargetName())
+			}
+		})
+	}
+}
+
+func TestTargetInfoSet(t *testing.T) {
+	p := TargetInfo(nil).
+		SetString(MsvAvNbDomainName, "Domain").
+		SetString(MsvAvNbComputerName, "Server")
+	if !compareBytes(p, avPairsTargetInfo) {
+		t.Errorf("SetString() = %x, want %x", []byte(p), avPairsTargetInfo)
+	}
+
+	q := p.SetString(MsvAvNbDomainName, "WORKGROUP").SetFlags(MSV_AV_FLAG_MIC_PRESENT)
+	if !compareBytes(p, avPairsTargetInfo) {
+		t.Errorf("Set() modified its receiver")
+	}
+	if ids := q.Ids(); len(ids) != 3 || ids[0] != MsvAvNbDomainName || ids[2] != MsvAvFlags {
+		t.Errorf("Ids() = %v", ids)
+	}
+	if q.NbDomainName() != "WORKGROUP" || q.Flags() != MSV_AV_FLAG_MIC_PRESENT {
+		t.Errorf("NbDomainName() = %q, Flags() = 0x%x", q.NbDomainName(), q.Flags())
+	}
+	if _, ok := q.Timestamp(); ok {
+		t.Errorf("Timestamp() present")
+	}
+}
+
+func TestTargetInfoIsInvalid(t *testing.T) {
+	cases := map[string][]byte{
+		"empty":       {},
+		"without EOL": avPairsTargetInfo[:len(avPairsTargetInfo)-4],
+		"overrun":     MustDecodeHex("02000e0044006f006d00610069006e0000000000"),
+	}
+	for name, input := range cases {
+		if !TargetInfo(input).IsInvalid() {
+			t.Errorf("%s: IsInvalid() = false", name)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package ntlmssp
 
-import "encoding/binary"
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+)
 
 type ChallengeMessage []byte
 
@@ -36,8 +40,10 @@ func (p ChallengeMessage) TargetName() string {
 	}
 
 	buf := p[targetNameBufferOffset : targetNameBufferOffset+uint32(targetNameLen)]
-	return string(buf) // TODO: unicode
-
+	if p.NegotiateFlags()&NTLMSSP_NEGOTIATE_UNICODE != 0 {
+		return decodeUTF16(buf)
+	}
+	return string(buf)
 }
 
 func (p ChallengeMessage) SetTargetName(input string) {
@@ -93,10 +99,10 @@ func (p ChallengeMessage) SetReserved(v []byte) {
 	copy(p[32:40], v)
 }
 
-func (p ChallengeMessage) TargetInfo() []byte {
+func (p ChallengeMessage) TargetInfo() TargetInfo {
 	// Check TargetInfoLen
 	if len(p) < 48 {
-		return []byte{}
+		return TargetInfo{}
 	}
 
 	targetInfoLen := binary.LittleEndian.Uint16(p[40:42])
@@ -104,10 +110,10 @@ func (p ChallengeMessage) TargetInfo() []byte {
 	targetInfoBufferOffset := binary.LittleEndian.Uint32(p[44:48])
 
 	if targetInfoLen == 0 || targetInfoMaxLen == 0 || targetInfoBufferOffset == 0 {
-		return []byte{}
+		return TargetInfo{}
 	}
 
-	return p[targetInfoBufferOffset : targetInfoBufferOffset+uint32(targetInfoLen)]
+	return TargetInfo(p[targetInfoBufferOffset : targetInfoBufferOffset+uint32(targetInfoLen)])
 }
 
 func (p ChallengeMessage) SetTargetInfo(v []byte) {
@@ -144,5 +150,98 @@ func (p ChallengeMessage) Version() Version {
 }
 
 func (p ChallengeMessage) SetVersion(v Version) {
-	binary.LittleEndian.PutUint64(p[48:56], uint64(v))
+	copy(p[48:56], v)
+}
+
+// TargetNames name the server in CHALLENGE messages.
+type TargetNames struct {
+	NetBIOSComputerName string
+	NetBIOSDomainName   string
+	DNSComputerName     string
+	DNSDomainName       string
+}
+
+// challengeMessageSize is the size of a CHALLENGE message up to its
+// payload, Version included.
+const challengeMessageSize = 56
+
+// NewChallengeMessage returns the CHALLENGE message answering a NEGOTIATE
+// message with negotiateFlags. The server challenge is random and the
+// target info carries the current time.
+// MS-NLMP 3.2.5.1.1 Server Receives a NEGOTIATE_MESSAGE from the Client
+func NewChallengeMessage(negotiateFlags NegotiateFlags, names TargetNames) (ChallengeMessage, error) {
+	flags := challengeFlags(negotiateFlags)
+
+	encode := func(s string) []byte {
+		if flags&NTLMSSP_NEGOTIATE_UNICODE != 0 {
+			return encodeUTF16(s)
+		}
+		return []byte(s)
+	}
+	var targetName []byte
+	if flags&NTLMSSP_REQUEST_TARGET != 0 {
+		targetName = encode(names.NetBIOSComputerName)
+	}
+
+	targetInfo := TargetInfo(nil).
+		SetString(MsvAvNbDomainName, names.NetBIOSDomainName).
+		SetString(MsvAvNbComputerName, names.NetBIOSComputerName).
+		SetString(MsvAvDnsDomainName, names.DNSDomainName).
+		SetString(MsvAvDnsComputerName, names.DNSComputerName).
+		SetTimestamp(filetime(time.Now()))
+
+	p := ChallengeMessage(make([]byte, challengeMessageSize, challengeMessageSize+len(targetName)+len(targetInfo)))
+	copy(p[0:8], "NTLMSSP\x00")
+	binary.LittleEndian.PutUint32(p[8:12], 2)
+	binary.LittleEndian.PutUint16(p[12:14], uint16(len(targetName)))
+	binary.LittleEndian.PutUint16(p[14:16], uint16(len(targetName)))
+	binary.LittleEndian.PutUint32(p[16:20], uint32(len(p)))
+	p = append(p, targetName...)
+	p.SetNegotiateFlags(flags)
+	if _, err := rand.Read(p.ServerChallenge()); err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint16(p[40:42], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint16(p[42:44], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(p[44:48], uint32(len(p)))
+	p = append(p, targetInfo...)
+	if flags&NTLMSSP_NEGOTIATE_VERSION != 0 {
+		v := p.Version()
+		v.SetProductMajorVersion(6)
+		v.SetProductMinorVersion(1)
+		v.SetProductBuild(0)
+		v.SetNTLMRevisionCurrent(0x0F) // NTLMSSP_REVISION_W2K3
+	}
+	return p, nil
+}
+
+// challengeFlags returns the flags of the CHALLENGE message answering a
+// client offering negotiateFlags: the options the server supports among
+// those the client asked for, NTLM and the target info. LM_KEY is never
+// negotiated since only NTLMv2 is accepted.
+func challengeFlags(negotiateFlags NegotiateFlags) NegotiateFlags {
+	flags := NTLMSSP_NEGOTIATE_NTLM | NTLMSSP_TARGET_INFO
+	if negotiateFlags&NTLMSSP_NEGOTIATE_UNICODE != 0 {
+		flags |= NTLMSSP_NEGOTIATE_UNICODE
+	} else if negotiateFlags&NTLMSSP_NEGOTIATE_OEM != 0 {
+		flags |= NTLMSSP_NEGOTIATE_OEM
+	}
+	if negotiateFlags&NTLMSSP_REQUEST_TARGET != 0 {
+		flags |= NTLMSSP_REQUEST_TARGET | NTLMSSP_TARGET_TYPE_SERVER
+	}
+	flags |= negotiateFlags & (NTLMSSP_NEGOTIATE_56 |
+		NTLMSSP_NEGOTIATE_KEY_EXCH |
+		NTLMSSP_NEGOTIATE_128 |
+		NTLMSSP_NEGOTIATE_VERSION |
+		NTLMSSP_NEGOTIATE_EXTENDED_SESSIONSECURITY |
+		NTLMSSP_NEGOTIATE_ALWAYS_SIGN |
+		NTLMSSP_NEGOTIATE_SEAL |
+		NTLMSSP_NEGOTIATE_SIGN)
+	return flags
+}
+
+// filetime returns t as a FILETIME, the count of 100 nanoseconds since
+// January 1, 1601.
+func filetime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + 116444736000000000
 }
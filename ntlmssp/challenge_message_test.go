@@ -0,0 +1,120 @@
+package ntlmssp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/go-ntlmssp"
+)
+
+func TestNewChallengeMessage(t *testing.T) {
+	names := TargetNames{
+		NetBIOSComputerName: "MBVM221208",
+		NetBIOSDomainName:   "WORKGROUP",
+		DNSComputerName:     "mbvm221208.example.com",
+		DNSDomainName:       "example.com",
+	}
+	cases := []struct {
+		name           string
+		negotiateFlags NegotiateFlags
+		expected       map[string]interface{}
+	}{
+		{
+			"windows",
+			0xe2088297,
+			map[string]interface{}{
+				"NegotiateFlags": NegotiateFlags(0xe28a8215),
+				"TargetName":     "MBVM221208",
+				"Version":        "060100000000000f",
+			},
+		},
+		{
+			"oem without target",
+			NTLMSSP_NEGOTIATE_OEM | NTLMSSP_NEGOTIATE_LM_KEY | NTLMSSP_NEGOTIATE_NTLM,
+			map[string]interface{}{
+				"NegotiateFlags": NTLMSSP_NEGOTIATE_OEM | NTLMSSP_NEGOTIATE_NTLM | NTLMSSP_TARGET_INFO,
+				"TargetName":     "",
+				"Version":        "0000000000000000",
+			},
+		},
+	}
+
+	before := filetime(time.Now())
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := NewChallengeMessage(c.negotiateFlags, names)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.IsInvalid() {
+				t.Fatalf("IsInvalid() = true")
+			}
+			if p.NegotiateFlags() != c.expected["NegotiateFlags"] {
+				t.Errorf("NegotiateFlags() = 0x%08x, want 0x%08x", uint32(p.NegotiateFlags()), uint32(c.expected["NegotiateFlags"].(NegotiateFlags)))
+			}
+			if p.TargetName() != c.expected["TargetName"] {
+				t.Errorf("TargetName() = %q, want %q", p.TargetName(), c.expected["TargetName"])
+			}
+			if v := []byte(p.Version()); !compareBytes(v, MustDecodeHex(c.expected["Version"].(string))) {
+				t.Errorf("Version() = %x, want %v", v, c.expected["Version"])
+			}
+
+			targetInfo := p.TargetInfo()
+			if targetInfo.IsInvalid() {
+				t.Fatalf("TargetInfo() is invalid: %x", []byte(targetInfo))
+			}
+			for _, n := range [][2]string{
+				{targetInfo.NbComputerName(), names.NetBIOSComputerName},
+				{targetInfo.NbDomainName(), names.NetBIOSDomainName},
+				{targetInfo.DnsComputerName(), names.DNSComputerName},
+				{targetInfo.DnsDomainName(), names.DNSDomainName},
+			} {
+				if n[0] != n[1] {
+					t.Errorf("target info name %q, want %q", n[0], n[1])
+				}
+			}
+			ts, ok := targetInfo.Timestamp()
+			if !ok || ts < before || ts > filetime(time.Now()) {
+				t.Errorf("MsvAvTimestamp %d is not the current time", ts)
+			}
+		})
+	}
+}
+
+func TestNewChallengeMessageServerChallenge(t *testing.T) {
+	a, err := NewChallengeMessage(NTLMSSP_NEGOTIATE_UNICODE, TargetNames{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewChallengeMessage(NTLMSSP_NEGOTIATE_UNICODE, TargetNames{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compareBytes(a.ServerChallenge(), b.ServerChallenge()) {
+		t.Errorf("ServerChallenge() repeated: %x", a.ServerChallenge())
+	}
+}
+
+func TestNewChallengeMessageInterop(t *testing.T) {
+	// go-ntlmssp answers the CHALLENGE with an NTLMv2 response, it does
+	// not support key exchange
+	flags := NTLMSSP_NEGOTIATE_UNICODE | NTLMSSP_REQUEST_TARGET | NTLMSSP_NEGOTIATE_NTLM |
+		NTLMSSP_NEGOTIATE_EXTENDED_SESSIONSECURITY | NTLMSSP_NEGOTIATE_128
+	challenge, err := NewChallengeMessage(flags, TargetNames{NetBIOSComputerName: "SERVER", NetBIOSDomainName: "DOMAIN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ntlmssp.ProcessChallenge(challenge, "User", "Password", true)
+	if err != nil {
+		t.Fatalf("ProcessChallenge() error: %v", err)
+	}
+	authMsg := AuthenticateMessage(b)
+	if authMsg.IsInvalid() || authMsg.UserName() != "User" || authMsg.DomainName() != "SERVER" {
+		t.Fatalf("AUTHENTICATE of %q\\%q", authMsg.DomainName(), authMsg.UserName())
+	}
+	responseKeyNT := NTOWFv2(NTHash("Password"), authMsg.UserName(), authMsg.DomainName())
+	if _, ok := VerifyNTLMv2Response(responseKeyNT, challenge.ServerChallenge(), authMsg.NtChallengeResponse()); !ok {
+		t.Errorf("VerifyNTLMv2Response() rejected the response of go-ntlmssp")
+	}
+}
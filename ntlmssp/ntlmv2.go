@@ -0,0 +1,94 @@
+package ntlmssp
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/md4"
+)
+
+// ntlmv2ClientChallengeSize is the size of an NTLMv2_CLIENT_CHALLENGE
+// without its AV pairs.
+// MS-NLMP 2.2.2.7 NTLM v2: NTLMv2_CLIENT_CHALLENGE
+const ntlmv2ClientChallengeSize = 28
+
+// NTHash returns the NT hash of password, the MD4 of its UTF-16LE form.
+// MS-NLMP 3.3.1 NTOWFv1
+func NTHash(password string) []byte {
+	h := md4.New()
+	h.Write(encodeUTF16(password))
+	return h.Sum(nil)
+}
+
+// NTOWFv2 returns the NTLMv2 response key of user in domain from the NT
+// hash of its password.
+// MS-NLMP 3.3.2 NTLM v2 Authentication
+func NTOWFv2(ntHash []byte, user, domain string) []byte {
+	return hmacMD5(ntHash, encodeUTF16(strings.ToUpper(user)+domain))
+}
+
+// NTLMv2Response returns the NtChallengeResponse a client computes with
+// responseKeyNT for serverChallenge: the NTProofStr followed by an
+// NTLMv2_CLIENT_CHALLENGE carrying timestamp, clientChallenge and the
+// targetInfo AV pairs.
+// MS-NLMP 3.3.2 NTLM v2 Authentication
+func NTLMv2Response(responseKeyNT, serverChallenge, clientChallenge []byte, timestamp uint64, targetInfo []byte) []byte {
+	temp := make([]byte, ntlmv2ClientChallengeSize, ntlmv2ClientChallengeSize+len(targetInfo)+4)
+	temp[0] = 1 // RespType
+	temp[1] = 1 // HiRespType
+	binary.LittleEndian.PutUint64(temp[8:16], timestamp)
+	copy(temp[16:24], clientChallenge)
+	temp = append(temp, targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+	return append(hmacMD5(responseKeyNT, serverChallenge, temp), temp...)
+}
+
+// VerifyNTLMv2Response checks ntChallengeResponse, the NTProofStr followed
+// by the client challenge, against serverChallenge and returns the session
+// base key when it was computed with responseKeyNT.
+// MS-NLMP 3.3.2 NTLM v2 Authentication
+func VerifyNTLMv2Response(responseKeyNT, serverChallenge, ntChallengeResponse []byte) ([]byte, bool) {
+	if len(ntChallengeResponse) < 16+ntlmv2ClientChallengeSize {
+		// NTLMv1 responses are 24 bytes
+		return nil, false
+	}
+	ntProofStr := hmacMD5(responseKeyNT, serverChallenge, ntChallengeResponse[16:])
+	if !hmac.Equal(ntProofStr, ntChallengeResponse[:16]) {
+		return nil, false
+	}
+	return hmacMD5(responseKeyNT, ntProofStr), true
+}
+
+// ExportedSessionKey returns the session key exported to the application.
+// With NTLMSSP_NEGOTIATE_KEY_EXCH the client picked a random key and sent
+// it encrypted with RC4 under the key exchange key, the key exchange key is
+// exported otherwise. For NTLMv2 the key exchange key is the session base
+// key.
+// MS-NLMP 3.2.5.1.2 Server Receives an AUTHENTICATE_MESSAGE from the Client
+func ExportedSessionKey(flags NegotiateFlags, keyExchangeKey, encryptedRandomSessionKey []byte) ([]byte, error) {
+	if flags&NTLMSSP_NEGOTIATE_KEY_EXCH == 0 {
+		return keyExchangeKey, nil
+	}
+	if len(encryptedRandomSessionKey) != 16 {
+		return nil, errors.New("ntlmssp: invalid EncryptedRandomSessionKey length")
+	}
+	c, err := rc4.NewCipher(keyExchangeKey)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 16)
+	c.XORKeyStream(key, encryptedRandomSessionKey)
+	return key, nil
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	h := hmac.New(md5.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
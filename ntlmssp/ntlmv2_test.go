@@ -0,0 +1,96 @@
+package ntlmssp
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// MS-NLMP 4.2.4 NTLMv2 Authentication: user "User" of "Domain" with the
+// password "Password", server challenge 0123456789abcdef.
+var (
+	ntlmv2ServerChallenge = MustDecodeHex("0123456789abcdef")
+	ntlmv2Response        = MustDecodeHex("" +
+		"68cd0ab851e51c96aabc927bebef6a1c" + // NTProofStr
+		"0101000000000000" + // RespType, HiRespType, Reserved
+		"0000000000000000" + // TimeStamp
+		"aaaaaaaaaaaaaaaa" + // ChallengeFromClient
+		"00000000" +
+		"02000c0044006f006d00610069006e00" + // MsvAvNbDomainName
+		"01000c005300650072007600650072000000" + // MsvAvNbComputerName
+		"0000" + // MsvAvEOL
+		"00000000")
+)
+
+func TestNTHash(t *testing.T) {
+	// MS-NLMP 4.2.2.1.2 NTOWFv1()
+	actual := NTHash("Password")
+	if hex.EncodeToString(actual) != "a4f49c406510bdcab6824ee7c30fd852" {
+		t.Errorf("NTHash() = %x", actual)
+	}
+}
+
+func TestVerifyNTLMv2Response(t *testing.T) {
+	responseKeyNT := NTOWFv2(NTHash("Password"), "User", "Domain")
+	if hex.EncodeToString(responseKeyNT) != "0c868a403bfd7a93a3001ef22ef02e3f" {
+		t.Errorf("NTOWFv2() = %x", responseKeyNT)
+	}
+
+	sessionBaseKey, ok := VerifyNTLMv2Response(responseKeyNT, ntlmv2ServerChallenge, ntlmv2Response)
+	if !ok {
+		t.Fatalf("VerifyNTLMv2Response() rejected the response")
+	}
+	if hex.EncodeToString(sessionBaseKey) != "8de40ccadbc14a82f15cb0ad0de95ca3" {
+		t.Errorf("session base key = %x", sessionBaseKey)
+	}
+
+	cases := map[string][]byte{
+		"wrong password": NTOWFv2(NTHash("password"), "User", "Domain"),
+		"wrong domain":   NTOWFv2(NTHash("Password"), "User", "DOMAIN"),
+	}
+	for name, key := range cases {
+		if _, ok := VerifyNTLMv2Response(key, ntlmv2ServerChallenge, ntlmv2Response); ok {
+			t.Errorf("%s: VerifyNTLMv2Response() accepted the response", name)
+		}
+	}
+	if _, ok := VerifyNTLMv2Response(NTOWFv2(NTHash("Password"), "USER", "Domain"), ntlmv2ServerChallenge, ntlmv2Response); !ok {
+		t.Errorf("VerifyNTLMv2Response() depends on the case of the user name")
+	}
+	if _, ok := VerifyNTLMv2Response(responseKeyNT, MustDecodeHex("0123456789abcdee"), ntlmv2Response); ok {
+		t.Errorf("VerifyNTLMv2Response() accepted another server challenge")
+	}
+	if _, ok := VerifyNTLMv2Response(responseKeyNT, ntlmv2ServerChallenge, ntlmv2Response[:24]); ok {
+		t.Errorf("VerifyNTLMv2Response() accepted an NTLMv1 sized response")
+	}
+}
+
+func TestExportedSessionKey(t *testing.T) {
+	sessionBaseKey := MustDecodeHex("8de40ccadbc14a82f15cb0ad0de95ca3")
+	encrypted := MustDecodeHex("c5dad2544fc9799094ce1ce90bc9d03e")
+
+	key, err := ExportedSessionKey(NTLMSSP_NEGOTIATE_KEY_EXCH, sessionBaseKey, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(key) != "55555555555555555555555555555555" {
+		t.Errorf("ExportedSessionKey() = %x", key)
+	}
+
+	key, err = ExportedSessionKey(0, sessionBaseKey, nil)
+	if err != nil || !compareBytes(key, sessionBaseKey) {
+		t.Errorf("ExportedSessionKey() without KEY_EXCH = %x, %v", key, err)
+	}
+
+	if _, err := ExportedSessionKey(NTLMSSP_NEGOTIATE_KEY_EXCH, sessionBaseKey, encrypted[:8]); err == nil {
+		t.Errorf("ExportedSessionKey() accepted a short key")
+	}
+}
+
+func TestNTLMv2Response(t *testing.T) {
+	responseKeyNT := NTOWFv2(NTHash("Password"), "User", "Domain")
+	// the target info of the response, without its trailing Z(4)
+	targetInfo := ntlmv2Response[16+ntlmv2ClientChallengeSize : len(ntlmv2Response)-4]
+	actual := NTLMv2Response(responseKeyNT, ntlmv2ServerChallenge, MustDecodeHex("aaaaaaaaaaaaaaaa"), 0, targetInfo)
+	if !compareBytes(actual, ntlmv2Response) {
+		t.Errorf("NTLMv2Response() = %x, want %x", actual, ntlmv2Response)
+	}
+}
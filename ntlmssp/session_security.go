@@ -0,0 +1,144 @@
+package ntlmssp
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/binary"
+	"errors"
+)
+
+// Magic constants of the signing and sealing keys.
+// MS-NLMP 3.4.5.2 SIGNKEY and 3.4.5.3 SEALKEY
+const (
+	clientSigningMagic = "session key to client-to-server signing key magic constant\x00"
+	serverSigningMagic = "session key to server-to-client signing key magic constant\x00"
+	clientSealingMagic = "session key to client-to-server sealing key magic constant\x00"
+	serverSealingMagic = "session key to server-to-client sealing key magic constant\x00"
+)
+
+// signatureSize is the size of an NTLMSSP_MESSAGE_SIGNATURE.
+const signatureSize = 16
+
+var errNoExtendedSessionSecurity = errors.New("ntlmssp: session security requires NTLMSSP_NEGOTIATE_EXTENDED_SESSIONSECURITY")
+
+// SessionSecurity signs and verifies messages once NTLM authentication
+// completed, as with GSS_GetMIC and GSS_VerifyMIC. Only extended session
+// security is supported. The sequence numbers and RC4 handles advance with
+// every message, a SessionSecurity is not safe for concurrent use.
+// MS-NLMP 3.4 Session Security Details
+type SessionSecurity struct {
+	flags NegotiateFlags
+
+	signingKey, verifyingKey       []byte
+	sealingHandle, verifyingHandle *rc4.Cipher
+	seqNum, verifySeqNum           uint32
+}
+
+// NewSessionSecurity returns the session security of the server, or of
+// the client when server is false, from the exported session key.
+func NewSessionSecurity(flags NegotiateFlags, exportedSessionKey []byte, server bool) (*SessionSecurity, error) {
+	if flags&NTLMSSP_NEGOTIATE_EXTENDED_SESSIONSECURITY == 0 {
+		return nil, errNoExtendedSessionSecurity
+	}
+	outSigning, inSigning := clientSigningMagic, serverSigningMagic
+	outSealing, inSealing := clientSealingMagic, serverSealingMagic
+	if server {
+		outSigning, inSigning = inSigning, outSigning
+		outSealing, inSealing = inSealing, outSealing
+	}
+
+	s := &SessionSecurity{
+		flags:        flags,
+		signingKey:   signKey(exportedSessionKey, outSigning),
+		verifyingKey: signKey(exportedSessionKey, inSigning),
+	}
+	var err error
+	if s.sealingHandle, err = rc4.NewCipher(sealKey(flags, exportedSessionKey, outSealing)); err != nil {
+		return nil, err
+	}
+	if s.verifyingHandle, err = rc4.NewCipher(sealKey(flags, exportedSessionKey, inSealing)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetMIC returns the signature of msg sent to the peer.
+func (s *SessionSecurity) GetMIC(msg []byte) []byte {
+	sig := mac(s.flags, s.signingKey, s.sealingHandle, s.seqNum, msg)
+	s.seqNum++
+	return sig
+}
+
+// VerifyMIC reports whether sig is the signature of msg received from the
+// peer.
+func (s *SessionSecurity) VerifyMIC(msg, sig []byte) bool {
+	expected := mac(s.flags, s.verifyingKey, s.verifyingHandle, s.verifySeqNum, msg)
+	s.verifySeqNum++
+	return hmac.Equal(sig, expected)
+}
+
+// mac returns the NTLMSSP_MESSAGE_SIGNATURE of msg with extended session
+// security.
+// MS-NLMP 3.4.4.2 With Extended Session Security
+func mac(flags NegotiateFlags, signingKey []byte, handle *rc4.Cipher, seqNum uint32, msg []byte) []byte {
+	seq := make([]byte, 4)
+	binary.LittleEndian.PutUint32(seq, seqNum)
+	checksum := hmacMD5(signingKey, seq, msg)[:8]
+	if flags&NTLMSSP_NEGOTIATE_KEY_EXCH != 0 {
+		handle.XORKeyStream(checksum, checksum)
+	}
+
+	sig := make([]byte, signatureSize)
+	binary.LittleEndian.PutUint32(sig[0:4], 1) // Version
+	copy(sig[4:12], checksum)
+	copy(sig[12:16], seq)
+	return sig
+}
+
+// signKey returns the signing key of one direction.
+// MS-NLMP 3.4.5.2 SIGNKEY
+func signKey(exportedSessionKey []byte, magic string) []byte {
+	h := md5.New()
+	h.Write(exportedSessionKey)
+	h.Write([]byte(magic))
+	return h.Sum(nil)
+}
+
+// sealKey returns the sealing key of one direction, weakened to the key
+// strength that was negotiated.
+// MS-NLMP 3.4.5.3 SEALKEY
+func sealKey(flags NegotiateFlags, exportedSessionKey []byte, magic string) []byte {
+	key := exportedSessionKey
+	switch {
+	case flags&NTLMSSP_NEGOTIATE_128 != 0:
+	case flags&NTLMSSP_NEGOTIATE_56 != 0:
+		key = key[:7]
+	default:
+		key = key[:5]
+	}
+	h := md5.New()
+	h.Write(key)
+	h.Write([]byte(magic))
+	return h.Sum(nil)
+}
+
+// ComputeMIC returns the MIC of the AUTHENTICATE message: the HMAC-MD5 of
+// the three messages of the exchange, the MIC field of authenticate
+// counting as zero.
+// MS-NLMP 3.1.5.1.2 Client Receives a CHALLENGE_MESSAGE from the Server
+func ComputeMIC(exportedSessionKey, negotiate, challenge []byte, authenticate AuthenticateMessage) []byte {
+	zeroed := append(AuthenticateMessage{}, authenticate...)
+	zeroed.SetMIC(make([]byte, 16))
+	return hmacMD5(exportedSessionKey, negotiate, challenge, zeroed)
+}
+
+// VerifyMIC reports whether the MIC of authenticate is valid.
+// MS-NLMP 3.2.5.1.2 Server Receives an AUTHENTICATE_MESSAGE from the Client
+func VerifyMIC(exportedSessionKey, negotiate, challenge []byte, authenticate AuthenticateMessage) bool {
+	mic := authenticate.MIC()
+	if mic == nil {
+		return false
+	}
+	return hmac.Equal(mic, ComputeMIC(exportedSessionKey, negotiate, challenge, authenticate))
+}
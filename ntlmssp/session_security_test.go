@@ -0,0 +1,97 @@
+package ntlmssp
+
+import (
+	"crypto/rc4"
+	"encoding/hex"
+	"testing"
+)
+
+// MS-NLMP 4.2.4.4 GSS_WrapEx Examples, NTLMv2 with the random session
+// key 55555555555555555555555555555555.
+func TestSessionSecurityKeys(t *testing.T) {
+	exportedSessionKey := MustDecodeHex("55555555555555555555555555555555")
+	flags := NTLMSSP_NEGOTIATE_EXTENDED_SESSIONSECURITY | NTLMSSP_NEGOTIATE_128 | NTLMSSP_NEGOTIATE_KEY_EXCH
+
+	signingKey := signKey(exportedSessionKey, clientSigningMagic)
+	if hex.EncodeToString(signingKey) != "4788dc861b4782f35d43fd98fe1a2d39" {
+		t.Errorf("signKey() = %x", signingKey)
+	}
+	sealingKey := sealKey(flags, exportedSessionKey, clientSealingMagic)
+	if hex.EncodeToString(sealingKey) != "59f600973cc4960a25480a7c196e4c58" {
+		t.Errorf("sealKey() = %x", sealingKey)
+	}
+
+	// the signature of GSS_WrapEx follows the sealed message on the same
+	// RC4 handle
+	handle, _ := rc4.NewCipher(sealingKey)
+	plaintext := encodeUTF16("Plaintext")
+	sealed := make([]byte, len(plaintext))
+	handle.XORKeyStream(sealed, plaintext)
+	if hex.EncodeToString(sealed) != "54e50165bf1936dc996020c1811b0f06fb5f" {
+		t.Errorf("sealed = %x", sealed)
+	}
+	sig := mac(flags, signingKey, handle, 0, plaintext)
+	if hex.EncodeToString(sig) != "010000007fb38ec5c55d497600000000" {
+		t.Errorf("mac() = %x", sig)
+	}
+}
+
+func TestSessionSecurityMIC(t *testing.T) {
+	exportedSessionKey := MustDecodeHex("55555555555555555555555555555555")
+	flags := NTLMSSP_NEGOTIATE_EXTENDED_SESSIONSECURITY | NTLMSSP_NEGOTIATE_128 | NTLMSSP_NEGOTIATE_KEY_EXCH
+	client, err := NewSessionSecurity(flags, exportedSessionKey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewSessionSecurity(flags, exportedSessionKey, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := []byte("mechTypes")
+	first := client.GetMIC(msg)
+	second := client.GetMIC(msg)
+	if compareBytes(first, second) {
+		t.Errorf("GetMIC() repeated %x", first)
+	}
+	if !server.VerifyMIC(msg, first) || !server.VerifyMIC(msg, second) {
+		t.Errorf("VerifyMIC() rejected the client signatures")
+	}
+	if server.VerifyMIC([]byte("mechtypes"), client.GetMIC(msg)) {
+		t.Errorf("VerifyMIC() accepted another message")
+	}
+	if client.VerifyMIC(msg, first) {
+		t.Errorf("VerifyMIC() accepted a signature of its own direction")
+	}
+
+	if _, err := NewSessionSecurity(NTLMSSP_NEGOTIATE_128, exportedSessionKey, true); err == nil {
+		t.Errorf("NewSessionSecurity() without extended session security succeeded")
+	}
+}
+
+func TestVerifyMIC(t *testing.T) {
+	exportedSessionKey := MustDecodeHex("55555555555555555555555555555555")
+	negotiate := MustDecodeHex("4e544c4d5353500001000000358288e000000000000000000000000000000000")
+	challenge, err := NewChallengeMessage(0xe2088297, TargetNames{NetBIOSComputerName: "Server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticate := NewAuthenticateMessage(AuthenticateMessageFields{
+		NegotiateFlags:      challenge.NegotiateFlags(),
+		NtChallengeResponse: ntlmv2Response,
+		UserName:            "User",
+	})
+	if VerifyMIC(exportedSessionKey, negotiate, challenge, authenticate) {
+		t.Errorf("VerifyMIC() accepted a zero MIC")
+	}
+
+	authenticate.SetMIC(ComputeMIC(exportedSessionKey, negotiate, challenge, authenticate))
+	if !VerifyMIC(exportedSessionKey, negotiate, challenge, authenticate) {
+		t.Errorf("VerifyMIC() rejected the MIC")
+	}
+	// the MIC covers the CHALLENGE the server sent
+	challenge.SetServerChallenge(make([]byte, 8))
+	if VerifyMIC(exportedSessionKey, negotiate, challenge, authenticate) {
+		t.Errorf("VerifyMIC() accepted the MIC of another CHALLENGE")
+	}
+}
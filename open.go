@@ -0,0 +1,352 @@
+package simba
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"strings"
+)
+
+// open is a file or a directory opened on a tree.
+// MS-SMB2 3.3.1.10 Per Open
+type open struct {
+	fileId FileId
+	tree   *treeConnect
+
+	// name is the name of the file on the file system of the share, and
+	// in its sharing table.
+	name string
+	file File
+
+	isDirectory   bool
+	grantedAccess AccessMask
+	shareAccess   ShareAccess
+	deleteOnClose bool
+}
+
+// sharedFile holds the opens of a file of a share, the sharing mode of a
+// new open is checked against them. Once an open with
+// FILE_DELETE_ON_CLOSE closes, the file is deleted with the last one.
+type sharedFile struct {
+	opens         []*open
+	deletePending bool
+}
+
+// dataAccess are the rights the sharing mode of opens applies to.
+const dataAccess = FILE_READ_DATA | FILE_WRITE_DATA | FILE_APPEND_DATA | FILE_EXECUTE | DELETE
+
+// sharingViolation reports whether an open of the file asking for access
+// and allowing shareAccess conflicts with the opens of f. Opens without
+// data access are not checked.
+// MS-FSA 2.1.5.1.2 Open of an Existing File
+func (f *sharedFile) sharingViolation(access AccessMask, shareAccess ShareAccess) bool {
+	if f == nil || access&dataAccess == 0 {
+		return false
+	}
+	for _, o := range f.opens {
+		if o.grantedAccess&dataAccess == 0 {
+			continue
+		}
+		if accessConflicts(o.grantedAccess, shareAccess) || accessConflicts(access, o.shareAccess) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessConflicts reports whether access is refused by the sharing mode
+// shareAccess.
+func accessConflicts(access AccessMask, shareAccess ShareAccess) bool {
+	return access&(FILE_READ_DATA|FILE_EXECUTE) != 0 && shareAccess&FILE_SHARE_READ == 0 ||
+		access&(FILE_WRITE_DATA|FILE_APPEND_DATA) != 0 && shareAccess&FILE_SHARE_WRITE == 0 ||
+		access&DELETE != 0 && shareAccess&FILE_SHARE_DELETE == 0
+}
+
+// releaseOpen removes o from the sharing table of the share, the file is
+// deleted once its last open is gone if it is pending deletion. o must be
+// closed.
+func (sh *Share) releaseOpen(o *open) {
+	sh.filesMu.Lock()
+	defer sh.filesMu.Unlock()
+	f := sh.files[o.name]
+	if f == nil {
+		return
+	}
+	for i, v := range f.opens {
+		if v == o {
+			f.opens = append(f.opens[:i], f.opens[i+1:]...)
+			break
+		}
+	}
+	if o.deleteOnClose {
+		f.deletePending = true
+	}
+	if len(f.opens) != 0 {
+		return
+	}
+	delete(sh.files, o.name)
+	if f.deletePending {
+		if err := sh.fileSystem().Remove(o.name); err != nil {
+			log.Printf("releaseOpen: delete on close of %q: %v", o.name, err)
+		}
+	}
+}
+
+// grantAccess returns the rights granted on tree tc to an open asking for
+// desired, with the generic rights mapped to the rights of files.
+// MS-SMB2 3.3.5.9 Receiving an SMB2 CREATE Request
+func (tc *treeConnect) grantAccess(desired AccessMask) (AccessMask, error) {
+	if desired&^validAccess != 0 {
+		return 0, statusError(STATUS_ACCESS_DENIED)
+	}
+	access := desired &^ (GENERIC_ALL | GENERIC_EXECUTE | GENERIC_WRITE | GENERIC_READ | MAXIMUM_ALLOWED)
+	if desired&GENERIC_READ != 0 {
+		access |= FILE_GENERIC_READ
+	}
+	if desired&GENERIC_WRITE != 0 {
+		access |= FILE_GENERIC_WRITE
+	}
+	if desired&GENERIC_EXECUTE != 0 {
+		access |= FILE_GENERIC_EXECUTE
+	}
+	if desired&GENERIC_ALL != 0 {
+		access |= FILE_ALL_ACCESS
+	}
+	if desired&MAXIMUM_ALLOWED != 0 {
+		access |= tc.maximalAccess
+	}
+	if access&^tc.maximalAccess != 0 {
+		return 0, statusError(STATUS_ACCESS_DENIED)
+	}
+	return access, nil
+}
+
+// vfsName returns the name on the file system of a share of name, a path
+// relative to the share with backslash separators.
+func vfsName(name string) (string, error) {
+	if name == "" {
+		return ".", nil
+	}
+	elems := strings.Split(name, `\`)
+	for _, elem := range elems {
+		if elem == "" || elem == "." || elem == ".." || strings.ContainsAny(elem, `"*/:<>?|`) {
+			return "", statusError(STATUS_OBJECT_NAME_INVALID)
+		}
+		for _, r := range elem {
+			if r < 0x20 {
+				return "", statusError(STATUS_OBJECT_NAME_INVALID)
+			}
+		}
+	}
+	return strings.Join(elems, "/"), nil
+}
+
+// newFileId returns a FileId unused on the session.
+func (s *session) newFileId() (FileId, error) {
+	var b [16]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			return FileId{}, fmt.Errorf("file id: %w", err)
+		}
+		id := getFileId(b[:])
+		// all ones is used by related compound requests
+		if id.Volatile == 1<<64-1 || s.opens[id] != nil {
+			continue
+		}
+		return id, nil
+	}
+}
+
+// createOpen opens, or creates, the file of request msg on tree tc as its
+// CreateDisposition tells, and returns the open with the action taken and
+// the description of the file.
+// MS-SMB2 3.3.5.9 Receiving an SMB2 CREATE Request
+func (s *session) createOpen(tc *treeConnect, msg CreateRequest) (*open, CreateAction, fs.FileInfo, error) {
+	sh := tc.share
+	switch sh.shareType() {
+	case SMB2_SHARE_TYPE_PIPE:
+		// named pipes are not served
+		return nil, 0, nil, statusError(STATUS_OBJECT_NAME_NOT_FOUND)
+	case SMB2_SHARE_TYPE_PRINT:
+		return nil, 0, nil, statusError(STATUS_NOT_SUPPORTED)
+	}
+
+	raw, ok := msg.Name()
+	if !ok || strings.HasPrefix(raw, `\`) {
+		return nil, 0, nil, statusError(STATUS_INVALID_PARAMETER)
+	}
+	name, err := vfsName(raw)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	disposition := msg.CreateDisposition()
+	options := msg.CreateOptions()
+	shareAccess := msg.ShareAccess()
+	switch {
+	case msg.ImpersonationLevel() > IMPERSONATION_DELEGATE:
+		return nil, 0, nil, statusError(STATUS_BAD_IMPERSONATION_LEVEL)
+	case disposition > FILE_OVERWRITE_IF,
+		shareAccess&^(FILE_SHARE_READ|FILE_SHARE_WRITE|FILE_SHARE_DELETE) != 0,
+		options&(FILE_DIRECTORY_FILE|FILE_NON_DIRECTORY_FILE) == FILE_DIRECTORY_FILE|FILE_NON_DIRECTORY_FILE,
+		options&FILE_DIRECTORY_FILE != 0 && disposition != FILE_OPEN && disposition != FILE_CREATE && disposition != FILE_OPEN_IF:
+		return nil, 0, nil, statusError(STATUS_INVALID_PARAMETER)
+	case options&(FILE_OPEN_BY_FILE_ID|FILE_RESERVE_OPFILTER) != 0:
+		return nil, 0, nil, statusError(STATUS_NOT_SUPPORTED)
+	}
+	access, err := tc.grantAccess(msg.DesiredAccess())
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	deleteOnClose := options&FILE_DELETE_ON_CLOSE != 0
+	if deleteOnClose && access&DELETE == 0 {
+		return nil, 0, nil, statusError(STATUS_ACCESS_DENIED)
+	}
+	if deleteOnClose && name == "." {
+		return nil, 0, nil, statusError(STATUS_CANNOT_DELETE)
+	}
+
+	// the sharing table is locked until the open is added to it
+	sh.filesMu.Lock()
+	defer sh.filesMu.Unlock()
+	shared := sh.files[name]
+	if shared != nil && shared.deletePending {
+		return nil, 0, nil, statusError(STATUS_DELETE_PENDING)
+	}
+	if shared.sharingViolation(access, shareAccess) {
+		return nil, 0, nil, statusError(STATUS_SHARING_VIOLATION)
+	}
+
+	fsys := sh.fileSystem()
+	fi, err := fsys.Stat(name)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, nil, err
+	}
+	var action CreateAction
+	if exists {
+		switch disposition {
+		case FILE_CREATE:
+			return nil, 0, nil, statusError(STATUS_OBJECT_NAME_COLLISION)
+		case FILE_OPEN, FILE_OPEN_IF:
+			action = FILE_OPENED
+		case FILE_OVERWRITE, FILE_OVERWRITE_IF:
+			action = FILE_OVERWRITTEN
+		case FILE_SUPERSEDE:
+			action = FILE_SUPERSEDED
+		}
+		switch {
+		case fi.IsDir() && (options&FILE_NON_DIRECTORY_FILE != 0 || action != FILE_OPENED):
+			return nil, 0, nil, statusError(STATUS_FILE_IS_A_DIRECTORY)
+		case !fi.IsDir() && options&FILE_DIRECTORY_FILE != 0:
+			return nil, 0, nil, statusError(STATUS_NOT_A_DIRECTORY)
+		case action != FILE_OPENED && tc.maximalAccess&FILE_WRITE_DATA == 0:
+			return nil, 0, nil, statusError(STATUS_ACCESS_DENIED)
+		}
+		if !fi.IsDir() && fileAttributes(fi)&FILE_ATTRIBUTE_READONLY != 0 {
+			if access&(FILE_WRITE_DATA|FILE_APPEND_DATA) != 0 || action != FILE_OPENED {
+				return nil, 0, nil, statusError(STATUS_ACCESS_DENIED)
+			}
+			if deleteOnClose {
+				return nil, 0, nil, statusError(STATUS_CANNOT_DELETE)
+			}
+		}
+	} else {
+		if disposition == FILE_OPEN || disposition == FILE_OVERWRITE {
+			if dir, err := fsys.Stat(path.Dir(name)); err != nil || !dir.IsDir() {
+				return nil, 0, nil, statusError(STATUS_OBJECT_PATH_NOT_FOUND)
+			}
+			return nil, 0, nil, statusError(STATUS_OBJECT_NAME_NOT_FOUND)
+		}
+		// creating a file needs FILE_ADD_FILE, or FILE_ADD_SUBDIRECTORY
+		if tc.maximalAccess&FILE_WRITE_DATA == 0 {
+			return nil, 0, nil, statusError(STATUS_ACCESS_DENIED)
+		}
+		action = FILE_CREATED
+	}
+
+	// undo removes the file created for an open that fails
+	undo := func() {
+		if !exists {
+			if err := fsys.Remove(name); err != nil {
+				log.Printf("createOpen: remove %q: %v", name, err)
+			}
+		}
+	}
+	isDirectory := exists && fi.IsDir() || !exists && options&FILE_DIRECTORY_FILE != 0
+	flag := os.O_RDONLY
+	switch {
+	case isDirectory:
+		if !exists {
+			if err := fsys.Mkdir(name, 0777); err != nil {
+				return nil, 0, nil, err
+			}
+		}
+	case !exists:
+		flag = os.O_RDWR | os.O_CREATE | os.O_EXCL
+	case action != FILE_OPENED:
+		flag = os.O_RDWR | os.O_TRUNC
+	case access&(FILE_WRITE_DATA|FILE_APPEND_DATA) != 0:
+		flag = os.O_RDWR
+	}
+	f, err := fsys.Open(name, flag, 0666)
+	if err != nil {
+		// a file opened with O_EXCL is not created when the open fails
+		if isDirectory {
+			undo()
+		}
+		return nil, 0, nil, err
+	}
+	if action != FILE_OPENED {
+		// the attributes of a new, or replaced, file are the requested ones
+		if attrs := msg.FileAttributes() &^ (FILE_ATTRIBUTE_DIRECTORY | FILE_ATTRIBUTE_NORMAL); attrs != 0 {
+			if err := fsys.SetAttributes(name, attrs); err != nil {
+				log.Printf("createOpen: attributes of %q: %v", name, err)
+			}
+		}
+	}
+	if fi, err = f.Stat(); err != nil {
+		f.Close()
+		undo()
+		return nil, 0, nil, err
+	}
+	fileId, err := s.newFileId()
+	if err != nil {
+		f.Close()
+		undo()
+		return nil, 0, nil, err
+	}
+
+	o := &open{
+		fileId:        fileId,
+		tree:          tc,
+		name:          name,
+		file:          f,
+		isDirectory:   isDirectory,
+		grantedAccess: access,
+		shareAccess:   shareAccess,
+		deleteOnClose: deleteOnClose,
+	}
+	if shared == nil {
+		if sh.files == nil {
+			sh.files = map[string]*sharedFile{}
+		}
+		shared = &sharedFile{}
+		sh.files[name] = shared
+	}
+	shared.opens = append(shared.opens, o)
+	s.opens[fileId] = o
+	return o, action, fi, nil
+}
+
+// closeOpen closes o and removes it from its session and its share.
+func (s *session) closeOpen(o *open) {
+	delete(s.opens, o.fileId)
+	if err := o.file.Close(); err != nil {
+		log.Printf("closeOpen: %q: %v", o.name, err)
+	}
+	o.tree.share.releaseOpen(o)
+}
@@ -1,50 +1,86 @@
-package simba
-
-type HashAlgorithm uint16
-
-const (
-	// MS-SMB2 - v20211006 page 48/481
-	SMB2_PREAUTH_INTEGRITY_SHA512 HashAlgorithm = 0x0001
-)
-
-type PreauthIntegrityCapability []byte
-
-func (p PreauthIntegrityCapability) HashAlgorithmCount() uint16 {
-	return le.Uint16(p[0:2])
-}
-
-func (p PreauthIntegrityCapability) SetHashAlgorithmCount(c uint16) {
-	le.PutUint16(p[0:2], c)
-}
-
-func (p PreauthIntegrityCapability) SaltLength() uint16 {
-	return le.Uint16(p[2:4])
-}
-
-func (p PreauthIntegrityCapability) SetSaltLength(l uint16) {
-	le.PutUint16(p[2:4], l)
-}
-
-func (p PreauthIntegrityCapability) HashAlgorithms() []HashAlgorithm {
-	var res []HashAlgorithm
-	for i := 0; i < int(p.HashAlgorithmCount()); i++ {
-		res = append(res, HashAlgorithm(le.Uint16(p[4+i*2:6+i*2])))
-	}
-	return res
-}
-
-func (p PreauthIntegrityCapability) SetHashAlgorithms(a []HashAlgorithm) {
-	p.SetHashAlgorithmCount(uint16(len(a)))
-	for i, v := range a {
-		le.PutUint16(p[4+i*2:6+i*2], uint16(v))
-	}
-}
-
-func (p PreauthIntegrityCapability) Salt() []byte {
-	return p[4+int(p.HashAlgorithmCount())*2:]
-}
-
-func (p PreauthIntegrityCapability) SetSalt(s []byte) {
-	p.SetSaltLength(uint16(len(s)))
-	copy(p[4+int(p.HashAlgorithmCount())*2:], s)
-}
+package simba
+
+import "crypto/sha512"
+
+type HashAlgorithm uint16
+
+const (
+	// MS-SMB2 - v20211006 page 48/481
+	SMB2_PREAUTH_INTEGRITY_SHA512 HashAlgorithm = 0x0001
+)
+
+type PreauthIntegrityCapability []byte
+
+func (p PreauthIntegrityCapability) HashAlgorithmCount() uint16 {
+	return le.Uint16(p[0:2])
+}
+
+func (p PreauthIntegrityCapability) SetHashAlgorithmCount(c uint16) {
+	le.PutUint16(p[0:2], c)
+}
+
+func (p PreauthIntegrityCapability) SaltLength() uint16 {
+	return le.Uint16(p[2:4])
+}
+
+func (p PreauthIntegrityCapability) SetSaltLength(l uint16) {
+	le.PutUint16(p[2:4], l)
+}
+
+func (p PreauthIntegrityCapability) HashAlgorithms() []HashAlgorithm {
+	var res []HashAlgorithm
+	for i := 0; i < int(p.HashAlgorithmCount()); i++ {
+		res = append(res, HashAlgorithm(le.Uint16(p[4+i*2:6+i*2])))
+	}
+	return res
+}
+
+func (p PreauthIntegrityCapability) SetHashAlgorithms(a []HashAlgorithm) {
+	p.SetHashAlgorithmCount(uint16(len(a)))
+	for i, v := range a {
+		le.PutUint16(p[4+i*2:6+i*2], uint16(v))
+	}
+}
+
+func (p PreauthIntegrityCapability) Salt() []byte {
+	offset := 4 + int(p.HashAlgorithmCount())*2
+	length := int(p.SaltLength())
+	if offset+length > len(p) {
+		return nil
+	}
+	return p[offset : offset+length]
+}
+
+func (p PreauthIntegrityCapability) SetSalt(s []byte) {
+	p.SetSaltLength(uint16(len(s)))
+	copy(p[4+int(p.HashAlgorithmCount())*2:], s)
+}
+
+// NewPreauthIntegrityCapability returns the SMB2_PREAUTH_INTEGRITY_CAPABILITIES
+// data with the hash algorithms and salt.
+// MS-SMB2 2.2.3.1.1 SMB2_PREAUTH_INTEGRITY_CAPABILITIES
+func NewPreauthIntegrityCapability(algorithms []HashAlgorithm, salt []byte) PreauthIntegrityCapability {
+	p := PreauthIntegrityCapability(make([]byte, 4+2*len(algorithms)+len(salt)))
+	p.SetHashAlgorithms(algorithms)
+	p.SetSalt(salt)
+	return p
+}
+
+// PreauthIntegrityHashValue is the SHA-512 hash chained over the NEGOTIATE
+// and SESSION_SETUP messages of an SMB 3.1.1 connection and session. The
+// final value of a session is the context of its key derivation.
+// MS-SMB2 3.2.5.2 Receiving an SMB2 NEGOTIATE Response
+// MS-SMB2 3.3.5.5 Receiving an SMB2 SESSION_SETUP Request
+type PreauthIntegrityHashValue [sha512.Size]byte
+
+// Update returns Hash(h || msg), where msg is a whole SMB2 message starting
+// at its SMB2 header.
+func (h PreauthIntegrityHashValue) Update(msg []byte) PreauthIntegrityHashValue {
+	d := sha512.New()
+	d.Write(h[:])
+	d.Write(msg)
+
+	var res PreauthIntegrityHashValue
+	copy(res[:], d.Sum(nil))
+	return res
+}
@@ -0,0 +1,40 @@
+package simba
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"testing"
+)
+
+func TestPreauthIntegrityHashValue(t *testing.T) {
+	messages := [][]byte{
+		[]byte("negotiate request"),
+		[]byte("negotiate response"),
+		[]byte("session setup request"),
+	}
+
+	var h PreauthIntegrityHashValue
+	expected := make([]byte, sha512.Size)
+	for _, m := range messages {
+		h = h.Update(m)
+
+		d := sha512.New()
+		d.Write(expected)
+		d.Write(m)
+		expected = d.Sum(nil)
+
+		if !bytes.Equal(h[:], expected) {
+			t.Fatalf("Update(%q) = %x, want %x", m, h, expected)
+		}
+	}
+
+	// sessions fork the connection hash without changing it
+	conn := h
+	session := conn.Update([]byte("session setup response"))
+	if session == conn {
+		t.Errorf("Update() did not change the session hash")
+	}
+	if conn != h {
+		t.Errorf("Update() changed the connection hash")
+	}
+}
@@ -1,338 +1,1027 @@
-package simba
-
-import (
-	"encoding/hex"
-	"fmt"
-	"log"
-	"net"
-	"time"
-
-	"github.com/PichuChen/simba/auth"
-)
-
-var serverGUID = []byte{0x6d, 0x62, 0x76, 0x6d, 0x32, 0x32, 0x31, 0x32, 0x30, 0x38, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
-
-// TODO: change to a session manager
-var sessionID = uint64(2023)
-
-type conn struct {
-	server *Server
-
-	// rwc is the underlying network connection.
-	rwc net.Conn
-
-	remoteAddr string
-}
-
-type response struct {
-	conn *conn
-}
-
-func (srv *Server) Serve(l net.Listener) error {
-	for {
-		rw, err := l.Accept()
-		if err != nil {
-
-			return err
-		}
-		fmt.Printf("Accept a new connection: %v\n", rw)
-		c := srv.newConn(rw)
-		go c.serve()
-	}
-}
-
-func (srv *Server) newConn(rw net.Conn) *conn {
-	c := &conn{
-		server: srv,
-		rwc:    rw,
-	}
-	return c
-}
-
-func (c *conn) serve() {
-	fmt.Printf("remote addr: %v\n", c.rwc.RemoteAddr())
-	c.remoteAddr = c.rwc.RemoteAddr().String()
-
-	defer c.rwc.Close()
-
-	for {
-		r, err := c.readRequest()
-		if err != nil {
-			fmt.Printf("readRequest error: %v\n", err)
-			return
-		}
-		fmt.Printf("readRequest: %v\n", r)
-
-		switch r.Command() {
-		case SMB2_NEGOTIATE:
-			fmt.Printf("SMB2_NEGOTIATE\n")
-			msg := NegotiateRequest(r[64:])
-			c.handleNegotiate(r, msg)
-		case SMB2_SESSION_SETUP:
-			fmt.Printf("SMB2_SESSION_SETUP\n")
-			msg := SessionSetupRequest(r[64:])
-			c.handleSessionSetup(r, msg)
-
-		default:
-			fmt.Printf("unknown command: %v (%d)\n", r.Command(), r.Command())
-		}
-	}
-
-}
-
-func (c *conn) readRequest() (w PacketCodec, err error) {
-	var buf [1024]byte
-	n, err := c.rwc.Read(buf[:])
-	if err != nil {
-		return nil, err
-	}
-
-	// From NetBIOS
-	fmt.Printf("readRequest: %v len: %d\n", hex.EncodeToString(buf[:n]), n)
-	// zero := buf[0]
-	stringProtocolLength := (uint32(buf[1]) << 16) + (uint32(buf[2]) << 8) + uint32(buf[3])
-	// TODO: using loop to read all data
-	if n < int(stringProtocolLength) {
-		n2, err := c.rwc.Read(buf[n:])
-		if err != nil {
-			return nil, err
-		}
-		n += n2
-	}
-
-	smb2Message := buf[4 : 4+stringProtocolLength]
-
-	msg := PacketCodec(smb2Message)
-	fmt.Printf("msg: len: %d data: %+v\n", len(msg), msg)
-	if msg.IsInvalid() {
-		fmt.Printf("msg is invalid\n")
-		return nil, fmt.Errorf("msg is invalid")
-	}
-
-	fmt.Printf("msg type: %v\n", msg.Command())
-
-	return msg, nil
-
-}
-
-func (c *conn) handleNegotiate(p PacketCodec, msg NegotiateRequest) error {
-	fmt.Printf("handleNegotiate: %v\n", msg.ClientGuid())
-
-	securityBufferPayload := auth.DefaultNegoPayload
-
-	negotiateContextPreauth := NegotiateContext(make([]byte, 8+38))
-	negotiateContextPreauth.SetContextType(SMB2_PREAUTH_INTEGRITY_CAPABILITIES)
-	negotiateContextPreauth.SetDataLength(38)
-	negotiateContextPreauth.SetReserved(0)
-	negotiateContextPreauth.SetData([]byte{
-		0x01, 0x00, // hash algorithm count
-		0x20, 0x00, // salt length
-		0x01, 0x00, // hash algorithm: SHA-512
-		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10,
-		0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f, 0x20})
-
-	negotiateContextEncryption := NegotiateContext(make([]byte, 8+4))
-	negotiateContextEncryption.SetContextType(SMB2_ENCRYPTION_CAPABILITIES)
-	negotiateContextEncryption.SetDataLength(4)
-	negotiateContextEncryption.SetReserved(0)
-	negotiateContextEncryption.SetData([]byte{0x01, 0x00, 0x02, 0x00})
-
-	pkt := []byte{}
-	responseHdr := NegotiateResponse(make([]byte, 65+len(securityBufferPayload)+len(negotiateContextPreauth)+19))
-	responseHdr.SetStructureSize(65)
-	responseHdr.SetSecurityMode(SMB2_NEGOTIATE_SIGNING_ENABLED)
-	responseHdr.SetDialectRevision(0x311)
-	responseHdr.SetNegotiateContextCount(2)
-	responseHdr.SetServerGuid(serverGUID)
-	responseHdr.SetCapabilities(SMB2_GLOBAL_CAP_DFS | SMB2_GLOBAL_CAP_LEASING | SMB2_GLOBAL_CAP_LARGE_MTU)
-	responseHdr.SetMaxTransactSize(8388608) // 8MB
-	responseHdr.SetMaxReadSize(8388608)
-	responseHdr.SetMaxWriteSize(8388608)
-	responseHdr.SetSystemTime(time.Now())
-	responseHdr.SetServerStartTime(time.Time{})
-	responseHdr.SetSecurityBufferOffset(0x80)
-	responseHdr.SetSecurityBufferLength(uint16(len(securityBufferPayload)))
-	responseHdr.SetBuffer(securityBufferPayload)
-
-	responseHdr.SetNegotiateContextOffset(0xD0)
-	responseHdr.SetNegotiateContexts([]NegotiateContext{negotiateContextPreauth, negotiateContextEncryption})
-
-	smb2Header := PacketCodec(make([]byte, 64, 64))
-	smb2Header.SetProtocolId()
-	smb2Header.SetStructureSize()
-	smb2Header.SetCreditCharge(1)
-	smb2Header.SetCommand(SMB2_NEGOTIATE)
-	smb2Header.SetStatus(0)
-	smb2Header.SetCreditRequestResponse(1)
-	smb2Header.SetFlags(SMB2_FLAGS_SERVER_TO_REDIR)
-	smb2Header.SetNextCommand(0)
-	smb2Header.SetMessageId(p.MessageId())
-	smb2Header.SetTreeId(0)
-	smb2Header.SetSessionId(p.SessionId())
-	smb2Header.SetSignature([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
-
-	l := len(smb2Header) + len(responseHdr)
-	netBIOSHeader := []byte{0x00, 0x00, 0x00, 0x00}
-	netBIOSHeader[3] = byte(l)
-	netBIOSHeader[2] = byte(l >> 8)
-
-	// smb2Header := []byte{0xfe, 0x53, 0x4d, 0x42, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
-
-	pkt = append(pkt, netBIOSHeader...)
-	pkt = append(pkt, smb2Header...)
-	pkt = append(pkt, responseHdr...)
-	// pkt = append(pkt, responseBody...)
-
-	fmt.Printf("handleNegotiate: %v\n", hex.EncodeToString(pkt))
-	c.rwc.Write(pkt)
-	fmt.Printf("send response: %d\n", len(pkt))
-
-	return nil
-
-}
-
-func (c *conn) handleSessionSetup(p PacketCodec, msg SessionSetupRequest) error {
-	fmt.Printf("handleSessionSetup request: %x\n", msg)
-
-	// get NTLMSSP message
-	gssBuffer := msg.Buffer()
-	var mechToken []byte
-	if gssBuffer[0] == 0x60 {
-		gssPayload, err := auth.NewInitPayload(gssBuffer)
-		if err != nil {
-			log.Printf("handleSessionSetup NewInitPayload: %v", err)
-			return fmt.Errorf("handleSessionSetup NewInitPayload: %v", err)
-		}
-		mechToken = gssPayload.Token.NegTokenInit.MechToken
-	} else if gssBuffer[0] == 0xa1 {
-		gssPayload, err := auth.NewTargPayload(gssBuffer)
-		if err != nil {
-			log.Printf("handleSessionSetup NewTargPayload: %v", err)
-			return fmt.Errorf("handleSessionSetup NewTargPayload: %v", err)
-		}
-		mechToken = gssPayload.ResponseToken
-
-	}
-
-	// get NTLMSSP message
-	fmt.Printf("mechToken: %v\n", hex.EncodeToString(mechToken))
-
-	ntlmsspPayload := auth.NTLMMessage(mechToken)
-	if ntlmsspPayload.IsInvalid() {
-		return fmt.Errorf("handleSessionSetup NTLMMessage is invalid")
-	}
-
-	switch ntlmsspPayload.MessageType() {
-	case auth.NTLMSSP_NEGOTIATE:
-		log.Printf("NTLM_NEGOTIATE: %v\n", len(ntlmsspPayload))
-		return c.handleSessionSetupNtmlsspNetotiate(p, msg, auth.NTLMNegotiateMessage(mechToken))
-	case auth.NTLMSSP_AUTH:
-		log.Printf("NTLMSSP_AUTH: %v\n", len(ntlmsspPayload))
-		return c.handleSessionSetupNtmlsspAuth(p, msg, auth.NTLMNegotiateMessage(mechToken))
-	default:
-		fmt.Printf("NTLMSSP unknown message type: %0x\n", ntlmsspPayload.MessageType())
-		// case auth.NTLM_CHALLENGE:
-		// 	fmt.Printf("NTLM_CHALLENGE: %v\n", ntlmsspPayload)
-		// case auth.NTLM_AUTHENTICATE:
-		// 	fmt.Printf("NTLM_AUTHENTICATE: %v\n", ntlmsspPayload)
-	}
-	return fmt.Errorf("unknown ntlm message type: %0x\n", ntlmsspPayload.MessageType())
-}
-func (c *conn) handleSessionSetupNtmlsspNetotiate(p PacketCodec, msg SessionSetupRequest, ntlpPayload auth.NTLMNegotiateMessage) error {
-
-	pkt := []byte{}
-	securityBuffer, _ := hex.DecodeString("a181c43081c1a0030a0101a10c060a2b06010401823702020aa281ab0481a84e544c4d5353500002000000140014003800000015828ae2ade8f7c5b20b941000000000000000005c005c004c000000060100000000000f4d00420056004d00320032003100320030003800020014004d00420056004d00320032003100320030003800010014004d00420056004d0032003200310032003000380004000000030014006d00620076006d0032003200310032003000380007000800a421b4497870d90100000000")
-	log.Printf("securityBuffer lenght: %v", len(securityBuffer))
-	responseHdr := SessionSetupResponse(make([]byte, 8+len(securityBuffer)))
-	responseHdr.SetStructureSize()
-	responseHdr.SetSecurityBufferOffset(0x48)
-	responseHdr.SetSecurityBufferLength(uint16(len(securityBuffer)))
-	responseHdr.SetBuffer(securityBuffer)
-	// responseHdr.SetSecurityMode(Securi)
-
-	smb2Header := PacketCodec(make([]byte, 64, 64))
-	smb2Header.SetProtocolId()
-	smb2Header.SetStructureSize()
-	smb2Header.SetCreditCharge(1)
-	smb2Header.SetCommand(SMB2_SESSION_SETUP)
-	smb2Header.SetStatus(STATUS_MORE_PROCESSING_REQUIRED)
-	smb2Header.SetCreditRequestResponse(1)
-	smb2Header.SetFlags(SMB2_FLAGS_SERVER_TO_REDIR)
-	smb2Header.SetNextCommand(0)
-	smb2Header.SetMessageId(p.MessageId())
-	smb2Header.SetTreeId(0)
-	// if sessionID == 0 {
-	// 	sessionID = 0xebc20a15
-	// } else {
-	// 	sessionID++
-	// }
-	fmt.Printf("p.SessionId(): %v\n", p.SessionId())
-	smb2Header.SetSessionId(sessionID)
-	smb2Header.SetSignature([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
-
-	l := len(smb2Header) + len(responseHdr)
-	log.Printf("smb2 length: %v, resp len: %v\n", len(smb2Header), len(responseHdr))
-	netBIOSHeader := []byte{0x00, 0x00, 0x00, 0x00}
-	netBIOSHeader[3] = byte(l)
-	netBIOSHeader[2] = byte(l >> 8)
-
-	// smb2Header := []byte{0xfe, 0x53, 0x4d, 0x42, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
-
-	pkt = append(pkt, netBIOSHeader...)
-	pkt = append(pkt, smb2Header...)
-	pkt = append(pkt, responseHdr...)
-
-	fmt.Printf("handleSessionSetup response 1: %v\n", hex.EncodeToString(pkt))
-	c.rwc.Write(pkt)
-	fmt.Printf("send response: %d\n", len(pkt))
-
-	return nil
-}
-
-func (c *conn) handleSessionSetupNtmlsspAuth(p PacketCodec, msg SessionSetupRequest, ntlpPayload auth.NTLMNegotiateMessage) error {
-
-	pkt := []byte{}
-	responseHdr := SessionSetupResponse(make([]byte, 8))
-	responseHdr.SetStructureSize()
-	responseHdr.SetSessionFlags(SMB2_SESSION_FLAG_IS_GUEST | SMB2_SESSION_FLAG_IS_NULL)
-	responseHdr.SetSecurityBufferOffset(0)
-	responseHdr.SetSecurityBufferLength(0)
-	// responseHdr.SetSecurityMode(Securi)
-
-	smb2Header := PacketCodec(make([]byte, 64))
-	smb2Header.SetProtocolId()
-	smb2Header.SetStructureSize()
-	smb2Header.SetCreditCharge(1)
-	smb2Header.SetCommand(SMB2_SESSION_SETUP)
-	smb2Header.SetStatus(STATUS_LOGON_FAILURE)
-	smb2Header.SetCreditRequestResponse(1)
-	smb2Header.SetFlags(SMB2_FLAGS_SERVER_TO_REDIR)
-	smb2Header.SetNextCommand(0)
-	smb2Header.SetMessageId(p.MessageId())
-	smb2Header.SetTreeId(0)
-	// if sessionID == 0 {
-	// 	sessionID = 0xebc20a15
-	// } else {
-	// 	sessionID++
-	// }
-	smb2Header.SetSessionId(p.SessionId())
-	smb2Header.SetSignature([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
-
-	l := len(smb2Header) + len(responseHdr)
-	log.Printf("smb2 length: %v, resp len: %v\n", len(smb2Header), len(responseHdr))
-	netBIOSHeader := []byte{0x00, 0x00, 0x00, 0x00}
-	netBIOSHeader[3] = byte(l)
-	netBIOSHeader[2] = byte(l >> 8)
-
-	// smb2Header := []byte{0xfe, 0x53, 0x4d, 0x42, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
-
-	pkt = append(pkt, netBIOSHeader...)
-	pkt = append(pkt, smb2Header...)
-	pkt = append(pkt, responseHdr...)
-
-	fmt.Printf("handleSessionSetup response 2: %v\n", hex.EncodeToString(pkt))
-	c.rwc.Write(pkt)
-	fmt.Printf("send response: %d\n", len(pkt))
-
-	return nil
-}
+package simba
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/PichuChen/simba/auth"
+	"github.com/PichuChen/simba/krb5"
+	"github.com/PichuChen/simba/ntlmssp"
+)
+
+var serverGUID = []byte{0x6d, 0x62, 0x76, 0x6d, 0x32, 0x32, 0x31, 0x32, 0x30, 0x38, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+type conn struct {
+	server *Server
+
+	// rwc is the underlying network connection.
+	rwc net.Conn
+
+	remoteAddr string
+
+	// maxFrameSize is the largest Direct TCP frame accepted from the client,
+	// raised once MaxTransactSize has been negotiated.
+	maxFrameSize int
+
+	// wmu serializes writes of whole frames to rwc.
+	wmu sync.Mutex
+
+	// dialect is the SMB2 dialect selected by NEGOTIATE, zero before that.
+	dialect Dialect
+
+	// Selected from the negotiate contexts of a 3.1.1 client.
+	preauthIntegrityHashId HashAlgorithm
+	cipherId               Cipher
+	signingAlgorithmId     SingingAlgorithm
+	compressionIds         []CompressionAlgorithm
+	compressionChained     bool
+
+	// clientCapabilities are the Capabilities of the NEGOTIATE request.
+	clientCapabilities Capabilities
+
+	// preauthIntegrityHashValue covers the NEGOTIATE exchange, sessions
+	// continue from it. 3.1.1 only.
+	preauthIntegrityHashValue PreauthIntegrityHashValue
+
+	// sessions established or being set up on this connection.
+	sessions map[uint64]*session
+
+	// encrypted is set while serving a request that arrived in a
+	// TRANSFORM_HEADER, its response is encrypted as well.
+	encrypted bool
+
+	// compound is set while serving a compounded request, the responses
+	// are sent chained once every request of it is served.
+	compound *compound
+}
+
+type response struct {
+	conn *conn
+}
+
+func (srv *Server) Serve(l net.Listener) error {
+	for {
+		rw, err := l.Accept()
+		if err != nil {
+
+			return err
+		}
+		c := srv.newConn(rw)
+		go c.serve()
+	}
+}
+
+func (srv *Server) newConn(rw net.Conn) *conn {
+	c := &conn{
+		server:       srv,
+		rwc:          rw,
+		maxFrameSize: negotiateFrameSize,
+		sessions:     map[uint64]*session{},
+	}
+	return c
+}
+
+func (c *conn) serve() {
+	c.remoteAddr = c.rwc.RemoteAddr().String()
+
+	defer c.rwc.Close()
+	defer c.closeSessions()
+
+	for {
+		r, err := c.readRequest()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("serve: %s: %v", c.remoteAddr, err)
+			}
+			return
+		}
+
+		c.encrypted = false
+		var sessionId uint64
+		if r.ProtocolId()[0] == 0xfd {
+			sessionId = TransformHeader(r).SessionId()
+			msg, err := c.decrypt(TransformHeader(r))
+			putFrameBuffer(r)
+			if err != nil {
+				// MS-SMB2 3.3.5.2.1.1, the connection is dropped
+				log.Printf("serve: %s: decrypt: %v", c.remoteAddr, err)
+				return
+			}
+			r = msg
+			c.encrypted = true
+		}
+
+		if r.ProtocolId()[0] == 0xfc {
+			msg, err := decompressMessage(c.compressionIds, c.compressionChained,
+				CompressionTransformHeader(r), c.maxFrameSize)
+			putFrameBuffer(r)
+			if err == nil && (msg.IsInvalid() || msg.ProtocolId()[0] != 0xfe) {
+				putFrameBuffer(msg)
+				err = fmt.Errorf("compressed message is not smb2")
+			} else if err == nil && c.encrypted && msg.SessionId() != sessionId {
+				// MS-SMB2 3.3.5.2.1.1, as checked by decrypt for
+				// uncompressed messages
+				putFrameBuffer(msg)
+				err = fmt.Errorf("compressed message of session 0x%x in transform header of session 0x%x", msg.SessionId(), sessionId)
+			}
+			if err != nil {
+				// MS-SMB2 3.3.5.2.12, the connection is dropped
+				log.Printf("serve: %s: decompress: %v", c.remoteAddr, err)
+				return
+			}
+			r = msg
+		}
+
+		if r.ProtocolId()[0] == 0xff {
+			err := c.handleSMB1Negotiate(SMB1PacketCodec(r))
+			putFrameBuffer(r)
+			if err != nil {
+				log.Printf("serve: %s: SMB1 negotiate: %v", c.remoteAddr, err)
+				return
+			}
+			continue
+		}
+
+		if err := c.serveMessage(r); err != nil {
+			log.Printf("serve: %s: %v: %v", c.remoteAddr, r.Command(), err)
+			putFrameBuffer(r)
+			return
+		}
+		putFrameBuffer(r)
+	}
+
+}
+
+func (c *conn) readRequest() (w PacketCodec, err error) {
+	buf, err := readFrame(c.rwc, c.maxFrameSize)
+	if err != nil {
+		return nil, err
+	}
+	msg := PacketCodec(buf)
+	if len(buf) > 0 && buf[0] == 0xff {
+		// SMB1 message, only SMB_COM_NEGOTIATE is handled
+		if SMB1PacketCodec(buf).IsInvalid() {
+			putFrameBuffer(buf)
+			return nil, fmt.Errorf("smb1 msg is invalid")
+		}
+		return msg, nil
+	}
+	if len(buf) > 0 && buf[0] == 0xfc {
+		// decompressed by serve with the negotiated algorithms
+		if CompressionTransformHeader(buf).IsInvalid() {
+			putFrameBuffer(buf)
+			return nil, fmt.Errorf("compression transform header is invalid")
+		}
+		return msg, nil
+	}
+	if len(buf) > 0 && buf[0] == 0xfd {
+		// decrypted by serve once the session is known
+		if TransformHeader(buf).IsInvalid() {
+			putFrameBuffer(buf)
+			return nil, fmt.Errorf("transform header is invalid")
+		}
+		return msg, nil
+	}
+	if msg.IsInvalid() {
+		putFrameBuffer(buf)
+		return nil, fmt.Errorf("msg is invalid")
+	}
+
+	return msg, nil
+
+}
+
+// writePacket sends one SMB2 message to the client.
+func (c *conn) writePacket(msg []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return writeFrame(c.rwc, msg)
+}
+
+// negotiated updates the frame limit after MaxTransactSize, MaxReadSize
+// and MaxWriteSize have been announced to the client.
+func (c *conn) negotiated() {
+	n := c.server.maxTransactSize()
+	if r := c.server.maxReadSize(); r > n {
+		n = r
+	}
+	if w := c.server.maxWriteSize(); w > n {
+		n = w
+	}
+	c.maxFrameSize = int(n) + frameOverhead
+	if c.maxFrameSize > directTCPMaxLength {
+		c.maxFrameSize = directTCPMaxLength
+	}
+}
+
+// sendResponse signs, compresses and encrypts pkt, the response to req, as
+// session s and the connection require, and sends it. Encrypted messages
+// are not signed, signatures cover the uncompressed message. The response
+// to a request of a compound is sent with the others by sendCompound.
+func (c *conn) sendResponse(s *session, req PacketCodec, pkt []byte) error {
+	resp := PacketCodec(pkt)
+	encrypt := c.shouldEncrypt(s, resp)
+	sign := !encrypt && c.shouldSign(s, req, resp)
+	if c.compound != nil {
+		c.compound.add(pendingResponse{s: s, req: req, pkt: pkt, sign: sign, encrypt: encrypt})
+		return nil
+	}
+	if sign {
+		if err := signMessage(c.signingAlgorithm(), s.keys.SigningKey, resp); err != nil {
+			return err
+		}
+	}
+	return c.writeResponse(s, req, pkt, encrypt)
+}
+
+// writeResponse compresses and encrypts pkt, the signed response to req,
+// and sends it.
+func (c *conn) writeResponse(s *session, req PacketCodec, pkt []byte, encrypt bool) error {
+	if c.shouldCompress(req, PacketCodec(pkt)) {
+		if compressed, ok := compressMessage(c.compressionIds, c.compressionChained, pkt); ok {
+			pkt = compressed
+		}
+	}
+	if encrypt {
+		enc, err := c.encrypt(s, pkt)
+		if err != nil {
+			return err
+		}
+		pkt = enc
+	}
+	return c.writePacket(pkt)
+}
+
+// compressionThreshold is the smallest response worth compressing.
+const compressionThreshold = 4096
+
+// shouldCompress reports whether resp, the response to req, is compressed:
+// READ responses the client asked to be compressed, or every large
+// response with Server.CompressResponses.
+func (c *conn) shouldCompress(req, resp PacketCodec) bool {
+	if len(c.compressionIds) == 0 || len(resp) < compressionThreshold {
+		return false
+	}
+	if c.server.CompressResponses {
+		return true
+	}
+	// MS-SMB2 2.2.19, Flags of the READ request
+	return req.Command() == SMB2_READ && len(req) > 64+3 &&
+		req[64+3]&SMB2_READFLAG_REQUEST_COMPRESSED != 0
+}
+
+// shouldEncrypt reports whether resp is encrypted: responses to encrypted
+// requests are, and every response of a session encrypting its data except
+// the SESSION_SETUP response that establishes it, or of a tree encrypting
+// its data except the TREE_CONNECT response that connects it.
+// MS-SMB2 3.3.4.1.4 Encrypting the Message
+func (c *conn) shouldEncrypt(s *session, resp PacketCodec) bool {
+	if s == nil || s.keys.EncryptionKey == nil || c.encryptionCipher() == 0 {
+		return false
+	}
+	if c.encrypted {
+		return true
+	}
+	if s.encryptData && resp.Command() != SMB2_SESSION_SETUP {
+		return true
+	}
+	return s.treeEncryptData(resp.TreeId()) && resp.Command() != SMB2_TREE_CONNECT
+}
+
+// checkEncryption rejects unencrypted request p on session sessionId, or
+// a tree of it, encrypting its data.
+// MS-SMB2 3.3.5.2.9 Verifying the Session
+// MS-SMB2 3.3.5.2.11 Verifying the Tree Connect
+func (c *conn) checkEncryption(p PacketCodec, sessionId uint64, treeId uint32) error {
+	s, ok := c.sessions[sessionId]
+	if !ok {
+		return nil
+	}
+	switch p.Command() {
+	case SMB2_NEGOTIATE, SMB2_SESSION_SETUP:
+		return nil
+	case SMB2_TREE_CONNECT:
+		if !s.encryptData {
+			return nil
+		}
+	default:
+		if !s.encryptData && !s.treeEncryptData(treeId) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unencrypted %v on session 0x%x encrypting data", p.Command(), s.sessionId)
+}
+
+// shouldSign reports whether resp, the response to req, is signed.
+// MS-SMB2 3.3.4.1.1 Signing the Message
+func (c *conn) shouldSign(s *session, req, resp PacketCodec) bool {
+	if s == nil || s.keys.SigningKey == nil {
+		return false
+	}
+	if resp.Command() == SMB2_SESSION_SETUP && resp.Status() == STATUS_SUCCESS &&
+		c.dialect >= SMB2_DIALECT_30 {
+		// the final SESSION_SETUP response of SMB 3.x is always signed
+		return true
+	}
+	return s.signingRequired || req.Flags()&SMB2_FLAGS_SIGNED != 0
+}
+
+// checkSignature verifies the signature of request p for session
+// sessionId. Requests of sessions that are not authenticated yet are not
+// checked.
+// MS-SMB2 3.3.5.2.4 Verifying the Signature
+func (c *conn) checkSignature(p PacketCodec, sessionId uint64) error {
+	s, ok := c.sessions[sessionId]
+	if !ok || s.keys.SigningKey == nil {
+		return nil
+	}
+	if p.Flags()&SMB2_FLAGS_SIGNED == 0 {
+		if s.signingRequired && p.Command() != SMB2_SESSION_SETUP && p.Command() != SMB2_NEGOTIATE {
+			return fmt.Errorf("unsigned %v on session 0x%x requiring signing", p.Command(), s.sessionId)
+		}
+		return nil
+	}
+	if !verifySignature(c.signingAlgorithm(), s.keys.SigningKey, p) {
+		return fmt.Errorf("bad signature of %v on session 0x%x", p.Command(), s.sessionId)
+	}
+	return nil
+}
+
+// newResponseHeader returns the SMB2 header of the response to request p.
+func newResponseHeader(p PacketCodec, status uint32) PacketCodec {
+	smb2Header := PacketCodec(make([]byte, 64))
+	smb2Header.SetProtocolId()
+	smb2Header.SetStructureSize()
+	smb2Header.SetCreditCharge(p.CreditCharge())
+	smb2Header.SetCommand(p.Command())
+	smb2Header.SetStatus(status)
+	smb2Header.SetCreditRequestResponse(1)
+	smb2Header.SetFlags(SMB2_FLAGS_SERVER_TO_REDIR)
+	smb2Header.SetNextCommand(0)
+	smb2Header.SetMessageId(p.MessageId())
+	smb2Header.SetTreeId(p.TreeId())
+	smb2Header.SetSessionId(p.SessionId())
+	return smb2Header
+}
+
+// writeErrorResponse answers request p with an SMB2 ERROR response.
+func (c *conn) writeErrorResponse(p PacketCodec, status uint32) error {
+	pkt := []byte{}
+	pkt = append(pkt, newResponseHeader(p, status)...)
+	pkt = append(pkt, NewErrorResponse()...)
+	return c.sendResponse(c.sessions[p.SessionId()], p, pkt)
+}
+
+func (c *conn) handleNegotiate(p PacketCodec, msg NegotiateRequest) error {
+	if c.dialect != 0 && c.dialect != SMB2_DIALECT_2xx {
+		// MS-SMB2 3.3.5.4, a second NEGOTIATE ends the connection
+		return fmt.Errorf("negotiate after dialect %v", c.dialect)
+	}
+
+	if msg.IsInvalid() || msg.DialectCount() == 0 {
+		return c.writeErrorResponse(p, STATUS_INVALID_PARAMETER)
+	}
+
+	c.clientCapabilities = Capabilities(msg.Capabilities())
+
+	dialect, ok := c.server.selectDialect(msg.Dialects())
+	if !ok {
+		log.Printf("handleNegotiate: no common dialect in %v", msg.Dialects())
+		return c.writeErrorResponse(p, STATUS_NOT_SUPPORTED)
+	}
+	log.Printf("handleNegotiate: %v for client %v", dialect, msg.ClientGuid())
+
+	securityBufferPayload := c.server.negTokenInit()
+
+	var contexts []NegotiateContext
+	if dialect == SMB2_DIALECT_311 {
+		offer, err := parseNegotiateContexts(msg.NegotiateContextList())
+		if err == nil {
+			contexts, err = c.selectNegotiateContexts(offer)
+		}
+		if err != nil {
+			log.Printf("handleNegotiate: negotiate contexts: %v", err)
+			return c.writeErrorResponse(p, errorStatus(err))
+		}
+	}
+
+	pkt := []byte{}
+	// security buffer follows the SMB2 header and the 64 bytes fixed part
+	length := 64 + len(securityBufferPayload)
+	contextOffset := align8(64 + length)
+	if len(contexts) > 0 {
+		length = contextOffset - 64 + negotiateContextsLength(contexts)
+	}
+	responseHdr := NegotiateResponse(make([]byte, length))
+	if len(contexts) > 0 {
+		responseHdr.SetNegotiateContextCount(uint16(len(contexts)))
+		responseHdr.SetNegotiateContextOffset(uint32(contextOffset))
+		responseHdr.SetNegotiateContexts(contexts)
+	}
+	c.setNegotiateResponse(responseHdr, dialect, securityBufferPayload)
+
+	smb2Header := newResponseHeader(p, STATUS_SUCCESS)
+	smb2Header.SetCreditCharge(1)
+	smb2Header.SetTreeId(0)
+
+	pkt = append(pkt, smb2Header...)
+	pkt = append(pkt, responseHdr...)
+
+	if dialect == SMB2_DIALECT_311 {
+		// MS-SMB2 3.3.5.4, the hash covers the request and the response
+		c.preauthIntegrityHashValue = PreauthIntegrityHashValue{}.Update(p).Update(pkt)
+	}
+
+	if err := c.writePacket(pkt); err != nil {
+		return err
+	}
+	c.dialect = dialect
+	c.negotiated()
+
+	return nil
+
+}
+
+// setNegotiateResponse fills the fixed part of a NEGOTIATE response and its
+// security buffer, the negotiate contexts are left to the caller.
+func (c *conn) setNegotiateResponse(responseHdr NegotiateResponse, dialect Dialect, securityBufferPayload []byte) {
+	capabilities := SMB2_GLOBAL_CAP_DFS
+	if dialect >= SMB2_DIALECT_21 {
+		capabilities |= SMB2_GLOBAL_CAP_LEASING | SMB2_GLOBAL_CAP_LARGE_MTU
+	}
+	if (dialect == SMB2_DIALECT_30 || dialect == SMB2_DIALECT_302) &&
+		c.clientCapabilities&SMB2_GLOBAL_CAP_ENCRYPTION != 0 {
+		// 3.1.1 negotiates encryption with a context instead
+		capabilities |= SMB2_GLOBAL_CAP_ENCRYPTION
+	}
+
+	responseHdr.SetStructureSize(65)
+	securityMode := SMB2_NEGOTIATE_SIGNING_ENABLED
+	if c.server.RequireSigning {
+		securityMode |= SMB2_NEGOTIATE_SIGNING_REQUIRED
+	}
+	responseHdr.SetSecurityMode(securityMode)
+	responseHdr.SetDialectRevision(dialect)
+	responseHdr.SetServerGuid(serverGUID)
+	responseHdr.SetCapabilities(capabilities)
+	responseHdr.SetMaxTransactSize(c.server.maxTransactSize())
+	responseHdr.SetMaxReadSize(c.server.maxReadSize())
+	responseHdr.SetMaxWriteSize(c.server.maxWriteSize())
+	responseHdr.SetSystemTime(time.Now())
+	responseHdr.SetServerStartTime(time.Time{})
+	responseHdr.SetSecurityBufferOffset(0x80)
+	responseHdr.SetSecurityBufferLength(uint16(len(securityBufferPayload)))
+	responseHdr.SetBuffer(securityBufferPayload)
+}
+
+// handleSMB1Negotiate answers an SMB1 multi-protocol negotiate offering
+// SMB2 with an SMB2 NEGOTIATE response, any other SMB1 message ends the
+// connection.
+// MS-SMB2 3.3.5.3 Receiving an SMB_COM_NEGOTIATE
+func (c *conn) handleSMB1Negotiate(p SMB1PacketCodec) error {
+	if c.dialect != 0 {
+		return fmt.Errorf("smb1 message after negotiate")
+	}
+	if p.Command() != SMB_COM_NEGOTIATE {
+		return fmt.Errorf("unsupported smb1 command 0x%02x", p.Command())
+	}
+
+	dialects := p.Dialects()
+	dialect, ok := c.server.selectSMB1Dialect(dialects)
+	if !ok {
+		return fmt.Errorf("smb1 negotiate without smb2 dialect: %q", dialects)
+	}
+
+	securityBufferPayload := c.server.negTokenInit()
+	responseHdr := NegotiateResponse(make([]byte, 64+len(securityBufferPayload)))
+	c.setNegotiateResponse(responseHdr, dialect, securityBufferPayload)
+
+	smb2Header := PacketCodec(make([]byte, 64))
+	smb2Header.SetProtocolId()
+	smb2Header.SetStructureSize()
+	smb2Header.SetCommand(SMB2_NEGOTIATE)
+	smb2Header.SetStatus(STATUS_SUCCESS)
+	smb2Header.SetCreditRequestResponse(1)
+	smb2Header.SetFlags(SMB2_FLAGS_SERVER_TO_REDIR)
+
+	pkt := []byte{}
+	pkt = append(pkt, smb2Header...)
+	pkt = append(pkt, responseHdr...)
+
+	if err := c.writePacket(pkt); err != nil {
+		return err
+	}
+	log.Printf("handleSMB1Negotiate: %v of %q", dialect, dialects)
+	c.dialect = dialect
+	c.negotiated()
+
+	return nil
+}
+
+func (c *conn) handleSessionSetup(p PacketCodec, msg SessionSetupRequest) error {
+	if msg.IsInvalid() || len(msg.Buffer()) == 0 {
+		return c.writeErrorResponse(p, STATUS_INVALID_PARAMETER)
+	}
+
+	var s *session
+	if p.SessionId() == 0 {
+		if c.server.EncryptData && c.encryptionCipher() == 0 {
+			// MS-SMB2 3.3.5.5, unencrypted access is rejected
+			log.Printf("handleSessionSetup: client of %v can not encrypt", c.dialect)
+			return c.writeErrorResponse(p, STATUS_ACCESS_DENIED)
+		}
+		var err error
+		if s, err = c.newSession(); err != nil {
+			return err
+		}
+		s.preauthIntegrityHashValue = c.preauthIntegrityHashValue
+		// MS-SMB2 3.3.5.5.3
+		s.signingRequired = c.server.RequireSigning ||
+			msg.SecurityMode()&SMB2_NEGOTIATE_SIGNING_REQUIRED != 0
+		s.encryptData = c.server.EncryptData
+	} else {
+		var ok bool
+		if s, ok = c.sessions[p.SessionId()]; !ok {
+			return c.writeErrorResponse(p, STATUS_USER_SESSION_DELETED)
+		}
+	}
+	c.updatePreauthIntegrityHash(s, p)
+
+	// get NTLMSSP message
+	gssBuffer := msg.Buffer()
+	var mechToken, mechListMIC []byte
+	if gssBuffer[0] == 0x60 {
+		gssPayload, err := auth.NewInitPayload(gssBuffer)
+		if err != nil {
+			log.Printf("handleSessionSetup NewInitPayload: %v", err)
+			return c.failSessionSetup(p, s, statusError(STATUS_INVALID_PARAMETER))
+		}
+		mechToken = gssPayload.Token.NegTokenInit.MechToken
+		s.mechTypes = gssPayload.Token.NegTokenInit.MechTypes
+	} else if gssBuffer[0] == 0xa1 {
+		gssPayload, err := auth.NewTargPayload(gssBuffer)
+		if err != nil {
+			log.Printf("handleSessionSetup NewTargPayload: %v", err)
+			return c.failSessionSetup(p, s, statusError(STATUS_INVALID_PARAMETER))
+		}
+		mechToken = gssPayload.ResponseToken
+		mechListMIC = gssPayload.MechListMIC
+	}
+
+	if gssBuffer[0] == 0x60 && len(s.mechTypes) > 0 && isKerberos(s.mechTypes[0]) {
+		if c.server.Keytab != nil && len(mechToken) > 0 {
+			return c.handleSessionSetupKerberos(p, s, s.mechTypes[0], mechToken)
+		}
+		// the optimistic Kerberos token is dropped, NTLM is selected if the
+		// client offered it and the mechListMIC then becomes mandatory
+		// RFC 4178 4.2.2. negTokenResp
+		for _, mech := range s.mechTypes[1:] {
+			if mech.Equal(auth.NlmpMechType) && !c.server.DisableNTLM {
+				return c.sendSessionSetupMoreProcessing(p, s, &auth.TargPayload{
+					NegResult:     3, // request-mic
+					SupportedMech: auth.NlmpMechType,
+				})
+			}
+		}
+		return c.failSessionSetup(p, s, fmt.Errorf("no supported mechanism in %v", s.mechTypes))
+	}
+
+	if c.server.DisableNTLM {
+		return c.failSessionSetup(p, s, fmt.Errorf("ntlm is disabled"))
+	}
+
+	// get NTLMSSP message
+	ntlmsspPayload := auth.NTLMMessage(mechToken)
+	if ntlmsspPayload.IsInvalid() {
+		log.Printf("handleSessionSetup: invalid NTLM message of %d bytes", len(mechToken))
+		return c.failSessionSetup(p, s, statusError(STATUS_INVALID_PARAMETER))
+	}
+
+	switch ntlmsspPayload.MessageType() {
+	case auth.NTLMSSP_NEGOTIATE:
+		return c.handleSessionSetupNtmlsspNetotiate(p, s, msg, auth.NTLMNegotiateMessage(mechToken))
+	case auth.NTLMSSP_AUTH:
+		return c.handleSessionSetupNtmlsspAuth(p, s, msg, ntlmssp.AuthenticateMessage(mechToken), mechListMIC)
+	default:
+		log.Printf("handleSessionSetup: unexpected NTLM message type %d", ntlmsspPayload.MessageType())
+	}
+	return c.failSessionSetup(p, s, statusError(STATUS_INVALID_PARAMETER))
+}
+func (c *conn) handleSessionSetupNtmlsspNetotiate(p PacketCodec, s *session, msg SessionSetupRequest, ntlpPayload auth.NTLMNegotiateMessage) error {
+	if ntlpPayload.IsInvalid() {
+		c.removeSession(s)
+		return c.writeErrorResponse(p, STATUS_INVALID_PARAMETER)
+	}
+	challenge, err := ntlmssp.NewChallengeMessage(ntlmssp.NegotiateFlags(ntlpPayload.Flags()), c.server.targetNames())
+	if err != nil {
+		return err
+	}
+	s.ntlmNegotiate = append([]byte{}, ntlpPayload...)
+	s.ntlmChallenge = challenge
+	// accept-incomplete
+	return c.sendSessionSetupMoreProcessing(p, s, &auth.TargPayload{
+		NegResult:     1,
+		SupportedMech: auth.NlmpMechType,
+		ResponseToken: challenge,
+	})
+}
+
+// sendSessionSetupMoreProcessing answers p with an intermediate
+// SESSION_SETUP response carrying the SPNEGO token, which the preauth
+// integrity hash of the session covers.
+func (c *conn) sendSessionSetupMoreProcessing(p PacketCodec, s *session, token *auth.TargPayload) error {
+	securityBuffer, err := token.Bytes()
+	if err != nil {
+		return fmt.Errorf("sendSessionSetupMoreProcessing Bytes: %v", err)
+	}
+	responseHdr := SessionSetupResponse(make([]byte, 8+len(securityBuffer)))
+	responseHdr.SetStructureSize()
+	responseHdr.SetSecurityBufferOffset(0x48)
+	responseHdr.SetSecurityBufferLength(uint16(len(securityBuffer)))
+	responseHdr.SetBuffer(securityBuffer)
+
+	smb2Header := PacketCodec(make([]byte, 64, 64))
+	smb2Header.SetProtocolId()
+	smb2Header.SetStructureSize()
+	smb2Header.SetCreditCharge(1)
+	smb2Header.SetCommand(SMB2_SESSION_SETUP)
+	smb2Header.SetStatus(STATUS_MORE_PROCESSING_REQUIRED)
+	smb2Header.SetCreditRequestResponse(1)
+	smb2Header.SetFlags(SMB2_FLAGS_SERVER_TO_REDIR)
+	smb2Header.SetNextCommand(0)
+	smb2Header.SetMessageId(p.MessageId())
+	smb2Header.SetTreeId(0)
+	smb2Header.SetSessionId(s.sessionId)
+	smb2Header.SetSignature([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+
+	pkt := []byte{}
+	pkt = append(pkt, smb2Header...)
+	pkt = append(pkt, responseHdr...)
+	c.updatePreauthIntegrityHash(s, pkt)
+
+	return c.sendResponse(s, p, pkt)
+}
+
+// handleSessionSetupNtmlsspAuth verifies the AUTHENTICATE message and the
+// mechListMIC of the client and establishes the session with the exported
+// session key, a failed logon removes the session.
+func (c *conn) handleSessionSetupNtmlsspAuth(p PacketCodec, s *session, msg SessionSetupRequest, authMsg ntlmssp.AuthenticateMessage, mechListMIC []byte) error {
+	if !authMsg.IsInvalid() && authMsg.IsAnonymous() {
+		return c.completeGuestSessionSetup(p, s, true)
+	}
+	sessionKey, account, err := c.authenticateNTLM(s, authMsg)
+	if errors.Is(err, ErrAccountNotFound) && c.server.MapToGuest {
+		log.Printf("handleSessionSetupNtmlsspAuth: %v, mapped to guest", err)
+		return c.completeGuestSessionSetup(p, s, false)
+	}
+	if err == nil {
+		mechListMIC, err = s.checkMechListMIC(authMsg.NegotiateFlags(), sessionKey, mechListMIC)
+	}
+	if err == nil {
+		err = s.setUser(authMsg.UserName(), authMsg.DomainName(), account, c.server.ntlmExpirationTime())
+	}
+	if err != nil {
+		log.Printf("handleSessionSetupNtmlsspAuth: %v", err)
+		return c.failSessionSetup(p, s, err)
+	}
+
+	s.ntlmNegotiate, s.ntlmChallenge = nil, nil
+	log.Printf("handleSessionSetup: session 0x%x of %s\\%s from %s", s.sessionId, s.domainName, s.userName, authMsg.Workstation())
+	// accept-completed
+	return c.completeSessionSetup(p, s, sessionKey, &auth.TargPayload{
+		NegResult:     0,
+		SupportedMech: auth.NlmpMechType,
+		MechListMIC:   mechListMIC,
+	})
+}
+
+// handleSessionSetupKerberos verifies the AP-REQ of the client with the
+// keytab of the server and establishes the session with the key of the
+// security context, the AP-REP is returned for mutual authentication.
+// MS-KILE 3.4.5 Message Processing Events and Sequencing Rules
+func (c *conn) handleSessionSetupKerberos(p PacketCodec, s *session, mech auth.MechType, token []byte) error {
+	ctx, err := krb5.Accept(c.server.Keytab, &c.server.replayCache, token)
+	if err != nil {
+		log.Printf("handleSessionSetupKerberos: %v", err)
+		return c.failSessionSetup(p, s, err)
+	}
+
+	account := &Account{UserName: ctx.Client.String(), DomainName: ctx.Realm}
+	if info := ctx.LogonInfo; info != nil {
+		account = &Account{
+			UserName:   info.EffectiveName,
+			DomainName: info.LogonDomainName,
+			SID:        info.UserSID(),
+			GroupSIDs:  info.GroupSIDs(),
+		}
+	}
+	// the session lasts as long as the ticket
+	if err := s.setUser(ctx.Client.String(), ctx.Realm, account, ctx.EndTime); err != nil {
+		log.Printf("handleSessionSetupKerberos: %v", err)
+		return c.failSessionSetup(p, s, err)
+	}
+	log.Printf("handleSessionSetup: session 0x%x of %s@%s", s.sessionId, s.userName, s.domainName)
+	// accept-completed
+	return c.completeSessionSetup(p, s, ctx.SessionKey.KeyValue, &auth.TargPayload{
+		NegResult:     0,
+		SupportedMech: mech,
+		ResponseToken: ctx.Response,
+	})
+}
+
+// completeGuestSessionSetup establishes s as a null session, or as a
+// session of the guest account, as the policy of the server allows. Such
+// sessions have no session key: they are neither signed nor encrypted.
+// MS-SMB2 3.3.5.5.3 Handling GSS-API Authentication
+func (c *conn) completeGuestSessionSetup(p PacketCodec, s *session, anonymous bool) error {
+	if anonymous && !c.server.AllowAnonymous {
+		log.Printf("completeGuestSessionSetup: anonymous logon refused")
+		return c.failSessionSetup(p, s, fmt.Errorf("anonymous logon is not allowed"))
+	}
+	if s.encryptData {
+		log.Printf("completeGuestSessionSetup: guest session of a server encrypting data")
+		return c.failSessionSetup(p, s, statusError(STATUS_ACCESS_DENIED))
+	}
+
+	var err error
+	if anonymous {
+		err = s.setUser("", "", &Account{SID: anonymousSID}, time.Time{})
+	} else {
+		err = s.setUser(guestUserName, "", &Account{UserName: guestUserName, GroupSIDs: []string{guestsSID}}, time.Time{})
+	}
+	if err != nil {
+		log.Printf("completeGuestSessionSetup: %v", err)
+		return c.failSessionSetup(p, s, err)
+	}
+	s.isAnonymous, s.isGuest = anonymous, !anonymous
+	s.signingRequired = false
+	s.ntlmNegotiate, s.ntlmChallenge = nil, nil
+	log.Printf("handleSessionSetup: session 0x%x, flags %v", s.sessionId, s.sessionFlags())
+	// accept-completed
+	return c.completeSessionSetup(p, s, nil, &auth.TargPayload{
+		NegResult:     0,
+		SupportedMech: auth.NlmpMechType,
+	})
+}
+
+// completeSessionSetup establishes s with the session key returned by
+// authentication and answers p with the final SESSION_SETUP response.
+// Anonymous and guest sessions have no session key, re-authenticated
+// sessions keep the keys they were established with.
+// MS-SMB2 3.3.5.5.3 Handling GSS-API Authentication
+func (c *conn) completeSessionSetup(p PacketCodec, s *session, sessionKey []byte, token *auth.TargPayload) error {
+	if s.state == sessionInProgress {
+		s.dialect = c.dialect
+		if sessionKey != nil {
+			c.deriveKeys(s, sessionKey)
+		}
+	}
+	s.state = sessionValid
+
+	securityBuffer, err := token.Bytes()
+	if err != nil {
+		return fmt.Errorf("completeSessionSetup Bytes: %v", err)
+	}
+	responseHdr := SessionSetupResponse(make([]byte, 8+len(securityBuffer)))
+	responseHdr.SetStructureSize()
+	responseHdr.SetSessionFlags(s.sessionFlags())
+	responseHdr.SetSecurityBufferOffset(0x48)
+	responseHdr.SetSecurityBufferLength(uint16(len(securityBuffer)))
+	responseHdr.SetBuffer(securityBuffer)
+
+	smb2Header := newResponseHeader(p, STATUS_SUCCESS)
+	smb2Header.SetSessionId(s.sessionId)
+	pkt := []byte{}
+	pkt = append(pkt, smb2Header...)
+	pkt = append(pkt, responseHdr...)
+	return c.sendResponse(s, p, pkt)
+}
+
+// failSessionSetup answers p with the status of err, STATUS_LOGON_FAILURE
+// unless it is a statusError. A failed SESSION_SETUP removes the session.
+func (c *conn) failSessionSetup(p PacketCodec, s *session, err error) error {
+	status := STATUS_LOGON_FAILURE
+	var se statusError
+	if errors.As(err, &se) {
+		status = uint32(se)
+	}
+	responseHdr := SessionSetupResponse(make([]byte, 8))
+	responseHdr.SetStructureSize()
+
+	smb2Header := newResponseHeader(p, status)
+	smb2Header.SetSessionId(s.sessionId)
+	pkt := []byte{}
+	pkt = append(pkt, smb2Header...)
+	pkt = append(pkt, responseHdr...)
+
+	c.removeSession(s)
+	return c.sendResponse(s, p, pkt)
+}
+
+// isKerberos reports whether mech is Kerberos, under its standard OID or
+// the one of Windows.
+func isKerberos(mech auth.MechType) bool {
+	return mech.Equal(auth.KerberosMechType) || mech.Equal(auth.MSKerberosMechType)
+}
+
+// authenticateNTLM verifies the NTLMv2 response of authMsg against the
+// challenge sent to the client and returns the exported session key and
+// the account of the user. The response key is computed with the domain the client sent, then with
+// no domain as some clients leave it out of NTOWFv2.
+// MS-NLMP 3.2.5.1.2 Server Receives an AUTHENTICATE_MESSAGE from the Client
+func (c *conn) authenticateNTLM(s *session, authMsg ntlmssp.AuthenticateMessage) ([]byte, *Account, error) {
+	if authMsg.IsInvalid() {
+		return nil, nil, fmt.Errorf("invalid AUTHENTICATE message")
+	}
+	if s.ntlmChallenge == nil {
+		return nil, nil, fmt.Errorf("AUTHENTICATE before CHALLENGE")
+	}
+	user, domain := authMsg.UserName(), authMsg.DomainName()
+	if c.server.Credentials == nil {
+		return nil, nil, fmt.Errorf("lookup of %s\\%s: %w", domain, user, ErrAccountNotFound)
+	}
+	account, err := c.server.Credentials.LookupAccount(user, domain)
+	if err != nil {
+		return nil, nil, fmt.Errorf("lookup of %s\\%s: %w", domain, user, err)
+	}
+	if account.NTHash == nil {
+		return nil, nil, fmt.Errorf("account %s\\%s has no password", domain, user)
+	}
+
+	for _, d := range []string{domain, ""} {
+		responseKeyNT := ntlmssp.NTOWFv2(account.NTHash, user, d)
+		sessionBaseKey, ok := ntlmssp.VerifyNTLMv2Response(responseKeyNT, s.ntlmChallenge.ServerChallenge(), authMsg.NtChallengeResponse())
+		if !ok {
+			continue
+		}
+		key, err := ntlmssp.ExportedSessionKey(authMsg.NegotiateFlags(), sessionBaseKey, authMsg.EncryptedRandomSessionKey())
+		if err != nil {
+			return nil, nil, err
+		}
+		// the client tells in its copy of the target info that the MIC
+		// covers the three messages
+		if authMsg.TargetInfo().Flags()&ntlmssp.MSV_AV_FLAG_MIC_PRESENT != 0 &&
+			!ntlmssp.VerifyMIC(key, s.ntlmNegotiate, s.ntlmChallenge, authMsg) {
+			return nil, nil, fmt.Errorf("bad MIC of %s\\%s", d, user)
+		}
+		// the account state is only told to clients knowing the password
+		if account.Flags&ACCOUNT_DISABLED != 0 {
+			return nil, nil, fmt.Errorf("account %s\\%s: %w", d, user, statusError(STATUS_ACCOUNT_DISABLED))
+		}
+		if account.Flags&ACCOUNT_LOCKED != 0 {
+			return nil, nil, fmt.Errorf("account %s\\%s: %w", d, user, statusError(STATUS_ACCOUNT_LOCKED_OUT))
+		}
+		return key, account, nil
+	}
+	return nil, nil, fmt.Errorf("wrong NTLMv2 response of %s\\%s", domain, user)
+}
+
+// checkMechListMIC verifies the mechListMIC of the client over the
+// mechanisms it offered and returns the mechListMIC of the server, nil when
+// none is exchanged. It is required when NTLM was not the first choice of
+// the client, so that an attacker can not downgrade the mechanism.
+// RFC 4178 5. Processing of mechListMIC
+func (s *session) checkMechListMIC(flags ntlmssp.NegotiateFlags, sessionKey, mechListMIC []byte) ([]byte, error) {
+	if mechListMIC == nil {
+		if len(s.mechTypes) > 0 && !s.mechTypes[0].Equal(auth.NlmpMechType) {
+			return nil, fmt.Errorf("mechListMIC missing, NTLM was not the preferred mechanism")
+		}
+		return nil, nil
+	}
+	if len(s.mechTypes) == 0 {
+		return nil, fmt.Errorf("mechListMIC without NegTokenInit")
+	}
+	mechTypes, err := s.mechTypes.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	security, err := ntlmssp.NewSessionSecurity(flags, sessionKey, true)
+	if err != nil {
+		return nil, err
+	}
+	if !security.VerifyMIC(mechTypes, mechListMIC) {
+		return nil, fmt.Errorf("bad mechListMIC")
+	}
+	return security.GetMIC(mechTypes), nil
+}
+
+// handleLogoff ends the session of request p, the response is still
+// signed or encrypted with the keys of the session.
+// MS-SMB2 3.3.5.6 Receiving an SMB2 LOGOFF Request
+func (c *conn) handleLogoff(p PacketCodec, s *session) error {
+	pkt := []byte{}
+	pkt = append(pkt, newResponseHeader(p, STATUS_SUCCESS)...)
+	pkt = append(pkt, NewLogoffResponse()...)
+	c.removeSession(s)
+	log.Printf("handleLogoff: session 0x%x", s.sessionId)
+	return c.sendResponse(s, p, pkt)
+}
+
+// handleTreeConnect connects session s to the share named by request msg.
+// MS-SMB2 3.3.5.7 Receiving an SMB2 TREE_CONNECT Request
+func (c *conn) handleTreeConnect(p PacketCodec, s *session, msg TreeConnectRequest) error {
+	path := msg.Path()
+	name, ok := shareName(path)
+	if !ok {
+		log.Printf("handleTreeConnect: invalid path %q", path)
+		return c.writeErrorResponse(p, STATUS_INVALID_PARAMETER)
+	}
+	sh := c.server.lookupShare(name)
+	if sh == nil {
+		log.Printf("handleTreeConnect: no share %q", name)
+		return c.writeErrorResponse(p, STATUS_BAD_NETWORK_NAME)
+	}
+	if !sh.allows(s) {
+		log.Printf("handleTreeConnect: %s\\%s refused on %q", s.domainName, s.userName, sh.Name)
+		return c.writeErrorResponse(p, STATUS_ACCESS_DENIED)
+	}
+	if sh.EncryptData && !s.encryptData && (c.encryptionCipher() == 0 || s.keys.EncryptionKey == nil) {
+		// the client can not encrypt, or the session has no key to
+		log.Printf("handleTreeConnect: %q requires encryption", sh.Name)
+		return c.writeErrorResponse(p, STATUS_ACCESS_DENIED)
+	}
+
+	tc := s.newTreeConnect(sh)
+	tc.encryptData = sh.EncryptData
+	flags := sh.shareFlags()
+	if s.encryptData {
+		flags |= SMB2_SHAREFLAG_ENCRYPT_DATA
+	}
+
+	responseHdr := TreeConnectResponse(make([]byte, 16))
+	responseHdr.SetStructureSize()
+	responseHdr.SetShareType(sh.shareType())
+	responseHdr.SetShareFlags(flags)
+	responseHdr.SetMaximalAccess(tc.maximalAccess)
+
+	smb2Header := newResponseHeader(p, STATUS_SUCCESS)
+	smb2Header.SetTreeId(tc.treeId)
+	pkt := []byte{}
+	pkt = append(pkt, smb2Header...)
+	pkt = append(pkt, responseHdr...)
+	log.Printf("handleTreeConnect: %q tree 0x%x on session 0x%x", sh.Name, tc.treeId, s.sessionId)
+	return c.sendResponse(s, p, pkt)
+}
+
+// handleTreeDisconnect closes the opens of tree tc of session s and
+// disconnects it.
+// MS-SMB2 3.3.5.8 Receiving an SMB2 TREE_DISCONNECT Request
+func (c *conn) handleTreeDisconnect(p PacketCodec, s *session, tc *treeConnect) error {
+	pkt := []byte{}
+	pkt = append(pkt, newResponseHeader(p, STATUS_SUCCESS)...)
+	pkt = append(pkt, NewTreeDisconnectResponse()...)
+	log.Printf("handleTreeDisconnect: tree 0x%x on session 0x%x", tc.treeId, s.sessionId)
+	// the tree is only removed once the response is sent, it is encrypted
+	// if the tree was
+	s.closeTreeOpens(tc)
+	err := c.sendResponse(s, p, pkt)
+	s.disconnectTree(tc)
+	return err
+}
+
+// handleCreate opens a file of the share of tree tc for session s.
+// MS-SMB2 3.3.5.9 Receiving an SMB2 CREATE Request
+func (c *conn) handleCreate(p PacketCodec, s *session, tc *treeConnect, msg CreateRequest) error {
+	o, action, fi, err := s.createOpen(tc, msg)
+	if err != nil {
+		log.Printf("handleCreate: %v", err)
+		return c.writeErrorResponse(p, vfsStatus(err))
+	}
+
+	pkt := []byte{}
+	pkt = append(pkt, newResponseHeader(p, STATUS_SUCCESS)...)
+	pkt = append(pkt, NewCreateResponse(action, o.fileId, fi)...)
+	log.Printf("handleCreate: %q action %d on tree 0x%x", o.name, action, tc.treeId)
+	return c.sendResponse(s, p, pkt)
+}
+
+// handleClose closes the open of request msg on tree tc of session s,
+// answering with the attributes the file had when the client asks for
+// them.
+// MS-SMB2 3.3.5.10 Receiving an SMB2 CLOSE Request
+func (c *conn) handleClose(p PacketCodec, s *session, tc *treeConnect, msg CloseRequest) error {
+	o := s.opens[msg.FileId()]
+	if o == nil || o.tree != tc {
+		return c.writeErrorResponse(p, STATUS_FILE_CLOSED)
+	}
+	var fi fs.FileInfo
+	if msg.Flags()&SMB2_CLOSE_FLAG_POSTQUERY_ATTRIB != 0 {
+		var err error
+		if fi, err = o.file.Stat(); err != nil {
+			log.Printf("handleClose: %q: %v", o.name, err)
+			fi = nil
+		}
+	}
+	s.closeOpen(o)
+
+	pkt := []byte{}
+	pkt = append(pkt, newResponseHeader(p, STATUS_SUCCESS)...)
+	pkt = append(pkt, NewCloseResponse(fi)...)
+	return c.sendResponse(s, p, pkt)
+}
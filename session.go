@@ -0,0 +1,377 @@
+package simba
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PichuChen/simba/auth"
+	"github.com/PichuChen/simba/ntlmssp"
+)
+
+var errNonceExhausted = errors.New("session: encryption nonces exhausted")
+
+type sessionState uint8
+
+// MS-SMB2 3.3.1.8 Session.State
+const (
+	sessionInProgress sessionState = iota
+	sessionValid
+	sessionExpired
+)
+
+// session is the state of an SMB2 session on a connection.
+// MS-SMB2 3.3.1.8 Per Session
+type session struct {
+	sessionId uint64
+
+	// conn is the connection the session was established on.
+	conn *conn
+
+	state   sessionState
+	dialect Dialect
+
+	// userName and domainName identify the authenticated user, account
+	// is its entry in the credential store.
+	userName   string
+	domainName string
+	account    *Account
+
+	// isAnonymous and isGuest are set for null sessions and for users
+	// mapped to the guest account, which have no session key.
+	isAnonymous bool
+	isGuest     bool
+
+	// expirationTime is when the credentials of the user lapse and the
+	// client must re-authenticate, zero for never.
+	expirationTime time.Time
+
+	// ntlmNegotiate and ntlmChallenge are the NEGOTIATE message of the
+	// client and the CHALLENGE answering it, the AUTHENTICATE message is
+	// verified against them.
+	ntlmNegotiate []byte
+	ntlmChallenge ntlmssp.ChallengeMessage
+
+	// mechTypes are the mechanisms of the SPNEGO NegTokenInit of the
+	// client, protected by the mechListMIC.
+	mechTypes auth.MechTypeList
+
+	// preauthIntegrityHashValue starts from the connection hash and
+	// continues over the SESSION_SETUP exchange, 3.1.1 only.
+	preauthIntegrityHashValue PreauthIntegrityHashValue
+
+	// keys are derived once authentication returned a session key.
+	keys SessionKeys
+
+	// signingRequired is set when the server or the client requires all
+	// messages of the session to be signed.
+	signingRequired bool
+
+	// encryptData is set when every message of the session is encrypted,
+	// announced with SMB2_SESSION_FLAG_ENCRYPT_DATA.
+	encryptData bool
+
+	// nonceMu guards nonce, the count of messages encrypted with
+	// keys.EncryptionKey.
+	nonceMu sync.Mutex
+	nonce   uint64
+
+	// treeConnects are the trees connected on the session by TreeId,
+	// lastTreeId is the last TreeId handed out.
+	treeConnects map[uint32]*treeConnect
+	lastTreeId   uint32
+
+	// opens are the files opened on the trees of the session by FileId.
+	opens map[FileId]*open
+}
+
+// treeConnect is a tree connected on a session.
+// MS-SMB2 3.3.1.10 Per Tree Connect
+type treeConnect struct {
+	treeId uint32
+	share  *Share
+
+	// maximalAccess are the rights of the user on the share.
+	maximalAccess AccessMask
+
+	// encryptData is set when the messages of the tree are encrypted.
+	encryptData bool
+}
+
+// sessionTable holds the sessions of every connection of a server, it is
+// safe for concurrent use.
+// MS-SMB2 3.3.1.1 GlobalSessionTable
+type sessionTable struct {
+	mu       sync.Mutex
+	sessions map[uint64]*session
+}
+
+// create registers a new session of c with a SessionId unused on the
+// server. Ids are random so they can not be guessed from one another.
+func (t *sessionTable) create(c *conn) (*session, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sessions == nil {
+		t.sessions = map[uint64]*session{}
+	}
+
+	var b [8]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, fmt.Errorf("session id: %w", err)
+		}
+		id := binary.LittleEndian.Uint64(b[:])
+		// 0 is no session, all ones is used by related compound requests
+		if id == 0 || id == 1<<64-1 || t.sessions[id] != nil {
+			continue
+		}
+		s := &session{
+			sessionId:    id,
+			conn:         c,
+			state:        sessionInProgress,
+			dialect:      c.dialect,
+			treeConnects: map[uint32]*treeConnect{},
+			opens:        map[FileId]*open{},
+		}
+		t.sessions[id] = s
+		return s, nil
+	}
+}
+
+// lookup returns the session with id, nil if there is none.
+func (t *sessionTable) lookup(id uint64) *session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessions[id]
+}
+
+func (t *sessionTable) remove(id uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.sessions, id)
+}
+
+func (t *sessionTable) len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.sessions)
+}
+
+// newSession creates an in progress session on the connection.
+func (c *conn) newSession() (*session, error) {
+	s, err := c.server.sessionTable.create(c)
+	if err != nil {
+		return nil, err
+	}
+	c.sessions[s.sessionId] = s
+	return s, nil
+}
+
+// removeSession tears s down: its trees are disconnected and it is
+// removed from the connection and the server.
+func (c *conn) removeSession(s *session) {
+	for _, tc := range s.treeConnects {
+		s.disconnectTree(tc)
+	}
+	delete(c.sessions, s.sessionId)
+	c.server.sessionTable.remove(s.sessionId)
+}
+
+// closeSessions tears down every session of the connection once it is
+// lost.
+// MS-SMB2 3.3.7.1 Handling Loss of a Connection
+func (c *conn) closeSessions() {
+	for _, s := range c.sessions {
+		c.removeSession(s)
+	}
+}
+
+// newTreeConnect connects a tree of share sh on s, with a TreeId unused on
+// the session.
+func (s *session) newTreeConnect(sh *Share) *treeConnect {
+	for {
+		s.lastTreeId++
+		id := s.lastTreeId
+		// 0xFFFFFFFF is used by related compound requests
+		if id == 0 || id == 1<<32-1 || s.treeConnects[id] != nil {
+			continue
+		}
+		tc := &treeConnect{
+			treeId:        id,
+			share:         sh,
+			maximalAccess: sh.maximalAccess(),
+		}
+		s.treeConnects[id] = tc
+		return tc
+	}
+}
+
+// disconnectTree closes the opens of tree tc and removes it from s.
+func (s *session) disconnectTree(tc *treeConnect) {
+	s.closeTreeOpens(tc)
+	delete(s.treeConnects, tc.treeId)
+}
+
+// closeTreeOpens closes the files opened on tree tc.
+func (s *session) closeTreeOpens(tc *treeConnect) {
+	for _, o := range s.opens {
+		if o.tree == tc {
+			s.closeOpen(o)
+		}
+	}
+}
+
+// treeEncryptData reports whether the tree treeId of s encrypts its data.
+func (s *session) treeEncryptData(treeId uint32) bool {
+	tc, ok := s.treeConnects[treeId]
+	return ok && tc.encryptData
+}
+
+// verifySession returns the session of request p, or the status to fail
+// the request with.
+// MS-SMB2 3.3.5.2.9 Verifying the Session
+func (c *conn) verifySession(p PacketCodec) (*session, uint32) {
+	s, ok := c.sessions[p.SessionId()]
+	if !ok {
+		return nil, STATUS_USER_SESSION_DELETED
+	}
+	if s.state == sessionValid && !s.expirationTime.IsZero() && time.Now().After(s.expirationTime) {
+		s.state = sessionExpired
+	}
+	switch s.state {
+	case sessionInProgress:
+		return nil, STATUS_USER_SESSION_DELETED
+	case sessionExpired:
+		// an expired session can still be logged off
+		if p.Command() != SMB2_LOGOFF {
+			return nil, STATUS_NETWORK_SESSION_EXPIRED
+		}
+	}
+	return s, STATUS_SUCCESS
+}
+
+// setUser records the user authenticated on s. A session established
+// before is being re-authenticated and must stay with the same user.
+// MS-SMB2 3.3.5.5.3 Handling GSS-API Authentication
+func (s *session) setUser(userName, domainName string, account *Account, expirationTime time.Time) error {
+	if s.state != sessionInProgress && (!strings.EqualFold(s.userName, userName) || !strings.EqualFold(s.domainName, domainName)) {
+		return fmt.Errorf("re-authentication of %s\\%s as %s\\%s: %w", s.domainName, s.userName, domainName, userName, statusError(STATUS_ACCESS_DENIED))
+	}
+	s.userName, s.domainName = userName, domainName
+	s.account = account
+	s.expirationTime = expirationTime
+	return nil
+}
+
+// nextNonce returns a nonce of size bytes that was never returned before
+// for the session. The nonce is a counter: the encryption key belongs to
+// the session, so a nonce is never repeated under one key.
+func (s *session) nextNonce(size int) ([]byte, error) {
+	s.nonceMu.Lock()
+	defer s.nonceMu.Unlock()
+	if s.nonce == 1<<64-1 {
+		return nil, errNonceExhausted
+	}
+	s.nonce++
+	nonce := make([]byte, size)
+	le.PutUint64(nonce, s.nonce)
+	return nonce, nil
+}
+
+// sessionFlags returns the SessionFlags of the final SESSION_SETUP
+// response of s.
+func (s *session) sessionFlags() SessionSetupSessionFlags {
+	switch {
+	case s.isAnonymous:
+		return SMB2_SESSION_FLAG_IS_NULL
+	case s.isGuest:
+		return SMB2_SESSION_FLAG_IS_GUEST
+	}
+	if s.encryptData {
+		return SMB2_SESSION_FLAG_ENCRYPT_DATA
+	}
+	return 0
+}
+
+// updatePreauthIntegrityHash adds msg to the preauth integrity hash of the
+// session, if the connection uses SMB 3.1.1 and the session is not
+// established yet.
+func (c *conn) updatePreauthIntegrityHash(s *session, msg []byte) {
+	// the keys of a re-authenticated session are kept
+	if c.dialect != SMB2_DIALECT_311 || s.state != sessionInProgress {
+		return
+	}
+	s.preauthIntegrityHashValue = s.preauthIntegrityHashValue.Update(msg)
+}
+
+// deriveKeys sets the keys of s from the session key returned by
+// authentication. In 3.1.1 the preauth integrity hash of the session is the
+// context of the derivation, so it must not be updated afterwards.
+func (c *conn) deriveKeys(s *session, sessionKey []byte) {
+	s.keys = DeriveSessionKeys(c.dialect, c.cipherId, sessionKey, s.preauthIntegrityHashValue[:])
+}
+
+// encryptionCipher returns the cipher encrypting messages of the
+// connection, zero when the client can not encrypt: AES-128-CCM for 3.0
+// and 3.0.2 clients with SMB2_GLOBAL_CAP_ENCRYPTION, the cipher selected
+// by the encryption context in 3.1.1.
+func (c *conn) encryptionCipher() Cipher {
+	switch c.dialect {
+	case SMB2_DIALECT_30, SMB2_DIALECT_302:
+		if c.clientCapabilities&SMB2_GLOBAL_CAP_ENCRYPTION != 0 {
+			return SMB2_ENCRYPTION_AES128_CCM
+		}
+	case SMB2_DIALECT_311:
+		return c.cipherId
+	}
+	return 0
+}
+
+// encrypt returns msg wrapped in a TRANSFORM_HEADER, encrypted with the
+// keys of s.
+func (c *conn) encrypt(s *session, msg []byte) ([]byte, error) {
+	size := 11
+	if cipher := c.encryptionCipher(); cipher == SMB2_ENCRYPTION_AES128_GCM || cipher == SMB2_ENCRYPTION_AES256_GCM {
+		size = 12
+	}
+	nonce, err := s.nextNonce(size)
+	if err != nil {
+		return nil, err
+	}
+	return encryptMessage(c.encryptionCipher(), s.keys.EncryptionKey, nonce, s.sessionId, msg)
+}
+
+// decrypt returns the message wrapped in the TRANSFORM_HEADER pkt, an SMB2
+// message of the session of pkt or a compressed one whose session is
+// checked once decompressed. The returned buffer must be released with
+// putFrameBuffer.
+// MS-SMB2 3.3.5.2.1.1 Decrypting the Message
+func (c *conn) decrypt(pkt TransformHeader) (PacketCodec, error) {
+	if pkt.IsInvalid() {
+		return nil, fmt.Errorf("invalid transform header")
+	}
+	s, ok := c.sessions[pkt.SessionId()]
+	if !ok || s.keys.DecryptionKey == nil || c.encryptionCipher() == 0 {
+		return nil, fmt.Errorf("transform header of unknown session 0x%x", pkt.SessionId())
+	}
+
+	buf := getFrameBuffer(len(pkt.Message()))
+	plain, err := decryptMessage(c.encryptionCipher(), s.keys.DecryptionKey, pkt, buf)
+	if err != nil {
+		putFrameBuffer(buf)
+		return nil, err
+	}
+	msg := PacketCodec(plain)
+	if len(msg) > 0 && msg[0] == 0xfc && !CompressionTransformHeader(msg).IsInvalid() {
+		return msg, nil
+	}
+	if msg.IsInvalid() || msg.ProtocolId()[0] != 0xfe || msg.SessionId() != pkt.SessionId() {
+		putFrameBuffer(buf)
+		return nil, fmt.Errorf("invalid message in transform header of session 0x%x", pkt.SessionId())
+	}
+	return msg, nil
+}
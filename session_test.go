@@ -0,0 +1,805 @@
+package simba
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/hex"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PichuChen/simba/auth"
+	"github.com/PichuChen/simba/krb5"
+	"github.com/PichuChen/simba/ntlmssp"
+)
+
+func TestSessionTable(t *testing.T) {
+	srv := &Server{}
+	c := srv.newConn(nil)
+
+	const n = 64
+	ids := make(chan uint64, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := srv.sessionTable.create(c)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids <- s.sessionId
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := map[uint64]bool{}
+	for id := range ids {
+		if id == 0 || seen[id] {
+			t.Errorf("session id 0x%x is not unique", id)
+		}
+		seen[id] = true
+		if s := srv.sessionTable.lookup(id); s == nil || s.state != sessionInProgress {
+			t.Errorf("lookup(0x%x) = %v", id, s)
+		}
+		srv.sessionTable.remove(id)
+	}
+	if srv.sessionTable.len() != 0 {
+		t.Errorf("%d sessions left", srv.sessionTable.len())
+	}
+}
+
+// newLogoffRequest returns a LOGOFF request of session id.
+func newLogoffRequest(id uint64) []byte {
+	hdr := PacketCodec(make([]byte, 64))
+	hdr.SetProtocolId()
+	hdr.SetStructureSize()
+	hdr.SetCommand(SMB2_LOGOFF)
+	hdr.SetMessageId(7)
+	hdr.SetSessionId(id)
+	req := LogoffRequest(make([]byte, 4))
+	req.SetStructureSize()
+	return append(hdr, req...)
+}
+
+func TestLogoff(t *testing.T) {
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	srv := &Server{}
+	c := srv.newConn(sv)
+	c.dialect = SMB2_DIALECT_302
+	s, err := c.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.state = sessionValid
+	other, err := c.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.state = sessionValid
+	done := make(chan struct{})
+	go func() {
+		c.serve()
+		close(done)
+	}()
+
+	roundTrip := func(msg []byte) PacketCodec {
+		if err := writeFrame(cl, msg); err != nil {
+			t.Fatal(err)
+		}
+		r, err := readFrame(cl, directTCPMaxLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return PacketCodec(r)
+	}
+
+	r := roundTrip(newLogoffRequest(s.sessionId))
+	if r.Status() != STATUS_SUCCESS || r.Command() != SMB2_LOGOFF || r.MessageId() != 7 {
+		t.Errorf("LOGOFF response: status 0x%08x, command %v", r.Status(), r.Command())
+	}
+	if LogoffResponse(r[64:]).StructureSize() != 4 {
+		t.Errorf("StructureSize() = %d", LogoffResponse(r[64:]).StructureSize())
+	}
+	if srv.sessionTable.lookup(s.sessionId) != nil {
+		t.Errorf("session 0x%x is still registered", s.sessionId)
+	}
+
+	r = roundTrip(newLogoffRequest(s.sessionId))
+	if r.Status() != STATUS_USER_SESSION_DELETED {
+		t.Errorf("second LOGOFF: status 0x%08x", r.Status())
+	}
+
+	// losing the connection tears the other session down
+	cl.Close()
+	<-done
+	if srv.sessionTable.len() != 0 {
+		t.Errorf("%d sessions left after the connection closed", srv.sessionTable.len())
+	}
+}
+
+// newSessionSetupRequest returns a SESSION_SETUP request of session id
+// carrying the SPNEGO token.
+func newSessionSetupRequest(id, messageId uint64, token []byte) []byte {
+	hdr := PacketCodec(make([]byte, 64))
+	hdr.SetProtocolId()
+	hdr.SetStructureSize()
+	hdr.SetCommand(SMB2_SESSION_SETUP)
+	hdr.SetMessageId(messageId)
+	hdr.SetSessionId(id)
+	req := SessionSetupRequest(make([]byte, 24+len(token)))
+	req.SetStructureSize()
+	req.SetSecurityBufferOffset(64 + 24)
+	req.SetSecurityBufferLength(uint16(len(token)))
+	copy(req[24:], token)
+	return append(hdr, req...)
+}
+
+// newAuthenticateMessage answers challenge with the NTLMv2 response of
+// user, sending sessionKey encrypted with the session base key.
+func newAuthenticateMessage(challenge ntlmssp.ChallengeMessage, user, domain, password string, sessionKey []byte) []byte {
+	responseKeyNT := ntlmssp.NTOWFv2(ntlmssp.NTHash(password), user, domain)
+	ntResponse := ntlmssp.NTLMv2Response(responseKeyNT, challenge.ServerChallenge(),
+		bytes.Repeat([]byte{0xaa}, 8), 0, challenge.TargetInfo())
+	sessionBaseKey, _ := ntlmssp.VerifyNTLMv2Response(responseKeyNT, challenge.ServerChallenge(), ntResponse)
+	// RC4 encryption is its own inverse
+	encryptedKey, _ := ntlmssp.ExportedSessionKey(ntlmssp.NTLMSSP_NEGOTIATE_KEY_EXCH, sessionBaseKey, sessionKey)
+
+	return ntlmssp.NewAuthenticateMessage(ntlmssp.AuthenticateMessageFields{
+		NegotiateFlags:            challenge.NegotiateFlags(),
+		LmChallengeResponse:       make([]byte, 24),
+		NtChallengeResponse:       ntResponse,
+		DomainName:                domain,
+		UserName:                  user,
+		Workstation:               "WORKSTATION",
+		EncryptedRandomSessionKey: encryptedKey,
+	})
+}
+
+func TestSessionSetupNTLMv2(t *testing.T) {
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	srv := &Server{
+		Credentials: memoryCredentials(
+			&Account{UserName: "User", NTHash: ntlmssp.NTHash("Password")},
+			&Account{UserName: "Disabled", NTHash: ntlmssp.NTHash("Password"), Flags: ACCOUNT_DISABLED},
+		),
+	}
+	c := srv.newConn(sv)
+	c.dialect = SMB2_DIALECT_302
+	go c.serve()
+
+	roundTrip := func(msg []byte) PacketCodec {
+		if err := writeFrame(cl, msg); err != nil {
+			t.Fatal(err)
+		}
+		r, err := readFrame(cl, directTCPMaxLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return PacketCodec(r)
+	}
+	negotiate, _ := hex.DecodeString("4e544c4d5353500001000000358288e000000000000000000000000000000000")
+	token, err := (&auth.TargPayload{NegResult: 1, SupportedMech: auth.NlmpMechType, ResponseToken: negotiate}).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// challenge returns the CHALLENGE message of a new session
+	challenge := func() (uint64, ntlmssp.ChallengeMessage) {
+		r := roundTrip(newSessionSetupRequest(0, 1, token))
+		if r.Status() != STATUS_MORE_PROCESSING_REQUIRED {
+			t.Fatalf("SESSION_SETUP status 0x%08x", r.Status())
+		}
+		resp, err := auth.NewTargPayload(SessionSetupResponse(r[64:]).Buffer())
+		if err != nil {
+			t.Fatal(err)
+		}
+		challenge := ntlmssp.ChallengeMessage(resp.ResponseToken)
+		if challenge.IsInvalid() {
+			t.Fatalf("invalid CHALLENGE %x", resp.ResponseToken)
+		}
+		return r.SessionId(), challenge
+	}
+
+	cases := []struct {
+		name     string
+		user     string
+		domain   string
+		password string
+		status   uint32
+	}{
+		{"valid", "user", "Domain", "Password", STATUS_SUCCESS},
+		{"no domain", "User", "", "Password", STATUS_SUCCESS},
+		{"wrong password", "User", "Domain", "password", STATUS_LOGON_FAILURE},
+		{"unknown user", "Other", "Domain", "Password", STATUS_LOGON_FAILURE},
+		{"disabled", "Disabled", "Domain", "Password", STATUS_ACCOUNT_DISABLED},
+		{"disabled with wrong password", "Disabled", "Domain", "password", STATUS_LOGON_FAILURE},
+	}
+	var challenges [][]byte
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			id, cm := challenge()
+			for _, seen := range challenges {
+				if bytes.Equal(seen, cm.ServerChallenge()) {
+					t.Errorf("server challenge %x repeated", seen)
+				}
+			}
+			challenges = append(challenges, append([]byte{}, cm.ServerChallenge()...))
+
+			sessionKey := bytes.Repeat([]byte{0x55}, 16)
+			authMsg := newAuthenticateMessage(cm, tc.user, tc.domain, tc.password, sessionKey)
+			token, err := (&auth.TargPayload{NegResult: 1, SupportedMech: auth.NlmpMechType, ResponseToken: authMsg}).Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := roundTrip(newSessionSetupRequest(id, 2, token))
+			if r.Status() != tc.status {
+				t.Fatalf("SESSION_SETUP status 0x%08x, want 0x%08x", r.Status(), tc.status)
+			}
+
+			s := srv.sessionTable.lookup(id)
+			if tc.status != STATUS_SUCCESS {
+				if s != nil {
+					t.Errorf("session 0x%x survived a failed logon", id)
+				}
+				return
+			}
+			if s == nil || s.state != sessionValid || s.userName != tc.user {
+				t.Fatalf("session 0x%x is not established", id)
+			}
+			// the response is signed with the key the client sent
+			keys := DeriveSessionKeys(SMB2_DIALECT_302, 0, sessionKey, nil)
+			if r.Flags()&SMB2_FLAGS_SIGNED == 0 ||
+				!verifySignature(SMB2_SIGNING_ALGORITHM_AES_CMAC, keys.SigningKey, r) {
+				t.Errorf("final SESSION_SETUP response is not signed with the exported session key")
+			}
+		})
+	}
+}
+
+func TestSessionSetupGuest(t *testing.T) {
+	anonymous := ntlmssp.NewAuthenticateMessage(ntlmssp.AuthenticateMessageFields{
+		NegotiateFlags:      ntlmssp.NTLMSSP_NEGOTIATE_UNICODE | ntlmssp.NTLMSSP_NEGOTIATE_ANONYMOUS,
+		LmChallengeResponse: []byte{0},
+	})
+	cases := []struct {
+		name           string
+		allowAnonymous bool
+		mapToGuest     bool
+		user           string
+		password       string
+		status         uint32
+		flags          SessionSetupSessionFlags
+	}{
+		{"anonymous refused", false, true, "", "", STATUS_LOGON_FAILURE, 0},
+		{"anonymous", true, false, "", "", STATUS_SUCCESS, SMB2_SESSION_FLAG_IS_NULL},
+		{"unknown user refused", true, false, "Other", "Password", STATUS_LOGON_FAILURE, 0},
+		{"unknown user as guest", false, true, "Other", "Password", STATUS_SUCCESS, SMB2_SESSION_FLAG_IS_GUEST},
+		{"wrong password not mapped", false, true, "User", "password", STATUS_LOGON_FAILURE, 0},
+		{"user", true, true, "User", "Password", STATUS_SUCCESS, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cl, sv := net.Pipe()
+			defer cl.Close()
+			srv := &Server{
+				Credentials:    memoryCredentials(&Account{UserName: "User", NTHash: ntlmssp.NTHash("Password")}),
+				RequireSigning: true,
+				AllowAnonymous: tc.allowAnonymous,
+				MapToGuest:     tc.mapToGuest,
+			}
+			c := srv.newConn(sv)
+			c.dialect = SMB2_DIALECT_302
+			go c.serve()
+
+			roundTrip := func(msg []byte) PacketCodec {
+				if err := writeFrame(cl, msg); err != nil {
+					t.Fatal(err)
+				}
+				r, err := readFrame(cl, directTCPMaxLength)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return PacketCodec(r)
+			}
+			negotiate, _ := hex.DecodeString("4e544c4d5353500001000000358288e000000000000000000000000000000000")
+			token, _ := (&auth.TargPayload{NegResult: 1, SupportedMech: auth.NlmpMechType, ResponseToken: negotiate}).Bytes()
+			r := roundTrip(newSessionSetupRequest(0, 1, token))
+			resp, err := auth.NewTargPayload(SessionSetupResponse(r[64:]).Buffer())
+			if err != nil {
+				t.Fatal(err)
+			}
+			id := r.SessionId()
+
+			authMsg := []byte(anonymous)
+			if tc.user != "" {
+				authMsg = newAuthenticateMessage(ntlmssp.ChallengeMessage(resp.ResponseToken), tc.user, "", tc.password, bytes.Repeat([]byte{0x55}, 16))
+			}
+			token, _ = (&auth.TargPayload{NegResult: 1, SupportedMech: auth.NlmpMechType, ResponseToken: authMsg}).Bytes()
+			r = roundTrip(newSessionSetupRequest(id, 2, token))
+			if r.Status() != tc.status {
+				t.Fatalf("SESSION_SETUP status 0x%08x, want 0x%08x", r.Status(), tc.status)
+			}
+			if tc.status != STATUS_SUCCESS {
+				return
+			}
+			if flags := SessionSetupResponse(r[64:]).SessionFlags(); flags != tc.flags {
+				t.Errorf("SessionFlags = %v, want %v", flags, tc.flags)
+			}
+			// anonymous and guest sessions are not signed even though the
+			// server requires signing
+			guest := tc.flags != 0
+			if signed := r.Flags()&SMB2_FLAGS_SIGNED != 0; signed == guest {
+				t.Errorf("final SESSION_SETUP response signed = %v", signed)
+			}
+			if guest {
+				if r := roundTrip(newLogoffRequest(id)); r.Status() != STATUS_SUCCESS {
+					t.Errorf("unsigned LOGOFF status 0x%08x", r.Status())
+				}
+			}
+		})
+	}
+}
+
+func TestSessionReauthentication(t *testing.T) {
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	srv := &Server{
+		Credentials: memoryCredentials(
+			&Account{UserName: "User", NTHash: ntlmssp.NTHash("Password")},
+			&Account{UserName: "Other", NTHash: ntlmssp.NTHash("Password")},
+		),
+		NTLMSessionLifetime: time.Hour,
+	}
+	c := srv.newConn(sv)
+	c.dialect = SMB2_DIALECT_302
+	go c.serve()
+
+	roundTrip := func(msg []byte) PacketCodec {
+		if err := writeFrame(cl, msg); err != nil {
+			t.Fatal(err)
+		}
+		r, err := readFrame(cl, directTCPMaxLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return PacketCodec(r)
+	}
+	negotiate, _ := hex.DecodeString("4e544c4d5353500001000000358288e000000000000000000000000000000000")
+	// logon authenticates user on session id, 0 for a new session
+	logon := func(id uint64, user string, sessionKey []byte) PacketCodec {
+		token, _ := (&auth.TargPayload{NegResult: 1, SupportedMech: auth.NlmpMechType, ResponseToken: negotiate}).Bytes()
+		r := roundTrip(newSessionSetupRequest(id, 1, token))
+		if r.Status() != STATUS_MORE_PROCESSING_REQUIRED {
+			t.Fatalf("SESSION_SETUP status 0x%08x", r.Status())
+		}
+		resp, err := auth.NewTargPayload(SessionSetupResponse(r[64:]).Buffer())
+		if err != nil {
+			t.Fatal(err)
+		}
+		authMsg := newAuthenticateMessage(ntlmssp.ChallengeMessage(resp.ResponseToken), user, "", "Password", sessionKey)
+		token, _ = (&auth.TargPayload{NegResult: 1, SupportedMech: auth.NlmpMechType, ResponseToken: authMsg}).Bytes()
+		return roundTrip(newSessionSetupRequest(r.SessionId(), 2, token))
+	}
+
+	firstKey := bytes.Repeat([]byte{0x55}, 16)
+	r := logon(0, "User", firstKey)
+	if r.Status() != STATUS_SUCCESS {
+		t.Fatalf("SESSION_SETUP status 0x%08x", r.Status())
+	}
+	id := r.SessionId()
+	s := srv.sessionTable.lookup(id)
+	if d := time.Until(s.expirationTime); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("session expires in %v, want 1h", d)
+	}
+
+	// the session keeps the keys it was established with
+	r = logon(id, "user", bytes.Repeat([]byte{0x66}, 16))
+	if r.Status() != STATUS_SUCCESS || r.SessionId() != id {
+		t.Fatalf("re-authentication status 0x%08x of session 0x%x", r.Status(), r.SessionId())
+	}
+	keys := DeriveSessionKeys(SMB2_DIALECT_302, 0, firstKey, nil)
+	if !verifySignature(SMB2_SIGNING_ALGORITHM_AES_CMAC, keys.SigningKey, r) {
+		t.Errorf("re-authentication response is not signed with the first session key")
+	}
+	if srv.sessionTable.lookup(id) != s || s.state != sessionValid {
+		t.Errorf("session 0x%x was not kept", id)
+	}
+
+	// another user can not take the session over
+	r = logon(id, "Other", firstKey)
+	if r.Status() != STATUS_ACCESS_DENIED {
+		t.Errorf("re-authentication as another user status 0x%08x, want STATUS_ACCESS_DENIED", r.Status())
+	}
+	if srv.sessionTable.lookup(id) != nil {
+		t.Errorf("session 0x%x survived a failed re-authentication", id)
+	}
+}
+
+func TestVerifySessionExpired(t *testing.T) {
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	c := (&Server{}).newConn(sv)
+	s, err := c.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.state = sessionValid
+	s.expirationTime = time.Now().Add(time.Hour)
+
+	request := func(command Command) PacketCodec {
+		p := PacketCodec(make([]byte, 64))
+		p.SetCommand(command)
+		p.SetSessionId(s.sessionId)
+		return p
+	}
+	if _, status := c.verifySession(request(SMB2_ECHO)); status != STATUS_SUCCESS {
+		t.Errorf("verifySession() = 0x%08x before expiration", status)
+	}
+
+	s.expirationTime = time.Now().Add(-time.Second)
+	if _, status := c.verifySession(request(SMB2_ECHO)); status != STATUS_NETWORK_SESSION_EXPIRED {
+		t.Errorf("verifySession() = 0x%08x, want STATUS_NETWORK_SESSION_EXPIRED", status)
+	}
+	if s.state != sessionExpired {
+		t.Errorf("state = %v, want sessionExpired", s.state)
+	}
+	if _, status := c.verifySession(request(SMB2_LOGOFF)); status != STATUS_SUCCESS {
+		t.Errorf("verifySession() of LOGOFF = 0x%08x on an expired session", status)
+	}
+}
+
+func TestSessionSetupMechListMIC(t *testing.T) {
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	srv := &Server{
+		Credentials: NewMemoryCredentials(map[string]string{"User": "Password"}),
+	}
+	c := srv.newConn(sv)
+	c.dialect = SMB2_DIALECT_302
+	go c.serve()
+
+	roundTrip := func(msg []byte) PacketCodec {
+		if err := writeFrame(cl, msg); err != nil {
+			t.Fatal(err)
+		}
+		r, err := readFrame(cl, directTCPMaxLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return PacketCodec(r)
+	}
+	kerberos := auth.MechType{1, 2, 840, 48018, 1, 2, 2}
+	negotiate, _ := hex.DecodeString("4e544c4d5353500001000000358288e000000000000000000000000000000000")
+
+	cases := []struct {
+		name           string
+		mechTypes      auth.MechTypeList
+		badMIC         bool
+		mechListMIC    bool
+		badMechListMIC bool
+		status         uint32
+	}{
+		{"mic", auth.MechTypeList{auth.NlmpMechType}, false, true, false, STATUS_SUCCESS},
+		{"without mechListMIC", auth.MechTypeList{auth.NlmpMechType}, false, false, false, STATUS_SUCCESS},
+		{"bad mic", auth.MechTypeList{auth.NlmpMechType}, true, true, false, STATUS_LOGON_FAILURE},
+		{"kerberos preferred", auth.MechTypeList{kerberos, auth.NlmpMechType}, false, true, false, STATUS_SUCCESS},
+		{"downgrade", auth.MechTypeList{kerberos, auth.NlmpMechType}, false, false, false, STATUS_LOGON_FAILURE},
+		{"bad mechListMIC", auth.MechTypeList{kerberos, auth.NlmpMechType}, false, true, true, STATUS_LOGON_FAILURE},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// the optimistic token is for the preferred mechanism
+			kerberosPreferred := tc.mechTypes[0].Equal(kerberos)
+			mechToken := negotiate
+			if kerberosPreferred {
+				mechToken = []byte{0x60, 0x03, 0x06, 0x01, 0x00}
+			}
+			init, err := (&auth.InitPayload{
+				OID: []int{1, 3, 6, 1, 5, 5, 2},
+				Token: auth.NegotiationToken{NegTokenInit: auth.NegTokenInitData{
+					MechTypes: tc.mechTypes,
+					MechToken: mechToken,
+				}},
+			}).Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			messageId := uint64(1)
+			r := roundTrip(newSessionSetupRequest(0, messageId, init))
+			resp, err := auth.NewTargPayload(SessionSetupResponse(r[64:]).Buffer())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kerberosPreferred {
+				// without a keytab the server selects NTLM and asks for
+				// the mechListMIC
+				if r.Status() != STATUS_MORE_PROCESSING_REQUIRED || resp.NegResult != 3 ||
+					!resp.SupportedMech.Equal(auth.NlmpMechType) || resp.ResponseToken != nil {
+					t.Fatalf("SESSION_SETUP status 0x%08x, NegResult %d, SupportedMech %v, want request-mic for NTLM",
+						r.Status(), resp.NegResult, resp.SupportedMech)
+				}
+				token, err := (&auth.TargPayload{
+					NegResult:     1,
+					SupportedMech: auth.NlmpMechType,
+					ResponseToken: negotiate,
+				}).Bytes()
+				if err != nil {
+					t.Fatal(err)
+				}
+				messageId++
+				r = roundTrip(newSessionSetupRequest(r.SessionId(), messageId, token))
+				if resp, err = auth.NewTargPayload(SessionSetupResponse(r[64:]).Buffer()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			challenge := ntlmssp.ChallengeMessage(resp.ResponseToken)
+
+			// the client returns the target info with the MIC flag
+			sessionKey := bytes.Repeat([]byte{0x55}, 16)
+			responseKeyNT := ntlmssp.NTOWFv2(ntlmssp.NTHash("Password"), "User", "Domain")
+			targetInfo := challenge.TargetInfo().SetFlags(ntlmssp.MSV_AV_FLAG_MIC_PRESENT)
+			ntResponse := ntlmssp.NTLMv2Response(responseKeyNT, challenge.ServerChallenge(),
+				bytes.Repeat([]byte{0xaa}, 8), 0, targetInfo)
+			sessionBaseKey, _ := ntlmssp.VerifyNTLMv2Response(responseKeyNT, challenge.ServerChallenge(), ntResponse)
+			encryptedKey, _ := ntlmssp.ExportedSessionKey(ntlmssp.NTLMSSP_NEGOTIATE_KEY_EXCH, sessionBaseKey, sessionKey)
+			authMsg := ntlmssp.NewAuthenticateMessage(ntlmssp.AuthenticateMessageFields{
+				NegotiateFlags:            challenge.NegotiateFlags(),
+				NtChallengeResponse:       ntResponse,
+				DomainName:                "Domain",
+				UserName:                  "User",
+				EncryptedRandomSessionKey: encryptedKey,
+			})
+			mic := ntlmssp.ComputeMIC(sessionKey, negotiate, challenge, authMsg)
+			if tc.badMIC {
+				mic[0] ^= 1
+			}
+			authMsg.SetMIC(mic)
+
+			security, err := ntlmssp.NewSessionSecurity(challenge.NegotiateFlags(), sessionKey, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mechTypes, _ := tc.mechTypes.Bytes()
+			var mechListMIC []byte
+			if tc.mechListMIC {
+				mechListMIC = security.GetMIC(mechTypes)
+				if tc.badMechListMIC {
+					mechListMIC[4] ^= 1
+				}
+			}
+			token, err := (&auth.TargPayload{
+				NegResult:     1,
+				SupportedMech: auth.NlmpMechType,
+				ResponseToken: authMsg,
+				MechListMIC:   mechListMIC,
+			}).Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			messageId++
+			r = roundTrip(newSessionSetupRequest(r.SessionId(), messageId, token))
+			if r.Status() != tc.status {
+				t.Fatalf("SESSION_SETUP status 0x%08x, want 0x%08x", r.Status(), tc.status)
+			}
+			if tc.status != STATUS_SUCCESS {
+				return
+			}
+			resp, err = auth.NewTargPayload(SessionSetupResponse(r[64:]).Buffer())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.NegResult != 0 {
+				t.Errorf("NegResult = %d, want accept-completed", resp.NegResult)
+			}
+			if !tc.mechListMIC {
+				if resp.MechListMIC != nil {
+					t.Errorf("unexpected mechListMIC %x", resp.MechListMIC)
+				}
+				return
+			}
+			if !security.VerifyMIC(mechTypes, resp.MechListMIC) {
+				t.Errorf("bad mechListMIC of the server %x", resp.MechListMIC)
+			}
+		})
+	}
+}
+
+// newKerberosInit returns the NegTokenInit of a client presenting a ticket
+// of user for the service, encrypted with serviceKey, and the subkey of
+// its authenticator.
+func newKerberosInit(t *testing.T, user string, service krb5.PrincipalName, serviceKey krb5.EncryptionKey) ([]byte, krb5.EncryptionKey) {
+	now := time.Now().UTC().Truncate(time.Second)
+	sessionKey := krb5.EncryptionKey{KeyType: krb5.ETYPE_AES256_CTS_HMAC_SHA1_96, KeyValue: bytes.Repeat([]byte{0x11}, 32)}
+	subkey := krb5.EncryptionKey{KeyType: krb5.ETYPE_AES256_CTS_HMAC_SHA1_96, KeyValue: bytes.Repeat([]byte{0x22}, 32)}
+	client := krb5.PrincipalName{NameType: krb5.KRB_NT_PRINCIPAL, NameString: []string{user}}
+
+	part, err := (&krb5.EncTicketPart{
+		Flags:    asn1.BitString{Bytes: []byte{0, 0, 0, 0}, BitLength: 32},
+		Key:      sessionKey,
+		CRealm:   "EXAMPLE.COM",
+		CName:    client,
+		AuthTime: now,
+		EndTime:  now.Add(time.Hour),
+	}).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticketCipher, err := serviceKey.Encrypt(krb5.KeyUsageTicket, part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := (&krb5.Authenticator{
+		AuthenticatorVNO: 5,
+		CRealm:           "EXAMPLE.COM",
+		CName:            client,
+		CTime:            now,
+		Subkey:           subkey,
+	}).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticatorCipher, err := sessionKey.Encrypt(krb5.KeyUsageAPReqAuthenticator, authenticator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apReq, err := (&krb5.APReq{
+		APOptions: asn1.BitString{Bytes: []byte{0x20, 0, 0, 0}, BitLength: 32}, // mutual-required
+		Ticket: krb5.Ticket{
+			TktVNO:  5,
+			Realm:   "EXAMPLE.COM",
+			SName:   service,
+			EncPart: krb5.EncryptedData{EType: serviceKey.KeyType, KVNO: 1, Cipher: ticketCipher},
+		},
+		Authenticator: krb5.EncryptedData{EType: sessionKey.KeyType, Cipher: authenticatorCipher},
+	}).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mechToken, err := krb5.WrapToken(krb5.TOK_ID_AP_REQ, apReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	init, err := (&auth.InitPayload{
+		OID: []int{1, 3, 6, 1, 5, 5, 2},
+		Token: auth.NegotiationToken{NegTokenInit: auth.NegTokenInitData{
+			MechTypes: auth.MechTypeList{auth.MSKerberosMechType, auth.KerberosMechType, auth.NlmpMechType},
+			MechToken: mechToken,
+		}},
+	}).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return init, subkey
+}
+
+func TestSessionSetupKerberos(t *testing.T) {
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	service := krb5.PrincipalName{NameType: krb5.KRB_NT_SRV_INST, NameString: []string{"cifs", "server.example.com"}}
+	serviceKey, err := krb5.StringToKey(krb5.ETYPE_AES256_CTS_HMAC_SHA1_96, "service password", "EXAMPLE.COMcifsserver.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{
+		Keytab: &krb5.Keytab{Entries: []krb5.KeytabEntry{
+			{Principal: service, Realm: "EXAMPLE.COM", KVNO: 1, Key: serviceKey},
+		}},
+		DisableNTLM: true,
+	}
+	c := srv.newConn(sv)
+	c.dialect = SMB2_DIALECT_302
+	go c.serve()
+
+	roundTrip := func(msg []byte) PacketCodec {
+		if err := writeFrame(cl, msg); err != nil {
+			t.Fatal(err)
+		}
+		r, err := readFrame(cl, directTCPMaxLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return PacketCodec(r)
+	}
+
+	init, subkey := newKerberosInit(t, "alice", service, serviceKey)
+	r := roundTrip(newSessionSetupRequest(0, 1, init))
+	if r.Status() != STATUS_SUCCESS {
+		t.Fatalf("SESSION_SETUP status 0x%08x", r.Status())
+	}
+	s := srv.sessionTable.lookup(r.SessionId())
+	if s == nil || s.state != sessionValid || s.userName != "alice" || s.domainName != "EXAMPLE.COM" {
+		t.Fatalf("session 0x%x is not established", r.SessionId())
+	}
+	if d := time.Until(s.expirationTime); d <= 0 || d > time.Hour {
+		t.Errorf("session expires in %v, want with the ticket in 1h", d)
+	}
+	resp, err := auth.NewTargPayload(SessionSetupResponse(r[64:]).Buffer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.NegResult != 0 || !resp.SupportedMech.Equal(auth.MSKerberosMechType) {
+		t.Errorf("NegResult %d, SupportedMech %v, want accept-completed of MS-KRB5", resp.NegResult, resp.SupportedMech)
+	}
+	if tokID, _, err := krb5.UnwrapToken(resp.ResponseToken); err != nil || tokID != krb5.TOK_ID_AP_REP {
+		t.Errorf("ResponseToken is not an AP-REP: 0x%04x, %v", tokID, err)
+	}
+	// the session key is the subkey of the authenticator
+	keys := DeriveSessionKeys(SMB2_DIALECT_302, 0, subkey.KeyValue, nil)
+	if r.Flags()&SMB2_FLAGS_SIGNED == 0 || !verifySignature(SMB2_SIGNING_ALGORITHM_AES_CMAC, keys.SigningKey, r) {
+		t.Errorf("final SESSION_SETUP response is not signed with the subkey")
+	}
+
+	// a ticket the keytab can not decrypt fails the logon
+	otherKey, _ := krb5.StringToKey(krb5.ETYPE_AES256_CTS_HMAC_SHA1_96, "other password", "EXAMPLE.COMcifsserver.example.com")
+	init, _ = newKerberosInit(t, "mallory", service, otherKey)
+	r = roundTrip(newSessionSetupRequest(0, 2, init))
+	if r.Status() != STATUS_LOGON_FAILURE {
+		t.Errorf("SESSION_SETUP status 0x%08x, want STATUS_LOGON_FAILURE", r.Status())
+	}
+	if srv.sessionTable.lookup(r.SessionId()) != nil {
+		t.Errorf("session 0x%x survived a failed logon", r.SessionId())
+	}
+	// NTLM is disabled
+	negotiate, _ := hex.DecodeString("4e544c4d5353500001000000358288e000000000000000000000000000000000")
+	token, err := (&auth.TargPayload{NegResult: 1, SupportedMech: auth.NlmpMechType, ResponseToken: negotiate}).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = roundTrip(newSessionSetupRequest(0, 3, token))
+	if r.Status() != STATUS_LOGON_FAILURE {
+		t.Errorf("NTLM SESSION_SETUP status 0x%08x, want STATUS_LOGON_FAILURE", r.Status())
+	}
+}
+
+func TestServerNegTokenInit(t *testing.T) {
+	keytab := &krb5.Keytab{}
+	cases := []struct {
+		name     string
+		srv      *Server
+		expected auth.MechTypeList
+	}{
+		{"ntlm", &Server{}, auth.MechTypeList{auth.NlmpMechType}},
+		{"kerberos and ntlm", &Server{Keytab: keytab}, auth.MechTypeList{auth.MSKerberosMechType, auth.KerberosMechType, auth.NlmpMechType}},
+		{"kerberos", &Server{Keytab: keytab, DisableNTLM: true}, auth.MechTypeList{auth.MSKerberosMechType, auth.KerberosMechType}},
+		{"none", &Server{DisableNTLM: true}, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := c.srv.negTokenInit()
+			if c.expected == nil {
+				if len(b) != 0 {
+					t.Errorf("negTokenInit() = %x, want empty", b)
+				}
+				return
+			}
+			init, err := auth.NewInitPayload(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !init.OID.Equal(auth.SpnegoOID) {
+				t.Errorf("OID = %v, want %v", init.OID, auth.SpnegoOID)
+			}
+			actual := init.Token.NegTokenInit.MechTypes
+			if len(actual) != len(c.expected) {
+				t.Fatalf("MechTypes = %v, want %v", actual, c.expected)
+			}
+			for i := range actual {
+				if !actual[i].Equal(c.expected[i]) {
+					t.Errorf("MechTypes = %v, want %v", actual, c.expected)
+				}
+			}
+			if string(init.Token.NegTokenInit.NegHints) != auth.NegHintsIgnore {
+				t.Errorf("NegHints = %q, want %q", init.Token.NegTokenInit.NegHints, auth.NegHintsIgnore)
+			}
+		})
+	}
+}
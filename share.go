@@ -0,0 +1,188 @@
+package simba
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Share is a resource the server exports, a file system for disk shares.
+// It must not be modified once added to the server.
+// MS-SMB2 3.3.1.6 Per Share
+type Share struct {
+	// Name is the name clients connect to, it is not case sensitive.
+	Name string
+
+	// FS is the file system served by a disk share. Path is a shorthand
+	// for LocalFS(Path) when FS is nil. The sharing checks are by name on
+	// FS, they miss opens of a file by names of another case when FS is
+	// not case sensitive.
+	FS   VFS
+	Path string
+
+	Description string
+
+	// Type is SMB2_SHARE_TYPE_DISK unless set.
+	Type ShareType
+
+	// Flags are announced in TREE_CONNECT responses. CachingMode is the
+	// offline caching mode among them, SMB2_SHAREFLAG_MANUAL_CACHING by
+	// default.
+	Flags       ShareFlags
+	CachingMode ShareFlags
+
+	// ReadOnly shares refuse any modification.
+	ReadOnly bool
+
+	// EncryptData makes clients encrypt the messages of the trees
+	// connected to the share, clients that can not encrypt are refused.
+	EncryptData bool
+
+	// AllowGuest lets anonymous and guest sessions connect.
+	AllowGuest bool
+
+	// ValidUsers restricts the share to the users and groups listed, by
+	// user name, DOMAIN\user or SID. Empty means every authenticated user.
+	ValidUsers []string
+
+	// filesMu guards files, the files opened on the share by name on FS,
+	// for the sharing checks.
+	filesMu sync.Mutex
+	files   map[string]*sharedFile
+}
+
+// ipcShare is the share of named pipes, available on every server.
+var ipcShare = &Share{
+	Name:        "IPC$",
+	Type:        SMB2_SHARE_TYPE_PIPE,
+	Description: "IPC Service",
+	AllowGuest:  true,
+}
+
+// fileSystem returns the file system of a disk share, nil if it has none.
+func (sh *Share) fileSystem() VFS {
+	if sh.FS == nil && sh.Path != "" {
+		return LocalFS(sh.Path)
+	}
+	return sh.FS
+}
+
+func (sh *Share) shareType() ShareType {
+	if sh.Type == 0 {
+		return SMB2_SHARE_TYPE_DISK
+	}
+	return sh.Type
+}
+
+// shareFlags returns the ShareFlags of TREE_CONNECT responses.
+func (sh *Share) shareFlags() ShareFlags {
+	flags := sh.Flags&^shareFlagCachingMask | sh.CachingMode&shareFlagCachingMask
+	if sh.EncryptData {
+		flags |= SMB2_SHAREFLAG_ENCRYPT_DATA
+	}
+	return flags
+}
+
+// readOnly reports whether the share can not be modified, because it is
+// configured so or because its file system can not be.
+func (sh *Share) readOnly() bool {
+	_, ok := sh.fileSystem().(*ReadOnlyFS)
+	return sh.ReadOnly || ok
+}
+
+// maximalAccess returns the rights users are granted on the share.
+func (sh *Share) maximalAccess() AccessMask {
+	if sh.readOnly() {
+		return FILE_GENERIC_READ | FILE_GENERIC_EXECUTE
+	}
+	return FILE_ALL_ACCESS
+}
+
+// allows reports whether the user of session s may connect to the share.
+func (sh *Share) allows(s *session) bool {
+	if s.isAnonymous || s.isGuest {
+		return sh.AllowGuest
+	}
+	if len(sh.ValidUsers) == 0 {
+		return true
+	}
+	names := []string{s.userName, s.domainName + `\` + s.userName}
+	if s.account != nil {
+		names = append(names, s.account.SID)
+		names = append(names, s.account.GroupSIDs...)
+	}
+	for _, valid := range sh.ValidUsers {
+		for _, name := range names {
+			if name != "" && strings.EqualFold(valid, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shareTable holds the shares of a server by name, it is safe for
+// concurrent use.
+// MS-SMB2 3.3.1.5 Global
+type shareTable struct {
+	mu     sync.Mutex
+	shares map[string]*Share
+}
+
+// AddShare exports sh, its name must not be used by another share.
+func (srv *Server) AddShare(sh *Share) error {
+	if sh.Name == "" || strings.ContainsAny(sh.Name, `\/`) {
+		return fmt.Errorf("invalid share name %q", sh.Name)
+	}
+	if sh.shareType() == SMB2_SHARE_TYPE_DISK && sh.fileSystem() == nil {
+		return fmt.Errorf("disk share %q has no file system", sh.Name)
+	}
+	t := &srv.shares
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.shares == nil {
+		t.shares = map[string]*Share{}
+	}
+	key := strings.ToUpper(sh.Name)
+	if t.shares[key] != nil {
+		return fmt.Errorf("share %q already exists", sh.Name)
+	}
+	t.shares[key] = sh
+	return nil
+}
+
+// RemoveShare stops exporting the share of name, trees already connected
+// to it are kept.
+func (srv *Server) RemoveShare(name string) {
+	t := &srv.shares
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.shares, strings.ToUpper(name))
+}
+
+// lookupShare returns the share of name, nil if there is none. IPC$ is
+// served unless another share is added under its name.
+func (srv *Server) lookupShare(name string) *Share {
+	t := &srv.shares
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if sh := t.shares[strings.ToUpper(name)]; sh != nil {
+		return sh
+	}
+	if strings.EqualFold(name, ipcShare.Name) {
+		return ipcShare
+	}
+	return nil
+}
+
+// shareName returns the share of the UNC path \\server\share.
+func shareName(path string) (string, bool) {
+	if !strings.HasPrefix(path, `\\`) {
+		return "", false
+	}
+	parts := strings.Split(path[2:], `\`)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
@@ -1,35 +1,155 @@
-package simba
-
-type SingingAlgorithm uint16
-
-const (
-	// MS-SMB2 - v20211006 page 51/481
-	SMB2_SIGNING_ALGORITHM_HMAC_SHA256 SingingAlgorithm = 0x0000
-	SMB2_SIGNING_ALGORITHM_AES_CMAC    SingingAlgorithm = 0x0001
-	SMB2_SIGNING_ALGORITHM_AES_GMAC    SingingAlgorithm = 0x0002
-)
-
-type SigningCapability []byte
-
-func (c SigningCapability) SigningAlgorithmCount() uint16 {
-	return uint16(le.Uint16(c[0:2]))
-}
-
-func (c SigningCapability) SetSigningAlgorithmCount(v uint16) {
-	le.PutUint16(c[0:2], v)
-}
-
-func (c SigningCapability) SigningAlgorithms() []SingingAlgorithm {
-	var res = make([]SingingAlgorithm, c.SigningAlgorithmCount())
-	for i := 0; i < int(c.SigningAlgorithmCount()); i++ {
-		res[i] = SingingAlgorithm(le.Uint16(c[4+i*2 : 6+i*2]))
-	}
-	return res
-}
-
-func (c SigningCapability) SetSigningAlgorithms(v []SingingAlgorithm) {
-	c.SetSigningAlgorithmCount(uint16(len(v)))
-	for i, t := range v {
-		le.PutUint16(c[4+i*2:6+i*2], uint16(t))
-	}
-}
+package simba
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+)
+
+type SingingAlgorithm uint16
+
+const (
+	// MS-SMB2 - v20211006 page 51/481
+	SMB2_SIGNING_ALGORITHM_HMAC_SHA256 SingingAlgorithm = 0x0000
+	SMB2_SIGNING_ALGORITHM_AES_CMAC    SingingAlgorithm = 0x0001
+	SMB2_SIGNING_ALGORITHM_AES_GMAC    SingingAlgorithm = 0x0002
+)
+
+func (a SingingAlgorithm) String() string {
+	switch a {
+	case SMB2_SIGNING_ALGORITHM_HMAC_SHA256:
+		return "SMB2_SIGNING_ALGORITHM_HMAC_SHA256"
+	case SMB2_SIGNING_ALGORITHM_AES_CMAC:
+		return "SMB2_SIGNING_ALGORITHM_AES_CMAC"
+	case SMB2_SIGNING_ALGORITHM_AES_GMAC:
+		return "SMB2_SIGNING_ALGORITHM_AES_GMAC"
+	}
+	return "Unknown"
+}
+
+// MS-SMB2 2.2.3.1.7 SMB2_SIGNING_CAPABILITIES
+type SigningCapability []byte
+
+func (c SigningCapability) SigningAlgorithmCount() uint16 {
+	return uint16(le.Uint16(c[0:2]))
+}
+
+func (c SigningCapability) SetSigningAlgorithmCount(v uint16) {
+	le.PutUint16(c[0:2], v)
+}
+
+func (c SigningCapability) SigningAlgorithms() []SingingAlgorithm {
+	var res []SingingAlgorithm
+	for i := 0; i < int(c.SigningAlgorithmCount()) && 4+i*2 <= len(c); i++ {
+		res = append(res, SingingAlgorithm(le.Uint16(c[2+i*2:4+i*2])))
+	}
+	return res
+}
+
+func (c SigningCapability) SetSigningAlgorithms(v []SingingAlgorithm) {
+	c.SetSigningAlgorithmCount(uint16(len(v)))
+	for i, t := range v {
+		le.PutUint16(c[2+i*2:4+i*2], uint16(t))
+	}
+}
+
+// NewSigningCapability returns the SMB2_SIGNING_CAPABILITIES data listing
+// algorithms.
+func NewSigningCapability(algorithms []SingingAlgorithm) SigningCapability {
+	c := SigningCapability(make([]byte, 2+2*len(algorithms)))
+	c.SetSigningAlgorithms(algorithms)
+	return c
+}
+
+// computeSignature returns the signature of msg, a whole SMB2 message whose
+// Signature field is zero.
+// MS-SMB2 3.1.4.1 Signing An Outgoing Message
+func computeSignature(alg SingingAlgorithm, key []byte, msg PacketCodec) ([]byte, error) {
+	switch alg {
+	case SMB2_SIGNING_ALGORITHM_HMAC_SHA256:
+		h := hmac.New(sha256.New, key)
+		h.Write(msg)
+		return h.Sum(nil)[:16], nil
+	case SMB2_SIGNING_ALGORITHM_AES_CMAC:
+		b, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return aesCMAC(b, msg), nil
+	case SMB2_SIGNING_ALGORITHM_AES_GMAC:
+		b, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		gcm, err := cipher.NewGCM(b)
+		if err != nil {
+			return nil, err
+		}
+		return gcm.Seal(nil, gmacNonce(msg), nil, msg), nil
+	}
+	return nil, fmt.Errorf("unknown signing algorithm %v", alg)
+}
+
+// gmacNonce returns the 12 bytes nonce of AES-GMAC: the MessageId followed
+// by a bit set for responses and a bit set for CANCEL requests.
+func gmacNonce(msg PacketCodec) []byte {
+	nonce := make([]byte, 12)
+	le.PutUint64(nonce[0:8], msg.MessageId())
+	var flags uint32
+	if msg.Flags()&SMB2_FLAGS_SERVER_TO_REDIR != 0 {
+		flags |= 0x00000001
+	}
+	if msg.Command() == SMB2_CANCEL {
+		flags |= 0x00000002
+	}
+	le.PutUint32(nonce[8:12], flags)
+	return nonce
+}
+
+// signMessage sets SMB2_FLAGS_SIGNED and the signature of msg.
+func signMessage(alg SingingAlgorithm, key []byte, msg PacketCodec) error {
+	msg.SetFlags(msg.Flags() | SMB2_FLAGS_SIGNED)
+	msg.SetSignature(make([]byte, 16))
+	signature, err := computeSignature(alg, key, msg)
+	if err != nil {
+		return err
+	}
+	msg.SetSignature(signature)
+	return nil
+}
+
+// verifySignature reports whether the signature of msg is valid, msg is
+// left unchanged.
+// MS-SMB2 3.3.5.2.4 Verifying the Signature
+func verifySignature(alg SingingAlgorithm, key []byte, msg PacketCodec) bool {
+	if len(msg) < 64 {
+		return false
+	}
+	signature := make([]byte, 16)
+	copy(signature, msg.Signature())
+
+	unsigned := PacketCodec(make([]byte, len(msg)))
+	copy(unsigned, msg)
+	unsigned.SetSignature(make([]byte, 16))
+	expected, err := computeSignature(alg, key, unsigned)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(signature, expected) == 1
+}
+
+// signingAlgorithm returns the algorithm signing messages of the
+// connection: HMAC-SHA256 for 2.x, AES-CMAC for 3.x unless a signing
+// context selected another one in 3.1.1.
+func (c *conn) signingAlgorithm() SingingAlgorithm {
+	switch c.dialect {
+	case SMB2_DIALECT_202, SMB2_DIALECT_21:
+		return SMB2_SIGNING_ALGORITHM_HMAC_SHA256
+	case SMB2_DIALECT_311:
+		return c.signingAlgorithmId
+	}
+	return SMB2_SIGNING_ALGORITHM_AES_CMAC
+}
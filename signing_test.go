@@ -0,0 +1,72 @@
+package simba
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSignMessage(t *testing.T) {
+	key, _ := hex.DecodeString("0B7E9C5CAC36C0F6EA9AB275298CEDCE")
+	// ECHO response, MessageId 5, SessionId 0x1122334455667788
+	unsigned := "fe534d4240000000000000000d00000001000000000000000500000000000000000000000000000088776655443322110000000000000000000000000000000004000000"
+
+	cases := map[string]struct {
+		alg      SingingAlgorithm
+		expected string
+	}{
+		"hmac-sha256": {alg: SMB2_SIGNING_ALGORITHM_HMAC_SHA256, expected: "98e298f52074104e7e3046e63ec635ed"},
+		"aes-cmac":    {alg: SMB2_SIGNING_ALGORITHM_AES_CMAC},
+		"aes-gmac":    {alg: SMB2_SIGNING_ALGORITHM_AES_GMAC},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			b, _ := hex.DecodeString(unsigned)
+			msg := PacketCodec(b)
+			if err := signMessage(c.alg, key, msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.Flags()&SMB2_FLAGS_SIGNED == 0 {
+				t.Errorf("SMB2_FLAGS_SIGNED is not set")
+			}
+			if c.expected != "" {
+				expected, _ := hex.DecodeString(c.expected)
+				if !bytes.Equal(msg.Signature(), expected) {
+					t.Errorf("expected signature %x, got %x", expected, msg.Signature())
+				}
+			}
+			signed := make([]byte, len(msg))
+			copy(signed, msg)
+			if !verifySignature(c.alg, key, msg) {
+				t.Errorf("signature does not verify")
+			}
+			if !bytes.Equal(signed, msg) {
+				t.Errorf("verifySignature modified the message")
+			}
+
+			msg[len(msg)-1] ^= 0x01
+			if verifySignature(c.alg, key, msg) {
+				t.Errorf("tampered message verifies")
+			}
+		})
+	}
+}
+
+func TestGMACNonce(t *testing.T) {
+	b, _ := hex.DecodeString("fe534d4240000000000000000d00000000000000000000000500000000000000000000000000000088776655443322110000000000000000000000000000000004000000")
+	msg := PacketCodec(b)
+
+	if got := hex.EncodeToString(gmacNonce(msg)); got != "050000000000000000000000" {
+		t.Errorf("request nonce: got %s", got)
+	}
+	msg.SetFlags(SMB2_FLAGS_SERVER_TO_REDIR)
+	if got := hex.EncodeToString(gmacNonce(msg)); got != "050000000000000001000000" {
+		t.Errorf("response nonce: got %s", got)
+	}
+	msg.SetFlags(0)
+	msg.SetCommand(SMB2_CANCEL)
+	if got := hex.EncodeToString(gmacNonce(msg)); got != "050000000000000002000000" {
+		t.Errorf("cancel nonce: got %s", got)
+	}
+}
@@ -0,0 +1,153 @@
+package simba
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+)
+
+// SMB1 is only understood far enough to upgrade a multi-protocol negotiate
+// to SMB2, see MS-SMB2 3.3.5.3.
+const (
+	// MS-CIFS - v20201001 2.2.2.1 SMB_COM
+	SMB_COM_NEGOTIATE uint8 = 0x72
+)
+
+// Dialect strings an SMB1 negotiate uses to offer SMB2.
+// MS-SMB2 - v20211006 page 254/481
+const (
+	SMB1_DIALECT_SMB2_002 = "SMB 2.002"
+	SMB1_DIALECT_SMB2_XXX = "SMB 2.???"
+)
+
+// MS-CIFS 2.2.3.1 The SMB Header
+type SMB1PacketCodec []byte
+
+func (p SMB1PacketCodec) IsInvalid() bool {
+	// 32 bytes header, WordCount and ByteCount
+	if len(p) < 35 {
+		return true
+	}
+
+	if p[0] != 0xff || p[1] != 'S' || p[2] != 'M' || p[3] != 'B' {
+		return true
+	}
+	return false
+}
+
+func (p SMB1PacketCodec) Command() uint8 {
+	return p[4]
+}
+
+func (p SMB1PacketCodec) Status() uint32 {
+	return binary.LittleEndian.Uint32(p[5:9])
+}
+
+func (p SMB1PacketCodec) Flags() uint8 {
+	return p[9]
+}
+
+func (p SMB1PacketCodec) Flags2() uint16 {
+	return binary.LittleEndian.Uint16(p[10:12])
+}
+
+func (p SMB1PacketCodec) TID() uint16 {
+	return binary.LittleEndian.Uint16(p[24:26])
+}
+
+func (p SMB1PacketCodec) PIDLow() uint16 {
+	return binary.LittleEndian.Uint16(p[26:28])
+}
+
+func (p SMB1PacketCodec) UID() uint16 {
+	return binary.LittleEndian.Uint16(p[28:30])
+}
+
+func (p SMB1PacketCodec) MID() uint16 {
+	return binary.LittleEndian.Uint16(p[30:32])
+}
+
+func (p SMB1PacketCodec) WordCount() uint8 {
+	return p[32]
+}
+
+func (p SMB1PacketCodec) ByteCount() uint16 {
+	offset := 33 + int(p.WordCount())*2
+	if offset+2 > len(p) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(p[offset : offset+2])
+}
+
+// Bytes returns the SMB_Data.Bytes of the message.
+func (p SMB1PacketCodec) Bytes() []byte {
+	offset := 33 + int(p.WordCount())*2 + 2
+	count := int(p.ByteCount())
+	if offset+count > len(p) {
+		log.Printf("warning: smb1 bytes are out of bounds (offset=%d, count=%d, len=%d)", offset, count, len(p))
+		return nil
+	}
+	return p[offset : offset+count]
+}
+
+// MS-CIFS 2.2.4.52.1 SMB_COM_NEGOTIATE Request
+// The data is a list of dialect strings, each one prefixed with the buffer
+// format 0x02 and terminated by a null.
+func (p SMB1PacketCodec) Dialects() []string {
+	var res []string
+	b := p.Bytes()
+	for len(b) > 0 {
+		if b[0] != 0x02 {
+			log.Printf("warning: smb1 dialect buffer format 0x%02x", b[0])
+			return res
+		}
+		end := bytes.IndexByte(b[1:], 0x00)
+		if end < 0 {
+			log.Printf("warning: smb1 dialect is not null terminated")
+			return res
+		}
+		res = append(res, string(b[1:1+end]))
+		b = b[1+end+1:]
+	}
+	return res
+}
+
+// selectSMB1Dialect picks the SMB2 dialect to answer a multi-protocol
+// negotiate with: the wildcard revision when the client offers "SMB 2.???"
+// and a dialect above 2.0.2 is enabled, SMB 2.0.2 when the client offers
+// "SMB 2.002" and it is enabled.
+// MS-SMB2 3.3.5.3.1 SMB 2.1 or SMB 3.x Support
+// MS-SMB2 3.3.5.3.2 SMB 2.0.2 Support
+func (srv *Server) selectSMB1Dialect(dialects []string) (Dialect, bool) {
+	offer202 := false
+	offerWildcard := false
+	for _, d := range dialects {
+		switch d {
+		case SMB1_DIALECT_SMB2_002:
+			offer202 = true
+		case SMB1_DIALECT_SMB2_XXX:
+			offerWildcard = true
+		}
+	}
+
+	enable202 := false
+	enableNewer := false
+	for _, d := range srv.dialects() {
+		if !d.isSupported() {
+			continue
+		}
+		if d == SMB2_DIALECT_202 {
+			enable202 = true
+		} else {
+			enableNewer = true
+		}
+	}
+
+	if offerWildcard && enableNewer {
+		return SMB2_DIALECT_2xx, true
+	}
+	if offer202 && enable202 {
+		return SMB2_DIALECT_202, true
+	}
+	return 0, false
+}
@@ -0,0 +1,125 @@
+package simba
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestSMB1NegotiateRequest(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    []byte
+		expected map[string]interface{}
+	}{
+		{
+			name: "windows 7",
+			input: func() []byte {
+				r, _ := hex.DecodeString("ff534d4272000000001853c8000000000000000000000000fffffeff00000000007800025043204e4554574f524b2050524f4752414d20312e3000024c414e4d414e312e30000257696e646f777320666f7220576f726b67726f75707320332e316100024c4d312e325830303200024c414e4d414e322e3100024e54204c4d20302e31320002534d4220322e3030320002534d4220322e3f3f3f00")
+				return r
+			}(),
+			expected: map[string]interface{}{
+				"Command":   SMB_COM_NEGOTIATE,
+				"MID":       0,
+				"WordCount": 0,
+				"ByteCount": 0x78,
+				"Dialects": []string{
+					"PC NETWORK PROGRAM 1.0",
+					"LANMAN1.0",
+					"Windows for Workgroups 3.1a",
+					"LM1.2X002",
+					"LANMAN2.1",
+					"NT LM 0.12",
+					"SMB 2.002",
+					"SMB 2.???",
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := SMB1PacketCodec(c.input)
+			if p.IsInvalid() {
+				t.Errorf("SMB1PacketCodec.IsInvalid() = true, want false")
+			}
+			if p.Command() != c.expected["Command"].(uint8) {
+				t.Errorf("SMB1PacketCodec.Command() = %v, want %v", p.Command(), c.expected["Command"])
+			}
+			if p.MID() != uint16(c.expected["MID"].(int)) {
+				t.Errorf("SMB1PacketCodec.MID() = %v, want %v", p.MID(), c.expected["MID"])
+			}
+			if p.WordCount() != uint8(c.expected["WordCount"].(int)) {
+				t.Errorf("SMB1PacketCodec.WordCount() = %v, want %v", p.WordCount(), c.expected["WordCount"])
+			}
+			if p.ByteCount() != uint16(c.expected["ByteCount"].(int)) {
+				t.Errorf("SMB1PacketCodec.ByteCount() = %v, want %v", p.ByteCount(), c.expected["ByteCount"])
+			}
+			dialects := p.Dialects()
+			if len(dialects) != len(c.expected["Dialects"].([]string)) {
+				t.Fatalf("SMB1PacketCodec.Dialects() = %q, want %q", dialects, c.expected["Dialects"])
+			}
+			for i, d := range dialects {
+				if d != c.expected["Dialects"].([]string)[i] {
+					t.Errorf("SMB1PacketCodec.Dialects()[%d] = %q, want %q", i, d, c.expected["Dialects"].([]string)[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSelectSMB1Dialect(t *testing.T) {
+	cases := []struct {
+		name     string
+		server   []Dialect
+		client   []string
+		expected Dialect
+		ok       bool
+	}{
+		{
+			name:     "wildcard",
+			client:   []string{"NT LM 0.12", SMB1_DIALECT_SMB2_002, SMB1_DIALECT_SMB2_XXX},
+			expected: SMB2_DIALECT_2xx,
+			ok:       true,
+		},
+		{
+			name:     "only 2.002 offered",
+			client:   []string{"NT LM 0.12", SMB1_DIALECT_SMB2_002},
+			expected: SMB2_DIALECT_202,
+			ok:       true,
+		},
+		{
+			name:     "only 2.0.2 enabled",
+			server:   []Dialect{SMB2_DIALECT_202},
+			client:   []string{SMB1_DIALECT_SMB2_002, SMB1_DIALECT_SMB2_XXX},
+			expected: SMB2_DIALECT_202,
+			ok:       true,
+		},
+		{
+			name:   "only wildcard offered and 2.0.2 enabled",
+			server: []Dialect{SMB2_DIALECT_202},
+			client: []string{"NT LM 0.12", SMB1_DIALECT_SMB2_XXX},
+		},
+		{
+			name:   "2.002 offered but disabled",
+			server: []Dialect{SMB2_DIALECT_311},
+			client: []string{SMB1_DIALECT_SMB2_002},
+		},
+		{
+			name:   "smb1 only",
+			client: []string{"NT LM 0.12"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv := &Server{Dialects: c.server}
+			actual, ok := srv.selectSMB1Dialect(c.client)
+			if ok != c.ok {
+				t.Fatalf("selectSMB1Dialect() ok = %v, want %v", ok, c.ok)
+			}
+			if actual != c.expected {
+				t.Errorf("selectSMB1Dialect() = %v, want %v", actual, c.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package simba
+
+import (
+	"encoding/binary"
+)
+
+// MS-SMB2 2.2.41 SMB2 TRANSFORM_HEADER
+type TransformHeader []byte
+
+const transformHeaderSize = 52
+
+// SMB2_TRANSFORM_HEADER Flags, EncryptionAlgorithm in SMB 3.0 and 3.0.2
+const SMB2_TRANSFORM_FLAG_ENCRYPTED uint16 = 0x0001
+
+func (p TransformHeader) IsInvalid() bool {
+	if len(p) < transformHeaderSize {
+		return true
+	}
+	if p[0] != 0xfd || p[1] != 'S' || p[2] != 'M' || p[3] != 'B' {
+		return true
+	}
+	return false
+}
+
+func (p TransformHeader) ProtocolId() []byte {
+	return p[:4]
+}
+
+func (p TransformHeader) SetProtocolId() {
+	copy(p[:4], []byte{0xfd, 0x53, 0x4d, 0x42})
+}
+
+func (p TransformHeader) Signature() []byte {
+	return p[4:20]
+}
+
+func (p TransformHeader) SetSignature(v []byte) {
+	copy(p[4:20], v)
+}
+
+func (p TransformHeader) Nonce() []byte {
+	return p[20:36]
+}
+
+func (p TransformHeader) SetNonce(v []byte) {
+	copy(p[20:36], v)
+}
+
+func (p TransformHeader) OriginalMessageSize() uint32 {
+	return binary.LittleEndian.Uint32(p[36:40])
+}
+
+func (p TransformHeader) SetOriginalMessageSize(v uint32) {
+	binary.LittleEndian.PutUint32(p[36:40], v)
+}
+
+// In SMB 3.0 and 3.0.2 this field is EncryptionAlgorithm
+func (p TransformHeader) Flags() uint16 {
+	return binary.LittleEndian.Uint16(p[42:44])
+}
+
+func (p TransformHeader) SetFlags(v uint16) {
+	binary.LittleEndian.PutUint16(p[42:44], v)
+}
+
+func (p TransformHeader) SessionId() uint64 {
+	return binary.LittleEndian.Uint64(p[44:52])
+}
+
+func (p TransformHeader) SetSessionId(v uint64) {
+	binary.LittleEndian.PutUint64(p[44:52], v)
+}
+
+// AssociatedData returns the part of the header authenticated along with
+// the message, from the Nonce to the end of the header.
+func (p TransformHeader) AssociatedData() []byte {
+	return p[20:transformHeaderSize]
+}
+
+// Message returns the encrypted message following the header.
+func (p TransformHeader) Message() []byte {
+	return p[transformHeaderSize:]
+}
@@ -0,0 +1,57 @@
+package simba
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestTransformHeader(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    []byte
+		expected map[string]interface{}
+	}{
+		{
+			name: "encrypted",
+			input: func() []byte {
+				r, _ := hex.DecodeString("fd534d42" + "00112233445566778899aabbccddeeff" + "01000000000000000000000000000000" + "78000000" + "0000" + "0100" + "0900000000000000" + "00")
+				return r
+			}(),
+			expected: map[string]interface{}{
+				"ProtocolId":          "fd534d42",
+				"Signature":           "00112233445566778899aabbccddeeff",
+				"Nonce":               "01000000000000000000000000000000",
+				"OriginalMessageSize": uint32(120),
+				"Flags":               SMB2_TRANSFORM_FLAG_ENCRYPTED,
+				"SessionId":           uint64(9),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := TransformHeader(c.input)
+			if p.IsInvalid() {
+				t.Fatalf("IsInvalid() = true")
+			}
+			if v := hex.EncodeToString(p.ProtocolId()); v != c.expected["ProtocolId"] {
+				t.Errorf("ProtocolId() = %v, want %v", v, c.expected["ProtocolId"])
+			}
+			if v := hex.EncodeToString(p.Signature()); v != c.expected["Signature"] {
+				t.Errorf("Signature() = %v, want %v", v, c.expected["Signature"])
+			}
+			if v := hex.EncodeToString(p.Nonce()); v != c.expected["Nonce"] {
+				t.Errorf("Nonce() = %v, want %v", v, c.expected["Nonce"])
+			}
+			if v := p.OriginalMessageSize(); v != c.expected["OriginalMessageSize"] {
+				t.Errorf("OriginalMessageSize() = %v, want %v", v, c.expected["OriginalMessageSize"])
+			}
+			if v := p.Flags(); v != c.expected["Flags"] {
+				t.Errorf("Flags() = %v, want %v", v, c.expected["Flags"])
+			}
+			if v := p.SessionId(); v != c.expected["SessionId"] {
+				t.Errorf("SessionId() = %v, want %v", v, c.expected["SessionId"])
+			}
+		})
+	}
+}
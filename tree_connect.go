@@ -0,0 +1,230 @@
+package simba
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+type TreeConnectFlags uint16
+
+const (
+	// MS-SMB2 2.2.9 SMB2 TREE_CONNECT Request, SMB 3.1.1 only
+	SMB2_TREE_CONNECT_FLAG_CLUSTER_RECONNECT TreeConnectFlags = 0x0001
+	SMB2_TREE_CONNECT_FLAG_REDIRECT_TO_OWNER TreeConnectFlags = 0x0002
+	SMB2_TREE_CONNECT_FLAG_EXTENSION_PRESENT TreeConnectFlags = 0x0004
+)
+
+// MS-SMB2 2.2.9 SMB2 TREE_CONNECT Request
+type TreeConnectRequest []byte
+
+func (p TreeConnectRequest) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 9
+	if len(p) < 8 {
+		return true
+	}
+
+	return false
+}
+
+func (p TreeConnectRequest) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p TreeConnectRequest) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 9)
+}
+
+func (p TreeConnectRequest) Flags() TreeConnectFlags {
+	return TreeConnectFlags(binary.LittleEndian.Uint16(p[2:4]))
+}
+
+func (p TreeConnectRequest) SetFlags(v TreeConnectFlags) {
+	binary.LittleEndian.PutUint16(p[2:4], uint16(v))
+}
+
+// PathOffset is from the beginning of the SMB2 header.
+func (p TreeConnectRequest) PathOffset() uint16 {
+	return binary.LittleEndian.Uint16(p[4:6])
+}
+
+func (p TreeConnectRequest) SetPathOffset(v uint16) {
+	binary.LittleEndian.PutUint16(p[4:6], v)
+}
+
+func (p TreeConnectRequest) PathLength() uint16 {
+	return binary.LittleEndian.Uint16(p[6:8])
+}
+
+func (p TreeConnectRequest) SetPathLength(v uint16) {
+	binary.LittleEndian.PutUint16(p[6:8], v)
+}
+
+// Path returns the UNC path of the share, \\server\share, empty when it
+// lies outside of the request.
+func (p TreeConnectRequest) Path() string {
+	if p.PathOffset() < 64 {
+		return ""
+	}
+	offset := int(p.PathOffset()) - 64
+	length := int(p.PathLength())
+	if offset+length > len(p) {
+		return ""
+	}
+	return decodeUTF16(p[offset : offset+length])
+}
+
+// SetPath sets the path at PathOffset, the request must be large enough.
+func (p TreeConnectRequest) SetPath(v string) {
+	u := utf16.Encode([]rune(v))
+	offset := int(p.PathOffset()) - 64
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(p[offset+2*i:], c)
+	}
+	p.SetPathLength(uint16(2 * len(u)))
+}
+
+type ShareType uint8
+
+const (
+	// MS-SMB2 2.2.10 SMB2 TREE_CONNECT Response
+	SMB2_SHARE_TYPE_DISK  ShareType = 0x01
+	SMB2_SHARE_TYPE_PIPE  ShareType = 0x02
+	SMB2_SHARE_TYPE_PRINT ShareType = 0x03
+)
+
+type ShareFlags uint32
+
+const (
+	// MS-SMB2 2.2.10 SMB2 TREE_CONNECT Response
+	SMB2_SHAREFLAG_MANUAL_CACHING              ShareFlags = 0x00000000
+	SMB2_SHAREFLAG_AUTO_CACHING                ShareFlags = 0x00000010
+	SMB2_SHAREFLAG_VDO_CACHING                 ShareFlags = 0x00000020
+	SMB2_SHAREFLAG_NO_CACHING                  ShareFlags = 0x00000030
+	SMB2_SHAREFLAG_DFS                         ShareFlags = 0x00000001
+	SMB2_SHAREFLAG_DFS_ROOT                    ShareFlags = 0x00000002
+	SMB2_SHAREFLAG_RESTRICT_EXCLUSIVE_OPENS    ShareFlags = 0x00000100
+	SMB2_SHAREFLAG_FORCE_SHARED_DELETE         ShareFlags = 0x00000200
+	SMB2_SHAREFLAG_ALLOW_NAMESPACE_CACHING     ShareFlags = 0x00000400
+	SMB2_SHAREFLAG_ACCESS_BASED_DIRECTORY_ENUM ShareFlags = 0x00000800
+	SMB2_SHAREFLAG_FORCE_LEVELII_OPLOCK        ShareFlags = 0x00001000
+	SMB2_SHAREFLAG_ENABLE_HASH_V1              ShareFlags = 0x00002000
+	SMB2_SHAREFLAG_ENABLE_HASH_V2              ShareFlags = 0x00004000
+	SMB2_SHAREFLAG_ENCRYPT_DATA                ShareFlags = 0x00008000
+	SMB2_SHAREFLAG_IDENTITY_REMOTING           ShareFlags = 0x00040000
+	SMB2_SHAREFLAG_COMPRESS_DATA               ShareFlags = 0x00100000
+	SMB2_SHAREFLAG_ISOLATED_TRANSPORT          ShareFlags = 0x00200000
+
+	// shareFlagCachingMask selects the caching mode of the flags.
+	shareFlagCachingMask ShareFlags = 0x00000030
+)
+
+type ShareCapabilities uint32
+
+const (
+	// MS-SMB2 2.2.10 SMB2 TREE_CONNECT Response
+	SMB2_SHARE_CAP_DFS                     ShareCapabilities = 0x00000008
+	SMB2_SHARE_CAP_CONTINUOUS_AVAILABILITY ShareCapabilities = 0x00000010
+	SMB2_SHARE_CAP_SCALEOUT                ShareCapabilities = 0x00000020
+	SMB2_SHARE_CAP_CLUSTER                 ShareCapabilities = 0x00000040
+	SMB2_SHARE_CAP_ASYMMETRIC              ShareCapabilities = 0x00000080
+	SMB2_SHARE_CAP_REDIRECT_TO_OWNER       ShareCapabilities = 0x00000100
+)
+
+// MS-SMB2 2.2.10 SMB2 TREE_CONNECT Response
+type TreeConnectResponse []byte
+
+func (p TreeConnectResponse) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 16
+	if len(p) < 16 {
+		return true
+	}
+
+	return false
+}
+
+func (p TreeConnectResponse) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p TreeConnectResponse) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 16)
+}
+
+func (p TreeConnectResponse) ShareType() ShareType {
+	return ShareType(p[2])
+}
+
+func (p TreeConnectResponse) SetShareType(v ShareType) {
+	p[2] = byte(v)
+}
+
+func (p TreeConnectResponse) ShareFlags() ShareFlags {
+	return ShareFlags(binary.LittleEndian.Uint32(p[4:8]))
+}
+
+func (p TreeConnectResponse) SetShareFlags(v ShareFlags) {
+	binary.LittleEndian.PutUint32(p[4:8], uint32(v))
+}
+
+func (p TreeConnectResponse) Capabilities() ShareCapabilities {
+	return ShareCapabilities(binary.LittleEndian.Uint32(p[8:12]))
+}
+
+func (p TreeConnectResponse) SetCapabilities(v ShareCapabilities) {
+	binary.LittleEndian.PutUint32(p[8:12], uint32(v))
+}
+
+func (p TreeConnectResponse) MaximalAccess() AccessMask {
+	return AccessMask(binary.LittleEndian.Uint32(p[12:16]))
+}
+
+func (p TreeConnectResponse) SetMaximalAccess(v AccessMask) {
+	binary.LittleEndian.PutUint32(p[12:16], uint32(v))
+}
+
+// MS-SMB2 2.2.11 SMB2 TREE_DISCONNECT Request
+type TreeDisconnectRequest []byte
+
+func (p TreeDisconnectRequest) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 4
+	if len(p) < 4 {
+		return true
+	}
+
+	return false
+}
+
+func (p TreeDisconnectRequest) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p TreeDisconnectRequest) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 4)
+}
+
+// MS-SMB2 2.2.12 SMB2 TREE_DISCONNECT Response
+type TreeDisconnectResponse []byte
+
+func (p TreeDisconnectResponse) IsInvalid() bool {
+	// MS-SMB2, MUST be set to 4
+	if len(p) < 4 {
+		return true
+	}
+
+	return false
+}
+
+func (p TreeDisconnectResponse) StructureSize() uint16 {
+	return binary.LittleEndian.Uint16(p[0:2])
+}
+
+func (p TreeDisconnectResponse) SetStructureSize() {
+	binary.LittleEndian.PutUint16(p[0:2], 4)
+}
+
+// NewTreeDisconnectResponse returns a TREE_DISCONNECT response.
+func NewTreeDisconnectResponse() TreeDisconnectResponse {
+	r := TreeDisconnectResponse(make([]byte, 4))
+	r.SetStructureSize()
+	return r
+}
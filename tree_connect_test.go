@@ -0,0 +1,240 @@
+package simba
+
+import (
+	"net"
+	"testing"
+	"unicode/utf16"
+)
+
+// newTreeConnectRequest returns a TREE_CONNECT request of session id for
+// the UNC path.
+func newTreeConnectRequest(id, messageId uint64, path string) []byte {
+	size := 8 + 2*len(utf16.Encode([]rune(path)))
+	p := newRequest(SMB2_TREE_CONNECT, messageId, id, 0, 9, size)
+	msg := TreeConnectRequest(p[64:])
+	msg.SetPathOffset(64 + 8)
+	msg.SetPath(path)
+	return p
+}
+
+func TestTreeConnectRequest(t *testing.T) {
+	p := newTreeConnectRequest(1, 2, `\\server\Share`)
+	msg := TreeConnectRequest(p[64:])
+	if msg.IsInvalid() || msg.StructureSize() != 9 {
+		t.Fatalf("StructureSize %d", msg.StructureSize())
+	}
+	if msg.PathOffset() != 72 || msg.PathLength() != 28 {
+		t.Errorf("path at %d, %d bytes", msg.PathOffset(), msg.PathLength())
+	}
+	if got := msg.Path(); got != `\\server\Share` {
+		t.Errorf("Path %q", got)
+	}
+	msg.SetPathLength(100)
+	if got := msg.Path(); got != "" {
+		t.Errorf("Path out of the request %q", got)
+	}
+}
+
+func TestShareName(t *testing.T) {
+	cases := []struct {
+		path string
+		name string
+		ok   bool
+	}{
+		{`\\server\share`, "share", true},
+		{`\\192.168.1.2\IPC$`, "IPC$", true},
+		{`\\server`, "", false},
+		{`\\server\share\dir`, "", false},
+		{`\\\share`, "", false},
+		{`server\share`, "", false},
+		{"", "", false},
+	}
+	for _, tc := range cases {
+		name, ok := shareName(tc.path)
+		if name != tc.name || ok != tc.ok {
+			t.Errorf("shareName(%q) = %q, %v, want %q, %v", tc.path, name, ok, tc.name, tc.ok)
+		}
+	}
+}
+
+func TestAddShare(t *testing.T) {
+	srv := &Server{}
+	if err := srv.AddShare(&Share{Name: "public", FS: NewMemFS()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.AddShare(&Share{Name: "PUBLIC", FS: NewMemFS()}); err == nil {
+		t.Error("duplicate share added")
+	}
+	for _, name := range []string{"", `a\b`} {
+		if err := srv.AddShare(&Share{Name: name, FS: NewMemFS()}); err == nil {
+			t.Errorf("share %q added", name)
+		}
+	}
+	if err := srv.AddShare(&Share{Name: "nofs"}); err == nil {
+		t.Error("disk share without file system added")
+	}
+	if err := srv.AddShare(&Share{Name: "local", Path: t.TempDir()}); err != nil {
+		t.Error(err)
+	}
+	if srv.lookupShare("Public") == nil || srv.lookupShare("ipc$") != ipcShare {
+		t.Error("lookupShare")
+	}
+	srv.RemoveShare("public")
+	if srv.lookupShare("public") != nil {
+		t.Error("share not removed")
+	}
+}
+
+func TestTreeConnect(t *testing.T) {
+	cl, sv := net.Pipe()
+	defer cl.Close()
+	srv := &Server{}
+	shares := []*Share{
+		{Name: "public", FS: NewMemFS(), CachingMode: SMB2_SHAREFLAG_NO_CACHING},
+		{Name: "docs", FS: NewMemFS(), ReadOnly: true, AllowGuest: true},
+		{Name: "staff", FS: NewMemFS(), ValidUsers: []string{`CORP\bob`, "S-1-5-21-1-2-3-513"}},
+	}
+	for _, sh := range shares {
+		if err := srv.AddShare(sh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := srv.newConn(sv)
+	c.dialect = SMB2_DIALECT_302
+	user, err := c.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.state = sessionValid
+	user.userName, user.domainName = "alice", "CORP"
+	user.account = &Account{UserName: "alice", GroupSIDs: []string{"S-1-5-21-1-2-3-513"}}
+	guest, err := c.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	guest.state = sessionValid
+	guest.isGuest = true
+	go c.serve()
+
+	roundTrip := func(msg []byte) PacketCodec {
+		if err := writeFrame(cl, msg); err != nil {
+			t.Fatal(err)
+		}
+		r, err := readFrame(cl, directTCPMaxLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return PacketCodec(r)
+	}
+
+	cases := []struct {
+		name      string
+		session   *session
+		path      string
+		status    uint32
+		shareType ShareType
+		flags     ShareFlags
+		access    AccessMask
+	}{
+		{"disk", user, `\\server\PUBLIC`, STATUS_SUCCESS, SMB2_SHARE_TYPE_DISK, SMB2_SHAREFLAG_NO_CACHING, FILE_ALL_ACCESS},
+		{"read only", user, `\\server\docs`, STATUS_SUCCESS, SMB2_SHARE_TYPE_DISK, 0, 0x1200a9},
+		{"ipc", user, `\\server\IPC$`, STATUS_SUCCESS, SMB2_SHARE_TYPE_PIPE, 0, FILE_ALL_ACCESS},
+		{"valid users group", user, `\\server\staff`, STATUS_SUCCESS, SMB2_SHARE_TYPE_DISK, 0, FILE_ALL_ACCESS},
+		{"guest allowed", guest, `\\server\docs`, STATUS_SUCCESS, SMB2_SHARE_TYPE_DISK, 0, 0x1200a9},
+		{"guest refused", guest, `\\server\public`, STATUS_ACCESS_DENIED, 0, 0, 0},
+		{"guest not valid user", guest, `\\server\staff`, STATUS_ACCESS_DENIED, 0, 0, 0},
+		{"unknown share", user, `\\server\nothing`, STATUS_BAD_NETWORK_NAME, 0, 0, 0},
+		{"bad path", user, `server\public`, STATUS_INVALID_PARAMETER, 0, 0, 0},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := roundTrip(newTreeConnectRequest(tc.session.sessionId, uint64(i+1), tc.path))
+			if r.Status() != tc.status {
+				t.Fatalf("status 0x%08x, want 0x%08x", r.Status(), tc.status)
+			}
+			if tc.status != STATUS_SUCCESS {
+				return
+			}
+			resp := TreeConnectResponse(r[64:])
+			if resp.IsInvalid() || resp.StructureSize() != 16 {
+				t.Fatalf("StructureSize %d", resp.StructureSize())
+			}
+			if resp.ShareType() != tc.shareType || resp.ShareFlags() != tc.flags || resp.MaximalAccess() != tc.access {
+				t.Errorf("type %d, flags 0x%x, access 0x%x, want %d, 0x%x, 0x%x",
+					resp.ShareType(), resp.ShareFlags(), resp.MaximalAccess(), tc.shareType, tc.flags, tc.access)
+			}
+			if tc.session.treeConnects[r.TreeId()] == nil {
+				t.Errorf("TreeId 0x%x not connected", r.TreeId())
+			}
+		})
+	}
+	if len(user.treeConnects) != 4 || len(guest.treeConnects) != 1 {
+		t.Fatalf("%d and %d trees connected", len(user.treeConnects), len(guest.treeConnects))
+	}
+
+	// the tree is gone once disconnected
+	r := roundTrip(newRequest(SMB2_TREE_DISCONNECT, 20, user.sessionId, 1, 4, 4))
+	if r.Status() != STATUS_SUCCESS || TreeDisconnectResponse(r[64:]).StructureSize() != 4 {
+		t.Fatalf("TREE_DISCONNECT status 0x%08x", r.Status())
+	}
+	for _, command := range []Command{SMB2_TREE_DISCONNECT, SMB2_CREATE} {
+		r = roundTrip(newRequest(command, 21, user.sessionId, 1, commands[command].structureSizes[0], 64))
+		if r.Status() != STATUS_NETWORK_NAME_DELETED {
+			t.Errorf("%v of a disconnected tree: status 0x%08x", command, r.Status())
+		}
+	}
+}
+
+func TestTreeConnectEncryptData(t *testing.T) {
+	srv := &Server{}
+	if err := srv.AddShare(&Share{Name: "secret", FS: NewMemFS(), EncryptData: true}); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name    string
+		dialect Dialect
+		key     []byte
+		status  uint32
+	}{
+		{"encrypting", SMB2_DIALECT_302, make([]byte, 16), STATUS_SUCCESS},
+		{"no session key", SMB2_DIALECT_302, nil, STATUS_ACCESS_DENIED},
+		{"smb 2.1", SMB2_DIALECT_21, make([]byte, 16), STATUS_ACCESS_DENIED},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cl, sv := net.Pipe()
+			defer cl.Close()
+			c := srv.newConn(sv)
+			c.dialect = tc.dialect
+			c.clientCapabilities = SMB2_GLOBAL_CAP_ENCRYPTION
+			s, err := c.newSession()
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.state = sessionValid
+			s.keys.EncryptionKey = tc.key
+			go c.serve()
+
+			if err := writeFrame(cl, newTreeConnectRequest(s.sessionId, 1, `\\server\secret`)); err != nil {
+				t.Fatal(err)
+			}
+			r, err := readFrame(cl, directTCPMaxLength)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := PacketCodec(r)
+			if p.Status() != tc.status {
+				t.Fatalf("status 0x%08x, want 0x%08x", p.Status(), tc.status)
+			}
+			if tc.status != STATUS_SUCCESS {
+				return
+			}
+			if TreeConnectResponse(p[64:]).ShareFlags()&SMB2_SHAREFLAG_ENCRYPT_DATA == 0 {
+				t.Error("SMB2_SHAREFLAG_ENCRYPT_DATA not set")
+			}
+			if !s.treeEncryptData(p.TreeId()) {
+				t.Error("tree does not encrypt its data")
+			}
+		})
+	}
+}
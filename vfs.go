@@ -0,0 +1,136 @@
+package simba
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// VFS is the file system backing a disk share. Names are slash separated
+// and relative to the root of the share, as checked by fs.ValidPath: "."
+// is the root and ".." is never used. Errors should wrap fs.ErrNotExist,
+// fs.ErrExist, fs.ErrPermission and the errors below so they are answered
+// with the right NTSTATUS.
+type VFS interface {
+	// Open opens the file or directory name with the os.O_* flags flag,
+	// perm is the mode of a file it creates.
+	Open(name string, flag int, perm fs.FileMode) (File, error)
+
+	Stat(name string) (fs.FileInfo, error)
+	Mkdir(name string, perm fs.FileMode) error
+
+	// Remove removes a file, or an empty directory.
+	Remove(name string) error
+
+	// Rename moves oldname to newname, replacing a file there.
+	Rename(oldname, newname string) error
+
+	Chtimes(name string, atime, mtime time.Time) error
+
+	// SetAttributes sets the FILE_ATTRIBUTE_* of name, the ones the file
+	// system can not keep are ignored.
+	SetAttributes(name string, attrs FileAttributes) error
+}
+
+// File is a file or a directory opened on a VFS. It is used by one request
+// at a time.
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+
+	Stat() (fs.FileInfo, error)
+
+	// ReadDir reads the entries of a directory as os.File.ReadDir does.
+	ReadDir(n int) ([]fs.DirEntry, error)
+
+	Truncate(size int64) error
+	Sync() error
+}
+
+var (
+	ErrNotDirectory = errors.New("not a directory")
+	ErrIsDirectory  = errors.New("is a directory")
+	ErrNotEmpty     = errors.New("directory not empty")
+
+	// ErrWriteProtected is returned by file systems that can not be
+	// modified.
+	ErrWriteProtected = errors.New("file system is read-only")
+)
+
+type FileAttributes uint32
+
+const (
+	// MS-FSCC 2.6 File Attributes
+	FILE_ATTRIBUTE_READONLY              FileAttributes = 0x00000001
+	FILE_ATTRIBUTE_HIDDEN                FileAttributes = 0x00000002
+	FILE_ATTRIBUTE_SYSTEM                FileAttributes = 0x00000004
+	FILE_ATTRIBUTE_DIRECTORY             FileAttributes = 0x00000010
+	FILE_ATTRIBUTE_ARCHIVE               FileAttributes = 0x00000020
+	FILE_ATTRIBUTE_NORMAL                FileAttributes = 0x00000080
+	FILE_ATTRIBUTE_TEMPORARY             FileAttributes = 0x00000100
+	FILE_ATTRIBUTE_SPARSE_FILE           FileAttributes = 0x00000200
+	FILE_ATTRIBUTE_REPARSE_POINT         FileAttributes = 0x00000400
+	FILE_ATTRIBUTE_COMPRESSED            FileAttributes = 0x00000800
+	FILE_ATTRIBUTE_OFFLINE               FileAttributes = 0x00001000
+	FILE_ATTRIBUTE_NOT_CONTENT_INDEXED   FileAttributes = 0x00002000
+	FILE_ATTRIBUTE_ENCRYPTED             FileAttributes = 0x00004000
+	FILE_ATTRIBUTE_INTEGRITY_STREAM      FileAttributes = 0x00008000
+	FILE_ATTRIBUTE_NO_SCRUB_DATA         FileAttributes = 0x00020000
+	FILE_ATTRIBUTE_RECALL_ON_OPEN        FileAttributes = 0x00040000
+	FILE_ATTRIBUTE_PINNED                FileAttributes = 0x00080000
+	FILE_ATTRIBUTE_UNPINNED              FileAttributes = 0x00100000
+	FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS FileAttributes = 0x00400000
+)
+
+// fileAttributes returns the FILE_ATTRIBUTE_* of the file described by fi:
+// the FileAttributes returned by fi.Sys(), otherwise derived from its mode.
+func fileAttributes(fi fs.FileInfo) FileAttributes {
+	var attrs FileAttributes
+	if a, ok := fi.Sys().(FileAttributes); ok {
+		attrs = a &^ (FILE_ATTRIBUTE_DIRECTORY | FILE_ATTRIBUTE_NORMAL)
+	} else if !fi.IsDir() && fi.Mode().Perm()&0200 == 0 {
+		attrs = FILE_ATTRIBUTE_READONLY
+	}
+	if fi.IsDir() {
+		attrs |= FILE_ATTRIBUTE_DIRECTORY
+	}
+	if attrs == 0 {
+		// FILE_ATTRIBUTE_NORMAL is only valid alone
+		return FILE_ATTRIBUTE_NORMAL
+	}
+	return attrs
+}
+
+// vfsStatus returns the NTSTATUS to answer a VFS error with.
+func vfsStatus(err error) uint32 {
+	switch {
+	case err == nil:
+		return STATUS_SUCCESS
+	case errors.Is(err, ErrNotDirectory), errors.Is(err, syscall.ENOTDIR):
+		return STATUS_NOT_A_DIRECTORY
+	case errors.Is(err, ErrIsDirectory), errors.Is(err, syscall.EISDIR):
+		return STATUS_FILE_IS_A_DIRECTORY
+	case errors.Is(err, ErrNotEmpty), errors.Is(err, syscall.ENOTEMPTY):
+		return STATUS_DIRECTORY_NOT_EMPTY
+	case errors.Is(err, ErrWriteProtected), errors.Is(err, syscall.EROFS):
+		return STATUS_MEDIA_WRITE_PROTECTED
+	case errors.Is(err, fs.ErrNotExist):
+		return STATUS_OBJECT_NAME_NOT_FOUND
+	case errors.Is(err, fs.ErrExist):
+		return STATUS_OBJECT_NAME_COLLISION
+	case errors.Is(err, fs.ErrPermission):
+		return STATUS_ACCESS_DENIED
+	case errors.Is(err, fs.ErrInvalid):
+		return STATUS_OBJECT_NAME_INVALID
+	case errors.Is(err, syscall.ENOSPC):
+		return STATUS_DISK_FULL
+	}
+	var e statusError
+	if errors.As(err, &e) {
+		return uint32(e)
+	}
+	return STATUS_UNSUCCESSFUL
+}
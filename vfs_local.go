@@ -0,0 +1,139 @@
+package simba
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalFS is a VFS serving the local directory it names. Names never
+// resolve outside of the directory, symbolic links leading out of it are
+// refused.
+type LocalFS string
+
+// resolve returns the local path of name, after symbolic links.
+func (d LocalFS) resolve(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	root, err := filepath.EvalSymlinks(string(d))
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	full := filepath.Join(root, filepath.FromSlash(name))
+	real, err := filepath.EvalSymlinks(full)
+	if errors.Is(err, fs.ErrNotExist) {
+		// the last element is being created, or is a dangling link
+		dir, err := filepath.EvalSymlinks(filepath.Dir(full))
+		if err != nil {
+			return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		real = filepath.Join(dir, filepath.Base(full))
+		if fi, err := os.Lstat(real); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+			return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+		}
+	} else if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	rel, err := filepath.Rel(root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return real, nil
+}
+
+// resolveChild is resolve for operations on a directory entry itself, a
+// symbolic link is not followed. The root is not such an entry.
+func (d LocalFS) resolveChild(op, name string) (string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	dir, err := d.resolve(op, path.Dir(name))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, path.Base(name)), nil
+}
+
+func (d LocalFS) Open(name string, flag int, perm fs.FileMode) (File, error) {
+	p, err := d.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (d LocalFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := d.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+func (d LocalFS) Mkdir(name string, perm fs.FileMode) error {
+	p, err := d.resolveChild("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(p, perm)
+}
+
+func (d LocalFS) Remove(name string) error {
+	p, err := d.resolveChild("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+func (d LocalFS) Rename(oldname, newname string) error {
+	oldp, err := d.resolveChild("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newp, err := d.resolveChild("rename", newname)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldp, newp)
+}
+
+func (d LocalFS) Chtimes(name string, atime, mtime time.Time) error {
+	p, err := d.resolve("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(p, atime, mtime)
+}
+
+// SetAttributes keeps FILE_ATTRIBUTE_READONLY of files as the lack of
+// write permission, other attributes are ignored.
+func (d LocalFS) SetAttributes(name string, attrs FileAttributes) error {
+	p, err := d.resolve("setattributes", name)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(p)
+	if err != nil || fi.IsDir() {
+		return err
+	}
+	mode := fi.Mode().Perm()
+	if attrs&FILE_ATTRIBUTE_READONLY != 0 {
+		mode &^= 0222
+	} else {
+		mode |= 0200
+	}
+	if mode == fi.Mode().Perm() {
+		return nil
+	}
+	return os.Chmod(p, mode)
+}
@@ -0,0 +1,392 @@
+package simba
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFS is a VFS keeping its files in memory, for tests and for shares of
+// scratch data. It keeps the FILE_ATTRIBUTE_* of files, but only their
+// modification time.
+type MemFS struct {
+	mu   sync.Mutex
+	root *memNode
+}
+
+// memNode is a file or a directory of a MemFS, guarded by MemFS.mu.
+type memNode struct {
+	name     string
+	mode     fs.FileMode
+	modTime  time.Time
+	attrs    FileAttributes
+	data     []byte
+	children map[string]*memNode
+}
+
+// NewMemFS returns an empty MemFS.
+func NewMemFS() *MemFS {
+	return &MemFS{root: &memNode{
+		name:     ".",
+		mode:     fs.ModeDir | 0777,
+		modTime:  time.Now(),
+		children: map[string]*memNode{},
+	}}
+}
+
+func (n *memNode) info() fs.FileInfo {
+	return &memFileInfo{
+		name:    n.name,
+		size:    int64(len(n.data)),
+		mode:    n.mode,
+		modTime: n.modTime,
+		attrs:   n.attrs,
+	}
+}
+
+// lookup returns the node of name.
+func (m *MemFS) lookup(op, name string) (*memNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	n := m.root
+	if name == "." {
+		return n, nil
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if n.children == nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: ErrNotDirectory}
+		}
+		if n = n.children[elem]; n == nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	return n, nil
+}
+
+// lookupParent returns the directory holding name, the root is held by
+// none.
+func (m *MemFS) lookupParent(op, name string) (*memNode, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	dir, err := m.lookup(op, path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	if dir.children == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: ErrNotDirectory}
+	}
+	return dir, nil
+}
+
+func (m *MemFS) Open(name string, flag int, perm fs.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.lookup("open", name)
+	switch {
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case err != nil && flag&os.O_CREATE != 0 && os.IsNotExist(err):
+		dir, err := m.lookupParent("open", name)
+		if err != nil {
+			return nil, err
+		}
+		n = &memNode{name: path.Base(name), mode: perm.Perm(), modTime: time.Now()}
+		dir.children[n.name] = n
+		dir.modTime = n.modTime
+	case err != nil:
+		return nil, err
+	}
+
+	f := &memFile{fs: m, node: n, flag: flag}
+	if f.writable() {
+		if n.children != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: ErrIsDirectory}
+		}
+		if n.attrs&FILE_ATTRIBUTE_READONLY != 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+		}
+		if flag&os.O_TRUNC != 0 {
+			n.data = nil
+			n.modTime = time.Now()
+		}
+	}
+	return f, nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(), nil
+}
+
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, err := m.lookupParent("mkdir", name)
+	if err != nil {
+		return err
+	}
+	base := path.Base(name)
+	if dir.children[base] != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	now := time.Now()
+	dir.children[base] = &memNode{
+		name:     base,
+		mode:     fs.ModeDir | perm.Perm(),
+		modTime:  now,
+		children: map[string]*memNode{},
+	}
+	dir.modTime = now
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, err := m.lookupParent("remove", name)
+	if err != nil {
+		return err
+	}
+	base := path.Base(name)
+	n := dir.children[base]
+	if n == nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if len(n.children) != 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: ErrNotEmpty}
+	}
+	delete(dir.children, base)
+	dir.modTime = time.Now()
+	return nil
+}
+
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	olddir, err := m.lookupParent("rename", oldname)
+	if err != nil {
+		return err
+	}
+	n := olddir.children[path.Base(oldname)]
+	if n == nil {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	newdir, err := m.lookupParent("rename", newname)
+	if err != nil {
+		return err
+	}
+	if oldname == newname {
+		return nil
+	}
+	if strings.HasPrefix(newname, oldname+"/") {
+		// a directory can not be moved into itself
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+	}
+	// only a file replaces a file
+	if old := newdir.children[path.Base(newname)]; old != nil && (old.children != nil || n.children != nil) {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+	}
+	delete(olddir.children, n.name)
+	n.name = path.Base(newname)
+	newdir.children[n.name] = n
+	now := time.Now()
+	olddir.modTime, newdir.modTime = now, now
+	return nil
+}
+
+func (m *MemFS) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.lookup("chtimes", name)
+	if err != nil {
+		return err
+	}
+	n.modTime = mtime
+	return nil
+}
+
+func (m *MemFS) SetAttributes(name string, attrs FileAttributes) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.lookup("setattributes", name)
+	if err != nil {
+		return err
+	}
+	n.attrs = attrs &^ (FILE_ATTRIBUTE_DIRECTORY | FILE_ATTRIBUTE_NORMAL)
+	return nil
+}
+
+// memFile is a File of a MemFS.
+type memFile struct {
+	fs     *MemFS
+	node   *memNode
+	flag   int
+	closed bool
+
+	// dirOffset is the count of entries returned by ReadDir.
+	dirOffset int
+}
+
+func (f *memFile) readable() bool {
+	return f.flag&(os.O_WRONLY|os.O_RDWR) != os.O_WRONLY
+}
+
+func (f *memFile) writable() bool {
+	return f.flag&(os.O_WRONLY|os.O_RDWR) != 0
+}
+
+// check returns the error of operation op on f.
+func (f *memFile) check(op string, allowed bool) error {
+	switch {
+	case f.closed:
+		return &fs.PathError{Op: op, Path: f.node.name, Err: fs.ErrClosed}
+	case !allowed:
+		return &fs.PathError{Op: op, Path: f.node.name, Err: fs.ErrPermission}
+	case f.node.children != nil && (op == "read" || op == "write" || op == "truncate"):
+		return &fs.PathError{Op: op, Path: f.node.name, Err: ErrIsDirectory}
+	}
+	return nil
+}
+
+func (f *memFile) ReadAt(b []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if err := f.check("read", f.readable()); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.node.name, Err: fs.ErrInvalid}
+	}
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.node.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) WriteAt(b []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if err := f.check("write", f.writable()); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "write", Path: f.node.name, Err: fs.ErrInvalid}
+	}
+	if end := off + int64(len(b)); end > int64(len(f.node.data)) {
+		f.node.data = append(f.node.data, make([]byte, end-int64(len(f.node.data)))...)
+	}
+	copy(f.node.data[off:], b)
+	f.node.modTime = time.Now()
+	return len(b), nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.node.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if err := f.check("stat", true); err != nil {
+		return nil, err
+	}
+	return f.node.info(), nil
+}
+
+// ReadDir returns the entries of the directory sorted by name.
+func (f *memFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if err := f.check("readdir", true); err != nil {
+		return nil, err
+	}
+	if f.node.children == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: f.node.name, Err: ErrNotDirectory}
+	}
+	names := make([]string, 0, len(f.node.children))
+	for name := range f.node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if f.dirOffset > len(names) {
+		f.dirOffset = len(names)
+	}
+	names = names[f.dirOffset:]
+	if n > 0 {
+		if len(names) == 0 {
+			return nil, io.EOF
+		}
+		if len(names) > n {
+			names = names[:n]
+		}
+	}
+	f.dirOffset += len(names)
+	entries := make([]fs.DirEntry, len(names))
+	for i, name := range names {
+		entries[i] = fs.FileInfoToDirEntry(f.node.children[name].info())
+	}
+	return entries, nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if err := f.check("truncate", f.writable()); err != nil {
+		return err
+	}
+	if size < 0 {
+		return &fs.PathError{Op: "truncate", Path: f.node.name, Err: fs.ErrInvalid}
+	}
+	if size <= int64(len(f.node.data)) {
+		f.node.data = f.node.data[:size]
+	} else {
+		f.node.data = append(f.node.data, make([]byte, size-int64(len(f.node.data)))...)
+	}
+	f.node.modTime = time.Now()
+	return nil
+}
+
+func (f *memFile) Sync() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	return f.check("sync", true)
+}
+
+// memFileInfo describes a file of a MemFS, Sys returns its FileAttributes.
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	attrs   FileAttributes
+}
+
+func (fi *memFileInfo) Name() string       { return fi.name }
+func (fi *memFileInfo) Size() int64        { return fi.size }
+func (fi *memFileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *memFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *memFileInfo) Sys() interface{}   { return fi.attrs }
@@ -0,0 +1,174 @@
+package simba
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// ReadOnlyFS is a VFS serving an fs.FS, such as an embed.FS or a
+// zip.Reader, as a read-only share. Opening a file for writing is denied,
+// the operations that would modify the file system fail with
+// ErrWriteProtected.
+type ReadOnlyFS struct {
+	fsys fs.FS
+}
+
+// NewReadOnlyFS returns a ReadOnlyFS serving fsys.
+func NewReadOnlyFS(fsys fs.FS) *ReadOnlyFS {
+	return &ReadOnlyFS{fsys: fsys}
+}
+
+func (r *ReadOnlyFS) Open(name string, flag int, perm fs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND) != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	f, err := r.fsys.Open(name)
+	switch {
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case err == nil && flag&os.O_TRUNC != 0:
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrWriteProtected}
+	case errors.Is(err, fs.ErrNotExist) && flag&os.O_CREATE != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrWriteProtected}
+	case err != nil:
+		return nil, err
+	}
+	return &readOnlyFile{fsys: r.fsys, name: name, f: f}, nil
+}
+
+func (r *ReadOnlyFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(r.fsys, name)
+}
+
+func (r *ReadOnlyFS) Mkdir(name string, perm fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: ErrWriteProtected}
+}
+
+func (r *ReadOnlyFS) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: ErrWriteProtected}
+}
+
+func (r *ReadOnlyFS) Rename(oldname, newname string) error {
+	return &fs.PathError{Op: "rename", Path: oldname, Err: ErrWriteProtected}
+}
+
+func (r *ReadOnlyFS) Chtimes(name string, atime, mtime time.Time) error {
+	return &fs.PathError{Op: "chtimes", Path: name, Err: ErrWriteProtected}
+}
+
+func (r *ReadOnlyFS) SetAttributes(name string, attrs FileAttributes) error {
+	return &fs.PathError{Op: "setattributes", Path: name, Err: ErrWriteProtected}
+}
+
+// readOnlyFile is a File of a ReadOnlyFS.
+type readOnlyFile struct {
+	fsys fs.FS
+	name string
+	f    fs.File
+
+	// offset is the position of f for files that are not io.ReaderAt nor
+	// io.Seeker, which are read sequentially.
+	offset int64
+
+	// entries are the entries of a directory, dirOffset the count of them
+	// returned by ReadDir.
+	entries   []fs.DirEntry
+	dirOffset int
+}
+
+// ReadAt reads with the ReadAt of the file when it has one. Otherwise the
+// file is read from off, reopened when off is behind its position.
+func (f *readOnlyFile) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if ra, ok := f.f.(io.ReaderAt); ok {
+		return ra.ReadAt(b, off)
+	}
+	if s, ok := f.f.(io.Seeker); ok {
+		if _, err := s.Seek(off, io.SeekStart); err != nil {
+			return 0, err
+		}
+		n, err := io.ReadFull(f.f, b)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return n, err
+	}
+
+	if off < f.offset {
+		nf, err := f.fsys.Open(f.name)
+		if err != nil {
+			return 0, err
+		}
+		f.f.Close()
+		f.f, f.offset = nf, 0
+	}
+	skipped, err := io.CopyN(io.Discard, f.f, off-f.offset)
+	f.offset += skipped
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(f.f, b)
+	f.offset += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *readOnlyFile) WriteAt(b []byte, off int64) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrPermission}
+}
+
+func (f *readOnlyFile) Close() error {
+	return f.f.Close()
+}
+
+func (f *readOnlyFile) Stat() (fs.FileInfo, error) {
+	return f.f.Stat()
+}
+
+// ReadDir lists the directory with fs.ReadDir, so with the ReadDir of
+// an fs.ReadDirFS, in the order of names.
+func (f *readOnlyFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.entries == nil {
+		fi, err := f.f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: ErrNotDirectory}
+		}
+		entries, err := fs.ReadDir(f.fsys, f.name)
+		if err != nil {
+			return nil, err
+		}
+		f.entries = append([]fs.DirEntry{}, entries...)
+	}
+
+	entries := f.entries[f.dirOffset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if len(entries) > n {
+			entries = entries[:n]
+		}
+	}
+	f.dirOffset += len(entries)
+	return entries, nil
+}
+
+func (f *readOnlyFile) Truncate(size int64) error {
+	return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrPermission}
+}
+
+func (f *readOnlyFile) Sync() error {
+	return nil
+}
@@ -0,0 +1,142 @@
+package simba
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+// streamFS hides the ReadAt and Seek of the regular files of an fs.FS.
+type streamFS struct {
+	fs.FS
+}
+
+type streamFile struct {
+	fs.File
+}
+
+func (s streamFS) Open(name string) (fs.File, error) {
+	f, err := s.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := f.Stat(); err == nil && fi.IsDir() {
+		return f, nil
+	}
+	return streamFile{f}, nil
+}
+
+func TestReadOnlyFS(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"readme.txt":     {Data: []byte("hello world"), Mode: 0644},
+		"docs/a.txt":     {Data: []byte("a")},
+		"docs/b.txt":     {Data: []byte("b")},
+		"docs/c/d.txt":   {Data: []byte("d")},
+		"docs/e.txt":     {Data: []byte("e")},
+		"docs/f/g/h.txt": {Data: []byte("h")},
+	}
+	for _, tc := range []struct {
+		name string
+		fsys fs.FS
+	}{
+		{"reader at", mapFS},
+		{"stream", streamFS{mapFS}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := NewReadOnlyFS(tc.fsys)
+			f, err := v.Open("readme.txt", os.O_RDONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			for _, r := range []struct {
+				off  int64
+				size int
+				want string
+				err  error
+			}{
+				{6, 5, "world", nil},
+				{0, 5, "hello", nil},
+				{8, 10, "rld", io.EOF},
+				{11, 1, "", io.EOF},
+			} {
+				b := make([]byte, r.size)
+				n, err := f.ReadAt(b, r.off)
+				if string(b[:n]) != r.want || err != r.err {
+					t.Errorf("ReadAt(%d, %d) = %q, %v, want %q, %v", r.off, r.size, b[:n], err, r.want, r.err)
+				}
+			}
+			if _, err := f.WriteAt([]byte("x"), 0); !errors.Is(err, fs.ErrPermission) {
+				t.Errorf("WriteAt: %v", err)
+			}
+
+			d, err := v.Open("docs", os.O_RDONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+			var names []string
+			for {
+				entries, err := d.ReadDir(2)
+				for _, e := range entries {
+					names = append(names, e.Name())
+				}
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if fmt.Sprint(names) != "[a.txt b.txt c e.txt f]" {
+				t.Errorf("ReadDir %v", names)
+			}
+			if _, err := f.ReadDir(-1); vfsStatus(err) != STATUS_NOT_A_DIRECTORY {
+				t.Errorf("ReadDir of a file: %v", err)
+			}
+		})
+	}
+}
+
+func TestReadOnlyFSWrite(t *testing.T) {
+	v := NewReadOnlyFS(fstest.MapFS{"a.txt": {Data: []byte("a")}})
+	if fi, err := v.Stat("a.txt"); err != nil || fi.Size() != 1 {
+		t.Errorf("Stat %v, %v", fi, err)
+	}
+	cases := []struct {
+		name   string
+		op     func() error
+		status uint32
+	}{
+		{"open for writing", func() error { _, err := v.Open("a.txt", os.O_RDWR, 0); return err }, STATUS_ACCESS_DENIED},
+		{"create", func() error { _, err := v.Open("b.txt", os.O_RDONLY|os.O_CREATE, 0644); return err }, STATUS_MEDIA_WRITE_PROTECTED},
+		{"create existing", func() error { _, err := v.Open("a.txt", os.O_RDONLY|os.O_CREATE|os.O_EXCL, 0644); return err }, STATUS_OBJECT_NAME_COLLISION},
+		{"truncate", func() error { _, err := v.Open("a.txt", os.O_RDONLY|os.O_TRUNC, 0); return err }, STATUS_MEDIA_WRITE_PROTECTED},
+		{"missing", func() error { _, err := v.Open("b.txt", os.O_RDONLY, 0); return err }, STATUS_OBJECT_NAME_NOT_FOUND},
+		{"mkdir", func() error { return v.Mkdir("dir", 0755) }, STATUS_MEDIA_WRITE_PROTECTED},
+		{"remove", func() error { return v.Remove("a.txt") }, STATUS_MEDIA_WRITE_PROTECTED},
+		{"rename", func() error { return v.Rename("a.txt", "b.txt") }, STATUS_MEDIA_WRITE_PROTECTED},
+		{"set attributes", func() error { return v.SetAttributes("a.txt", FILE_ATTRIBUTE_HIDDEN) }, STATUS_MEDIA_WRITE_PROTECTED},
+	}
+	for _, tc := range cases {
+		if status := vfsStatus(tc.op()); status != tc.status {
+			t.Errorf("%s: status 0x%08x, want 0x%08x", tc.name, status, tc.status)
+		}
+	}
+
+	// the file is still served for reading
+	f, err := v.Open("a.txt", os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	sh := &Share{Name: "artifacts", FS: v}
+	if sh.maximalAccess() != FILE_GENERIC_READ|FILE_GENERIC_EXECUTE {
+		t.Errorf("MaximalAccess 0x%x", sh.maximalAccess())
+	}
+}
@@ -0,0 +1,235 @@
+package simba
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testVFS runs the operations shares rely on against v, an empty file
+// system.
+func testVFS(t *testing.T, v VFS) {
+	if err := v.Mkdir("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Mkdir("dir", 0755); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Mkdir existing: %v", err)
+	}
+	f, err := v.Open("dir/a.txt", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("hello world"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("W"), 6); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 20)
+	n, err := f.ReadAt(b, 0)
+	if err != io.EOF || string(b[:n]) != "hello World" {
+		t.Errorf("ReadAt %q, %v", b[:n], err)
+	}
+	if err := f.Truncate(5); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := f.Stat(); err != nil || fi.Size() != 5 || fi.IsDir() {
+		t.Errorf("Stat %v, %v", fi, err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Error(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.Open("dir/a.txt", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Open O_EXCL existing: %v", err)
+	}
+	if _, err := v.Open("dir/none", os.O_RDONLY, 0); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open missing: %v", err)
+	}
+	if _, err := v.Open("none/a.txt", os.O_RDWR|os.O_CREATE, 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open in missing directory: %v", err)
+	}
+	if _, err := v.Open("../a.txt", os.O_RDONLY, 0); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Open outside: %v", err)
+	}
+	if f, err := v.Open("dir", os.O_RDWR, 0); vfsStatus(err) != STATUS_FILE_IS_A_DIRECTORY {
+		t.Errorf("Open directory for writing: %v", err)
+		if err == nil {
+			f.Close()
+		}
+	}
+	if f, err := v.Open("dir/a.txt/b", os.O_RDONLY, 0); vfsStatus(err) != STATUS_NOT_A_DIRECTORY {
+		t.Errorf("Open under a file: %v", err)
+		if err == nil {
+			f.Close()
+		}
+	}
+
+	for _, name := range []string{"dir/c.txt", "dir/b.txt"} {
+		f, err := v.Open(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	d, err := v.Open("dir", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for {
+		entries, err := d.ReadDir(2)
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	d.Close()
+	sort.Strings(names)
+	if fmt.Sprint(names) != "[a.txt b.txt c.txt]" {
+		t.Errorf("ReadDir %v", names)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := v.Chtimes("dir/b.txt", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := v.Stat("dir/b.txt"); err != nil || !fi.ModTime().Equal(mtime) {
+		t.Errorf("Stat after Chtimes %v, %v", fi, err)
+	}
+	if err := v.SetAttributes("dir/b.txt", FILE_ATTRIBUTE_READONLY); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := v.Stat("dir/b.txt"); err != nil || fileAttributes(fi)&FILE_ATTRIBUTE_READONLY == 0 {
+		t.Errorf("Stat after SetAttributes %v, %v", fi, err)
+	}
+	if err := v.SetAttributes("dir/b.txt", FILE_ATTRIBUTE_NORMAL); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := v.Stat("dir/b.txt"); err != nil || fileAttributes(fi) != FILE_ATTRIBUTE_NORMAL {
+		t.Errorf("Stat after clearing attributes %v, %v", fi, err)
+	}
+
+	if err := v.Rename("dir/b.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Stat("dir/b.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat renamed: %v", err)
+	}
+	if fi, err := v.Stat("b.txt"); err != nil || fi.Name() != "b.txt" {
+		t.Errorf("Stat rename target %v, %v", fi, err)
+	}
+	if err := v.Remove("dir"); vfsStatus(err) != STATUS_DIRECTORY_NOT_EMPTY {
+		t.Errorf("Remove non-empty directory: %v", err)
+	}
+	for _, name := range []string{"dir/a.txt", "dir/c.txt", "dir", "b.txt"} {
+		if err := v.Remove(name); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := v.Remove("b.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Remove missing: %v", err)
+	}
+	if fi, err := v.Stat("."); err != nil || !fi.IsDir() {
+		t.Errorf("Stat root %v, %v", fi, err)
+	}
+	if err := v.Remove("."); err == nil {
+		t.Error("root removed")
+	}
+}
+
+func TestMemFS(t *testing.T) {
+	testVFS(t, NewMemFS())
+}
+
+func TestLocalFS(t *testing.T) {
+	testVFS(t, LocalFS(t.TempDir()))
+}
+
+func TestLocalFSSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "file"), []byte("file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"inside":   "file",
+		"outside":  "../secret",
+		"parent":   "..",
+		"dangling": "../created",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skip(err)
+		}
+	}
+
+	v := LocalFS(root)
+	if _, err := v.Stat("inside"); err != nil {
+		t.Errorf("link inside the root: %v", err)
+	}
+	for _, name := range []string{"outside", "parent/secret", "dangling"} {
+		f, err := v.Open(name, os.O_RDWR|os.O_CREATE, 0644)
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("Open %s: %v", name, err)
+		}
+		if err == nil {
+			f.Close()
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "created")); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("file created outside of the root")
+	}
+	// links themselves can be removed
+	if err := v.Remove("outside"); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "secret")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVFSStatus(t *testing.T) {
+	cases := []struct {
+		err    error
+		status uint32
+	}{
+		{nil, STATUS_SUCCESS},
+		{&fs.PathError{Op: "open", Path: "a", Err: fs.ErrNotExist}, STATUS_OBJECT_NAME_NOT_FOUND},
+		{fs.ErrExist, STATUS_OBJECT_NAME_COLLISION},
+		{fs.ErrPermission, STATUS_ACCESS_DENIED},
+		{fs.ErrInvalid, STATUS_OBJECT_NAME_INVALID},
+		{&fs.PathError{Op: "remove", Path: "a", Err: syscall.ENOTEMPTY}, STATUS_DIRECTORY_NOT_EMPTY},
+		{ErrNotDirectory, STATUS_NOT_A_DIRECTORY},
+		{ErrIsDirectory, STATUS_FILE_IS_A_DIRECTORY},
+		{fmt.Errorf("write: %w", syscall.ENOSPC), STATUS_DISK_FULL},
+		{fmt.Errorf("backend: %w", statusError(STATUS_NOT_SUPPORTED)), STATUS_NOT_SUPPORTED},
+		{errors.New("backend"), STATUS_UNSUCCESSFUL},
+	}
+	for _, tc := range cases {
+		if got := vfsStatus(tc.err); got != tc.status {
+			t.Errorf("vfsStatus(%v) = 0x%08x, want 0x%08x", tc.err, got, tc.status)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// Package xca implements the compression formats of MS-XCA used by SMB 3.1.1
+// compression: plain LZ77, LZ77+Huffman and LZNT1.
+//
+// Decompressors are given the uncompressed size, which SMB carries next to
+// every compressed payload, and never produce more than that.
+package xca
+
+import "errors"
+
+// ErrCorrupt is returned when compressed data can not be decoded.
+var ErrCorrupt = errors.New("xca: corrupt input")
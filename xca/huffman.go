@@ -0,0 +1,172 @@
+package xca
+
+import (
+	"container/heap"
+	"sort"
+)
+
+const (
+	huffmanSymbols   = 512
+	huffmanMaxLength = 15
+)
+
+// huffmanLengths returns the code length of every symbol for the given
+// frequencies, no longer than huffmanMaxLength. Symbols with no frequency
+// get no code.
+func huffmanLengths(freq []int) []uint8 {
+	lengths := make([]uint8, len(freq))
+	f := make([]int, len(freq))
+	copy(f, freq)
+	for {
+		if buildLengths(f, lengths) <= huffmanMaxLength {
+			return lengths
+		}
+		// flatten the distribution until the longest code fits
+		for i := range f {
+			if f[i] > 0 {
+				f[i] = f[i]>>1 | 1
+			}
+		}
+	}
+}
+
+type huffmanNode struct {
+	freq        int
+	symbol      int
+	left, right *huffmanNode
+}
+
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].symbol < h[j].symbol
+}
+func (h huffmanHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x interface{}) { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// buildLengths sets lengths from a Huffman tree of f and returns the
+// longest length.
+func buildLengths(f []int, lengths []uint8) int {
+	h := &huffmanHeap{}
+	for s, v := range f {
+		lengths[s] = 0
+		if v > 0 {
+			*h = append(*h, &huffmanNode{freq: v, symbol: s})
+		}
+	}
+	if h.Len() == 1 {
+		lengths[(*h)[0].symbol] = 1
+		return 1
+	}
+	heap.Init(h)
+	next := len(f)
+	for h.Len() > 1 {
+		a := heap.Pop(h).(*huffmanNode)
+		b := heap.Pop(h).(*huffmanNode)
+		heap.Push(h, &huffmanNode{freq: a.freq + b.freq, symbol: next, left: a, right: b})
+		next++
+	}
+
+	max := 0
+	var walk func(n *huffmanNode, depth int)
+	walk = func(n *huffmanNode, depth int) {
+		if n.left == nil {
+			lengths[n.symbol] = uint8(depth)
+			if depth > max {
+				max = depth
+			}
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	if h.Len() == 1 {
+		walk((*h)[0], 0)
+	}
+	return max
+}
+
+// huffmanCodes returns the canonical codes of lengths: shorter codes
+// first, symbols of the same length in increasing order.
+// MS-XCA 2.1.4.2 Huffman Code Construction
+func huffmanCodes(lengths []uint8) []uint16 {
+	symbols := make([]int, 0, len(lengths))
+	for s, l := range lengths {
+		if l > 0 {
+			symbols = append(symbols, s)
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		a, b := symbols[i], symbols[j]
+		if lengths[a] != lengths[b] {
+			return lengths[a] < lengths[b]
+		}
+		return a < b
+	})
+
+	codes := make([]uint16, len(lengths))
+	code := 0
+	prev := 0
+	for _, s := range symbols {
+		l := int(lengths[s])
+		code <<= l - prev
+		prev = l
+		codes[s] = uint16(code)
+		code++
+	}
+	return codes
+}
+
+// huffmanDecoder maps the next 15 bits of input to a symbol and its
+// length. Entries of unused codes have a zero length.
+type huffmanDecoder struct {
+	symbol [1 << huffmanMaxLength]uint16
+	length [1 << huffmanMaxLength]uint8
+}
+
+// newHuffmanDecoder builds the decoding table of the 4 bits code lengths
+// of table, 2 symbols per byte, lower nibble first.
+// MS-XCA 2.2.3 Processing
+func newHuffmanDecoder(table []byte) (*huffmanDecoder, error) {
+	lengths := make([]uint8, huffmanSymbols)
+	for i, b := range table[:huffmanSymbols/2] {
+		lengths[2*i] = b & 0x0f
+		lengths[2*i+1] = b >> 4
+	}
+
+	// reject an over-subscribed code before filling the table
+	kraft := 0
+	for _, l := range lengths {
+		if l > 0 {
+			kraft += 1 << (huffmanMaxLength - l)
+		}
+	}
+	if kraft == 0 || kraft > 1<<huffmanMaxLength {
+		return nil, ErrCorrupt
+	}
+
+	d := &huffmanDecoder{}
+	codes := huffmanCodes(lengths)
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		start := int(codes[s]) << (huffmanMaxLength - l)
+		end := start + 1<<(huffmanMaxLength-l)
+		for i := start; i < end; i++ {
+			d.symbol[i] = uint16(s)
+			d.length[i] = l
+		}
+	}
+	return d, nil
+}
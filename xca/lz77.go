@@ -0,0 +1,181 @@
+package xca
+
+import "encoding/binary"
+
+// Plain LZ77 matches reach 8192 bytes back.
+const lz77MaxOffset = 8192
+
+// CompressLZ77 compresses src with the plain LZ77 format.
+// MS-XCA 2.3 Plain LZ77 Compression Algorithm Details
+func CompressLZ77(src []byte) []byte {
+	out := make([]byte, 4, 4+len(src)+len(src)/32*4+4)
+	var flags uint32
+	flagCount := 0
+	flagPos := 0
+	lastLengthHalfByte := 0
+
+	m := newMatcher(src)
+	for pos := 0; pos < len(src); {
+		offset, length := m.find(pos, 0, lz77MaxOffset, len(src))
+		if length == 0 {
+			out = append(out, src[pos])
+			flags <<= 1
+			m.insert(pos)
+			pos++
+		} else {
+			for i := 0; i < length; i++ {
+				m.insert(pos + i)
+			}
+			pos += length
+
+			l := length - 3
+			token := uint16((offset - 1) << 3)
+			if l < 7 {
+				out = appendUint16(out, token|uint16(l))
+			} else {
+				out = appendUint16(out, token|7)
+				l -= 7
+				// length nibbles are paired in one byte
+				nibble := l
+				if nibble > 15 {
+					nibble = 15
+				}
+				if lastLengthHalfByte == 0 {
+					lastLengthHalfByte = len(out)
+					out = append(out, byte(nibble))
+				} else {
+					out[lastLengthHalfByte] |= byte(nibble << 4)
+					lastLengthHalfByte = 0
+				}
+				if l >= 15 {
+					l -= 15
+					if l < 255 {
+						out = append(out, byte(l))
+					} else {
+						out = append(out, 255)
+						l += 15 + 7
+						if l < 1<<16 {
+							out = appendUint16(out, uint16(l))
+						} else {
+							out = appendUint16(out, 0)
+							out = appendUint32(out, uint32(l))
+						}
+					}
+				}
+			}
+			flags = flags<<1 | 1
+		}
+
+		flagCount++
+		if flagCount == 32 {
+			binary.LittleEndian.PutUint32(out[flagPos:], flags)
+			flags, flagCount = 0, 0
+			flagPos = len(out)
+			out = append(out, 0, 0, 0, 0)
+		}
+	}
+
+	// the unused flags are set, a match past the end of input ends the data
+	free := 32 - flagCount
+	flags = flags<<free | (1<<free - 1)
+	binary.LittleEndian.PutUint32(out[flagPos:], flags)
+	return out
+}
+
+// DecompressLZ77 decompresses the plain LZ77 data src of size bytes.
+// MS-XCA 2.4 Plain LZ77 Decompression Algorithm Details
+func DecompressLZ77(src []byte, size int) ([]byte, error) {
+	out := make([]byte, 0, size)
+	var flags uint32
+	flagCount := 0
+	lastLengthHalfByte := 0
+	pos := 0
+
+	for {
+		if flagCount == 0 {
+			if pos+4 > len(src) {
+				break
+			}
+			flags = binary.LittleEndian.Uint32(src[pos:])
+			pos += 4
+			flagCount = 32
+		}
+		flagCount--
+
+		if flags&(1<<flagCount) == 0 {
+			if pos == len(src) {
+				break
+			}
+			if len(out) == size {
+				return nil, ErrCorrupt
+			}
+			out = append(out, src[pos])
+			pos++
+			continue
+		}
+
+		if pos == len(src) {
+			break
+		}
+		if pos+2 > len(src) {
+			return nil, ErrCorrupt
+		}
+		token := binary.LittleEndian.Uint16(src[pos:])
+		pos += 2
+		length := int(token & 7)
+		offset := int(token>>3) + 1
+		if length == 7 {
+			if lastLengthHalfByte == 0 {
+				if pos >= len(src) {
+					return nil, ErrCorrupt
+				}
+				length = int(src[pos] & 0x0f)
+				lastLengthHalfByte = pos
+				pos++
+			} else {
+				length = int(src[lastLengthHalfByte] >> 4)
+				lastLengthHalfByte = 0
+			}
+			if length == 15 {
+				if pos >= len(src) {
+					return nil, ErrCorrupt
+				}
+				length = int(src[pos])
+				pos++
+				if length == 255 {
+					if pos+2 > len(src) {
+						return nil, ErrCorrupt
+					}
+					length = int(binary.LittleEndian.Uint16(src[pos:]))
+					pos += 2
+					if length == 0 {
+						if pos+4 > len(src) {
+							return nil, ErrCorrupt
+						}
+						length = int(binary.LittleEndian.Uint32(src[pos:]))
+						pos += 4
+					}
+					if length < 15+7 {
+						return nil, ErrCorrupt
+					}
+					length -= 15 + 7
+				}
+				length += 15
+			}
+			length += 7
+		}
+		length += 3
+
+		if offset > len(out) || length > size-len(out) {
+			return nil, ErrCorrupt
+		}
+		for i := 0; i < length; i++ {
+			out = append(out, out[len(out)-offset])
+		}
+	}
+
+	if len(out) != size {
+		return nil, ErrCorrupt
+	}
+	return out, nil
+}
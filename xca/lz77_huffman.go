@@ -0,0 +1,263 @@
+package xca
+
+import "encoding/binary"
+
+const (
+	// every block of output has its own Huffman code
+	huffmanBlockSize = 65536
+	huffmanMaxOffset = 65535
+	// longest match whose length fits the 16 bits extension
+	huffmanMaxMatch = 65535 + 3
+	huffmanEOF      = 256
+)
+
+type huffmanToken struct {
+	literal        byte
+	offset, length int // length is zero for a literal
+}
+
+func (t huffmanToken) symbol() int {
+	if t.length == 0 {
+		return int(t.literal)
+	}
+	l := t.length - 3
+	if l > 15 {
+		l = 15
+	}
+	return 256 + offsetBits(t.offset)<<4 + l
+}
+
+// offsetBits returns the index of the highest bit set in offset.
+func offsetBits(offset int) int {
+	n := 0
+	for offset > 1 {
+		offset >>= 1
+		n++
+	}
+	return n
+}
+
+// CompressLZ77Huffman compresses src with the LZ77+Huffman format.
+// MS-XCA 2.1 LZ77+Huffman Compression Algorithm Details
+func CompressLZ77Huffman(src []byte) []byte {
+	w := &huffmanWriter{out: make([]byte, 0, len(src)/2+512)}
+	m := newMatcher(src)
+	for start := 0; ; start += huffmanBlockSize {
+		end := start + huffmanBlockSize
+		if end > len(src) {
+			end = len(src)
+		}
+
+		var tokens []huffmanToken
+		for pos := start; pos < end; {
+			maxLength := end - pos
+			if maxLength > huffmanMaxMatch {
+				maxLength = huffmanMaxMatch
+			}
+			offset, length := m.find(pos, 0, huffmanMaxOffset, maxLength)
+			if length == 0 {
+				tokens = append(tokens, huffmanToken{literal: src[pos]})
+				m.insert(pos)
+				pos++
+				continue
+			}
+			tokens = append(tokens, huffmanToken{offset: offset, length: length})
+			for i := 0; i < length; i++ {
+				m.insert(pos + i)
+			}
+			pos += length
+		}
+
+		// the end of data is marked in the first block that is not full,
+		// an empty one when the input fills its last block
+		last := end-start < huffmanBlockSize
+		w.writeBlock(tokens, last)
+		if last {
+			return w.out
+		}
+	}
+}
+
+// huffmanWriter writes the bit stream of a block as 16 bits words,
+// reserving each word at the point the decoder loads it so that the
+// extra length bytes land where the decoder reads them.
+type huffmanWriter struct {
+	out []byte
+
+	slots    []int  // offsets in out of the words reserved and not written
+	acc      uint32 // bits not written yet, left aligned
+	accBits  int
+	reserved int // words reserved in the block
+	written  int // bits written in the block
+}
+
+func (w *huffmanWriter) reserve() {
+	w.slots = append(w.slots, len(w.out))
+	w.out = append(w.out, 0, 0)
+	w.reserved++
+}
+
+// writeBits writes the n lower bits of v, most significant first.
+func (w *huffmanWriter) writeBits(v uint32, n int) {
+	if n == 0 {
+		return
+	}
+	w.acc |= v << (32 - n) >> w.accBits
+	w.accBits += n
+	w.written += n
+	for w.accBits >= 16 {
+		binary.LittleEndian.PutUint16(w.out[w.slots[0]:], uint16(w.acc>>16))
+		w.slots = w.slots[1:]
+		w.acc <<= 16
+		w.accBits -= 16
+	}
+	// the decoder loads a word once fewer than 16 bits are left
+	if w.reserved*16-w.written < 16 {
+		w.reserve()
+	}
+}
+
+func (w *huffmanWriter) writeBlock(tokens []huffmanToken, last bool) {
+	freq := make([]int, huffmanSymbols)
+	for _, t := range tokens {
+		freq[t.symbol()]++
+	}
+	if last {
+		freq[huffmanEOF]++
+	}
+	lengths := huffmanLengths(freq)
+	codes := huffmanCodes(lengths)
+
+	for i := 0; i < huffmanSymbols/2; i++ {
+		w.out = append(w.out, lengths[2*i]|lengths[2*i+1]<<4)
+	}
+	w.slots = w.slots[:0]
+	w.acc, w.accBits = 0, 0
+	w.reserved, w.written = 0, 0
+	w.reserve()
+	w.reserve()
+
+	for _, t := range tokens {
+		s := t.symbol()
+		w.writeBits(uint32(codes[s]), int(lengths[s]))
+		if t.length == 0 {
+			continue
+		}
+		if l := t.length - 3; l >= 15 {
+			if l-15 < 255 {
+				w.out = append(w.out, byte(l-15))
+			} else {
+				w.out = append(w.out, 255)
+				w.out = appendUint16(w.out, uint16(l))
+			}
+		}
+		n := offsetBits(t.offset)
+		w.writeBits(uint32(t.offset-1<<n), n)
+	}
+	if last {
+		w.writeBits(uint32(codes[huffmanEOF]), int(lengths[huffmanEOF]))
+	}
+	if w.accBits > 0 {
+		binary.LittleEndian.PutUint16(w.out[w.slots[0]:], uint16(w.acc>>16))
+	}
+}
+
+// DecompressLZ77Huffman decompresses the LZ77+Huffman data src of size
+// bytes.
+// MS-XCA 2.2 LZ77+Huffman Decompression Algorithm Details
+func DecompressLZ77Huffman(src []byte, size int) ([]byte, error) {
+	out := make([]byte, 0, size)
+	pos := 0
+	word := func() uint32 {
+		// words past the end read as zero, the output size ends decoding
+		var v uint32
+		if pos+2 <= len(src) {
+			v = uint32(binary.LittleEndian.Uint16(src[pos:]))
+		}
+		pos += 2
+		return v
+	}
+
+	for len(out) < size {
+		if pos+huffmanSymbols/2 > len(src) {
+			return nil, ErrCorrupt
+		}
+		d, err := newHuffmanDecoder(src[pos : pos+huffmanSymbols/2])
+		if err != nil {
+			return nil, err
+		}
+		pos += huffmanSymbols / 2
+
+		bits := word() << 16
+		bits |= word()
+		extra := 16
+		consume := func(n int) {
+			bits <<= uint(n)
+			extra -= n
+			if extra < 0 {
+				bits |= word() << uint(-extra)
+				extra += 16
+			}
+		}
+
+		for blockEnd := len(out) + huffmanBlockSize; len(out) < blockEnd && len(out) < size; {
+			next := bits >> (32 - huffmanMaxLength)
+			if d.length[next] == 0 {
+				return nil, ErrCorrupt
+			}
+			symbol := int(d.symbol[next])
+			consume(int(d.length[next]))
+
+			if symbol < 256 {
+				out = append(out, byte(symbol))
+				continue
+			}
+			// huffmanEOF, written after the last symbol, is a match of
+			// length 3 one byte back: decoding ends with the output size
+			// before it is read
+			symbol -= 256
+			length := symbol & 15
+			n := symbol >> 4
+			if length == 15 {
+				if pos >= len(src) {
+					return nil, ErrCorrupt
+				}
+				length = int(src[pos])
+				pos++
+				if length == 255 {
+					if pos+2 > len(src) {
+						return nil, ErrCorrupt
+					}
+					length = int(binary.LittleEndian.Uint16(src[pos:]))
+					pos += 2
+					if length == 0 {
+						if pos+4 > len(src) {
+							return nil, ErrCorrupt
+						}
+						length = int(binary.LittleEndian.Uint32(src[pos:]))
+						pos += 4
+					}
+					if length < 15 {
+						return nil, ErrCorrupt
+					}
+					length -= 15
+				}
+				length += 15
+			}
+			length += 3
+
+			offset := 1 << n
+			if n > 0 {
+				offset |= int(bits >> (32 - n))
+				consume(n)
+			}
+			if offset > len(out) || length > size-len(out) {
+				return nil, ErrCorrupt
+			}
+			for i := 0; i < length; i++ {
+				out = append(out, out[len(out)-offset])
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,117 @@
+package xca
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestLZ77Huffman(t *testing.T) {
+	// MS-XCA 3.2 LZ77+Huffman Compression Examples, the 256 bytes table
+	// of code lengths is followed by the encoded symbols
+	cases := []struct {
+		name       string
+		input      []byte
+		compressed string
+	}{
+		{
+			// 'w' to 'z' and the end of data have 4 bits codes, the
+			// other letters 5 bits codes
+			name:  "alphabet",
+			input: []byte("abcdefghijklmnopqrstuvwxyz"),
+			compressed: strings.Repeat("00", 0x30) + "50" + strings.Repeat("55", 10) + "454404" +
+				strings.Repeat("00", 0x80-0x3e) + "04" + strings.Repeat("00", 0xff-0x80) +
+				"d8523ed794115be9195ff9d67cdf8d0400000000",
+		},
+		{
+			// 'a' and 'b' have 3 bits codes, 'c', the match of length 297
+			// 3 bytes back and the end of data 2 bits codes
+			name:  "abc 100 times",
+			input: bytes.Repeat([]byte("abc"), 100),
+			compressed: strings.Repeat("00", 0x30) + "3023" +
+				strings.Repeat("00", 0x80-0x32) + "02" + strings.Repeat("00", 0x8f-0x81) + "20" + strings.Repeat("00", 0xff-0x8f) +
+				"a8dc0000ff2601",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := CompressLZ77Huffman(c.input)
+			if hex.EncodeToString(actual) != c.compressed {
+				t.Errorf("CompressLZ77Huffman() = %x, want %v", actual, c.compressed)
+			}
+
+			compressed, _ := hex.DecodeString(c.compressed)
+			output, err := DecompressLZ77Huffman(compressed, len(c.input))
+			if err != nil {
+				t.Fatalf("DecompressLZ77Huffman() error = %v", err)
+			}
+			if !bytes.Equal(output, c.input) {
+				t.Errorf("DecompressLZ77Huffman() = %q, want %q", output, c.input)
+			}
+		})
+	}
+}
+
+func TestLZ77HuffmanRoundTrip(t *testing.T) {
+	inputs := roundTripInputs()
+	inputs["one block"] = bytes.Repeat([]byte("0123456789abcdef"), huffmanBlockSize/16)
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			compressed := CompressLZ77Huffman(input)
+			output, err := DecompressLZ77Huffman(compressed, len(input))
+			if err != nil {
+				t.Fatalf("DecompressLZ77Huffman() error = %v", err)
+			}
+			if !bytes.Equal(output, input) {
+				t.Errorf("round trip of %d bytes differs", len(input))
+			}
+		})
+	}
+}
+
+func TestLZ77HuffmanRunAtEnd(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 100; i++ {
+		input := make([]byte, 5000+r.Intn(100))
+		r.Read(input)
+		b := input[len(input)-1-r.Intn(4)]
+		input = append(input, b, b, b, b)
+		compressed := CompressLZ77Huffman(input)
+		output, err := DecompressLZ77Huffman(compressed, len(input))
+		if err != nil {
+			t.Fatalf("DecompressLZ77Huffman() of input %d error = %v", i, err)
+		}
+		if !bytes.Equal(output, input) {
+			t.Fatalf("round trip of input %d differs", i)
+		}
+	}
+}
+
+func TestLZ77HuffmanSize(t *testing.T) {
+	input := bytes.Repeat([]byte("abc"), 100)
+	compressed := CompressLZ77Huffman(input)
+	// a 256 bytes table, 4 symbols and the end of data
+	if len(compressed) > 256+16 {
+		t.Errorf("CompressLZ77Huffman() = %d bytes", len(compressed))
+	}
+}
+
+func TestLZ77HuffmanCorrupt(t *testing.T) {
+	// every symbol has a 1 bit code, the code is over-subscribed
+	table := bytes.Repeat([]byte{0x11}, 256)
+	if _, err := DecompressLZ77Huffman(append(table, 0, 0, 0, 0), 1); err == nil {
+		t.Errorf("DecompressLZ77Huffman() of an invalid table succeeded")
+	}
+	if _, err := DecompressLZ77Huffman(make([]byte, 100), 1); err == nil {
+		t.Errorf("DecompressLZ77Huffman() of a truncated table succeeded")
+	}
+
+	compressed := CompressLZ77Huffman(bytes.Repeat([]byte("abc"), 100))
+	if _, err := DecompressLZ77Huffman(compressed, 301); err == nil {
+		t.Errorf("DecompressLZ77Huffman() past the end of data succeeded")
+	}
+}
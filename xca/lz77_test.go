@@ -0,0 +1,71 @@
+package xca
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestLZ77(t *testing.T) {
+	// MS-XCA 3.1 Plain LZ77 Compression Examples
+	cases := []struct {
+		name       string
+		input      []byte
+		compressed string
+	}{
+		{
+			name:       "alphabet",
+			input:      []byte("abcdefghijklmnopqrstuvwxyz"),
+			compressed: "3f000000" + hex.EncodeToString([]byte("abcdefghijklmnopqrstuvwxyz")),
+		},
+		{
+			name:       "abc 100 times",
+			input:      bytes.Repeat([]byte("abc"), 100),
+			compressed: "ffffff1f61626317000fff2601",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := CompressLZ77(c.input)
+			if hex.EncodeToString(actual) != c.compressed {
+				t.Errorf("CompressLZ77() = %x, want %v", actual, c.compressed)
+			}
+
+			compressed, _ := hex.DecodeString(c.compressed)
+			output, err := DecompressLZ77(compressed, len(c.input))
+			if err != nil {
+				t.Fatalf("DecompressLZ77() error = %v", err)
+			}
+			if !bytes.Equal(output, c.input) {
+				t.Errorf("DecompressLZ77() = %q, want %q", output, c.input)
+			}
+		})
+	}
+}
+
+func TestLZ77RoundTrip(t *testing.T) {
+	for name, input := range roundTripInputs() {
+		t.Run(name, func(t *testing.T) {
+			compressed := CompressLZ77(input)
+			output, err := DecompressLZ77(compressed, len(input))
+			if err != nil {
+				t.Fatalf("DecompressLZ77() error = %v", err)
+			}
+			if !bytes.Equal(output, input) {
+				t.Errorf("round trip of %d bytes differs", len(input))
+			}
+		})
+	}
+}
+
+func TestLZ77Corrupt(t *testing.T) {
+	// a match 8 bytes back at the start of the output
+	if _, err := DecompressLZ77([]byte{0x00, 0x00, 0x00, 0x80, 0x38, 0x00}, 3); err == nil {
+		t.Errorf("DecompressLZ77() of a match before the start succeeded")
+	}
+	compressed := CompressLZ77(bytes.Repeat([]byte("abc"), 100))
+	if _, err := DecompressLZ77(compressed, 299); err == nil {
+		t.Errorf("DecompressLZ77() past the expected size succeeded")
+	}
+}
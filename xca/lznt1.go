@@ -0,0 +1,142 @@
+package xca
+
+import "encoding/binary"
+
+const (
+	lznt1ChunkSize = 4096
+	// chunk header: size of the chunk minus 3 in the lower 12 bits,
+	// signature 3 in the next 3 bits, compressed flag in the highest bit
+	lznt1Signature  = 0x3000
+	lznt1Compressed = 0x8000
+)
+
+// lznt1Split returns the number of bits of the length in a copy token at
+// position pos of a chunk, the offset gets the other bits.
+func lznt1Split(pos int) int {
+	lengthBits := 12
+	for i := pos - 1; i >= 0x10; i >>= 1 {
+		lengthBits--
+	}
+	return lengthBits
+}
+
+// CompressLZNT1 compresses src with the LZNT1 format, chunks that do not
+// compress are stored as is.
+// MS-XCA 2.5 LZNT1 Algorithm Details
+func CompressLZNT1(src []byte) []byte {
+	out := make([]byte, 0, len(src)+len(src)/lznt1ChunkSize*2+2)
+	m := newMatcher(src)
+	for start := 0; start < len(src); start += lznt1ChunkSize {
+		end := start + lznt1ChunkSize
+		if end > len(src) {
+			end = len(src)
+		}
+
+		hdr := len(out)
+		out = append(out, 0, 0)
+		flagPos := -1
+		flagBit := 8
+		for pos := start; pos < end; {
+			if flagBit == 8 {
+				flagPos = len(out)
+				out = append(out, 0)
+				flagBit = 0
+			}
+			lengthBits := lznt1Split(pos - start)
+			maxLength := 1<<lengthBits - 1 + 3
+			maxOffset := 1 << (16 - lengthBits)
+			if rest := end - pos; maxLength > rest {
+				maxLength = rest
+			}
+			offset, length := m.find(pos, start, maxOffset, maxLength)
+			if length == 0 {
+				out = append(out, src[pos])
+				m.insert(pos)
+				pos++
+			} else {
+				token := uint16((offset-1)<<lengthBits | (length - 3))
+				out = appendUint16(out, token)
+				out[flagPos] |= 1 << flagBit
+				for i := 0; i < length; i++ {
+					m.insert(pos + i)
+				}
+				pos += length
+			}
+			flagBit++
+		}
+
+		size := len(out) - hdr
+		if size-2 >= end-start {
+			// not smaller, store the chunk uncompressed
+			out = append(out[:hdr+2], src[start:end]...)
+			binary.LittleEndian.PutUint16(out[hdr:], uint16(lznt1Signature|(end-start-1)))
+			continue
+		}
+		binary.LittleEndian.PutUint16(out[hdr:], uint16(lznt1Compressed|lznt1Signature|(size-3)))
+	}
+	return out
+}
+
+// DecompressLZNT1 decompresses the LZNT1 data src of size bytes.
+// MS-XCA 2.5 LZNT1 Algorithm Details
+func DecompressLZNT1(src []byte, size int) ([]byte, error) {
+	out := make([]byte, 0, size)
+	pos := 0
+	for pos+2 <= len(src) {
+		hdr := binary.LittleEndian.Uint16(src[pos:])
+		if hdr == 0 {
+			// end of data
+			break
+		}
+		n := int(hdr&0x0fff) + 1
+		pos += 2
+		if pos+n > len(src) {
+			return nil, ErrCorrupt
+		}
+		chunk := src[pos : pos+n]
+		pos += n
+
+		if hdr&lznt1Compressed == 0 {
+			if len(chunk) > size-len(out) {
+				return nil, ErrCorrupt
+			}
+			out = append(out, chunk...)
+			continue
+		}
+
+		start := len(out)
+		for i := 0; i < len(chunk); {
+			flags := chunk[i]
+			i++
+			for bit := 0; bit < 8 && i < len(chunk); bit++ {
+				if flags&(1<<bit) == 0 {
+					if len(out) == size {
+						return nil, ErrCorrupt
+					}
+					out = append(out, chunk[i])
+					i++
+					continue
+				}
+				if i+2 > len(chunk) {
+					return nil, ErrCorrupt
+				}
+				token := int(binary.LittleEndian.Uint16(chunk[i:]))
+				i += 2
+				lengthBits := lznt1Split(len(out) - start)
+				length := token&(1<<lengthBits-1) + 3
+				offset := token>>lengthBits + 1
+				if offset > len(out)-start || length > size-len(out) {
+					return nil, ErrCorrupt
+				}
+				for j := 0; j < length; j++ {
+					out = append(out, out[len(out)-offset])
+				}
+			}
+		}
+	}
+
+	if len(out) != size {
+		return nil, ErrCorrupt
+	}
+	return out, nil
+}
@@ -0,0 +1,100 @@
+package xca
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestLZNT1(t *testing.T) {
+	cases := []struct {
+		name       string
+		input      []byte
+		compressed string
+	}{
+		{
+			// flags 0x08: 3 literals and a copy token 3 bytes back
+			name:       "abc 10 times",
+			input:      bytes.Repeat([]byte("abc"), 10),
+			compressed: "05b00861626318" + "20",
+		},
+		{
+			// 4 literals do not compress, the chunk is stored
+			name:       "uncompressed chunk",
+			input:      []byte("abcd"),
+			compressed: "0330" + "61626364",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := CompressLZNT1(c.input)
+			if hex.EncodeToString(actual) != c.compressed {
+				t.Errorf("CompressLZNT1() = %x, want %v", actual, c.compressed)
+			}
+
+			compressed, _ := hex.DecodeString(c.compressed)
+			output, err := DecompressLZNT1(compressed, len(c.input))
+			if err != nil {
+				t.Fatalf("DecompressLZNT1() error = %v", err)
+			}
+			if !bytes.Equal(output, c.input) {
+				t.Errorf("DecompressLZNT1() = %q, want %q", output, c.input)
+			}
+		})
+	}
+}
+
+func TestLZNT1Example(t *testing.T) {
+	// the LZNT1 compression example of MS-XCA, the input ends with a NUL
+	input := []byte("F# F# G A A G F# E D D E F# F# E E F# F# G A A G F# E D D E F# E D D E E F# D E F# G F# D E F# G F# E D E A F# F# G A A G F# E D D E F# E D D\x00")
+	compressed, _ := hex.DecodeString("38b08846232000204720410010a24701a045204400084501507900c045200524138805b4024a44ef0358028c091601484500be009e000401189000")
+
+	output, err := DecompressLZNT1(compressed, len(input))
+	if err != nil {
+		t.Fatalf("DecompressLZNT1() error = %v", err)
+	}
+	if !bytes.Equal(output, input) {
+		t.Errorf("DecompressLZNT1() = %q, want %q", output, input)
+	}
+
+	// matches are not found as Windows does, the output differs but is
+	// not larger
+	actual := CompressLZNT1(input)
+	if len(actual) > len(compressed) {
+		t.Errorf("CompressLZNT1() = %d bytes, want at most %d", len(actual), len(compressed))
+	}
+	output, err = DecompressLZNT1(actual, len(input))
+	if err != nil {
+		t.Fatalf("DecompressLZNT1() of CompressLZNT1() error = %v", err)
+	}
+	if !bytes.Equal(output, input) {
+		t.Errorf("DecompressLZNT1() of CompressLZNT1() = %q, want %q", output, input)
+	}
+}
+
+func TestLZNT1RoundTrip(t *testing.T) {
+	for name, input := range roundTripInputs() {
+		t.Run(name, func(t *testing.T) {
+			compressed := CompressLZNT1(input)
+			output, err := DecompressLZNT1(compressed, len(input))
+			if err != nil {
+				t.Fatalf("DecompressLZNT1() error = %v", err)
+			}
+			if !bytes.Equal(output, input) {
+				t.Errorf("round trip of %d bytes differs", len(input))
+			}
+		})
+	}
+}
+
+func TestLZNT1Corrupt(t *testing.T) {
+	// a copy token 1 byte back at the start of the chunk
+	if _, err := DecompressLZNT1([]byte{0x02, 0xb0, 0x01, 0x00, 0x00}, 3); err == nil {
+		t.Errorf("DecompressLZNT1() of a copy before the chunk succeeded")
+	}
+	// a chunk longer than the input
+	if _, err := DecompressLZNT1([]byte{0xff, 0x3f, 0x61}, 4096); err == nil {
+		t.Errorf("DecompressLZNT1() of a truncated chunk succeeded")
+	}
+}
@@ -0,0 +1,79 @@
+package xca
+
+const (
+	minMatch   = 3
+	hashBits   = 15
+	chainDepth = 32
+)
+
+// matcher finds earlier occurrences of the bytes at a position with hash
+// chains over 3 bytes prefixes.
+type matcher struct {
+	in   []byte
+	head []int32 // hash to the latest position + 1
+	prev []int32 // position to the previous position + 1 with the same hash
+}
+
+func newMatcher(in []byte) *matcher {
+	return &matcher{
+		in:   in,
+		head: make([]int32, 1<<hashBits),
+		prev: make([]int32, len(in)),
+	}
+}
+
+func (m *matcher) hash(pos int) uint32 {
+	v := uint32(m.in[pos]) | uint32(m.in[pos+1])<<8 | uint32(m.in[pos+2])<<16
+	return (v * 2654435761) >> (32 - hashBits)
+}
+
+// insert adds pos to the chains, once every position before it was added.
+func (m *matcher) insert(pos int) {
+	if pos+minMatch > len(m.in) {
+		return
+	}
+	h := m.hash(pos)
+	m.prev[pos] = m.head[h]
+	m.head[h] = int32(pos + 1)
+}
+
+// find returns the longest match at pos starting at or after min, at most
+// maxOffset bytes back and maxLength long. length is zero when there is
+// no match of minMatch bytes.
+func (m *matcher) find(pos, min, maxOffset, maxLength int) (offset, length int) {
+	if pos+minMatch > len(m.in) {
+		return 0, 0
+	}
+	if rest := len(m.in) - pos; maxLength > rest {
+		maxLength = rest
+	}
+	cand := int(m.head[m.hash(pos)]) - 1
+	for depth := 0; cand >= 0 && depth < chainDepth; depth++ {
+		if cand < min || pos-cand > maxOffset {
+			break
+		}
+		n := 0
+		for n < maxLength && m.in[cand+n] == m.in[pos+n] {
+			n++
+		}
+		if n > length {
+			offset, length = pos-cand, n
+			if n == maxLength {
+				break
+			}
+		}
+		cand = int(m.prev[cand]) - 1
+	}
+	if length < minMatch {
+		return 0, 0
+	}
+	return offset, length
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
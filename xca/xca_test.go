@@ -0,0 +1,41 @@
+package xca
+
+import (
+	"bytes"
+	"math/rand"
+)
+
+// roundTripInputs returns inputs exercising literals, short and long
+// matches, far offsets and block boundaries.
+func roundTripInputs() map[string][]byte {
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 200000)
+	r.Read(random)
+
+	text := bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "), 3000)
+	mixed := make([]byte, 0, 300000)
+	for len(mixed) < 300000 {
+		n := r.Intn(300)
+		if r.Intn(2) == 0 {
+			mixed = append(mixed, random[:n]...)
+		} else {
+			off := r.Intn(len(text) - n)
+			mixed = append(mixed, text[off:off+n]...)
+		}
+	}
+
+	return map[string][]byte{
+		"empty":     {},
+		"one byte":  {0x42},
+		"zeros":     make([]byte, 70000),
+		"random":    random,
+		"text":      text,
+		"mixed":     mixed,
+		"long runs": append(append(bytes.Repeat([]byte{1}, 70000), random[:1000]...), bytes.Repeat([]byte{2}, 300)...),
+		// matches of length 3 one byte back at the end of the data
+		"short run":      []byte("aaaa"),
+		"short byte run": {0xf2, 0xf2, 0xf2, 0xf2},
+		"run at the end": []byte("hello world qqqq"),
+		"random run":     append(append([]byte{}, random[:5000]...), 7, 7, 7, 7),
+	}
+}